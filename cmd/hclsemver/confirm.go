@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/david1155/hclsemver/pkg/runner"
+)
+
+// Answers given with -non-interactive when a -confirm run cannot prompt because stdin is
+// not a terminal
+const (
+	nonInteractiveAbort = "abort"
+	nonInteractiveYes   = "yes"
+)
+
+// errNotConfirmed is returned when the changes of a -confirm run are not confirmed
+var errNotConfirmed = errors.New("changes not confirmed; no files were modified")
+
+// confirmOptions control the prompt of a -confirm run, shown after every change has been
+// previewed and before any file is written
+type confirmOptions struct {
+	enabled bool
+	// yes confirms without prompting
+	yes bool
+	// nonInteractive is the answer used when stdin is not a terminal
+	nonInteractive string
+}
+
+// stdinIsTerminal reports whether the confirmation can be asked on stdin; tests replace it
+var stdinIsTerminal = func() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// confirmChanges asks on prompt whether to write the previewed changes and reads the answer
+// from stdin, returning errNotConfirmed unless it is yes
+func confirmChanges(preview runner.RunResult, opts confirmOptions, prompt io.Writer) error {
+	if opts.yes {
+		return nil
+	}
+	if !stdinIsTerminal() {
+		if opts.nonInteractive == nonInteractiveYes {
+			return nil
+		}
+		return fmt.Errorf("%w: stdin is not a terminal; pass -yes or -non-interactive %s to write without asking", errNotConfirmed, nonInteractiveYes)
+	}
+
+	fmt.Fprintf(prompt, "Write %d change(s) to %d file(s)? [y/N]: ", len(preview.Changes), preview.FilesChanged())
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("error reading confirmation: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return errNotConfirmed
+}
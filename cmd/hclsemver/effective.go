@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/david1155/hclsemver/pkg/config"
+	"github.com/david1155/hclsemver/pkg/version"
+)
+
+// effectiveEntry is the resolved configuration of one module in one tier
+type effectiveEntry struct {
+	Module   string           `json:"module"`
+	Tier     string           `json:"tier"`
+	Version  string           `json:"version"`
+	Strategy version.Strategy `json:"strategy"`
+	Force    bool             `json:"force"`
+}
+
+// effectiveConfig resolves every module for every tier in the config, after tier, negated
+// tier, wildcard and module-level precedence. Tiers a module has no version for are left out;
+// the "*" row, or a negated tier row such as "!prod", is what applies to tiers the module
+// does not list.
+func effectiveConfig(cfg *config.Config) ([]effectiveEntry, error) {
+	var tiers []string
+	for tier := range config.GetTiersFromConfig(cfg) {
+		tiers = append(tiers, tier)
+	}
+	sort.Strings(tiers)
+
+	entries := []effectiveEntry{}
+	for _, module := range cfg.Modules {
+		moduleTiers := tiers
+		for key := range module.Versions {
+			if _, negated := config.NegatedTier(key); negated {
+				moduleTiers = append(append([]string{}, tiers...), key)
+				sort.Strings(moduleTiers)
+			}
+		}
+		for _, tier := range moduleTiers {
+			if _, ok := module.Versions[tier]; !ok && !config.CoversTier(module, tier) {
+				continue
+			}
+			vc, err := config.GetEffectiveVersionConfig(module, tier)
+			if err != nil {
+				return nil, fmt.Errorf("module %s tier %s: %w", module.Source, tier, err)
+			}
+			entries = append(entries, effectiveEntry{
+				Module:   module.Source,
+				Tier:     tier,
+				Version:  vc.Version,
+				Strategy: config.GetEffectiveStrategy(module, tier),
+				Force:    config.GetEffectiveForce(module, tier),
+			})
+		}
+	}
+	return entries, nil
+}
+
+// printEffective writes the effective configuration of every module and tier to w, as an
+// aligned table or, with the json format, as an array of entries
+func printEffective(w io.Writer, cfg *config.Config, format string) error {
+	entries, err := effectiveConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	if format == outputJSON {
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding effective config: %w", err)
+		}
+		fmt.Fprintln(w, string(data))
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODULE\tTIER\tVERSION\tSTRATEGY\tFORCE")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\n", e.Module, e.Tier, e.Version, e.Strategy, e.Force)
+	}
+	return tw.Flush()
+}
+
+// printConfigDiff writes the differences between the effective configurations of two
+// config files, both parsed as configFormat, to w, as an aligned table or, with the json
+// format, as an array of deltas
+func printConfigDiff(w io.Writer, oldPath, newPath string, configFormat config.Format, format string) error {
+	oldConfig, err := config.LoadConfigWithFormat(oldPath, configFormat)
+	if err != nil {
+		return fmt.Errorf("error loading config %s: %w", oldPath, err)
+	}
+	newConfig, err := config.LoadConfigWithFormat(newPath, configFormat)
+	if err != nil {
+		return fmt.Errorf("error loading config %s: %w", newPath, err)
+	}
+	deltas := config.DiffConfigs(oldConfig, newConfig)
+
+	if format == outputJSON {
+		data, err := json.MarshalIndent(deltas, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding config diff: %w", err)
+		}
+		fmt.Fprintln(w, string(data))
+		return nil
+	}
+
+	if len(deltas) == 0 {
+		fmt.Fprintln(w, "No differences in the effective configuration")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODULE\tTIER\tCHANGE\tOLD\tNEW")
+	for _, d := range deltas {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", d.Module, d.Tier, d.Kind, formatEffectiveTier(d.Old), formatEffectiveTier(d.New))
+	}
+	return tw.Flush()
+}
+
+// formatEffectiveTier renders a resolved tier config for the diff table, or "-" for none
+func formatEffectiveTier(t *config.EffectiveTier) string {
+	if t == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s (%s, force=%t)", t.Version, t.Strategy, t.Force)
+}
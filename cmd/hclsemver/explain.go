@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/david1155/hclsemver/pkg/version"
+)
+
+// explainStrategies are the strategies compared by -explain, in the order they are printed
+var explainStrategies = []version.Strategy{version.StrategyDynamic, version.StrategyRange, version.StrategyExact}
+
+// strategyOutcome is what one strategy makes of a target and an existing version
+type strategyOutcome struct {
+	Strategy version.Strategy `json:"strategy"`
+	Result   string           `json:"result,omitempty"`
+	Reason   string           `json:"reason,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// explainOutcomes applies each of explainStrategies to target and existing
+func explainOutcomes(target, existing string) []strategyOutcome {
+	outcomes := make([]strategyOutcome, 0, len(explainStrategies))
+	for _, strategy := range explainStrategies {
+		outcome := strategyOutcome{Strategy: strategy}
+		result, reason, err := version.ApplyVersionStrategyWithReason(strategy, target, existing, version.StrategyOptions{})
+		if err != nil {
+			outcome.Error = err.Error()
+		} else {
+			outcome.Result, outcome.Reason = result, reason
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	return outcomes
+}
+
+// printExplain writes what each strategy writes for target over existing to w, as an
+// aligned table or, with the json format, as an array of outcomes
+func printExplain(w io.Writer, target, existing, format string) error {
+	outcomes := explainOutcomes(target, existing)
+
+	if format == outputJSON {
+		data, err := json.MarshalIndent(outcomes, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding strategy outcomes: %w", err)
+		}
+		fmt.Fprintln(w, string(data))
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STRATEGY\tRESULT\tREASON")
+	for _, o := range outcomes {
+		if o.Error != "" {
+			fmt.Fprintf(tw, "%s\t-\terror: %s\n", o.Strategy, o.Error)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", o.Strategy, o.Result, o.Reason)
+	}
+	return tw.Flush()
+}
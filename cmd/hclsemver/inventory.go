@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/david1155/hclsemver/pkg/config"
+	"github.com/david1155/hclsemver/pkg/runner"
+)
+
+// printInventory writes every module block found in the work dirs to w, as an aligned
+// table or, with the json format, as an array of modules. The config is optional and only
+// assigns tiers.
+func printInventory(w io.Writer, configFile string, configFormat config.Format, workDirs []string, format string, opts runner.Options) error {
+	var cfg *config.Config
+	if configFile != "" {
+		var err error
+		if cfg, err = config.LoadConfigWithFormat(configFile, configFormat); err != nil {
+			return fmt.Errorf("error loading config: %w", err)
+		}
+	}
+
+	// Warnings about unparseable files go to stderr so the inventory stays machine-readable
+	opts.Output = os.Stderr
+	modules := []runner.Module{}
+	for _, workDir := range workDirs {
+		found, err := runner.Inventory(cfg, workDir, opts)
+		if err != nil {
+			return fmt.Errorf("error scanning %s: %w", workDir, err)
+		}
+		modules = append(modules, found...)
+	}
+
+	if format == outputJSON {
+		data, err := json.MarshalIndent(modules, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding inventory: %w", err)
+		}
+		fmt.Fprintln(w, string(data))
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tTIER\tSOURCE\tVERSION")
+	for _, m := range modules {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", m.File, orDash(m.Tier), m.Source, orDash(m.Version))
+	}
+	return tw.Flush()
+}
+
+// orDash returns s, or "-" when it is empty so table columns stay aligned
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -1,105 +1,320 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
-	"github.com/david1155/hclsemver/internal/terraform"
 	"github.com/david1155/hclsemver/pkg/config"
+	"github.com/david1155/hclsemver/pkg/registry"
+	"github.com/david1155/hclsemver/pkg/runner"
 	"github.com/david1155/hclsemver/pkg/version"
 )
 
-func processConfig(configFile string, workDir string, dryRun bool) error {
+// stringSliceFlag collects the values of a flag that may be repeated
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// readFileList reads a newline-separated list of file paths, skipping blank lines
+func readFileList(r io.Reader) ([]string, error) {
+	files := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, scanner.Err()
+}
+
+// bumpCommand is the subcommand that bumps every configured module by one semver level
+const bumpCommand = "bump"
+
+// Output formats for the run report
+const (
+	outputText  = "text"
+	outputJSON  = "json"
+	outputSARIF = "sarif"
+)
+
+// jsonReport is the run report printed with -output json
+type jsonReport struct {
+	Changes  []runner.Change `json:"changes"`
+	Errors   []string        `json:"errors"`
+	Warnings []string        `json:"warnings"`
+	Skips    []runner.Skip   `json:"skips"`
+}
+
+// errDrift is returned by a -diff-only run that found changes to make, so that it exits
+// non-zero
+var errDrift = errors.New("files are not up to date with the config")
+
+// reportFiles are the files a run's result is written to, in addition to stdout; an empty
+// path is not written
+type reportFiles struct {
+	metrics string
+	plan    string
+}
+
+// processConfig runs the config against each work dir in turn and reports the combined
+// result, writing it to the report files as well. With confirm enabled, the changes are
+// previewed and confirmed before any file is written. With failOnChanges, errDrift is
+// returned after the report when the run has any change. Module failures the run collected
+// are returned after the report too, joined into one error.
+func processConfig(configFile string, configFormat config.Format, workDirs []string, format string, files reportFiles, confirm confirmOptions, failOnChanges bool, opts runner.Options) error {
 	// Read and parse config
-	cfg, err := config.LoadConfig(configFile)
+	cfg, err := config.LoadConfigWithFormat(configFile, configFormat)
 	if err != nil {
 		return fmt.Errorf("error loading config: %w", err)
 	}
 
-	// Get all tiers from config
-	configTiers := config.GetTiersFromConfig(cfg)
-
-	// Process each module
-	for _, module := range cfg.Modules {
-		// If we only have a wildcard tier, use it
-		if len(module.Versions) == 1 {
-			if versionConfig, err := config.GetEffectiveVersionConfig(module, "*"); err == nil {
-				configTiers["*"] = true
-				strategy := config.GetEffectiveStrategy(module, "*")
-				force := config.GetEffectiveForce(module, "*")
-
-				// Parse the version/range
-				newIsVer, newVer, newConstr, err := version.ParseVersionOrRange(versionConfig.Version)
-				if err != nil {
-					log.Printf("Error parsing version '%s' for module '%s': %v", versionConfig.Version, module.Source, err)
-					continue
-				}
-
-				if err := terraform.ScanAndUpdateModules(workDir, module.Source, newIsVer, newVer, newConstr, versionConfig.Version, configTiers, strategy, dryRun, force); err != nil {
-					return fmt.Errorf("error processing module %s: %w", module.Source, err)
-				}
-				continue
+	opts.Output = os.Stdout
+	opts.Logger = log.Default()
+	if format == outputJSON || format == outputSARIF {
+		// Keep stdout for the report; progress still goes to the logger on stderr
+		opts.Output = io.Discard
+	}
+
+	// The limit covers every work dir, and a confirmation every change, so all of them are
+	// previewed before any is written
+	if (opts.MaxChanges > 0 && len(workDirs) > 1) || confirm.enabled {
+		preview := opts
+		preview.DryRun, preview.MaxChanges = true, 0
+		preview.Output, preview.Logger = nil, nil
+		prompt := os.Stdout
+		if confirm.enabled {
+			// The preview is the plan being confirmed, so it is shown, on stderr when stdout
+			// is kept for the report
+			if opts.Output == io.Discard {
+				prompt = os.Stderr
 			}
+			preview.Output = prompt
 		}
-
-		// Process specific tiers
-		for tier, _ := range module.Versions {
-			// Skip the wildcard tier as it's only used for inheritance when we have specific tiers
-			if tier == "*" {
-				continue
+		var previewResult runner.RunResult
+		for _, workDir := range workDirs {
+			dirResult, err := runner.Run(cfg, workDir, preview)
+			if err != nil {
+				return fmt.Errorf("error processing %s: %w", workDir, err)
+			}
+			previewResult.Changes = append(previewResult.Changes, dirResult.Changes...)
+		}
+		if n := previewResult.FilesChanged(); opts.MaxChanges > 0 && n > opts.MaxChanges {
+			return fmt.Errorf("%w: %d files would change, more than the limit of %d", runner.ErrTooManyChanges, n, opts.MaxChanges)
+		}
+		opts.MaxChanges = 0
+		if confirm.enabled && len(previewResult.Changes) > 0 {
+			if err := confirmChanges(previewResult, confirm, prompt); err != nil {
+				return err
 			}
+		}
+	}
 
-			// Get effective version config for this tier
-			versionConfig, err := config.GetEffectiveVersionConfig(module, tier)
-			if err != nil {
-				log.Printf("Error getting version config for module '%s' tier '%s': %v", module.Source, tier, err)
-				continue
+	result := runner.RunResult{Summary: make(map[string]runner.TierSummary)}
+	for _, workDir := range workDirs {
+		dirResult, err := runner.Run(cfg, workDir, opts)
+		if err != nil {
+			if len(workDirs) > 1 {
+				return fmt.Errorf("error processing %s: %w", workDir, err)
 			}
+			return err
+		}
+		result.Changes = append(result.Changes, dirResult.Changes...)
+		result.Decisions = append(result.Decisions, dirResult.Decisions...)
+		result.Skips = append(result.Skips, dirResult.Skips...)
+		result.Errors = append(result.Errors, dirResult.Errors...)
+		result.Warnings = append(result.Warnings, dirResult.Warnings...)
+		result.ModuleWarnings = append(result.ModuleWarnings, dirResult.ModuleWarnings...)
+		addSummary(result.Summary, dirResult.Summary)
+	}
+
+	if files.metrics != "" {
+		if err := writeMetrics(files.metrics, result.Summary); err != nil {
+			return err
+		}
+	}
+	if files.plan != "" {
+		if err := writePlan(files.plan, result); err != nil {
+			return err
+		}
+	}
 
-			// Get effective strategy
-			strategy := config.GetEffectiveStrategy(module, tier)
+	var drift error
+	if failOnChanges && len(result.Changes) > 0 {
+		drift = fmt.Errorf("%w: %d change(s) to %d file(s) would be made", errDrift, len(result.Changes), result.FilesChanged())
+	}
+	// Module failures are reported with the rest of the run and then fail it
+	if len(result.Errors) > 0 {
+		drift = errors.Join(drift, fmt.Errorf("%d module(s) failed to process:\n%w", len(result.Errors), errors.Join(result.Errors...)))
+	}
 
-			// Get effective force setting
-			force := config.GetEffectiveForce(module, tier)
+	if format == outputSARIF {
+		baseDir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("error encoding SARIF report: %w", err)
+		}
+		report, err := formatSARIF(result, baseDir)
+		if err != nil {
+			return err
+		}
+		fmt.Println(report)
+		return drift
+	}
 
-			// Parse the version/range
-			newIsVer, newVer, newConstr, err := version.ParseVersionOrRange(versionConfig.Version)
-			if err != nil {
-				log.Printf("Error parsing version '%s' for module '%s': %v", versionConfig.Version, module.Source, err)
-				continue
-			}
+	if format == outputJSON {
+		report := jsonReport{Changes: result.Changes, Errors: []string{}, Warnings: []string{}, Skips: result.Skips}
+		if report.Changes == nil {
+			report.Changes = []runner.Change{}
+		}
+		if report.Skips == nil {
+			report.Skips = []runner.Skip{}
+		}
+		for _, e := range result.Errors {
+			report.Errors = append(report.Errors, e.Error())
+		}
+		for _, w := range result.Warnings {
+			report.Warnings = append(report.Warnings, w.Error())
+		}
+		for _, w := range result.ModuleWarnings {
+			report.Warnings = append(report.Warnings, w.String())
+		}
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding report: %w", err)
+		}
+		fmt.Println(string(data))
+		return drift
+	}
 
-			rootDir := filepath.Join(workDir, tier)
-			if err := terraform.ScanAndUpdateModules(rootDir, module.Source, newIsVer, newVer, newConstr, versionConfig.Version, configTiers, strategy, dryRun, force); err != nil {
-				log.Printf("Error processing module '%s' in tier '%s': %v", module.Source, tier, err)
-				continue
-			}
+	if opts.DryRun && len(result.Changes) > 0 {
+		fmt.Printf("Dry run: %d change(s) previewed, no files were modified. Re-run with -write to apply them.\n", len(result.Changes))
+	}
+	return drift
+}
+
+// checkConfig reports whether candidate satisfies the constraint of each configured module
+// and tier in every work dir, failing when any does not
+func checkConfig(configFile string, configFormat config.Format, workDirs []string, candidate string, opts runner.Options) error {
+	cfg, err := config.LoadConfigWithFormat(configFile, configFormat)
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
 
-			log.Printf("Successfully processed module '%s' in tier '%s'", module.Source, tier)
+	opts.Output = os.Stdout
+	var results []runner.CheckResult
+	for _, workDir := range workDirs {
+		dirResults, err := runner.Check(cfg, workDir, candidate, opts)
+		if err != nil {
+			return err
 		}
+		results = append(results, dirResults...)
+	}
+
+	failed := 0
+	for _, r := range results {
+		status := "PASS"
+		if !r.Satisfied {
+			status = "FAIL"
+			failed++
+		}
+		line := fmt.Sprintf("%s %s [%s] %s: %q", status, r.Source, r.Tier, r.File, r.Constraint)
+		if r.Err != nil {
+			line += fmt.Sprintf(" (%v)", r.Err)
+		}
+		fmt.Println(line)
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No matching modules found")
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d module constraints are not satisfied by %s", failed, len(results), candidate)
 	}
 	return nil
 }
 
 func mainWithFlags(args []string, workDir string) error {
+	// The bump subcommand takes the same options as a run, plus -level
+	bump := len(args) > 0 && args[0] == bumpCommand
+	if bump {
+		args = args[1:]
+	}
+
 	// Create a new flag set
 	flags := flag.NewFlagSet("hclsemver", flag.ContinueOnError)
 
 	// Set custom usage message
 	flags.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: hclsemver [options]\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: hclsemver [options]\n")
+		fmt.Fprintf(os.Stderr, "       hclsemver %s -level major|minor|patch [options]\n\n", bumpCommand)
 		fmt.Fprintf(os.Stderr, "A tool for managing semantic versioning in Terraform HCL files.\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flags.PrintDefaults()
 	}
 
 	// Define flags
-	configFile := flags.String("config", "", "Path to config file (JSON or YAML)")
-	dir := flags.String("dir", "/work", "Directory to scan for Terraform files")
-	dryRun := flags.Bool("dry-run", false, "Preview changes without modifying files")
+	configFile := flags.String("config", "", "Path to config file (JSON, YAML or TOML)")
+	configFormatName := flags.String("config-format", "", "Parse the config file as json, yaml or toml instead of detecting its format")
+	var dirs stringSliceFlag
+	flags.Var(&dirs, "dir", "Directory to scan for Terraform files (can be repeated; default "+workDir+")")
+	dryRun := flags.Bool("dry-run", false, "Preview changes without modifying files (the default unless -write is given)")
+	write := flags.Bool("write", false, "Write the changes to the files; without it changes are only previewed")
+	apply := flags.Bool("apply", false, "Alias for -write")
+	reportOnly := flags.Bool("report-only", false, "Guarantee that no file is written: preview the changes even when -write, -apply or -confirm is given")
+	diffOnly := flags.Bool("diff-only", false, "Preview the changes without modifying files and exit non-zero if there are any, for CI checks")
+	confirm := flags.Bool("confirm", false, "Preview every change, then ask for confirmation before writing them; implies -write")
+	yes := flags.Bool("yes", false, "Answer yes to the -confirm prompt")
+	nonInteractive := flags.String("non-interactive", nonInteractiveAbort, "Answer to the -confirm prompt when stdin is not a terminal: abort or yes")
+	respectIgnore := flags.Bool("respect-ignore", false, "Skip paths matched by .terraformignore or .gitignore in the scanned directory")
+	backup := flags.Bool("backup", false, "Write a backup copy of each file before modifying it")
+	backupSuffix := flags.String("backup-suffix", runner.DefaultBackupSuffix, "Suffix appended to backup file names")
+	backupOverwrite := flags.Bool("backup-overwrite", false, "Overwrite existing backup files instead of failing")
+	followSymlinks := flags.Bool("follow-symlinks", false, "Follow symlinked directories while scanning")
+	maxChanges := flags.Int("max-changes", 0, "Fail without writing any file when more than this many files would change (default: no limit)")
+	allowHooks := flags.Bool("allow-hooks", false, "Run the post_update_hook commands of the config on each changed file")
+	failFast := flags.Bool("fail-fast", false, "Stop at the first module that fails to process instead of reporting the failure and continuing")
+	strict := flags.Bool("strict", false, "Fail the run when a post_update_hook or terraform fmt fails, or -verify-tiers finds a tier directory missing, instead of reporting the failure and continuing")
+	verifyTiers := flags.Bool("verify-tiers", false, "Warn about each configured tier without a directory under the scanned directory before processing (an error with -strict)")
+	annotate := flags.Bool("annotate", false, "Add a '# managed by hclsemver (<strategy> strategy)' comment to each version line changed, updating the comment left by an earlier run")
+	showProgress := flags.Bool("progress", false, "Print the files processed so far and the current tier to stderr every second")
+	format := flags.Bool("fmt", false, "Run terraform fmt on each changed file when terraform is on the PATH")
+	terragrunt := flags.Bool("terragrunt", false, "Also update the git source refs in the terraform blocks of terragrunt.hcl files")
+	var onlyTiers stringSliceFlag
+	flags.Var(&onlyTiers, "only-tier", "Only process the given tier (can be repeated)")
+	flat := flags.Bool("flat", false, "Treat the whole directory as a single tier instead of one subdirectory per tier")
+	modulePattern := flags.String("module", "", "Only process configured modules whose source matches this pattern")
+	stdinFiles := flags.Bool("stdin-files", false, "Only process the files listed on stdin, one path per line, instead of scanning")
+	filesFrom := flags.String("files-from", "", "Only process the files listed in this file, one path per line, instead of scanning")
+	since := flags.String("since", "", "Only process the .tf files that differ from this git ref, e.g. origin/main; every file is processed outside a git repository")
+	allowNetwork := flags.Bool("allow-network", false, "Allow querying the module registry to resolve 'latest' and 'latest-minor' versions")
+	registryHost := flags.String("registry-host", registry.DefaultHost, "Registry queried for module sources without a host")
+	registryCacheTTL := flags.Duration("registry-cache-ttl", 0, "Cache registry lookups on disk for this long, e.g. 1h (default: no disk cache)")
+	level := flags.String("level", "", "With the "+bumpCommand+" subcommand, bump the existing version of every configured module by this semver level instead of moving it to the configured version: major, minor or patch")
+	checkVersion := flags.String("check", "", "Report whether this version satisfies the current constraint of each configured module and tier, without modifying files")
+	validate := flags.Bool("validate", false, "Validate the config file and exit without scanning")
+	printEffectiveConfig := flags.Bool("print-effective", false, "Print the version, strategy and force resolved for every module and tier, then exit without scanning")
+	diffConfig := flags.String("diff-config", "", "Print how the resolved version, strategy and force of every module and tier change from this config to the one given as the next argument, e.g. -diff-config old.yaml new.yaml, then exit")
+	explain := flags.String("explain", "", "Print what the dynamic, range and exact strategies write for this target over the existing version given as the next argument, e.g. -explain 2.0.0 1.0.0, then exit without reading any file; -config is optional")
+	inventory := flags.Bool("inventory", false, "List every module block found, with its source, version, file and tier, without modifying files; -config is optional")
+	printSchema := flags.Bool("print-schema", false, "Print the JSON Schema of the config file format and exit")
+	output := flags.String("output", outputText, "Report format: text, json or sarif")
+	metricsPath := flags.String("metrics", "", "Write per-tier run metrics to this file in the Prometheus text format")
+	planOut := flags.String("plan-out", "", "Write the changes of the run to this file as a versioned JSON plan document")
+	debug := flags.Bool("debug", false, "Log the reason for every version decision to stderr")
 	help := flags.Bool("help", false, "Display help information")
 
 	// Parse flags
@@ -116,12 +331,197 @@ func mainWithFlags(args []string, workDir string) error {
 		return nil
 	}
 
-	if *configFile == "" {
+	configFormat, err := config.ParseFormat(*configFormatName)
+	if err != nil {
+		return fmt.Errorf("invalid -config-format: %w", err)
+	}
+
+	var bumpLevel version.BumpLevel
+	if bump {
+		if *level == "" {
+			return fmt.Errorf("%s requires -level major, minor or patch", bumpCommand)
+		}
+		if bumpLevel, err = version.ParseBumpLevel(*level); err != nil {
+			return fmt.Errorf("invalid -level: %w", err)
+		}
+		if *explain != "" || *diffConfig != "" || *printEffectiveConfig || *inventory || *checkVersion != "" || *validate || *printSchema {
+			return fmt.Errorf("%s only processes files; it cannot be combined with -explain, -diff-config, -print-effective, -inventory, -check, -validate or -print-schema", bumpCommand)
+		}
+	} else if *level != "" {
+		return fmt.Errorf("-level is only supported by the %s subcommand: hclsemver %s -level %s", bumpCommand, bumpCommand, *level)
+	}
+
+	if *printSchema {
+		data, err := json.MarshalIndent(config.Schema(), "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding schema: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if *explain != "" {
+		if flags.NArg() != 1 {
+			return fmt.Errorf("-explain requires the existing version as the next argument: -explain 2.0.0 1.0.0")
+		}
+		if *output == outputSARIF {
+			return fmt.Errorf("-output %s is only supported when processing files", outputSARIF)
+		}
+		return printExplain(os.Stdout, *explain, flags.Arg(0), *output)
+	}
+
+	if *configFile == "" && !*inventory && *diffConfig == "" {
 		flags.Usage()
 		return fmt.Errorf("config file is required: -config path/to/config.yaml")
 	}
 
-	return processConfig(*configFile, *dir, *dryRun)
+	if *validate {
+		if _, err := config.LoadConfigWithFormat(*configFile, configFormat); err != nil {
+			return fmt.Errorf("error loading config: %w", err)
+		}
+		fmt.Println("Configuration is valid")
+		return nil
+	}
+
+	if *output != outputText && *output != outputJSON && *output != outputSARIF {
+		return fmt.Errorf("invalid -output %q: must be %s, %s or %s", *output, outputText, outputJSON, outputSARIF)
+	}
+	if *output == outputSARIF && (*printEffectiveConfig || *inventory || *checkVersion != "" || *diffConfig != "") {
+		return fmt.Errorf("-output %s is only supported when processing files", outputSARIF)
+	}
+
+	if *diffConfig != "" {
+		if flags.NArg() != 1 {
+			return fmt.Errorf("-diff-config requires the new config file as the next argument: -diff-config old.yaml new.yaml")
+		}
+		return printConfigDiff(os.Stdout, *diffConfig, flags.Arg(0), configFormat, *output)
+	}
+
+	if *printEffectiveConfig {
+		cfg, err := config.LoadConfigWithFormat(*configFile, configFormat)
+		if err != nil {
+			return fmt.Errorf("error loading config: %w", err)
+		}
+		return printEffective(os.Stdout, cfg, *output)
+	}
+
+	if len(dirs) == 0 {
+		dirs = stringSliceFlag{workDir}
+	}
+
+	if *stdinFiles && *filesFrom != "" {
+		return fmt.Errorf("-stdin-files and -files-from cannot be used together")
+	}
+
+	var files []string
+	if *stdinFiles {
+		var err error
+		if files, err = readFileList(os.Stdin); err != nil {
+			return fmt.Errorf("error reading file list from stdin: %w", err)
+		}
+	}
+	if *filesFrom != "" {
+		f, err := os.Open(*filesFrom)
+		if err != nil {
+			return fmt.Errorf("error reading file list: %w", err)
+		}
+		files, err = readFileList(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("error reading file list: %w", err)
+		}
+	}
+
+	if *since != "" {
+		changed, inGit, err := changedFiles(*since, dirs, *terragrunt)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !inGit:
+			fmt.Fprintf(os.Stderr, "Warning: not in a git repository; ignoring -since %s and processing every file\n", *since)
+		case files != nil:
+			files = intersectFiles(files, changed)
+		default:
+			files = changed
+		}
+	}
+
+	if *maxChanges < 0 {
+		return fmt.Errorf("invalid -max-changes %d: must not be negative", *maxChanges)
+	}
+
+	if *nonInteractive != nonInteractiveAbort && *nonInteractive != nonInteractiveYes {
+		return fmt.Errorf("invalid -non-interactive %q: must be %s or %s", *nonInteractive, nonInteractiveAbort, nonInteractiveYes)
+	}
+
+	// Runs are read-only unless writing is explicitly requested
+	writeFiles := *write || *apply || *confirm
+	if writeFiles && *dryRun {
+		return fmt.Errorf("-dry-run cannot be used together with -write, -apply or -confirm")
+	}
+	if writeFiles && *diffOnly {
+		return fmt.Errorf("-diff-only cannot be used together with -write, -apply or -confirm")
+	}
+
+	// -report-only overrides every request to write, rather than conflicting with it
+	confirmWrite := *confirm
+	if *reportOnly {
+		if writeFiles {
+			log.Print("-report-only is set: previewing the changes without writing them")
+		}
+		writeFiles, confirmWrite = false, false
+	}
+
+	opts := runner.Options{
+		DryRun:          !writeFiles,
+		ReportOnly:      *reportOnly,
+		RespectIgnore:   *respectIgnore,
+		FollowSymlinks:  *followSymlinks,
+		Terragrunt:      *terragrunt,
+		MaxChanges:      *maxChanges,
+		AllowHooks:      *allowHooks,
+		Strict:          *strict,
+		VerifyTiers:     *verifyTiers,
+		FailFast:        *failFast,
+		Format:          *format,
+		Annotate:        *annotate,
+		Backup:          *backup,
+		BackupSuffix:    *backupSuffix,
+		OverwriteBackup: *backupOverwrite,
+		OnlyTiers:       onlyTiers,
+		Module:          *modulePattern,
+		Flat:            *flat,
+		Files:           files,
+		Bump:            bumpLevel,
+	}
+	if *checkVersion != "" {
+		return checkConfig(*configFile, configFormat, dirs, *checkVersion, opts)
+	}
+	if *inventory {
+		return printInventory(os.Stdout, *configFile, configFormat, dirs, *output, opts)
+	}
+
+	if *allowNetwork {
+		opts.Registry = registry.NewClient(*registryHost)
+		if *registryCacheTTL > 0 {
+			cacheDir, err := os.UserCacheDir()
+			if err != nil {
+				return fmt.Errorf("cannot determine registry cache directory: %w", err)
+			}
+			opts.Registry.CacheDir = filepath.Join(cacheDir, "hclsemver", "registry")
+			opts.Registry.CacheTTL = *registryCacheTTL
+		}
+	}
+	if *debug {
+		opts.Debug = log.New(os.Stderr, "DEBUG: ", 0)
+	}
+	if *showProgress {
+		// Progress lines stay off stdout, which may carry a json report
+		opts.Progress = os.Stderr
+	}
+	confirmOpts := confirmOptions{enabled: confirmWrite, yes: *yes, nonInteractive: *nonInteractive}
+	return processConfig(*configFile, configFormat, dirs, *output, reportFiles{metrics: *metricsPath, plan: *planOut}, confirmOpts, *diffOnly, opts)
 }
 
 func main() {
@@ -1,9 +1,21 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+
+	"github.com/david1155/hclsemver/pkg/config"
+	"github.com/david1155/hclsemver/pkg/runner"
+	"github.com/david1155/hclsemver/pkg/version"
 )
 
 func TestMainWithFlags(t *testing.T) {
@@ -26,6 +38,11 @@ modules:
 	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
 		t.Fatalf("Failed to write config file: %v", err)
 	}
+	for _, tier := range []string{"dev", "staging", "prod"} {
+		if err := os.Mkdir(filepath.Join(tmpDir, tier), 0755); err != nil {
+			t.Fatalf("Failed to create tier directory: %v", err)
+		}
+	}
 
 	// Test cases
 	tests := []struct {
@@ -67,3 +84,1451 @@ modules:
 		})
 	}
 }
+
+func TestMainWithFlags_OnlyTier(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	configContent := `
+modules:
+  - source: "test-module/aws"
+    strategy: "exact"
+    versions:
+      dev: "2.0.0"
+      prod: "2.0.0"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	tfContent := `
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+`
+	workDir := filepath.Join(tmpDir, "work")
+	for _, tier := range []string{"dev", "prod"} {
+		if err := os.MkdirAll(filepath.Join(workDir, tier), 0755); err != nil {
+			t.Fatalf("Failed to create tier directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(workDir, tier, "main.tf"), []byte(tfContent), 0644); err != nil {
+			t.Fatalf("Failed to write tf file: %v", err)
+		}
+	}
+
+	if err := mainWithFlags([]string{"-config", configPath, "-dir", workDir, "-only-tier", "prod", "-write"}, workDir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	devContent, err := os.ReadFile(filepath.Join(workDir, "dev", "main.tf"))
+	if err != nil {
+		t.Fatalf("Failed to read dev file: %v", err)
+	}
+	if string(devContent) != tfContent {
+		t.Errorf("dev file should be untouched, got:\n%s", devContent)
+	}
+
+	prodContent, err := os.ReadFile(filepath.Join(workDir, "prod", "main.tf"))
+	if err != nil {
+		t.Fatalf("Failed to read prod file: %v", err)
+	}
+	if !strings.Contains(string(prodContent), `version = "2.0.0"`) {
+		t.Errorf("prod file should be updated, got:\n%s", prodContent)
+	}
+
+	if err := mainWithFlags([]string{"-config", configPath, "-dir", workDir, "-only-tier", "staging"}, workDir); err == nil {
+		t.Error("Expected error for a tier that is not configured, got nil")
+	}
+}
+
+func TestMainWithFlags_Module(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	configContent := `
+modules:
+  - source: "org/aws/vpc"
+    strategy: "exact"
+    versions:
+      dev: "2.0.0"
+  - source: "org/aws/s3"
+    strategy: "exact"
+    versions:
+      dev: "2.0.0"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	tfContent := `
+module "vpc" {
+  source  = "registry.example.com/org/aws/vpc"
+  version = "1.0.0"
+}
+
+module "s3" {
+  source  = "registry.example.com/org/aws/s3"
+  version = "1.0.0"
+}
+`
+	workDir := filepath.Join(tmpDir, "work")
+	tfFile := filepath.Join(workDir, "dev", "main.tf")
+	if err := os.MkdirAll(filepath.Dir(tfFile), 0755); err != nil {
+		t.Fatalf("Failed to create tier directory: %v", err)
+	}
+	if err := os.WriteFile(tfFile, []byte(tfContent), 0644); err != nil {
+		t.Fatalf("Failed to write tf file: %v", err)
+	}
+
+	if err := mainWithFlags([]string{"-config", configPath, "-dir", workDir, "-module", "aws/vpc", "-write"}, workDir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	updated, err := os.ReadFile(tfFile)
+	if err != nil {
+		t.Fatalf("Failed to read tf file: %v", err)
+	}
+	want := strings.Replace(tfContent, `version = "1.0.0"`, `version = "2.0.0"`, 1)
+	if string(updated) != want {
+		t.Errorf("expected only the vpc module to be updated, got:\n%s", updated)
+	}
+
+	if err := mainWithFlags([]string{"-config", configPath, "-dir", workDir, "-module", "gcp/network"}, workDir); err == nil {
+		t.Error("Expected error for a pattern matching no module, got nil")
+	}
+}
+
+func TestMainWithFlags_Validate(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	validPath := filepath.Join(tmpDir, "valid.yaml")
+	if err := os.WriteFile(validPath, []byte("modules:\n  - source: \"test-module\"\n    strategy: \"exact\"\n    versions:\n      dev: \"2.0.0\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	invalidPath := filepath.Join(tmpDir, "invalid.yaml")
+	if err := os.WriteFile(invalidPath, []byte("modules:\n  - source: \"test-module\"\n    strategy: \"exact\"\n    versions:\n      dev: \">=1,<2\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	if err := mainWithFlags([]string{"-config", validPath, "-validate"}, tmpDir); err != nil {
+		t.Errorf("Unexpected error for valid config: %v", err)
+	}
+
+	err := mainWithFlags([]string{"-config", invalidPath, "-validate"}, tmpDir)
+	if err == nil {
+		t.Fatal("Expected error for invalid config, got nil")
+	}
+	if !strings.Contains(err.Error(), "module test-module tier dev: exact strategy cannot use range '>=1,<2'") {
+		t.Errorf("unexpected error message: %v", err)
+	}
+}
+
+func TestMainWithFlags_PrintSchema(t *testing.T) {
+	// The schema is printed without requiring a config file
+	if err := mainWithFlags([]string{"-print-schema"}, t.TempDir()); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestMainWithFlags_FileList(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	configContent := `
+modules:
+  - source: "test-module/aws"
+    strategy: "exact"
+    versions:
+      dev: "2.0.0"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	tfContent := `
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+`
+	writeFiles := func(workDir string) []string {
+		var paths []string
+		for _, name := range []string{"a.tf", "b.tf", "c.tf"} {
+			path := filepath.Join(workDir, "dev", name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("Failed to create tier directory: %v", err)
+			}
+			if err := os.WriteFile(path, []byte(tfContent), 0644); err != nil {
+				t.Fatalf("Failed to write tf file: %v", err)
+			}
+			paths = append(paths, path)
+		}
+		return paths
+	}
+	checkFiles := func(paths []string) {
+		for i, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read tf file: %v", err)
+			}
+			wantUpdated := i < 2
+			if updated := strings.Contains(string(data), `version = "2.0.0"`); updated != wantUpdated {
+				t.Errorf("%s: expected updated=%v, got:\n%s", path, wantUpdated, data)
+			}
+		}
+	}
+
+	t.Run("files-from", func(t *testing.T) {
+		workDir := filepath.Join(tmpDir, "files-from")
+		paths := writeFiles(workDir)
+		listPath := filepath.Join(tmpDir, "files.txt")
+		if err := os.WriteFile(listPath, []byte(paths[0]+"\n\n"+paths[1]+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file list: %v", err)
+		}
+
+		if err := mainWithFlags([]string{"-config", configPath, "-dir", workDir, "-files-from", listPath, "-write"}, workDir); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		checkFiles(paths)
+	})
+
+	t.Run("stdin-files", func(t *testing.T) {
+		workDir := filepath.Join(tmpDir, "stdin")
+		paths := writeFiles(workDir)
+		stdinPath := filepath.Join(tmpDir, "stdin.txt")
+		if err := os.WriteFile(stdinPath, []byte(paths[0]+"\n"+paths[1]+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file list: %v", err)
+		}
+		stdin, err := os.Open(stdinPath)
+		if err != nil {
+			t.Fatalf("Failed to open file list: %v", err)
+		}
+		defer stdin.Close()
+
+		oldStdin := os.Stdin
+		os.Stdin = stdin
+		defer func() { os.Stdin = oldStdin }()
+
+		if err := mainWithFlags([]string{"-config", configPath, "-dir", workDir, "-stdin-files", "-write"}, workDir); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		checkFiles(paths)
+	})
+
+	if err := mainWithFlags([]string{"-config", configPath, "-stdin-files", "-files-from", "files.txt"}, tmpDir); err == nil {
+		t.Error("Expected error when combining -stdin-files and -files-from, got nil")
+	}
+}
+
+func TestMainWithFlags_Since(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	configContent := `
+modules:
+  - source: "test-module/aws"
+    strategy: "exact"
+    versions:
+      dev: "2.0.0"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	tfContent := `
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+`
+	workDir := filepath.Join(tmpDir, "repo")
+	var paths []string
+	for _, name := range []string{"a.tf", "b.tf"} {
+		path := filepath.Join(workDir, "dev", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create tier directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(tfContent), 0644); err != nil {
+			t.Fatalf("Failed to write tf file: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", workDir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+
+	// Only a.tf differs from HEAD
+	if err := os.WriteFile(paths[0], []byte("# changed\n"+tfContent), 0644); err != nil {
+		t.Fatalf("Failed to write tf file: %v", err)
+	}
+
+	if err := mainWithFlags([]string{"-config", configPath, "-dir", workDir, "-since", "HEAD", "-write"}, workDir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read tf file: %v", err)
+		}
+		wantUpdated := i == 0
+		if updated := strings.Contains(string(data), `version = "2.0.0"`); updated != wantUpdated {
+			t.Errorf("%s: expected updated=%v, got:\n%s", path, wantUpdated, data)
+		}
+	}
+
+	if err := mainWithFlags([]string{"-config", configPath, "-dir", workDir, "-since", "no-such-ref"}, workDir); err == nil {
+		t.Error("Expected error for an unknown ref, got nil")
+	}
+
+	// Outside a git repository every file is processed
+	plainDir := filepath.Join(tmpDir, "plain")
+	plainFile := filepath.Join(plainDir, "dev", "main.tf")
+	if err := os.MkdirAll(filepath.Dir(plainFile), 0755); err != nil {
+		t.Fatalf("Failed to create tier directory: %v", err)
+	}
+	if err := os.WriteFile(plainFile, []byte(tfContent), 0644); err != nil {
+		t.Fatalf("Failed to write tf file: %v", err)
+	}
+	t.Setenv("GIT_CEILING_DIRECTORIES", tmpDir)
+	if err := mainWithFlags([]string{"-config", configPath, "-dir", plainDir, "-since", "HEAD", "-write"}, plainDir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(plainFile); !strings.Contains(string(data), `version = "2.0.0"`) {
+		t.Errorf("expected the file outside git to be updated, got:\n%s", data)
+	}
+}
+
+func TestMainWithFlags_Check(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("modules:\n  - source: \"test-module/aws\"\n    versions:\n      prod: \"2.0.0\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	tfContent := `
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = ">= 1.0.0, < 2.0.0"
+}
+`
+	workDir := filepath.Join(tmpDir, "work")
+	tfFile := filepath.Join(workDir, "prod", "main.tf")
+	if err := os.MkdirAll(filepath.Dir(tfFile), 0755); err != nil {
+		t.Fatalf("Failed to create tier directory: %v", err)
+	}
+	if err := os.WriteFile(tfFile, []byte(tfContent), 0644); err != nil {
+		t.Fatalf("Failed to write tf file: %v", err)
+	}
+
+	if err := mainWithFlags([]string{"-config", configPath, "-dir", workDir, "-check", "1.4.0"}, workDir); err != nil {
+		t.Errorf("Unexpected error for satisfying version: %v", err)
+	}
+	if err := mainWithFlags([]string{"-config", configPath, "-dir", workDir, "-check", "2.1.0"}, workDir); err == nil {
+		t.Error("Expected error for non-satisfying version, got nil")
+	}
+
+	data, err := os.ReadFile(tfFile)
+	if err != nil {
+		t.Fatalf("Failed to read tf file: %v", err)
+	}
+	if string(data) != tfContent {
+		t.Errorf("expected -check to leave the file untouched, got:\n%s", data)
+	}
+}
+
+func TestMainWithFlags_WriteRequired(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("modules:\n  - source: \"test-module/aws\"\n    strategy: \"exact\"\n    versions:\n      dev: \"2.0.0\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	tfContent := `
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+`
+	workDir := filepath.Join(tmpDir, "work")
+	tfFile := filepath.Join(workDir, "dev", "main.tf")
+	if err := os.MkdirAll(filepath.Dir(tfFile), 0755); err != nil {
+		t.Fatalf("Failed to create tier directory: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantUpdated bool
+		wantErr     bool
+	}{
+		{name: "default is read-only", args: nil, wantUpdated: false},
+		{name: "dry-run is the default", args: []string{"-dry-run"}, wantUpdated: false},
+		{name: "write", args: []string{"-write"}, wantUpdated: true},
+		{name: "apply", args: []string{"-apply"}, wantUpdated: true},
+		{name: "dry-run with write", args: []string{"-dry-run", "-write"}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := os.WriteFile(tfFile, []byte(tfContent), 0644); err != nil {
+				t.Fatalf("Failed to write tf file: %v", err)
+			}
+
+			args := append([]string{"-config", configPath, "-dir", workDir}, tc.args...)
+			err := mainWithFlags(args, workDir)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			data, err := os.ReadFile(tfFile)
+			if err != nil {
+				t.Fatalf("Failed to read tf file: %v", err)
+			}
+			if updated := strings.Contains(string(data), `version = "2.0.0"`); updated != tc.wantUpdated {
+				t.Errorf("expected updated=%v, got:\n%s", tc.wantUpdated, data)
+			}
+		})
+	}
+}
+
+func TestMainWithFlags_Confirm(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("modules:\n  - source: \"test-module/aws\"\n    strategy: \"exact\"\n    versions:\n      dev: \"2.0.0\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	tfContent := `
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+`
+	workDir := filepath.Join(tmpDir, "work")
+	tfFile := filepath.Join(workDir, "dev", "main.tf")
+	if err := os.MkdirAll(filepath.Dir(tfFile), 0755); err != nil {
+		t.Fatalf("Failed to create tier directory: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		args         []string
+		terminal     bool
+		answer       string
+		wantUpdated  bool
+		wantErr      bool
+		notConfirmed bool
+	}{
+		{name: "yes", args: []string{"-confirm", "-yes"}, wantUpdated: true},
+		{name: "answered yes", args: []string{"-confirm"}, terminal: true, answer: "y\n", wantUpdated: true},
+		{name: "answered no", args: []string{"-confirm"}, terminal: true, answer: "n\n", wantErr: true, notConfirmed: true},
+		{name: "no answer", args: []string{"-confirm"}, terminal: true, answer: "", wantErr: true, notConfirmed: true},
+		{name: "not a terminal aborts", args: []string{"-confirm"}, wantErr: true, notConfirmed: true},
+		{name: "not a terminal answers yes", args: []string{"-confirm", "-non-interactive", "yes"}, wantUpdated: true},
+		{name: "invalid non-interactive", args: []string{"-confirm", "-non-interactive", "maybe"}, wantErr: true},
+		{name: "confirm with dry-run", args: []string{"-confirm", "-dry-run"}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := os.WriteFile(tfFile, []byte(tfContent), 0644); err != nil {
+				t.Fatalf("Failed to write tf file: %v", err)
+			}
+
+			answerPath := filepath.Join(tmpDir, "answer.txt")
+			if err := os.WriteFile(answerPath, []byte(tc.answer), 0644); err != nil {
+				t.Fatalf("Failed to write answer file: %v", err)
+			}
+			answer, err := os.Open(answerPath)
+			if err != nil {
+				t.Fatalf("Failed to open answer file: %v", err)
+			}
+			defer answer.Close()
+
+			oldStdin, oldIsTerminal := os.Stdin, stdinIsTerminal
+			os.Stdin = answer
+			stdinIsTerminal = func() bool { return tc.terminal }
+			defer func() { os.Stdin, stdinIsTerminal = oldStdin, oldIsTerminal }()
+
+			args := append([]string{"-config", configPath, "-dir", workDir}, tc.args...)
+			err = mainWithFlags(args, workDir)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				} else if tc.notConfirmed && !errors.Is(err, errNotConfirmed) {
+					t.Errorf("Expected errNotConfirmed, got: %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			data, err := os.ReadFile(tfFile)
+			if err != nil {
+				t.Fatalf("Failed to read tf file: %v", err)
+			}
+			if updated := strings.Contains(string(data), `version = "2.0.0"`); updated != tc.wantUpdated {
+				t.Errorf("expected updated=%v, got:\n%s", tc.wantUpdated, data)
+			}
+		})
+	}
+}
+
+func TestMainWithFlags_FailFast(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	// The broken module is listed first and fails because its tier directory is missing
+	configContent := `
+modules:
+  - source: "broken-module/aws"
+    versions:
+      qa: "1.0.0"
+  - source: "test-module/aws"
+    strategy: "exact"
+    versions:
+      dev: "2.0.0"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	workDir := filepath.Join(tmpDir, "work")
+	tfFile := filepath.Join(workDir, "dev", "main.tf")
+	if err := os.MkdirAll(filepath.Dir(tfFile), 0755); err != nil {
+		t.Fatalf("Failed to create tier directory: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantErr     string
+		wantUpdated bool
+	}{
+		{name: "failures aggregated", wantErr: "1 module(s) failed to process", wantUpdated: true},
+		{name: "fail fast", args: []string{"-fail-fast"}, wantErr: "error processing module 'broken-module/aws' in tier 'qa'"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			content := "module \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"1.0.0\"\n}\n"
+			if err := os.WriteFile(tfFile, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write tf file: %v", err)
+			}
+
+			args := append([]string{"-config", configPath, "-dir", workDir, "-write"}, tc.args...)
+			err := mainWithFlags(args, workDir)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tc.wantErr, err)
+			}
+
+			data, err := os.ReadFile(tfFile)
+			if err != nil {
+				t.Fatalf("Failed to read tf file: %v", err)
+			}
+			if updated := strings.Contains(string(data), `version = "2.0.0"`); updated != tc.wantUpdated {
+				t.Errorf("expected updated=%v, got:\n%s", tc.wantUpdated, data)
+			}
+		})
+	}
+}
+
+func TestMainWithFlags_ReportOnly(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	configContent := `
+modules:
+  - source: "test-module/aws"
+    strategy: "exact"
+    force: true
+    versions:
+      dev: "2.0.0"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	workDir := filepath.Join(tmpDir, "work")
+	tfFile := filepath.Join(workDir, "dev", "main.tf")
+	if err := os.MkdirAll(filepath.Dir(tfFile), 0755); err != nil {
+		t.Fatalf("Failed to create tier directory: %v", err)
+	}
+	// force would add a version to the block if the run were allowed to write
+	content := "module \"test\" {\n  source = \"registry.example.com/test-module/aws\"\n}\n"
+
+	for _, extra := range [][]string{{"-write"}, {"-apply", "-backup"}, {"-confirm", "-yes"}} {
+		t.Run(strings.Join(extra, " "), func(t *testing.T) {
+			if err := os.WriteFile(tfFile, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write tf file: %v", err)
+			}
+
+			args := append([]string{"-config", configPath, "-dir", workDir, "-report-only"}, extra...)
+			if err := mainWithFlags(args, workDir); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			data, err := os.ReadFile(tfFile)
+			if err != nil {
+				t.Fatalf("Failed to read tf file: %v", err)
+			}
+			if string(data) != content {
+				t.Errorf("expected the file to be left untouched, got:\n%s", data)
+			}
+			if entries, _ := os.ReadDir(filepath.Dir(tfFile)); len(entries) != 1 {
+				t.Errorf("expected no backup or other file to be written, got %d entries", len(entries))
+			}
+		})
+	}
+}
+
+func TestMainWithFlags_Bump(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	// The configured versions only select the modules and tiers to bump
+	configContent := `
+modules:
+  - source: "test-module/aws"
+    strategy: "exact"
+    versions:
+      dev: "9.9.9"
+  - source: "range-module/aws"
+    strategy: "range"
+    versions:
+      dev: "~> 9.0"
+  - source: "org/git-module"
+    strategy: "exact"
+    versions:
+      dev: "9.9.9"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	workDir := filepath.Join(tmpDir, "work")
+	tfFile := filepath.Join(workDir, "dev", "main.tf")
+	if err := os.MkdirAll(filepath.Dir(tfFile), 0755); err != nil {
+		t.Fatalf("Failed to create tier directory: %v", err)
+	}
+	content := `module "exact" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.2.3"
+}
+
+module "range" {
+  source  = "registry.example.com/range-module/aws"
+  version = ">= 1.2.0, < 2.0.0"
+}
+
+module "git" {
+  source = "git::https://example.com/org/git-module.git?ref=v1.2.0"
+}
+`
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr string
+	}{
+		{name: "minor", args: []string{"bump", "--level", "minor", "-write"}, want: []string{`version = "1.3.0"`, `version = ">= 1.3.0, < 2.0.0"`, `?ref=v1.3.0"`}},
+		{name: "patch", args: []string{"bump", "-level=patch", "-write"}, want: []string{`version = "1.2.4"`, `version = ">= 1.2.1, < 2.0.0"`, `?ref=v1.2.1"`}},
+		{name: "dry run", args: []string{"bump", "-level", "minor"}, want: []string{`version = "1.2.3"`, `?ref=v1.2.0"`}},
+		{name: "missing level", args: []string{"bump", "-write"}, wantErr: "bump requires -level"},
+		{name: "invalid level", args: []string{"bump", "-level", "build"}, wantErr: "invalid bump level 'build'"},
+		{name: "level without bump", args: []string{"-level", "minor"}, wantErr: "-level is only supported by the bump subcommand"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := os.WriteFile(tfFile, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write tf file: %v", err)
+			}
+
+			args := append(tc.args, "-config", configPath, "-dir", workDir)
+			err := mainWithFlags(args, workDir)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			data, err := os.ReadFile(tfFile)
+			if err != nil {
+				t.Fatalf("Failed to read tf file: %v", err)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("expected %s in:\n%s", want, data)
+				}
+			}
+		})
+	}
+}
+
+func TestMainWithFlags_ConfigFormat(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	// Valid as JSON too, where "Strategy" matches strategy and makes the range invalid
+	content := `{"modules": [{"source": "test-module/aws", "strategy": "range", "Strategy": "exact", "versions": {"dev": ">=1,<2"}}]}`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "sniffed as json", wantErr: "exact strategy"},
+		{name: "forced yaml", args: []string{"-config-format", "yaml"}},
+		{name: "forced toml", args: []string{"-config-format", "toml"}, wantErr: "parsing config file as toml"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			args := append([]string{"-config", configPath, "-validate"}, tc.args...)
+			err := mainWithFlags(args, t.TempDir())
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	tomlPath := filepath.Join(t.TempDir(), "config.conf")
+	tomlContent := "[[modules]]\nsource = \"test-module/aws\"\nstrategy = \"range\"\nversions.dev = \">=1,<2\"\n"
+	if err := os.WriteFile(tomlPath, []byte(tomlContent), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	if err := mainWithFlags([]string{"-config", tomlPath, "-config-format", "toml", "-validate"}, t.TempDir()); err != nil {
+		t.Errorf("Unexpected error validating a toml config: %v", err)
+	}
+}
+
+func TestMainWithFlags_DiffOnly(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("modules:\n  - source: \"test-module/aws\"\n    strategy: \"exact\"\n    versions:\n      dev: \"2.0.0\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	workDir := filepath.Join(tmpDir, "work")
+	tfFile := filepath.Join(workDir, "dev", "main.tf")
+	if err := os.MkdirAll(filepath.Dir(tfFile), 0755); err != nil {
+		t.Fatalf("Failed to create tier directory: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		version   string
+		args      []string
+		wantDrift bool
+		wantErr   bool
+	}{
+		{name: "drift", version: "1.0.0", wantDrift: true},
+		{name: "drift with json", version: "1.0.0", args: []string{"-output", "json"}, wantDrift: true},
+		{name: "up to date", version: "2.0.0"},
+		{name: "with write", version: "1.0.0", args: []string{"-write"}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			content := "module \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"" + tc.version + "\"\n}\n"
+			if err := os.WriteFile(tfFile, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write tf file: %v", err)
+			}
+
+			stdout := os.Stdout
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("Failed to create pipe: %v", err)
+			}
+			os.Stdout = w
+			args := append([]string{"-config", configPath, "-dir", workDir, "-diff-only"}, tc.args...)
+			runErr := mainWithFlags(args, workDir)
+			w.Close()
+			os.Stdout = stdout
+			out, _ := io.ReadAll(r)
+
+			switch {
+			case tc.wantErr:
+				if runErr == nil || errors.Is(runErr, errDrift) {
+					t.Errorf("expected a usage error, got %v", runErr)
+				}
+			case tc.wantDrift:
+				if !errors.Is(runErr, errDrift) {
+					t.Errorf("expected errDrift, got %v", runErr)
+				}
+				if !strings.Contains(string(out), "1.0.0") || !strings.Contains(string(out), "2.0.0") {
+					t.Errorf("expected the change in the output, got:\n%s", out)
+				}
+			case runErr != nil:
+				t.Errorf("Unexpected error: %v", runErr)
+			}
+
+			data, err := os.ReadFile(tfFile)
+			if err != nil {
+				t.Fatalf("Failed to read tf file: %v", err)
+			}
+			if string(data) != content {
+				t.Errorf("expected the file to be unchanged, got:\n%s", data)
+			}
+		})
+	}
+}
+
+func TestMainWithFlags_OutputJSON(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("modules:\n  - source: \"test-module/aws\"\n    name: \"Test module\"\n    strategy: \"exact\"\n    versions:\n      dev: \"2.0.0\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	workDir := filepath.Join(tmpDir, "work")
+	tfFile := filepath.Join(workDir, "dev", "main.tf")
+	if err := os.MkdirAll(filepath.Dir(tfFile), 0755); err != nil {
+		t.Fatalf("Failed to create tier directory: %v", err)
+	}
+	if err := os.WriteFile(tfFile, []byte("module \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"1.0.0\"\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write tf file: %v", err)
+	}
+	otherFile := filepath.Join(workDir, "dev", "other.tf")
+	if err := os.WriteFile(otherFile, []byte("module \"other\" {\n  source  = \"registry.example.com/other-module/aws\"\n  version = \"1.0.0\"\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write tf file: %v", err)
+	}
+
+	if err := mainWithFlags([]string{"-config", configPath, "-dir", workDir, "-output", "yaml"}, workDir); err == nil {
+		t.Error("Expected error for unknown -output format, got nil")
+	}
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+	runErr := mainWithFlags([]string{"-config", configPath, "-dir", workDir, "-output", "json"}, workDir)
+	w.Close()
+	os.Stdout = stdout
+	if runErr != nil {
+		t.Fatalf("mainWithFlags failed: %v", runErr)
+	}
+
+	var report jsonReport
+	if err := json.NewDecoder(r).Decode(&report); err != nil {
+		t.Fatalf("stdout is not a JSON report: %v", err)
+	}
+	if len(report.Changes) != 1 {
+		t.Fatalf("got %d changes, want 1: %+v", len(report.Changes), report.Changes)
+	}
+	if c := report.Changes[0]; c.Name != "Test module" || c.OldVersion != "1.0.0" || c.NewVersion != "2.0.0" || c.Reason == "" || !c.DryRun {
+		t.Errorf("unexpected change record: %+v", c)
+	}
+	if len(report.Skips) != 1 {
+		t.Fatalf("got %d skips, want 1: %+v", len(report.Skips), report.Skips)
+	}
+	if s := report.Skips[0]; s.File != otherFile || s.Tier != "dev" || s.Reason != runner.SkipNoMatch {
+		t.Errorf("unexpected skip record: %+v", s)
+	}
+}
+
+func TestMainWithFlags_VerifyTiers(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("modules:\n  - source: \"test-module/aws\"\n    strategy: \"exact\"\n    versions:\n      dev: \"2.0.0\"\n      stg: \"2.0.0\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	workDir := filepath.Join(tmpDir, "work")
+	tfFile := filepath.Join(workDir, "dev", "main.tf")
+	if err := os.MkdirAll(filepath.Dir(tfFile), 0755); err != nil {
+		t.Fatalf("Failed to create tier directory: %v", err)
+	}
+	tfContent := "module \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"1.0.0\"\n}\n"
+	if err := os.WriteFile(tfFile, []byte(tfContent), 0644); err != nil {
+		t.Fatalf("Failed to write tf file: %v", err)
+	}
+
+	err := mainWithFlags([]string{"-config", configPath, "-dir", workDir, "-write", "-verify-tiers", "-strict"}, workDir)
+	if err == nil || !strings.Contains(err.Error(), "tier stg: tier directory not found") {
+		t.Fatalf("expected the missing stg directory to fail the run, got %v", err)
+	}
+	if data, _ := os.ReadFile(tfFile); string(data) != tfContent {
+		t.Errorf("expected dev/main.tf to be left unchanged, got:\n%s", data)
+	}
+
+	if err := mainWithFlags([]string{"-config", configPath, "-dir", workDir, "-write", "-verify-tiers"}, workDir); err != nil {
+		t.Fatalf("expected the missing directory to only warn without -strict, got %v", err)
+	}
+	if data, _ := os.ReadFile(tfFile); !strings.Contains(string(data), `version = "2.0.0"`) {
+		t.Errorf("expected dev/main.tf to be updated, got:\n%s", data)
+	}
+}
+
+func TestMainWithFlags_Progress(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("modules:\n  - source: \"test-module/aws\"\n    strategy: \"exact\"\n    versions:\n      dev: \"2.0.0\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	workDir := filepath.Join(tmpDir, "work")
+	tfFile := filepath.Join(workDir, "dev", "main.tf")
+	if err := os.MkdirAll(filepath.Dir(tfFile), 0755); err != nil {
+		t.Fatalf("Failed to create tier directory: %v", err)
+	}
+	if err := os.WriteFile(tfFile, []byte("module \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"1.0.0\"\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write tf file: %v", err)
+	}
+
+	stdout, stderr := os.Stdout, os.Stderr
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	os.Stdout, os.Stderr = outW, errW
+	runErr := mainWithFlags([]string{"-config", configPath, "-dir", workDir, "-output", "json", "-progress"}, workDir)
+	outW.Close()
+	errW.Close()
+	os.Stdout, os.Stderr = stdout, stderr
+	if runErr != nil {
+		t.Fatalf("mainWithFlags failed: %v", runErr)
+	}
+
+	// Progress goes to stderr, leaving stdout a JSON report only
+	out, _ := io.ReadAll(outR)
+	var report jsonReport
+	if err := json.Unmarshal(out, &report); err != nil {
+		t.Fatalf("stdout is not a JSON report: %v\n%s", err, out)
+	}
+	if len(report.Changes) != 1 {
+		t.Errorf("got %d changes, want 1: %+v", len(report.Changes), report.Changes)
+	}
+	progress, _ := io.ReadAll(errR)
+	if !strings.Contains(string(progress), "Progress: 1/1 files, done\n") {
+		t.Errorf("expected a progress line on stderr, got:\n%s", progress)
+	}
+}
+
+func TestBuildSARIF(t *testing.T) {
+	cfg := &config.Config{Modules: []config.ModuleConfig{
+		{Source: "test-module/aws", Strategy: version.StrategyExact, Versions: map[string]interface{}{"dev": "2.0.0"}},
+		{Source: "other-module/aws", Strategy: version.StrategyDynamic, Versions: map[string]interface{}{"dev": "1.0.0"}},
+		{Source: "range-module/aws", Strategy: version.StrategyDynamic, Versions: map[string]interface{}{"dev": "1.5.0"}},
+	}}
+
+	workDir := t.TempDir()
+	tfFile := filepath.Join(workDir, "dev", "main.tf")
+	content := `# modules
+module "drifted" {
+  source = "registry.example.com/test-module/aws"
+
+    version = "1.0.0"
+}
+
+module "protected" {
+  source  = "registry.example.com/other-module/aws"
+  version = "3.0.0"
+}
+
+module "current" {
+  source  = "registry.example.com/test-module/aws"
+  version = "2.0.0"
+}
+
+# the range contains its target, so it is neither drifted nor protected
+module "in_range" {
+  source  = "registry.example.com/range-module/aws"
+  version = ">= 1.0.0, < 2.0.0"
+}
+`
+	if err := os.MkdirAll(filepath.Dir(tfFile), 0755); err != nil {
+		t.Fatalf("Failed to create tier directory: %v", err)
+	}
+	if err := os.WriteFile(tfFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write tf file: %v", err)
+	}
+
+	result, err := runner.Run(cfg, workDir, runner.Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	report, err := formatSARIF(result, workDir)
+	if err != nil {
+		t.Fatalf("formatSARIF failed: %v", err)
+	}
+
+	// Decode generically so the test checks the document as code scanning reads it
+	var doc struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name  string `json:"name"`
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string                `json:"ruleId"`
+				Level     string                `json:"level"`
+				Message   struct{ Text string } `json:"message"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string } `json:"artifactLocation"`
+						Region           struct {
+							StartLine   int `json:"startLine"`
+							StartColumn int `json:"startColumn"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal([]byte(report), &doc); err != nil {
+		t.Fatalf("report is not JSON: %v", err)
+	}
+	if doc.Version != "2.1.0" || len(doc.Runs) != 1 || doc.Runs[0].Tool.Driver.Name != "hclsemver" || len(doc.Runs[0].Tool.Driver.Rules) != 2 {
+		t.Fatalf("unexpected SARIF document:\n%s", report)
+	}
+
+	type location struct {
+		rule, level, uri string
+		line, column     int
+	}
+	var got []location
+	for _, r := range doc.Runs[0].Results {
+		if len(r.Locations) != 1 || r.Message.Text == "" {
+			t.Fatalf("result without a single location or message: %+v", r)
+		}
+		loc := r.Locations[0].PhysicalLocation
+		got = append(got, location{r.RuleID, r.Level, loc.ArtifactLocation.URI, loc.Region.StartLine, loc.Region.StartColumn})
+	}
+	want := []location{
+		{ruleVersionDrift, "warning", "dev/main.tf", 5, 5},
+		{ruleVersionProtected, "note", "dev/main.tf", 10, 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got results %+v, want %+v", got, want)
+	}
+}
+
+func TestMainWithFlags_MultipleDirs(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("modules:\n  - source: \"test-module/aws\"\n    strategy: \"exact\"\n    versions:\n      dev: \"2.0.0\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	tfContent := "module \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"1.0.0\"\n}\n"
+	var roots []string
+	for _, root := range []string{"infra", "apps"} {
+		root = filepath.Join(tmpDir, root)
+		if err := os.MkdirAll(filepath.Join(root, "dev"), 0755); err != nil {
+			t.Fatalf("Failed to create tier directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(root, "dev", "main.tf"), []byte(tfContent), 0644); err != nil {
+			t.Fatalf("Failed to write tf file: %v", err)
+		}
+		roots = append(roots, root)
+	}
+
+	// The limit covers both directories together, so neither is written
+	err := mainWithFlags([]string{"-config", configPath, "-dir", roots[0], "-dir", roots[1], "-write", "-max-changes", "1"}, tmpDir)
+	if !errors.Is(err, runner.ErrTooManyChanges) {
+		t.Fatalf("expected ErrTooManyChanges, got %v", err)
+	}
+	for _, root := range roots {
+		if data, err := os.ReadFile(filepath.Join(root, "dev", "main.tf")); err != nil || string(data) != tfContent {
+			t.Errorf("expected %s to be untouched, got %v:\n%s", root, err, data)
+		}
+	}
+
+	if err := mainWithFlags([]string{"-config", configPath, "-dir", roots[0], "-dir", roots[1], "-write", "-max-changes", "2"}, tmpDir); err != nil {
+		t.Fatalf("mainWithFlags failed: %v", err)
+	}
+
+	for _, root := range roots {
+		data, err := os.ReadFile(filepath.Join(root, "dev", "main.tf"))
+		if err != nil {
+			t.Fatalf("Failed to read tf file: %v", err)
+		}
+		if !strings.Contains(string(data), `version = "2.0.0"`) {
+			t.Errorf("expected %s to be updated, got:\n%s", root, data)
+		}
+	}
+}
+
+func TestMainWithFlags_Metrics(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	// The stg range already contains its target, which is kept without counting as protection
+	configContent := `modules:
+  - source: "test-module/aws"
+    strategy: "exact"
+    versions:
+      dev: "2.0.0"
+      prod: "2.0.0"
+      stg:
+        version: "2.0.0"
+        strategy: "dynamic"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	workDir := filepath.Join(tmpDir, "work")
+	for tier, current := range map[string]string{"dev": "1.0.0", "prod": "3.0.0", "stg": ">= 1.0.0, < 3.0.0"} {
+		if err := os.MkdirAll(filepath.Join(workDir, tier), 0755); err != nil {
+			t.Fatalf("Failed to create tier directory: %v", err)
+		}
+		content := "module \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"" + current + "\"\n}\n"
+		if err := os.WriteFile(filepath.Join(workDir, tier, "main.tf"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write tf file: %v", err)
+		}
+	}
+
+	metricsPath := filepath.Join(tmpDir, "hclsemver.prom")
+	if err := mainWithFlags([]string{"-config", configPath, "-dir", workDir, "-metrics", metricsPath}, workDir); err != nil {
+		t.Fatalf("mainWithFlags failed: %v", err)
+	}
+
+	data, err := os.ReadFile(metricsPath)
+	if err != nil {
+		t.Fatalf("Failed to read metrics file: %v", err)
+	}
+
+	// Collect "name{labels} value" samples, checking every metric is declared as a gauge
+	samples := make(map[string]string)
+	gauges := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if name, ok := strings.CutPrefix(line, "# TYPE "); ok {
+			name, kind, _ := strings.Cut(name, " ")
+			gauges[name] = kind == "gauge"
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		series, value, ok := strings.Cut(line, " ")
+		if !ok {
+			t.Fatalf("malformed sample line %q", line)
+		}
+		name, _, _ := strings.Cut(series, "{")
+		if !gauges[name] {
+			t.Errorf("sample %q has no gauge TYPE line", line)
+		}
+		samples[series] = value
+	}
+
+	want := map[string]string{
+		`hclsemver_files_scanned{tier="dev"}`:      "1",
+		`hclsemver_files_scanned{tier="prod"}`:     "1",
+		`hclsemver_files_changed{tier="dev"}`:      "1",
+		`hclsemver_files_changed{tier="prod"}`:     "0",
+		`hclsemver_modules_protected{tier="dev"}`:  "0",
+		`hclsemver_modules_protected{tier="prod"}`: "1",
+		`hclsemver_files_changed{tier="stg"}`:      "0",
+		`hclsemver_modules_protected{tier="stg"}`:  "0",
+	}
+	for series, value := range want {
+		if samples[series] != value {
+			t.Errorf("%s = %q, want %q", series, samples[series], value)
+		}
+	}
+}
+
+func TestPrintEffective(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	content := `modules:
+  - source: "test-module/aws"
+    strategy: "range"
+    force: true
+    versions:
+      "*":
+        version: "2.0.0"
+        strategy: "dynamic"
+      dev: "3.0.0"
+      prod:
+        version: "1.5.0"
+        strategy: "exact"
+        force: false
+  - source: "other-module/aws"
+    versions:
+      dev: "1.0.0"
+  - source: "negated-module/aws"
+    versions:
+      "!prod": "2.5.0"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	var out strings.Builder
+	if err := printEffective(&out, cfg, outputJSON); err != nil {
+		t.Fatalf("printEffective failed: %v", err)
+	}
+	var entries []effectiveEntry
+	if err := json.Unmarshal([]byte(out.String()), &entries); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+
+	want := []effectiveEntry{
+		{Module: "test-module/aws", Tier: "*", Version: "2.0.0", Strategy: version.StrategyDynamic, Force: true},
+		{Module: "test-module/aws", Tier: "dev", Version: "3.0.0", Strategy: version.StrategyDynamic, Force: true},
+		{Module: "test-module/aws", Tier: "prod", Version: "1.5.0", Strategy: version.StrategyExact, Force: false},
+		{Module: "other-module/aws", Tier: "dev", Version: "1.0.0", Strategy: version.StrategyDynamic, Force: false},
+		{Module: "negated-module/aws", Tier: "!prod", Version: "2.5.0", Strategy: version.StrategyDynamic, Force: false},
+		{Module: "negated-module/aws", Tier: "dev", Version: "2.5.0", Strategy: version.StrategyDynamic, Force: false},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got %+v, want %+v", entries, want)
+	}
+
+	out.Reset()
+	if err := printEffective(&out, cfg, outputText); err != nil {
+		t.Fatalf("printEffective failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(want)+1 || !strings.HasPrefix(lines[0], "MODULE") {
+		t.Fatalf("unexpected table:\n%s", out.String())
+	}
+	if fields := strings.Fields(lines[3]); len(fields) != 5 || fields[1] != "prod" || fields[3] != "exact" {
+		t.Errorf("unexpected prod row %q", lines[3])
+	}
+
+	if err := mainWithFlags([]string{"-config", configPath, "-print-effective"}, filepath.Join(tmpDir, "missing")); err != nil {
+		t.Errorf("-print-effective failed: %v", err)
+	}
+}
+
+func TestPrintConfigDiff(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldPath := filepath.Join(tmpDir, "old.yaml")
+	newPath := filepath.Join(tmpDir, "new.yaml")
+	configs := map[string]string{
+		oldPath: `modules:
+  - source: "test-module/aws"
+    versions:
+      dev: "1.0.0"
+      prod: "1.0.0"
+`,
+		newPath: `modules:
+  - source: "test-module/aws"
+    versions:
+      dev: "1.1.0"
+      prod:
+        version: "1.0.0"
+        strategy: "exact"
+`,
+	}
+	for path, content := range configs {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+	}
+
+	var out strings.Builder
+	if err := printConfigDiff(&out, oldPath, newPath, config.FormatAuto, outputText); err != nil {
+		t.Fatalf("printConfigDiff failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "MODULE") {
+		t.Fatalf("unexpected table:\n%s", out.String())
+	}
+	if !strings.Contains(lines[1], "1.0.0 (dynamic, force=false)") || !strings.Contains(lines[1], "1.1.0 (dynamic, force=false)") {
+		t.Errorf("unexpected dev row %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "prod") || !strings.Contains(lines[2], "1.0.0 (exact, force=false)") {
+		t.Errorf("unexpected prod row %q", lines[2])
+	}
+
+	out.Reset()
+	if err := printConfigDiff(&out, oldPath, oldPath, config.FormatAuto, outputText); err != nil {
+		t.Fatalf("printConfigDiff failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "No differences") {
+		t.Errorf("expected no differences, got:\n%s", out.String())
+	}
+
+	if err := mainWithFlags([]string{"-output", "json", "-diff-config", oldPath, newPath}, tmpDir); err != nil {
+		t.Errorf("-diff-config failed: %v", err)
+	}
+	if err := mainWithFlags([]string{"-diff-config", oldPath}, tmpDir); err == nil {
+		t.Error("expected an error without the new config file")
+	}
+}
+
+func TestPrintExplain(t *testing.T) {
+	var out strings.Builder
+	if err := printExplain(&out, "2.0.0", "1.0.0", outputText); err != nil {
+		t.Fatalf("printExplain failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := [][]string{
+		{"STRATEGY", "RESULT", "REASON"},
+		{"dynamic", "2.0.0", "used target: not lower than existing version"},
+		{"range", ">= 2.0.0, < 3.0.0", "used target: range strategy"},
+		{"exact", "2.0.0", "used target: not lower than existing version"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("unexpected table:\n%s", out.String())
+	}
+	for i, fields := range want {
+		for _, field := range fields {
+			if !strings.Contains(lines[i], field) {
+				t.Errorf("row %d %q does not contain %q", i, lines[i], field)
+			}
+		}
+	}
+
+	outcomes := explainOutcomes(">= 2.0.0, < 3.0.0", "1.0.0")
+	if len(outcomes) != 3 || outcomes[2].Strategy != version.StrategyExact || outcomes[2].Error == "" {
+		t.Errorf("expected the exact strategy to reject a range target, got %+v", outcomes)
+	}
+
+	if err := mainWithFlags([]string{"-explain", "2.0.0"}, t.TempDir()); err == nil {
+		t.Error("expected an error without the existing version")
+	}
+}
+
+func TestMainWithFlags_PlanOutSelectedTiers(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	config := "modules:\n" +
+		"  - source: \"test-module/aws\"\n    strategy: \"exact\"\n    versions:\n      \"*\": \"2.0.0\"\n" +
+		"  - source: \"other-module/aws\"\n    strategy: \"exact\"\n    versions:\n      \"!qa\": \"3.0.0\"\n"
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	workDir := filepath.Join(tmpDir, "work")
+	tiers := []string{"dev", "prod", "stg"}
+	for _, tier := range tiers {
+		if err := os.MkdirAll(filepath.Join(workDir, tier), 0755); err != nil {
+			t.Fatalf("Failed to create tier directory: %v", err)
+		}
+		content := "module \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"1.0.0\"\n}\n" +
+			"module \"other\" {\n  source  = \"registry.example.com/other-module/aws\"\n  version = \"1.0.0\"\n}\n"
+		if err := os.WriteFile(filepath.Join(workDir, tier, "main.tf"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write tf file: %v", err)
+		}
+	}
+
+	// Selected tiers are processed in order, so repeated runs produce byte-identical plans
+	args := []string{"-config", configPath, "-dir", workDir, "-only-tier", "stg", "-only-tier", "prod", "-only-tier", "dev"}
+	var first []byte
+	for i := 0; i < 8; i++ {
+		planPath := filepath.Join(tmpDir, fmt.Sprintf("plan%d.json", i))
+		if err := mainWithFlags(append(args, "-plan-out", planPath), workDir); err != nil {
+			t.Fatalf("mainWithFlags failed: %v", err)
+		}
+		data, err := os.ReadFile(planPath)
+		if err != nil {
+			t.Fatalf("Failed to read plan: %v", err)
+		}
+		if first == nil {
+			first = data
+		} else if string(data) != string(first) {
+			t.Fatalf("plans of identical runs differ:\n%s\n---\n%s", first, data)
+		}
+	}
+
+	var p plan
+	if err := json.Unmarshal(first, &p); err != nil {
+		t.Fatalf("plan does not decode: %v", err)
+	}
+	if len(p.Changes) != 6 {
+		t.Errorf("expected 6 plan changes, got %+v", p.Changes)
+	}
+}
+
+func TestMainWithFlags_PlanOut(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("modules:\n  - source: \"test-module/aws\"\n    strategy: \"exact\"\n    versions:\n      dev: \"2.0.0\"\n      prod: \"2.0.0\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	workDir := filepath.Join(tmpDir, "work")
+	for tier, current := range map[string]string{"dev": "1.0.0", "prod": "1.5.0"} {
+		if err := os.MkdirAll(filepath.Join(workDir, tier), 0755); err != nil {
+			t.Fatalf("Failed to create tier directory: %v", err)
+		}
+		content := "module \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"" + current + "\"\n}\n"
+		if err := os.WriteFile(filepath.Join(workDir, tier, "main.tf"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write tf file: %v", err)
+		}
+	}
+
+	// Two dry runs over the same files must produce byte-identical plans
+	var plans [2][]byte
+	for i := range plans {
+		planPath := filepath.Join(tmpDir, fmt.Sprintf("plan%d.json", i))
+		if err := mainWithFlags([]string{"-config", configPath, "-dir", workDir, "-plan-out", planPath}, workDir); err != nil {
+			t.Fatalf("mainWithFlags failed: %v", err)
+		}
+		data, err := os.ReadFile(planPath)
+		if err != nil {
+			t.Fatalf("Failed to read plan: %v", err)
+		}
+		plans[i] = data
+	}
+	if string(plans[0]) != string(plans[1]) {
+		t.Errorf("plans of identical runs differ:\n%s\n---\n%s", plans[0], plans[1])
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(plans[0], &doc); err != nil {
+		t.Fatalf("plan is not JSON: %v", err)
+	}
+	var keys []string
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if want := []string{"changes", "summary", "version"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("plan keys = %v, want %v", keys, want)
+	}
+
+	var p plan
+	if err := json.Unmarshal(plans[0], &p); err != nil {
+		t.Fatalf("plan does not decode: %v", err)
+	}
+	if p.Version != planFormatVersion || p.Summary.Changes != 2 || p.Summary.FilesScanned != 2 || len(p.Changes) != 2 {
+		t.Errorf("unexpected plan: %+v", p)
+	}
+	if p.Changes[0].Tier != "dev" || p.Changes[1].Tier != "prod" || p.Changes[1].OldVersion != "1.5.0" {
+		t.Errorf("unexpected plan changes: %+v", p.Changes)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/david1155/hclsemver/pkg/runner"
+)
+
+// metric is one gauge of the metrics file, read from a tier summary
+type metric struct {
+	name  string
+	help  string
+	value func(runner.TierSummary) int
+}
+
+var metrics = []metric{
+	{"hclsemver_files_scanned", "Terraform files scanned in the last run.", func(s runner.TierSummary) int { return s.FilesScanned }},
+	{"hclsemver_files_changed", "Terraform files changed, or previewed in dry-run, in the last run.", func(s runner.TierSummary) int { return s.FilesChanged }},
+	{"hclsemver_modules_protected", "Module blocks whose existing version was kept by backward protection in the last run.", func(s runner.TierSummary) int { return s.ModulesProtected }},
+}
+
+// addSummary adds the counts of one run to total
+func addSummary(total, summary map[string]runner.TierSummary) {
+	for tier, s := range summary {
+		t := total[tier]
+		t.FilesScanned += s.FilesScanned
+		t.FilesChanged += s.FilesChanged
+		t.ModulesProtected += s.ModulesProtected
+		total[tier] = t
+	}
+}
+
+// formatMetrics renders the tier summaries as gauges in the Prometheus text format,
+// labelled by tier
+func formatMetrics(summary map[string]runner.TierSummary) string {
+	tiers := make([]string, 0, len(summary))
+	for tier := range summary {
+		tiers = append(tiers, tier)
+	}
+	sort.Strings(tiers)
+
+	var b strings.Builder
+	for _, m := range metrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", m.name)
+		for _, tier := range tiers {
+			fmt.Fprintf(&b, "%s{tier=%q} %d\n", m.name, tier, m.value(summary[tier]))
+		}
+	}
+	return b.String()
+}
+
+// writeMetrics writes the metrics file for a node exporter textfile collector. The file is
+// replaced through a rename so a scrape never reads a partial file.
+func writeMetrics(path string, summary map[string]runner.TierSummary) error {
+	if err := writeFileAtomic(path, []byte(formatMetrics(summary))); err != nil {
+		return fmt.Errorf("error writing metrics: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to path through a temporary file in the same directory
+// and a rename, so readers see either the old or the new contents
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/david1155/hclsemver/pkg/runner"
+	"github.com/david1155/hclsemver/pkg/version"
+)
+
+// planFormatVersion is the version of the plan document. It is raised whenever a key is
+// removed or its meaning changes; new keys may be added without raising it.
+const planFormatVersion = 1
+
+// plan is the document written with -plan-out: the changes of a run in a stable order,
+// so plans of two runs can be diffed
+type plan struct {
+	Version int          `json:"version"`
+	Summary planSummary  `json:"summary"`
+	Changes []planChange `json:"changes"`
+}
+
+// planSummary counts the changes, files and problems of the run
+type planSummary struct {
+	Changes          int                    `json:"changes"`
+	FilesScanned     int                    `json:"files_scanned"`
+	FilesChanged     int                    `json:"files_changed"`
+	ModulesProtected int                    `json:"modules_protected"`
+	Errors           int                    `json:"errors"`
+	Warnings         int                    `json:"warnings"`
+	Tiers            map[string]planCounter `json:"tiers"`
+}
+
+// planCounter counts the files and modules of one tier
+type planCounter struct {
+	FilesScanned     int `json:"files_scanned"`
+	FilesChanged     int `json:"files_changed"`
+	ModulesProtected int `json:"modules_protected"`
+}
+
+// planChange is one module version change
+type planChange struct {
+	File       string           `json:"file"`
+	Line       int              `json:"line"`
+	Column     int              `json:"column"`
+	Source     string           `json:"source"`
+	Name       string           `json:"name,omitempty"`
+	Tier       string           `json:"tier"`
+	OldVersion string           `json:"old_version"`
+	NewVersion string           `json:"new_version"`
+	Strategy   version.Strategy `json:"strategy"`
+	Reason     string           `json:"reason"`
+}
+
+// buildPlan converts a run result into a plan, ordering changes by file, source and tier
+func buildPlan(result runner.RunResult) plan {
+	p := plan{
+		Version: planFormatVersion,
+		Summary: planSummary{
+			Changes:  len(result.Changes),
+			Errors:   len(result.Errors),
+			Warnings: len(result.Warnings) + len(result.ModuleWarnings),
+			Tiers:    make(map[string]planCounter, len(result.Summary)),
+		},
+		Changes: make([]planChange, 0, len(result.Changes)),
+	}
+
+	for tier, s := range result.Summary {
+		p.Summary.FilesScanned += s.FilesScanned
+		p.Summary.FilesChanged += s.FilesChanged
+		p.Summary.ModulesProtected += s.ModulesProtected
+		p.Summary.Tiers[tier] = planCounter{FilesScanned: s.FilesScanned, FilesChanged: s.FilesChanged, ModulesProtected: s.ModulesProtected}
+	}
+
+	for _, c := range result.Changes {
+		p.Changes = append(p.Changes, planChange{
+			File:       c.File,
+			Line:       c.Line,
+			Column:     c.Column,
+			Source:     c.Source,
+			Name:       c.Name,
+			Tier:       c.Tier,
+			OldVersion: c.OldVersion,
+			NewVersion: c.NewVersion,
+			Strategy:   c.Strategy,
+			Reason:     c.Reason,
+		})
+	}
+	sort.SliceStable(p.Changes, func(i, j int) bool {
+		a, b := p.Changes[i], p.Changes[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return a.Tier < b.Tier
+	})
+	return p
+}
+
+// writePlan writes the plan of a run to path, replacing any previous plan
+func writePlan(path string, result runner.RunResult) error {
+	data, err := json.MarshalIndent(buildPlan(result), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding plan: %w", err)
+	}
+	if err := writeFileAtomic(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing plan: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/david1155/hclsemver/pkg/runner"
+)
+
+// SARIF rules, one per kind of result
+const (
+	ruleVersionDrift     = "hclsemver/version-drift"
+	ruleVersionProtected = "hclsemver/version-protected"
+)
+
+// sarifLog is the SARIF 2.1.0 document printed with -output sarif, with one result per
+// module block whose version drifts from the config or is kept by backward protection
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+}
+
+// buildSARIF converts the decisions of a run into a SARIF log. File locations are made
+// relative to baseDir, which code scanning resolves against the repository root.
+func buildSARIF(result runner.RunResult, baseDir string) sarifLog {
+	results := []sarifResult{}
+	for _, d := range result.Decisions {
+		var r sarifResult
+		switch {
+		case d.Protected():
+			r = sarifResult{
+				RuleID:  ruleVersionProtected,
+				Level:   "note",
+				Message: sarifMessage{Text: fmt.Sprintf("Module %q in tier %s keeps version %q: %s", d.Source, d.Tier, d.OldVersion, d.Reason)},
+			}
+		case d.Changed():
+			r = sarifResult{
+				RuleID:  ruleVersionDrift,
+				Level:   "warning",
+				Message: sarifMessage{Text: fmt.Sprintf("Module %q in tier %s is at version %q; the config sets %q: %s", d.Source, d.Tier, d.OldVersion, d.NewVersion, d.Reason)},
+			}
+		default:
+			continue
+		}
+		r.Locations = []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: sarifURI(d.File, baseDir)},
+			Region:           sarifRegion{StartLine: d.Line, StartColumn: d.Column},
+		}}}
+		results = append(results, r)
+	}
+
+	return sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "hclsemver",
+				InformationURI: "https://github.com/david1155/hclsemver",
+				Rules: []sarifRule{
+					{ID: ruleVersionDrift, ShortDescription: sarifMessage{Text: "Module version differs from the configured version"}},
+					{ID: ruleVersionProtected, ShortDescription: sarifMessage{Text: "Module version kept by backward protection"}},
+				},
+			}},
+			Results: results,
+		}},
+	}
+}
+
+// sarifURI returns file relative to baseDir with forward slashes, or as a file URI when
+// it lies outside baseDir
+func sarifURI(file, baseDir string) string {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return filepath.ToSlash(file)
+	}
+	if rel, err := filepath.Rel(baseDir, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(rel)
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+}
+
+// formatSARIF renders the SARIF log of a run
+func formatSARIF(result runner.RunResult, baseDir string) (string, error) {
+	data, err := json.MarshalIndent(buildSARIF(result, baseDir), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error encoding SARIF report: %w", err)
+	}
+	return string(data), nil
+}
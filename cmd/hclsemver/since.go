@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// changedFiles returns the .tf files, and with terragrunt the terragrunt.hcl files, under
+// dirs that differ from ref in git, including uncommitted changes. It reports false, without an error, when a directory is not inside
+// a git work tree or git is not installed, since the run cannot then be narrowed.
+func changedFiles(ref string, dirs []string, terragrunt bool) ([]string, bool, error) {
+	files := []string{}
+	for _, dir := range dirs {
+		if err := exec.Command("git", "-C", dir, "rev-parse", "--is-inside-work-tree").Run(); err != nil {
+			return nil, false, nil
+		}
+
+		var stderr bytes.Buffer
+		cmd := exec.Command("git", "-C", dir, "diff", "--name-only", "--relative", "-z", ref, "--")
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, true, fmt.Errorf("error listing files changed since %s in %s: %s", ref, dir, strings.TrimSpace(stderr.String()))
+		}
+
+		// --relative lists paths relative to dir, leaving out those outside it
+		for _, name := range strings.Split(string(out), "\x00") {
+			if strings.HasSuffix(name, ".tf") || (terragrunt && path.Base(name) == "terragrunt.hcl") {
+				files = append(files, filepath.Join(dir, filepath.FromSlash(name)))
+			}
+		}
+	}
+	return files, true, nil
+}
+
+// intersectFiles returns the files of a that are also in b, in the order of a
+func intersectFiles(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, file := range b {
+		if abs, err := filepath.Abs(file); err == nil {
+			in[abs] = true
+		}
+	}
+
+	files := []string{}
+	for _, file := range a {
+		if abs, err := filepath.Abs(file); err == nil && in[abs] {
+			files = append(files, file)
+		}
+	}
+	return files
+}
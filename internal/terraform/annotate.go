@@ -0,0 +1,61 @@
+package terraform
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/david1155/hclsemver/pkg/version"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// AnnotationMarker starts the comment Options.Annotate adds to the attributes it writes;
+// a trailing comment holding it is rewritten instead of being annotated again
+const AnnotationMarker = "managed by hclsemver"
+
+// annotation is the comment for an attribute written by strategy, or empty without Annotate
+func (o Options) annotation(strategy version.Strategy) string {
+	if !o.Annotate {
+		return ""
+	}
+	return fmt.Sprintf("%s (%s strategy)", AnnotationMarker, strategy)
+}
+
+// annotateAttribute sets the trailing comment of the named attribute of body to "# text".
+// An annotation left by an earlier run is replaced, and any other trailing comment is
+// kept with the annotation appended to it; comments on the lines above are left alone.
+func annotateAttribute(body *hclwrite.Body, name, text string) {
+	attr := body.GetAttribute(name)
+	if attr == nil {
+		return
+	}
+	expr := attr.Expr().BuildTokens(nil)
+	if len(expr) == 0 {
+		return
+	}
+
+	// The line comment, if any, directly follows the expression
+	tokens := attr.BuildTokens(nil)
+	for i, tok := range tokens {
+		if tok != expr[len(expr)-1] {
+			continue
+		}
+		if i+1 < len(tokens) && tokens[i+1].Type == hclsyntax.TokenComment {
+			comment := tokens[i+1]
+			existing := strings.TrimRight(string(comment.Bytes), "\r\n")
+			newline := string(comment.Bytes[len(existing):])
+			if at := strings.Index(existing, AnnotationMarker); at >= 0 {
+				existing = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(existing[:at]), "#"))
+			}
+			if existing != "" {
+				existing += " "
+			}
+			comment.Bytes = []byte(existing + "# " + text + newline)
+			return
+		}
+		break
+	}
+
+	comment := &hclwrite.Token{Type: hclsyntax.TokenComment, Bytes: []byte("# " + text), SpacesBefore: 1}
+	body.SetAttributeRaw(name, append(expr, comment))
+}
@@ -0,0 +1,155 @@
+package terraform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// ModuleVersion is the version or range currently set on a module block
+type ModuleVersion struct {
+	File    string
+	Source  string
+	Version string
+}
+
+// ReadModuleVersions returns the version of every module block in filename whose source
+// matches oldSourceSubstr. Git sources without a version attribute report their ref;
+// modules without either, versions that are not string literals, local sources and
+// interpolated sources are left out.
+func ReadModuleVersions(filename, oldSourceSubstr string) ([]ModuleVersion, error) {
+	return readModuleVersions(filename, oldSourceSubstr, Options{OnWarning: func(Warning) {}})
+}
+
+// readModuleVersions implements ReadModuleVersions, matching interpolated sources and
+// reporting them as opts asks
+func readModuleVersions(filename, oldSourceSubstr string, opts Options) ([]ModuleVersion, error) {
+	blocks, err := readModuleBlocks(filename, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []ModuleVersion
+	for _, block := range blocks {
+		literal, _, ok := moduleSource(block, filename, oldSourceSubstr, opts)
+		if !ok || isLocalSource(literal) {
+			continue
+		}
+		gitSource := isGitSource(literal)
+		if block.Type() == "terraform" && !gitSource {
+			continue
+		}
+
+		if versionAttr := block.Body().GetAttribute("version"); versionAttr != nil {
+			current, ok := stringLiteral(versionAttr.Expr())
+			if !ok {
+				continue
+			}
+			versions = append(versions, ModuleVersion{File: filename, Source: literal, Version: current})
+			continue
+		}
+
+		if gitSource {
+			if ref, ok := gitSourceRef(literal); ok {
+				versions = append(versions, ModuleVersion{File: filename, Source: literal, Version: ref})
+			}
+		}
+	}
+	return versions, nil
+}
+
+// readModuleBlocks parses filename and returns its module blocks, including the terraform
+// blocks of Terragrunt files when opts asks for them
+func readModuleBlocks(filename string, opts Options) ([]*hclwrite.Block, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRead, err)
+	}
+
+	file, diags := hclwrite.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("%w %s: %s", ErrParse, filename, diags.Error())
+	}
+
+	var blocks []*hclwrite.Block
+	for _, block := range file.Body().Blocks() {
+		if opts.isModuleBlock(block) {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks, nil
+}
+
+// ReadModules returns every module block in filename that has a source, whether or not any
+// config matches it. Version is the version attribute, or for a git source without one its
+// ref; it is empty when the module has neither or its version is not a string literal.
+func ReadModules(filename string) ([]ModuleVersion, error) {
+	blocks, err := readModuleBlocks(filename, Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	var modules []ModuleVersion
+	for _, block := range blocks {
+		sourceAttr := block.Body().GetAttribute("source")
+		if sourceAttr == nil {
+			continue
+		}
+		source, ok := stringLiteral(sourceAttr.Expr())
+		if !ok {
+			source = strings.TrimSpace(string(sourceAttr.Expr().BuildTokens(nil).Bytes()))
+		}
+
+		module := ModuleVersion{File: filename, Source: source}
+		if versionAttr := block.Body().GetAttribute("version"); versionAttr != nil {
+			module.Version, _ = stringLiteral(versionAttr.Expr())
+		} else if isGitSource(source) {
+			module.Version, _ = gitSourceRef(source)
+		}
+		modules = append(modules, module)
+	}
+	return modules, nil
+}
+
+// FindModuleVersions walks workDir like ScanAndUpdateModules and returns the versions of
+// matching modules without modifying any file. Unparseable files are skipped with a warning.
+func FindModuleVersions(workDir, oldSourceSubstr string, configTiers map[string]bool, opts Options) ([]ModuleVersion, error) {
+	return findModules(workDir, configTiers, opts, func(path string) ([]ModuleVersion, error) {
+		return readModuleVersions(path, oldSourceSubstr, opts)
+	})
+}
+
+// FindModules walks workDir like ScanAndUpdateModules and returns every module block found,
+// as ReadModules does, without modifying any file. Unparseable files are skipped with a warning.
+func FindModules(workDir string, opts Options) ([]ModuleVersion, error) {
+	return findModules(workDir, nil, opts, ReadModules)
+}
+
+// findModules collects the modules read from each Terraform file of a matching tier
+func findModules(workDir string, configTiers map[string]bool, opts Options, read func(path string) ([]ModuleVersion, error)) ([]ModuleVersion, error) {
+	var versions []ModuleVersion
+	err := visitTerraformFiles(workDir, opts, func(path string) error {
+		if !ShouldProcessTier(path, configTiers) {
+			return nil
+		}
+
+		found, err := read(path)
+		if err != nil {
+			if !errors.Is(err, ErrParse) {
+				return fmt.Errorf("error reading file %s: %w", path, err)
+			}
+			fmt.Fprintf(opts.output(), "Warning: %v\n", err)
+			if opts.OnSkip != nil {
+				opts.OnSkip(err)
+			}
+			return nil
+		}
+		versions = append(versions, found...)
+		return nil
+	})
+	return versions, err
+}
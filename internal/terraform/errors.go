@@ -0,0 +1,24 @@
+package terraform
+
+import "errors"
+
+// Sentinel errors wrapped by UpdateModuleVersionInFile, so callers can tell failures
+// apart with errors.Is
+var (
+	// ErrRead is returned when a file cannot be read
+	ErrRead = errors.New("cannot read file")
+	// ErrParse is returned when a file is not valid HCL
+	ErrParse = errors.New("cannot parse file")
+	// ErrStrategy is returned when the version strategy cannot be applied to a module
+	ErrStrategy = errors.New("cannot apply version strategy")
+	// ErrMissingVersion is returned for a module without a version attribute when
+	// OnMissingVersion is "error"
+	ErrMissingVersion = errors.New("module has no version attribute")
+	// ErrInvalidVersion is returned for a module whose version is not a valid version or
+	// range when OnInvalidExisting is "error"
+	ErrInvalidVersion = errors.New("module has an invalid version")
+	// ErrBackup is returned when the backup of a file cannot be written
+	ErrBackup = errors.New("cannot write backup")
+	// ErrWrite is returned when an updated file cannot be written
+	ErrWrite = errors.New("cannot write file")
+)
@@ -0,0 +1,184 @@
+package terraform
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/david1155/hclsemver/pkg/version"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// gitSourcePrefixes are the source prefixes Terraform treats as git repositories
+var gitSourcePrefixes = []string{"git::", "git@", "github.com/", "bitbucket.org/"}
+
+// isGitSource reports whether a module source points at a git repository,
+// where the version is carried by the "ref" query parameter instead of a version attribute
+func isGitSource(source string) bool {
+	for _, prefix := range gitSourcePrefixes {
+		if strings.HasPrefix(source, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalSource reports whether a module source is a local path, which Terraform never versions
+func isLocalSource(source string) bool {
+	return strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")
+}
+
+// splitGitQuery splits a git source into the part before the query string and its parameters
+func splitGitQuery(source string) (string, []string) {
+	idx := strings.LastIndex(source, "?")
+	if idx < 0 {
+		return source, nil
+	}
+	return source[:idx], strings.Split(source[idx+1:], "&")
+}
+
+// gitSourceRef returns the value of the "ref" parameter of a git source, if present
+func gitSourceRef(source string) (string, bool) {
+	_, params := splitGitQuery(source)
+	for _, param := range params {
+		if ref, ok := strings.CutPrefix(param, "ref="); ok {
+			return ref, true
+		}
+	}
+	return "", false
+}
+
+// setGitSourceRef returns source with the "ref" parameter replaced by ref
+func setGitSourceRef(source, ref string) string {
+	base, params := splitGitQuery(source)
+	for i, param := range params {
+		if strings.HasPrefix(param, "ref=") {
+			params[i] = "ref=" + ref
+		}
+	}
+	return base + "?" + strings.Join(params, "&")
+}
+
+// gitMatchSource returns the form of a git source used for pattern matching: the
+// query string is dropped and ".git" suffixes are trimmed from path segments, so
+// "git::https://example.com/org/vpc.git//modules/vpc?ref=v1.0.0" matches "org/vpc//modules/vpc"
+// as well as "modules/vpc"
+func gitMatchSource(source string) string {
+	base, _ := splitGitQuery(source)
+	parts := strings.Split(base, "/")
+	for i, part := range parts {
+		parts[i] = strings.TrimSuffix(part, ".git")
+	}
+	return strings.Join(parts, "/")
+}
+
+// updateGitRef applies the strategy to the semver tag in a git source's ref parameter.
+// A ref must name a single tag, so when the strategy yields a range the existing ref is
+// kept if it satisfies the range and the module is skipped with a warning otherwise.
+// Frozen refs are left alone, and the target is resolved from the ref like a version
+// attribute. sourceValue is the form of source used for pattern matching.
+// It returns whether the source was changed along with the old and new refs and the
+// strategy's reason, and an error wrapping ErrStrategy when the strategy fails.
+func updateGitRef(block *hclwrite.Block, source, sourceValue, filename string, pos hcl.Pos, newInput string, strategy version.Strategy, opts Options) (bool, string, string, string, error) {
+	oldRef, ok := gitSourceRef(source)
+	if !ok {
+		opts.warn(Warning{Source: source, File: filename, Reason: "is a git source without a ref parameter"})
+		return false, "", "", "", nil
+	}
+
+	oldVer, err := semver.NewVersion(oldRef)
+	if err != nil {
+		opts.warn(Warning{Source: source, File: filename, Reason: fmt.Sprintf("has non-semver git ref %q", oldRef)})
+		return false, oldRef, "", "", nil
+	}
+
+	// A freeze may name the tag with or without its "v"
+	if opts.isFrozen(filename, sourceValue, oldRef) || opts.isFrozen(filename, sourceValue, oldVer.String()) {
+		fmt.Fprintf(opts.output(), "Module %q in file %s is frozen at version %s. Skipping.\n", sourceValue, filename, oldRef)
+		opts.skipped(Skip{File: filename, Source: source, Reason: SkipFrozen})
+		return false, oldRef, "", "", nil
+	}
+
+	target := newInput
+	if opts.ResolveTarget != nil {
+		if target, err = opts.ResolveTarget(oldVer.String()); err != nil {
+			return false, oldRef, "", "", fmt.Errorf("%w for module %q in file %s: %w", ErrStrategy, source, filename, err)
+		}
+	}
+
+	result, reason, err := version.ApplyVersionStrategyWithReason(strategy, target, oldVer.String(), opts.StrategyOptions)
+	if err != nil {
+		return false, oldRef, "", "", fmt.Errorf("%w for module %q in file %s: %w", ErrStrategy, source, filename, err)
+	}
+	opts.decided(Decision{File: filename, Source: source, Line: pos.Line, Column: pos.Column, OldVersion: oldRef, NewVersion: result, Reason: reason})
+
+	newVer, err := semver.NewVersion(result)
+	if err != nil {
+		expanded, cerr := version.ExpandTerraformTildeArrow(result)
+		var constr *semver.Constraints
+		if cerr == nil {
+			constr, cerr = semver.NewConstraint(expanded)
+		}
+		if cerr != nil || !constr.Check(oldVer) {
+			opts.warn(Warning{Source: source, File: filename, Reason: fmt.Sprintf("cannot pin its git ref to range %q", result)})
+			return false, oldRef, "", "", nil
+		}
+		newVer = oldVer
+	}
+
+	// Keep the tag style of the existing ref, e.g. a leading "v"
+	newRef := newVer.String()
+	if strings.HasPrefix(oldRef, "v") {
+		newRef = "v" + newRef
+	}
+	if newRef == oldRef {
+		return false, oldRef, newRef, reason, nil
+	}
+
+	block.Body().SetAttributeValue("source", cty.StringVal(setGitSourceRef(source, newRef)))
+	if opts.Annotate {
+		annotateAttribute(block.Body(), "source", opts.annotation(strategy))
+	}
+	return true, oldRef, newRef, reason, nil
+}
+
+// reconcileSourceRef checks the semver ref in the source of a module that also has a
+// version attribute against versionValue, the version now set on that attribute. A ref that
+// disagrees is reported with a warning or, with SyncSourceRef and an exact version, set to
+// that version in the tag style of the existing ref. Non-semver refs such as "main" are not
+// compared. It returns whether the source was changed.
+func reconcileSourceRef(block *hclwrite.Block, source, filename, versionValue string, opts Options) bool {
+	ref, ok := gitSourceRef(source)
+	if !ok {
+		return false
+	}
+	refVer, err := semver.NewVersion(ref)
+	if err != nil {
+		return false
+	}
+	isVer, ver, constr, err := version.ParseVersionOrRange(versionValue)
+	if err != nil {
+		return false
+	}
+	if (isVer && ver.Equal(refVer)) || (!isVer && constr.Check(refVer)) {
+		return false
+	}
+
+	if !opts.SyncSourceRef {
+		opts.warn(Warning{Source: source, File: filename, Reason: fmt.Sprintf("has source ref %q that disagrees with version %q; set sync_source_ref to update both", ref, versionValue)})
+		return false
+	}
+	if !isVer {
+		opts.warn(Warning{Source: source, File: filename, Reason: fmt.Sprintf("cannot sync its source ref %q to range %q", ref, versionValue)})
+		return false
+	}
+
+	newRef := ver.String()
+	if strings.HasPrefix(ref, "v") {
+		newRef = "v" + newRef
+	}
+	block.Body().SetAttributeValue("source", cty.StringVal(setGitSourceRef(source, newRef)))
+	return true
+}
@@ -0,0 +1,67 @@
+package terraform
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// Comment markers that opt Terraform code out of updates regardless of config
+const (
+	// IgnoreMarker in a comment directly above a module block leaves that block untouched
+	IgnoreMarker = "hclsemver:ignore"
+	// IgnoreFileMarker in a comment at the top of a file leaves the whole file untouched
+	IgnoreFileMarker = "hclsemver:ignore-file"
+)
+
+// commentHasMarker reports whether a comment consists of marker, optionally followed by
+// an explanation, e.g. "# hclsemver:ignore pinned until the migration is done"
+func commentHasMarker(comment, marker string) bool {
+	text := strings.TrimSpace(comment)
+	for _, prefix := range []string{"#", "//", "/*"} {
+		if rest, ok := strings.CutPrefix(text, prefix); ok {
+			text = strings.TrimSpace(strings.TrimSuffix(rest, "*/"))
+			break
+		}
+	}
+	rest, ok := strings.CutPrefix(text, marker)
+	return ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t')
+}
+
+// fileHasIgnoreMarker reports whether the comments at the top of src, before any other
+// content, include IgnoreFileMarker
+func fileHasIgnoreMarker(src []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "//") && !strings.HasPrefix(line, "/*") {
+			return false
+		}
+		if commentHasMarker(line, IgnoreFileMarker) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockHasIgnoreMarker reports whether the comments directly above block include IgnoreMarker
+func blockHasIgnoreMarker(block *hclwrite.Block) bool {
+	for _, token := range block.BuildTokens(nil) {
+		switch token.Type {
+		case hclsyntax.TokenComment:
+			if commentHasMarker(string(token.Bytes), IgnoreMarker) {
+				return true
+			}
+		case hclsyntax.TokenNewline:
+		default:
+			return false
+		}
+	}
+	return false
+}
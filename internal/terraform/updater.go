@@ -1,20 +1,30 @@
 package terraform
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 
-	"github.com/Masterminds/semver/v3"
 	"github.com/david1155/hclsemver/pkg/version"
 	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/zclconf/go-cty/cty"
 )
 
-// ShouldProcessTier determines if a given path should be processed based on the config tiers
+// ShouldProcessTier determines if a given path should be processed based on the config tiers.
+// A tier may also be a directory path such as "environments/production", which matches paths
+// containing those consecutive directories, and a tier pattern such as "region-*" paths with
+// directories it matches as a glob, after the other specific tiers. A negated tier such as
+// "!prod" matches paths outside every negated tier, after the specific tiers and before the
+// wildcard. Paths and tiers may separate directories with / or \ on any platform.
 func ShouldProcessTier(path string, configTiers map[string]bool) bool {
 	// If no tiers are configured, process all files
 	if len(configTiers) == 0 {
@@ -22,12 +32,24 @@ func ShouldProcessTier(path string, configTiers map[string]bool) bool {
 	}
 
 	// Extract potential tier from path
-	parts := strings.Split(path, string(os.PathSeparator))
+	parts := strings.Split(slashPath(path), "/")
+
+	// Directory path tiers match whole consecutive segments, tier patterns after the others
+	for _, patterns := range []bool{false, true} {
+		for tier := range configTiers {
+			if strings.HasPrefix(tier, "!") || isTierPattern(tier) != patterns {
+				continue
+			}
+			if tierParts := tierSegments(tier); len(tierParts) > 1 && containsSegments(parts, tierParts) {
+				return configTiers[tier]
+			}
+		}
+	}
 
 	// First check for specific tier matches
 	for _, part := range parts {
 		for tier := range configTiers {
-			if tier == "*" {
+			if tier == "*" || strings.HasPrefix(tier, "!") || isTierPattern(tier) || len(tierSegments(tier)) > 1 {
 				continue
 			}
 			// Check if tier is a directory name or part of the filename
@@ -37,6 +59,33 @@ func ShouldProcessTier(path string, configTiers map[string]bool) bool {
 		}
 	}
 
+	// Then for tier patterns such as "region-*", which match whole directory names
+	for _, part := range parts {
+		for tier := range configTiers {
+			if strings.HasPrefix(tier, "!") || !isTierPattern(tier) || len(tierSegments(tier)) > 1 {
+				continue
+			}
+			if segmentMatches(part, tier) {
+				return configTiers[tier]
+			}
+		}
+	}
+
+	// Then check for negated tiers, which apply to paths outside all of them
+	negated, outside, process := false, true, false
+	for tier, value := range configTiers {
+		if excluded, ok := strings.CutPrefix(tier, "!"); ok {
+			negated = true
+			process = process || value
+			if pathInTier(parts, excluded) {
+				outside = false
+			}
+		}
+	}
+	if negated && outside {
+		return process
+	}
+
 	// If we have only "*" configured, use its value
 	if len(configTiers) == 1 && configTiers["*"] {
 		return true
@@ -52,63 +101,571 @@ func ShouldProcessTier(path string, configTiers map[string]bool) bool {
 	return false
 }
 
+// pathInTier reports whether the path split into parts lies in tier, matched the way
+// ShouldProcessTier matches specific tiers
+func pathInTier(parts []string, tier string) bool {
+	if tierParts := tierSegments(tier); len(tierParts) > 1 {
+		return containsSegments(parts, tierParts)
+	}
+	for _, part := range parts {
+		if strings.Contains(part, tier) {
+			return true
+		}
+	}
+	return false
+}
+
+// slashPath returns path with its directories separated by /. Backslashes are replaced as
+// well, since filepath.ToSlash leaves them alone outside Windows, where paths written for
+// Windows then still split into the same directories.
+func slashPath(path string) string {
+	return strings.ReplaceAll(filepath.ToSlash(path), `\`, "/")
+}
+
+// tierSegments splits a tier into its directories, one for a tier such as "prod" and
+// several for a directory path tier such as "environments/production"
+func tierSegments(tier string) []string {
+	return strings.Split(filepath.ToSlash(filepath.Clean(slashPath(tier))), "/")
+}
+
+// isTierPattern reports whether tier is a glob pattern such as "region-*"; the wildcard
+// tier "*" is not one
+func isTierPattern(tier string) bool {
+	return tier != "*" && strings.ContainsAny(tier, "*?[")
+}
+
+// segmentMatches reports whether the path segment part is segment, or is matched by it
+// when segment is a glob pattern
+func segmentMatches(part, segment string) bool {
+	if !strings.ContainsAny(segment, "*?[") {
+		return part == segment
+	}
+	matched, err := path.Match(segment, part)
+	return err == nil && matched
+}
+
+// containsSegments reports whether parts contains segments as a consecutive run, segments
+// holding glob patterns matching the parts at their place
+func containsSegments(parts, segments []string) bool {
+	for i := 0; i+len(segments) <= len(parts); i++ {
+		matched := true
+		for j, segment := range segments {
+			if !segmentMatches(parts[i+j], segment) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Options holds the optional behaviour shared by ScanAndUpdateModules and UpdateModuleVersionInFile
+type Options struct {
+	// DryRun previews changes without writing files
+	DryRun bool
+	// Force adds a version attribute to matching modules that have none
+	Force bool
+	// OnMissingVersion controls matching modules without a version attribute when Force is
+	// not set: "skip" ignores them silently, "error" fails the file, and "warn" (the default)
+	// prints a warning and skips them
+	OnMissingVersion string
+	// OnInvalidExisting controls matching modules whose version is not a valid version or
+	// range: "warn" prints a warning and skips them, "error" fails the file, and "overwrite"
+	// (the default) replaces the value like any other
+	OnInvalidExisting string
+	// VersionPlacement controls where Force adds a missing version attribute: "after_source"
+	// places it on the line after the source attribute, and "end" (the default) appends it
+	// to the block body
+	VersionPlacement string
+	// StrategyOptions tunes how the strategy computes the new version
+	StrategyOptions version.StrategyOptions
+	// RespectIgnore skips paths matched by .terraformignore or .gitignore at the work dir root
+	RespectIgnore bool
+	// IgnoreRoot is the directory holding the ignore files; defaults to the scanned directory
+	IgnoreRoot string
+	// Backup writes the original contents to filename+BackupSuffix before a file is overwritten
+	Backup bool
+	// BackupSuffix is appended to the filename for backups; defaults to DefaultBackupSuffix
+	BackupSuffix string
+	// OverwriteBackup replaces an existing backup instead of failing
+	OverwriteBackup bool
+	// FollowSymlinks descends into symlinked directories, visiting each real directory once
+	FollowSymlinks bool
+	// BackedUp records files already backed up during this run so that a file touched
+	// by several modules keeps the backup of its original contents; may be nil
+	BackedUp map[string]bool
+	// Output receives the per-file report and warnings; defaults to os.Stdout
+	Output io.Writer
+	// Frozen lists module versions that must be left untouched
+	Frozen []FrozenVersion
+	// ResolveTarget, when set, computes the target version for each matching module from
+	// its existing version, replacing the target passed to UpdateModuleVersionInFile
+	ResolveTarget func(existingVersion string) (string, error)
+	// Files, when non-nil, limits ScanAndUpdateModules to these files instead of walking
+	// the directory; files outside the scanned directory are left alone
+	Files []string
+	// OnSkip, when set, is called with each error that ScanAndUpdateModules skips over
+	// with a warning; these wrap ErrParse or ErrStrategy
+	OnSkip func(err error)
+	// OnWarning, when set, receives each module left unchanged with a warning instead of
+	// the warning being printed to Output
+	OnWarning func(Warning)
+	// OnSkipped, when set, receives each file and module block left unchanged before a
+	// version was decided for it, with the reason
+	OnSkipped func(Skip)
+	// Debugf, when set, receives the strategy's reason for every version decision,
+	// including those that leave the version unchanged
+	Debugf func(format string, args ...interface{})
+	// OnDecision, when set, receives every version decision, including those that leave
+	// the version unchanged
+	OnDecision func(Decision)
+	// OnFile, when set, is called with each file ScanAndUpdateModules scans after tier filtering
+	OnFile func(path string)
+	// MatchPartialSource matches sources built with interpolation, such as
+	// "${local.registry}/vpc/aws", on the static text after their last interpolation;
+	// otherwise they are skipped with a warning
+	MatchPartialSource bool
+	// SourceMatch controls how sources are matched against the configured source: "exact"
+	// requires them to be equal, "regex" treats the configured source as a regular
+	// expression, and "segment" (the default) matches it with MatchModuleSource
+	SourceMatch string
+	// SyncSourceRef sets the semver ref in the source of a module that also has a version
+	// attribute to the version written there; otherwise a ref that disagrees with the
+	// version is reported with a warning
+	SyncSourceRef bool
+	// Terragrunt also scans TerragruntFile files and updates the ref of the git source in
+	// their terraform block
+	Terragrunt bool
+	// Annotate adds a trailing "# managed by hclsemver (<strategy> strategy)" comment to each
+	// version attribute written, or to the source of a module versioned by its git ref,
+	// rewriting the annotation of an earlier run rather than adding another
+	Annotate bool
+}
+
+// Decision describes the version the strategy chose for one module block
+type Decision struct {
+	File   string
+	Source string
+	// Line and Column locate the version attribute, or the source attribute of modules
+	// without one, in File
+	Line       int
+	Column     int
+	OldVersion string
+	NewVersion string
+	// Reason explains which strategy rule determined NewVersion
+	Reason string
+}
+
+// SkipReason says why a file or module block was left unchanged before a version was
+// decided for it
+type SkipReason string
+
+const (
+	// SkipNoMatch marks a file without a module block matching the source
+	SkipNoMatch SkipReason = "no source match"
+	// SkipTierExcluded marks a file outside the tiers being processed
+	SkipTierExcluded SkipReason = "tier excluded"
+	// SkipNoVersion marks a module block without a version attribute when Force is not set
+	SkipNoVersion SkipReason = "no version attribute"
+	// SkipFrozen marks a module block whose version is frozen
+	SkipFrozen SkipReason = "frozen"
+	// SkipIgnored marks a file or module block opted out with an ignore marker
+	SkipIgnored SkipReason = "ignored"
+)
+
+// Skip describes a file or module block left unchanged before a version was decided for it
+type Skip struct {
+	File string
+	// Source is the source of the module block as written, or empty when the whole file
+	// was skipped
+	Source string
+	Reason SkipReason
+}
+
+// FrozenVersion pins the current version of modules matching Source
+type FrozenVersion struct {
+	// Source is a module source pattern, matched like the update pattern
+	Source string
+	// Tier restricts the entry to files in that tier; empty or "*" applies to all files
+	Tier string
+	// Version is the frozen version
+	Version string
+}
+
+// isFrozen reports whether a module in filename is pinned at currentVersion
+func (o Options) isFrozen(filename, source, currentVersion string) bool {
+	for _, f := range o.Frozen {
+		if f.Tier != "" && f.Tier != "*" && !ShouldProcessTier(filename, map[string]bool{f.Tier: true}) {
+			continue
+		}
+		if MatchModuleSource(source, f.Source) && version.NormalizeVersionString(f.Version) == version.NormalizeVersionString(currentVersion) {
+			return true
+		}
+	}
+	return false
+}
+
+// decided reports a version decision to Debugf and OnDecision
+func (o Options) decided(d Decision) {
+	if o.Debugf != nil {
+		o.Debugf("Module %q in file %s: %q -> %q (%s)", d.Source, d.File, d.OldVersion, d.NewVersion, d.Reason)
+	}
+	if o.OnDecision != nil {
+		o.OnDecision(d)
+	}
+}
+
+// skipped reports a skip to OnSkipped
+func (o Options) skipped(s Skip) {
+	if o.OnSkipped != nil {
+		o.OnSkipped(s)
+	}
+}
+
+// output returns the writer for reports and warnings
+func (o Options) output() io.Writer {
+	if o.Output == nil {
+		return os.Stdout
+	}
+	return o.Output
+}
+
+// Change describes a version update made, or previewed in dry-run, to a single module block
+type Change struct {
+	File string
+	// Line and Column locate the version attribute of the module changed, or its source
+	// attribute when the version was added or lives in a git ref
+	Line       int
+	Column     int
+	OldVersion string
+	NewVersion string
+	Strategy   version.Strategy
+	// Reason explains which strategy rule determined NewVersion
+	Reason string
+	DryRun bool
+}
+
+// Location returns the file of the change with its line and column, as editors accept
+// them, e.g. "dev/main.tf:4:3"
+func (c Change) Location() string {
+	if c.Line == 0 {
+		return c.File
+	}
+	return fmt.Sprintf("%s:%d:%d", c.File, c.Line, c.Column)
+}
+
+// DefaultBackupSuffix is the suffix used for backups when Options.BackupSuffix is empty
+const DefaultBackupSuffix = ".bak"
+
+// writeBackup stores the original file contents next to the file before it is modified
+func writeBackup(filename string, src []byte, opts Options) error {
+	if opts.BackedUp[filename] {
+		return nil
+	}
+
+	suffix := opts.BackupSuffix
+	if suffix == "" {
+		suffix = DefaultBackupSuffix
+	}
+	backupFile := filename + suffix
+
+	// The backup holds the same contents, so it gets the same permission bits
+	info, err := os.Stat(filename)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrBackup, err)
+	}
+	mode := info.Mode().Perm()
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !opts.OverwriteBackup {
+		flags |= os.O_EXCL
+	}
+
+	f, err := os.OpenFile(backupFile, flags, mode)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%w: backup file %s already exists", ErrBackup, backupFile)
+		}
+		return fmt.Errorf("%w: %w", ErrBackup, err)
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return fmt.Errorf("%w: %w", ErrBackup, err)
+	}
+	if _, err := f.Write(src); err != nil {
+		f.Close()
+		return fmt.Errorf("%w: %w", ErrBackup, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("%w: %w", ErrBackup, err)
+	}
+
+	if opts.BackedUp != nil {
+		opts.BackedUp[filename] = true
+	}
+	return nil
+}
+
 // ScanAndUpdateModules walks `rootDir`, searching for *.tf files.
-// For each, calls UpdateModuleVersionInFile(...) to update module blocks if needed.
+// For each, calls UpdateModuleVersionInFile(...) to update module blocks if needed,
+// and returns the changes made, one per module block, in the order the files were visited. A file failing with
+// ErrMissingVersion or ErrInvalidVersion does not stop the scan; those failures are
+// returned together once every file has been visited.
 func ScanAndUpdateModules(
 	workDir string,
 	oldSourceSubstr string,
-	newIsVer bool,
-	newVer *semver.Version,
-	newConstr *semver.Constraints,
 	newInput string,
 	configTiers map[string]bool,
 	strategy version.Strategy,
-	dryRun bool,
-	force bool,
-) error {
-	err := filepath.WalkDir(workDir, func(path string, d fs.DirEntry, err error) error {
+	opts Options,
+) ([]Change, error) {
+	var changes []Change
+	var fileErrs []error
+	out := opts.output()
+
+	// process updates a single .tf file and reports the change
+	process := func(path string) error {
+		// Check if this file is in a tier we want to process
+		if !ShouldProcessTier(path, configTiers) {
+			opts.skipped(Skip{File: path, Reason: SkipTierExcluded})
+			return nil
+		}
+		if opts.OnFile != nil {
+			opts.OnFile(path)
+		}
+
+		fileChanges, _, err := updateModuleVersionInFile(path, oldSourceSubstr, newInput, strategy, opts)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrMissingVersion), errors.Is(err, ErrInvalidVersion):
+			// A module rejected by on_missing_version or on_invalid_existing fails its file
+			// only; the scan goes on and the failure is returned with the others once it ends
+			fileErrs = append(fileErrs, fmt.Errorf("error updating file %s: %w", path, err))
+		case errors.Is(err, ErrParse), errors.Is(err, ErrStrategy):
+			// Unparseable files and modules the strategy cannot handle are skipped
+			// with a warning
+			fmt.Fprintf(out, "Warning: %v\n", err)
+			if opts.OnSkip != nil {
+				opts.OnSkip(err)
+			}
+		default:
+			// Anything else aborts the scan
+			return fmt.Errorf("error updating file %s: %w", path, err)
+		}
+
+		for _, change := range fileChanges {
+			changes = append(changes, change)
+
+			if opts.DryRun {
+				fmt.Fprintf(out, "[DRY RUN] Would update file %s:\n", change.Location())
+				fmt.Fprintf(out, "  - Would change version from '%s' to '%s'\n", change.OldVersion, change.NewVersion)
+				fmt.Fprintf(out, "  - Strategy that would be used: %s\n", strategy)
+			} else {
+				fmt.Fprintf(out, "Updated file %s:\n", change.Location())
+				fmt.Fprintf(out, "  - Version changed from '%s' to '%s'\n", change.OldVersion, change.NewVersion)
+				fmt.Fprintf(out, "  - Strategy used: %s\n", strategy)
+			}
+		}
+
+		return nil
+	}
+
+	err := visitTerraformFiles(workDir, opts, process)
+	return changes, errors.Join(append([]error{err}, fileErrs...)...)
+}
+
+// CountFiles returns the number of files ScanAndUpdateModules would visit under workDir
+// with opts, before tier filtering
+func CountFiles(workDir string, opts Options) (int, error) {
+	count := 0
+	err := visitTerraformFiles(workDir, opts, func(string) error {
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// visitTerraformFiles calls visit for each .tf file under workDir, or for each file of
+// opts.Files when set, skipping paths matched by ignore files under opts.RespectIgnore
+func visitTerraformFiles(workDir string, opts Options, visit func(path string) error) error {
+	var ignore *ignoreMatcher
+	ignoreRoot := opts.IgnoreRoot
+	if ignoreRoot == "" {
+		ignoreRoot = workDir
+	}
+	if opts.RespectIgnore {
+		var err error
+		if ignore, err = loadIgnoreMatcher(ignoreRoot); err != nil {
+			return err
+		}
+	}
+
+	if opts.Files != nil {
+		return processFileList(workDir, ignoreRoot, ignore, opts, visit)
+	}
+
+	return walkTree(workDir, opts.FollowSymlinks, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
+		if ignore != nil && path != ignoreRoot {
+			if rel, relErr := filepath.Rel(ignoreRoot, path); relErr == nil && ignore.Match(rel, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
 		if d.IsDir() {
 			return nil
 		}
 
-		if !strings.HasSuffix(path, ".tf") {
+		if !opts.scansFile(path) {
 			return nil
 		}
 
-		// Check if this file is in a tier we want to process
-		if !ShouldProcessTier(path, configTiers) {
-			return nil
+		return visit(path)
+	})
+}
+
+// TerragruntFile is the name of the Terragrunt configuration files scanned with
+// Options.Terragrunt
+const TerragruntFile = "terragrunt.hcl"
+
+// scansFile reports whether path is a file the scan reads: a .tf file or, with Terragrunt,
+// a TerragruntFile
+func (o Options) scansFile(path string) bool {
+	return strings.HasSuffix(path, ".tf") || (o.Terragrunt && filepath.Base(path) == TerragruntFile)
+}
+
+// isModuleBlock reports whether block names a module: a module block or, with Terragrunt,
+// a terraform block such as Terragrunt's terraform { source = "...?ref=v1.2.0" }
+func (o Options) isModuleBlock(block *hclwrite.Block) bool {
+	return block.Type() == "module" || (o.Terragrunt && block.Type() == "terraform")
+}
+
+// processFileList runs process on the .tf files of opts.Files that lie under workDir and
+// are not ignored, in the order given. Files that no longer exist are skipped.
+func processFileList(workDir, ignoreRoot string, ignore *ignoreMatcher, opts Options, process func(path string) error) error {
+	absWorkDir, err := filepath.Abs(workDir)
+	if err != nil {
+		return err
+	}
+	absIgnoreRoot, err := filepath.Abs(ignoreRoot)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range opts.Files {
+		if !opts.scansFile(file) {
+			continue
 		}
 
-		changed, oldVersion, newVersion, err := UpdateModuleVersionInFile(path, oldSourceSubstr, newIsVer, newVer, newConstr, newInput, strategy, dryRun, force)
+		absFile, err := filepath.Abs(file)
 		if err != nil {
-			return fmt.Errorf("error updating file %s: %w", path, err)
+			return err
+		}
+		rel, err := filepath.Rel(absWorkDir, absFile)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
 		}
 
-		if changed {
-			if dryRun {
-				fmt.Printf("[DRY RUN] Would update file %s:\n", path)
-				fmt.Printf("  - Would change version from '%s' to '%s'\n", oldVersion, newVersion)
-				fmt.Printf("  - Strategy that would be used: %s\n", strategy)
-			} else {
-				fmt.Printf("Updated file %s:\n", path)
-				fmt.Printf("  - Version changed from '%s' to '%s'\n", oldVersion, newVersion)
-				fmt.Printf("  - Strategy used: %s\n", strategy)
+		if ignore != nil {
+			if ignoreRel, err := filepath.Rel(absIgnoreRoot, absFile); err == nil && ignore.MatchPath(ignoreRel) {
+				continue
 			}
 		}
 
-		return nil
-	})
+		path := filepath.Join(workDir, rel)
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
 
-	return err
+		if err := process(path); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// matchModuleSource checks if the source matches the pattern by comparing path segments
-func matchModuleSource(source, pattern string) bool {
+// keepLineEndings rewrites the line endings of out, the updated contents of src, to the
+// style of src: CRLF when every line of src ends in CRLF, since hclwrite writes new lines
+// with LF, and a final line ending only when src has one. Files with mixed line endings
+// keep those hclwrite writes.
+func keepLineEndings(src, out []byte) []byte {
+	crlf := bytes.Count(src, []byte("\r\n"))
+	if crlf > 0 && crlf == bytes.Count(src, []byte("\n")) {
+		out = bytes.ReplaceAll(bytes.ReplaceAll(out, []byte("\r\n"), []byte("\n")), []byte("\n"), []byte("\r\n"))
+	}
+
+	eol := []byte("\n")
+	if bytes.HasSuffix(src, []byte("\r\n")) {
+		eol = []byte("\r\n")
+	}
+	switch endsWithEOL := bytes.HasSuffix(src, []byte("\n")); {
+	case len(src) == 0:
+	case endsWithEOL && !bytes.HasSuffix(out, []byte("\n")):
+		out = append(out, eol...)
+	case !endsWithEOL && bytes.HasSuffix(out, []byte("\n")):
+		out = bytes.TrimSuffix(bytes.TrimSuffix(out, []byte("\n")), []byte("\r"))
+	}
+	return out
+}
+
+// writeFileAtomic replaces filename with data by writing a temporary file in the same
+// directory and renaming it over the original, so a crash never leaves a truncated file.
+// The original file mode is kept and symlinks are written through to their targets.
+func writeFileAtomic(filename string, data []byte) error {
+	target, err := filepath.EvalSymlinks(filename)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+
+	// Renaming bypasses the permissions of the file itself, so make sure it is writable
+	f, err := os.OpenFile(target, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	f.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op once the rename succeeded
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpName, target)
+}
+
+// MatchModuleSource checks if the source matches the pattern by comparing path segments
+func MatchModuleSource(source, pattern string) bool {
 	// Split both strings by forward slash
 	sourceParts := strings.Split(source, "/")
 	patternParts := strings.Split(pattern, "/")
@@ -138,108 +695,365 @@ func matchModuleSource(source, pattern string) bool {
 	return false
 }
 
+// matchSource matches source against pattern as opts.SourceMatch says
+func (o Options) matchSource(source, pattern string) bool {
+	switch o.SourceMatch {
+	case "exact":
+		return source == pattern
+	case "regex":
+		matched, err := regexp.MatchString(pattern, source)
+		return err == nil && matched
+	}
+	return MatchModuleSource(source, pattern)
+}
+
 // UpdateModuleVersionInFile reads a single .tf file, finds any module blocks
 // whose "source" matches oldSourceSubstr, then updates "version" attribute using
-// "keep old if it fits new, else new" logic. We pass newInput to decideVersionOrRange.
+// "keep old if it fits new, else new" logic. newInput is the configured version or range
+// the strategy is applied with. It returns the versions of the last block changed, or the
+// existing version of the last matching block when none was.
 func UpdateModuleVersionInFile(
 	filename string,
 	oldSourceSubstr string,
-	newIsVer bool,
-	newVer *semver.Version,
-	newConstr *semver.Constraints,
 	newInput string,
 	strategy version.Strategy,
-	dryRun bool,
-	force bool,
+	opts Options,
 ) (bool, string, string, error) {
+	changes, existing, err := updateModuleVersionInFile(filename, oldSourceSubstr, newInput, strategy, opts)
+	if len(changes) == 0 {
+		return false, existing, "", err
+	}
+	last := changes[len(changes)-1]
+	return true, last.OldVersion, last.NewVersion, err
+}
+
+// stringLiteral returns the value of expr when it is a string literal. A heredoc's value
+// has its surrounding whitespace trimmed, since its body always ends in a newline.
+// References, function calls and interpolations cannot be evaluated without the rest of
+// the configuration and report false.
+func stringLiteral(expr *hclwrite.Expression) (string, bool) {
+	tokens := expr.BuildTokens(nil)
+	// A heredoc's closing marker must end its line, which the attribute's tokens leave out
+	src := append(tokens.Bytes(), '\n')
+	parsed, diags := hclsyntax.ParseExpression(src, "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return "", false
+	}
+	if tmpl, ok := parsed.(*hclsyntax.TemplateExpr); !ok || !tmpl.IsStringLiteral() {
+		return "", false
+	}
+
+	val, diags := parsed.Value(nil)
+	if diags.HasErrors() || val.IsNull() || !val.Type().Equals(cty.String) {
+		return "", false
+	}
+	if len(tokens) > 0 && tokens[0].Type == hclsyntax.TokenOHeredoc {
+		return strings.TrimSpace(val.AsString()), true
+	}
+	return val.AsString(), true
+}
+
+// moduleSource returns the source of a module block as written and the value pattern is
+// matched against, reporting false when the block does not match. Git sources match
+// without their scheme and parameters. Interpolated sources cannot be resolved without the
+// rest of the configuration: they match on their static suffix with opts.MatchPartialSource
+// and are otherwise skipped, with a warning when that suffix would have matched. Interpolated
+// git sources are never matched, since their ref cannot be rewritten.
+func moduleSource(block *hclwrite.Block, filename, pattern string, opts Options) (string, string, bool) {
+	sourceAttr := block.Body().GetAttribute("source")
+	if sourceAttr == nil {
+		return "", "", false
+	}
+
+	literal, ok := stringLiteral(sourceAttr.Expr())
+	if !ok {
+		written := strings.Trim(strings.TrimSpace(string(sourceAttr.Expr().BuildTokens(nil).Bytes())), `"`)
+		suffix, interpolated := interpolatedSuffix(sourceAttr.Expr())
+		if !interpolated || isGitSource(written) || suffix == "" || !opts.matchSource(suffix, pattern) {
+			return "", "", false
+		}
+		if !opts.MatchPartialSource {
+			opts.warn(Warning{Source: written, File: filename, Reason: "has an interpolated source; set match_partial_source to match its static suffix"})
+			return "", "", false
+		}
+		return written, suffix, true
+	}
+
+	sourceValue := literal
+	if isGitSource(literal) {
+		sourceValue = gitMatchSource(literal)
+	}
+	if sourceValue == "" || !opts.matchSource(sourceValue, pattern) {
+		return "", "", false
+	}
+	return literal, sourceValue, true
+}
+
+// interpolatedSuffix returns the static text after the last interpolation of a quoted
+// template such as "${local.registry}/vpc/aws", without its leading slashes, and whether
+// expr is such a template
+func interpolatedSuffix(expr *hclwrite.Expression) (string, bool) {
+	parsed, diags := hclsyntax.ParseExpression(expr.BuildTokens(nil).Bytes(), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return "", false
+	}
+	switch tmpl := parsed.(type) {
+	case *hclsyntax.TemplateWrapExpr:
+		return "", true
+	case *hclsyntax.TemplateExpr:
+		last, ok := tmpl.Parts[len(tmpl.Parts)-1].(*hclsyntax.LiteralValueExpr)
+		if !ok || !last.Val.Type().Equals(cty.String) {
+			return "", true
+		}
+		return strings.TrimLeft(last.Val.AsString(), "/"), true
+	}
+	return "", false
+}
+
+// versionPositions maps each module block of body, parsed by hclwrite from src, to the start
+// of its version attribute, or of its source attribute when it has none. hclwrite tokens
+// carry no positions, so src is parsed again with hclsyntax, whose blocks come in the same
+// order.
+func versionPositions(src []byte, filename string, body *hclwrite.Body) map[*hclwrite.Block]hcl.Pos {
+	positions := make(map[*hclwrite.Block]hcl.Pos)
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return positions
+	}
+	syntaxBlocks := file.Body.(*hclsyntax.Body).Blocks
+	blocks := body.Blocks()
+	if len(syntaxBlocks) != len(blocks) {
+		return positions
+	}
+
+	for i, block := range blocks {
+		attrs := syntaxBlocks[i].Body.Attributes
+		if attr, ok := attrs["version"]; ok {
+			positions[block] = attr.SrcRange.Start
+		} else if attr, ok := attrs["source"]; ok {
+			positions[block] = attr.SrcRange.Start
+		}
+	}
+	return positions
+}
+
+// setVersionAfterSource adds a version attribute to a block body that has none on the line
+// after its source attribute. hclwrite can only append attributes, so the body is rebuilt
+// from its tokens; a source that does not end its line gets the version appended instead.
+func setVersionAfterSource(body *hclwrite.Body, value cty.Value, comment string) {
+	line := hclwrite.NewEmptyFile().Body()
+	line.SetAttributeValue("version", value)
+	if comment != "" {
+		annotateAttribute(line, "version", comment)
+	}
+
+	sourceTokens := body.GetAttribute("source").BuildTokens(nil)
+	last := sourceTokens[len(sourceTokens)-1]
+	// A line comment carries the newline that ends its line
+	if last.Type == hclsyntax.TokenNewline || (last.Type == hclsyntax.TokenComment && strings.HasSuffix(string(last.Bytes), "\n")) {
+		tokens := body.BuildTokens(nil)
+		for i, tok := range tokens {
+			if tok == last {
+				reordered := append(append(tokens[:i+1:i+1], line.BuildTokens(nil)...), tokens[i+1:]...)
+				body.Clear()
+				body.AppendUnstructuredTokens(reordered)
+				return
+			}
+		}
+	}
+	body.SetAttributeValue("version", value)
+	if comment != "" {
+		annotateAttribute(body, "version", comment)
+	}
+}
+
+// updateModuleVersionInFile implements UpdateModuleVersionInFile, returning one change of
+// filename per module block changed, in file order, and the existing version of the last
+// matching module.
+func updateModuleVersionInFile(filename, oldSourceSubstr, newInput string, strategy version.Strategy, opts Options) ([]Change, string, error) {
 	// 1) Read file
 	src, err := os.ReadFile(filename)
 	if err != nil {
-		return false, "", "", fmt.Errorf("cannot read file: %w", err)
+		return nil, "", fmt.Errorf("%w: %w", ErrRead, err)
+	}
+
+	// Files opted out with a comment marker are never changed
+	if fileHasIgnoreMarker(src) {
+		fmt.Fprintf(opts.output(), "File %s is marked %s. Skipping.\n", filename, IgnoreFileMarker)
+		opts.skipped(Skip{File: filename, Reason: SkipIgnored})
+		return nil, "", nil
 	}
 
 	// 2) Parse into AST
 	file, diags := hclwrite.ParseConfig(src, filename, hcl.InitialPos)
 	if diags.HasErrors() {
-		// Skip files that can't be parsed instead of failing
-		fmt.Printf("Warning: Skipping file %s due to parse errors: %s\n", filename, diags.Error())
-		return false, "", "", nil
+		return nil, "", fmt.Errorf("%w %s: %s", ErrParse, filename, diags.Error())
 	}
 
-	changed := false
-	var oldVersion, newVersion string
+	// Each block is decided on its own existing version, so that blocks sharing a source
+	// never share protection, and reported as its own change
+	matched := false
+	var changes []Change
+	var lastExisting string
+	var strategyErrs []error
+	// record adds the change of a block
+	record := func(oldVersion, newVersion, reason string, at hcl.Pos) {
+		changes = append(changes, Change{
+			File:       filename,
+			Line:       at.Line,
+			Column:     at.Column,
+			OldVersion: oldVersion,
+			NewVersion: newVersion,
+			Strategy:   strategy,
+			Reason:     reason,
+			DryRun:     opts.DryRun,
+		})
+	}
 	rootBody := file.Body()
+	positions := versionPositions(src, filename, rootBody)
 
-	// Find module blocks
+	// Find module blocks, and with Terragrunt the terraform block naming the module to deploy
 	for _, block := range rootBody.Blocks() {
-		if block.Type() != "module" {
+		if !opts.isModuleBlock(block) {
 			continue
 		}
 
 		// Check if this is the module we want to update
-		sourceAttr := block.Body().GetAttribute("source")
-		if sourceAttr == nil {
+		literal, sourceValue, ok := moduleSource(block, filename, oldSourceSubstr, opts)
+		if !ok {
 			continue
 		}
+		matched = true
+		gitSource := isGitSource(literal)
 
-		sourceTokens := sourceAttr.Expr().BuildTokens(nil)
-		if sourceTokens == nil {
-			continue // Skip if we can't get source tokens
+		// Local paths have no version to update and must not be given one; a Terragrunt
+		// source carries its version only in a git ref
+		if isLocalSource(literal) || (block.Type() == "terraform" && !gitSource) {
+			continue
 		}
 
-		sourceValue := strings.Trim(string(sourceTokens.Bytes()), `"`)
-		if sourceValue == "" {
-			continue // Skip if source is empty
+		// Blocks opted out with a comment marker are never changed
+		if blockHasIgnoreMarker(block) {
+			fmt.Fprintf(opts.output(), "Module %q in file %s is marked %s. Skipping.\n", literal, filename, IgnoreMarker)
+			opts.skipped(Skip{File: filename, Source: literal, Reason: SkipIgnored})
+			continue
 		}
 
-		if !matchModuleSource(sourceValue, oldSourceSubstr) {
+		if gitSource && block.Body().GetAttribute("version") == nil {
+			refChanged, oldRef, newRef, refReason, err := updateGitRef(block, literal, sourceValue, filename, positions[block], newInput, strategy, opts)
+			if err != nil {
+				strategyErrs = append(strategyErrs, err)
+			}
+			if refChanged {
+				record(oldRef, newRef, refReason, positions[block])
+			}
 			continue
 		}
 
 		// Get existing version if any
+		var existing string
 		versionAttr := block.Body().GetAttribute("version")
 		if versionAttr != nil {
-			versionTokens := versionAttr.Expr().BuildTokens(nil)
-			if versionTokens != nil {
-				oldVersion = strings.Trim(strings.TrimSpace(string(versionTokens.Bytes())), `"`)
+			current, ok := stringLiteral(versionAttr.Expr())
+			if !ok {
+				opts.warn(Warning{Source: literal, File: filename, Reason: "has a version that is not a string literal"})
+				continue
+			}
+			if _, _, _, err := version.ParseVersionOrRange(current); err != nil {
+				switch opts.OnInvalidExisting {
+				case "warn":
+					opts.warn(Warning{Source: literal, File: filename, Reason: fmt.Sprintf("has an invalid version %q", current)})
+					continue
+				case "error":
+					return nil, "", fmt.Errorf("%w: module %q in file %s: %w", ErrInvalidVersion, sourceValue, filename, err)
+				}
+			}
+			existing = current
+			lastExisting = existing
+		} else if !opts.Force {
+			// If no version attribute and force is false, skip as configured
+			switch opts.OnMissingVersion {
+			case "skip":
+			case "error":
+				return nil, "", fmt.Errorf("%w: module %q in file %s", ErrMissingVersion, sourceValue, filename)
+			default:
+				opts.warn(Warning{Source: literal, File: filename, Reason: "has no version attribute"})
 			}
-		} else if !force {
-			// If no version attribute and force is false, output warning and skip
-			fmt.Printf("Warning: Module %q in file %s has no version attribute. Use force flag to add version.\n", sourceValue, filename)
+			opts.skipped(Skip{File: filename, Source: literal, Reason: SkipNoVersion})
 			continue
 		}
 
+		// Frozen versions are never changed
+		if existing != "" && opts.isFrozen(filename, sourceValue, existing) {
+			fmt.Fprintf(opts.output(), "Module %q in file %s is frozen at version %s. Skipping.\n", sourceValue, filename, existing)
+			opts.skipped(Skip{File: filename, Source: literal, Reason: SkipFrozen})
+			continue
+		}
+
+		target := newInput
+		if opts.ResolveTarget != nil {
+			if target, err = opts.ResolveTarget(existing); err != nil {
+				strategyErrs = append(strategyErrs, fmt.Errorf("%w for module %q in file %s: %w", ErrStrategy, sourceValue, filename, err))
+				continue
+			}
+		}
+
 		// Apply version strategy
-		finalVersion, err := version.ApplyVersionStrategy(strategy, newInput, oldVersion)
+		finalVersion, finalReason, err := version.ApplyVersionStrategyWithReason(strategy, target, existing, opts.StrategyOptions)
 		if err != nil {
-			fmt.Printf("Warning: Failed to apply version strategy for module %q in file %s: %v\n", sourceValue, filename, err)
-			continue // Skip this module but continue processing others
+			// Skip this module but continue processing others
+			strategyErrs = append(strategyErrs, fmt.Errorf("%w for module %q in file %s: %w", ErrStrategy, sourceValue, filename, err))
+			continue
 		}
-		newVersion = finalVersion
+		pos := positions[block]
+		opts.decided(Decision{File: filename, Source: literal, Line: pos.Line, Column: pos.Column, OldVersion: existing, NewVersion: finalVersion, Reason: finalReason})
 
 		// Normalize both versions for comparison
-		normalizedOld := version.NormalizeVersionString(oldVersion)
+		normalizedOld := version.NormalizeVersionString(existing)
 		normalizedNew := version.NormalizeVersionString(finalVersion)
 
 		// Only update if the normalized versions are different
 		if normalizedOld != normalizedNew {
 			// Update the version attribute
-			block.Body().SetAttributeValue("version", cty.StringVal(finalVersion))
-			changed = true
+			if versionAttr == nil && opts.VersionPlacement == "after_source" {
+				setVersionAfterSource(block.Body(), cty.StringVal(finalVersion), opts.annotation(strategy))
+			} else {
+				block.Body().SetAttributeValue("version", cty.StringVal(finalVersion))
+				if opts.Annotate {
+					annotateAttribute(block.Body(), "version", opts.annotation(strategy))
+				}
+			}
+			record(existing, finalVersion, finalReason, pos)
+		}
+
+		// A ref in the source is kept in step with the version attribute
+		if reconcileSourceRef(block, literal, filename, finalVersion, opts) {
+			if normalizedOld == normalizedNew {
+				record(existing, finalVersion, finalReason, pos)
+			}
+			changes[len(changes)-1].Reason += "; synced source ref"
 		}
 	}
 
-	if !changed {
-		return false, oldVersion, "", nil
+	if !matched {
+		opts.skipped(Skip{File: filename, Reason: SkipNoMatch})
 	}
+	if len(changes) == 0 {
+		return nil, lastExisting, errors.Join(strategyErrs...)
+	}
+
+	if !opts.DryRun {
+		if opts.Backup {
+			if err := writeBackup(filename, src, opts); err != nil {
+				return nil, "", err
+			}
+		}
 
-	if !dryRun {
 		// Write the file back
-		if err := os.WriteFile(filename, file.Bytes(), 0o644); err != nil {
-			fmt.Printf("Warning: Failed to write file %s: %v\n", filename, err)
-			return false, "", "", nil // Skip instead of failing
+		if err := writeFileAtomic(filename, keepLineEndings(src, file.Bytes())); err != nil {
+			return nil, "", fmt.Errorf("%w: %w", ErrWrite, err)
 		}
 	}
 
-	return true, oldVersion, newVersion, nil
+	return changes, lastExisting, errors.Join(strategyErrs...)
 }
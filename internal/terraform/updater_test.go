@@ -1,12 +1,16 @@
 package terraform
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
-	"github.com/Masterminds/semver/v3"
 	"github.com/david1155/hclsemver/pkg/version"
 )
 
@@ -99,6 +103,42 @@ module "test_module" {
 			wantOld:     ">=1.0.0, <2.0.0",
 			wantNew:     "",
 		},
+		{
+			name: "space-joined comparisons",
+			content: `
+module "test_module" {
+  source  = "api.env0.com/test-module/test"
+  version = ">= 1.0.0 < 2.0.0"
+}`,
+			newVersion:  ">= 1.0.0, < 2.0.0",
+			wantChanged: false,
+			wantOld:     ">= 1.0.0 < 2.0.0",
+			wantNew:     "",
+		},
+		{
+			name: "exclusion in range",
+			content: `
+module "test_module" {
+  source  = "api.env0.com/test-module/test"
+  version = ">= 1.0.0, < 2.0.0, != 1.5.0"
+}`,
+			newVersion:  ">=1.0.0,<2.0.0,!=1.5.0",
+			wantChanged: false,
+			wantOld:     ">= 1.0.0, < 2.0.0, != 1.5.0",
+			wantNew:     "",
+		},
+		{
+			name: "compact exclusion in range",
+			content: `
+module "test_module" {
+  source  = "api.env0.com/test-module/test"
+  version = ">=1.0.0,<2.0.0,!=1.5.0"
+}`,
+			newVersion:  ">= 1.0.0, < 2.0.0, != 1.5.0",
+			wantChanged: false,
+			wantOld:     ">=1.0.0,<2.0.0,!=1.5.0",
+			wantNew:     "",
+		},
 	}
 
 	for _, tc := range tests {
@@ -110,14 +150,8 @@ module "test_module" {
 				t.Fatal(err)
 			}
 
-			// Parse new version
-			newIsVer, newVer, newConstr, err := version.ParseVersionOrRange(tc.newVersion)
-			if err != nil {
-				t.Fatal(err)
-			}
-
 			// Test updating the version
-			changed, oldVersion, newVersion, err := UpdateModuleVersionInFile(testFile, "test-module", newIsVer, newVer, newConstr, tc.newVersion, version.StrategyRange, false, false)
+			changed, oldVersion, newVersion, err := UpdateModuleVersionInFile(testFile, "test-module", tc.newVersion, version.StrategyRange, Options{})
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -149,12 +183,7 @@ module "kafka_topics_ziworkflows_module" {
 
 	// Test updating the version
 	newVersion := ">= 2.0.0, < 3.0.0"
-	newIsVer, newVer, newConstr, err := version.ParseVersionOrRange(newVersion)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	changed, oldVersion, resultVersion, err := UpdateModuleVersionInFile(testFile, "kafka-topics-module/confluent", newIsVer, newVer, newConstr, newVersion, version.StrategyRange, false, false)
+	changed, oldVersion, resultVersion, err := UpdateModuleVersionInFile(testFile, "kafka-topics-module/confluent", newVersion, version.StrategyRange, Options{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -199,12 +228,7 @@ module "example_module" {
 		t.Fatalf("failed to write file: %v", err)
 	}
 
-	newIsVer, newVer, newConstr, err := version.ParseVersionOrRange(">=2,<3")
-	if err != nil {
-		t.Fatalf("cannot parse new version: %v", err)
-	}
-
-	changed, oldVersion, newVersion, err := UpdateModuleVersionInFile(tfFile, "kafka-topics-module/confluent", newIsVer, newVer, newConstr, ">=2,<3", version.StrategyDynamic, false, false)
+	changed, oldVersion, newVersion, err := UpdateModuleVersionInFile(tfFile, "kafka-topics-module/confluent", ">=2,<3", version.StrategyDynamic, Options{})
 	if err != nil {
 		t.Fatalf("UpdateModuleVersionInFile error: %v", err)
 	}
@@ -255,12 +279,7 @@ module "kafka_topics_ziworkflows_module" {
 			}
 
 			// new version => ">=2,<3"
-			newIsVer, newVer, newConstr, err := version.ParseVersionOrRange(">=2,<3")
-			if err != nil {
-				t.Fatalf("cannot parse new version: %v", err)
-			}
-
-			changed, oldVersion, newVersion, err := UpdateModuleVersionInFile(tfFile, "kafka-topics-module/confluent", newIsVer, newVer, newConstr, ">=2,<3", version.StrategyDynamic, false, tt.force)
+			changed, oldVersion, newVersion, err := UpdateModuleVersionInFile(tfFile, "kafka-topics-module/confluent", ">=2,<3", version.StrategyDynamic, Options{Force: tt.force})
 			if err != nil {
 				t.Fatalf("UpdateModuleVersionInFile error: %v", err)
 			}
@@ -317,12 +336,7 @@ module "kafka_topics_ziworkflows_module" {
 
 	// Test updating the version
 	newVersion := ">= 2.0.0, < 3.0.0"
-	newIsVer, newVer, newConstr, err := version.ParseVersionOrRange(newVersion)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	changed, oldVersion, resultVersion, err := UpdateModuleVersionInFile(testFile, "kafka-topics-module/confluent", newIsVer, newVer, newConstr, newVersion, version.StrategyRange, false, false)
+	changed, oldVersion, resultVersion, err := UpdateModuleVersionInFile(testFile, "kafka-topics-module/confluent", newVersion, version.StrategyRange, Options{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -367,14 +381,56 @@ module "test" {
 		t.Fatalf("Failed to write test file: %v", err)
 	}
 
-	newIsVer, newVer, newConstr, err := version.ParseVersionOrRange("2.0.0")
+	_, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module", "2.0.0", version.StrategyDynamic, Options{})
+	if err == nil {
+		t.Fatal("Expected error for invalid HCL, got nil")
+	}
+	if !errors.Is(err, ErrParse) {
+		t.Errorf("Expected ErrParse, got %v", err)
+	}
+
+	// Scanning skips the unparseable file with a warning instead of failing
+	var out strings.Builder
+	var skipped []error
+	_, err = ScanAndUpdateModules(tmpDir, "test-module", "2.0.0", nil, version.StrategyDynamic, Options{Output: &out, OnSkip: func(err error) { skipped = append(skipped, err) }})
 	if err != nil {
-		t.Fatalf("Failed to parse version: %v", err)
+		t.Fatalf("Expected scan to skip invalid HCL, got %v", err)
+	}
+	if !strings.Contains(out.String(), "Warning: cannot parse file") {
+		t.Errorf("Expected parse warning, got %q", out.String())
 	}
+	if len(skipped) != 1 || !errors.Is(skipped[0], ErrParse) {
+		t.Errorf("Expected one skipped ErrParse, got %v", skipped)
+	}
+}
 
-	_, _, _, err = UpdateModuleVersionInFile(tfFile, "test-module", newIsVer, newVer, newConstr, "2.0.0", version.StrategyDynamic, false, false)
-	if err == nil {
-		t.Error("Expected error for invalid HCL, got nil")
+func TestUpdateModuleVersionInFile_StrategyError(t *testing.T) {
+	content := `
+module "a" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+
+module "b" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.5.0"
+}
+`
+	tfFile := filepath.Join(t.TempDir(), "main.tf")
+	if err := os.WriteFile(tfFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	// The exact strategy rejects a range target for every matching module
+	changed, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", ">=2,<3", version.StrategyExact, Options{})
+	if !errors.Is(err, ErrStrategy) {
+		t.Fatalf("Expected ErrStrategy, got %v", err)
+	}
+	if errors.Is(err, ErrParse) || errors.Is(err, ErrWrite) {
+		t.Errorf("Expected only ErrStrategy, got %v", err)
+	}
+	if changed {
+		t.Error("Expected no change")
 	}
 }
 
@@ -401,15 +457,13 @@ module "test" {
 	}
 	defer os.Chmod(tfFile, 0644) // Restore permissions for cleanup
 
-	newIsVer, newVer, newConstr, err := version.ParseVersionOrRange("2.0.0")
-	if err != nil {
-		t.Fatalf("Failed to parse version: %v", err)
-	}
-
-	_, _, _, err = UpdateModuleVersionInFile(tfFile, "test-module", newIsVer, newVer, newConstr, "2.0.0", version.StrategyDynamic, false, false)
+	_, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module", "2.0.0", version.StrategyDynamic, Options{})
 	if err == nil {
 		t.Error("Expected error for write-protected file, got nil")
 	}
+	if !errors.Is(err, ErrWrite) {
+		t.Errorf("Expected ErrWrite, got %v", err)
+	}
 }
 
 func TestUpdateModuleVersionInFile_DryRun(t *testing.T) {
@@ -428,12 +482,7 @@ module "test_module" {
 	// Save original content for comparison
 	originalContent := content
 
-	newIsVer, newVer, newConstr, err := version.ParseVersionOrRange("2.0.0")
-	if err != nil {
-		t.Fatalf("Failed to parse version: %v", err)
-	}
-
-	changed, oldVersion, newVersion, err := UpdateModuleVersionInFile(tfFile, "test-module", newIsVer, newVer, newConstr, "2.0.0", version.StrategyDynamic, true, false)
+	changed, oldVersion, newVersion, err := UpdateModuleVersionInFile(tfFile, "test-module", "2.0.0", version.StrategyDynamic, Options{DryRun: true})
 	if err != nil {
 		t.Fatalf("UpdateModuleVersionInFile error: %v", err)
 	}
@@ -535,6 +584,126 @@ func TestShouldProcessTier(t *testing.T) {
 			},
 			want: false,
 		},
+		{
+			name:        "directory path tier",
+			path:        "/work/environments/production/vpc/main.tf",
+			configTiers: map[string]bool{"environments/production": true},
+			want:        true,
+		},
+		{
+			name:        "directory path tier needs consecutive segments",
+			path:        "/work/environments/staging/production/main.tf",
+			configTiers: map[string]bool{"environments/production": true},
+			want:        false,
+		},
+		{
+			name:        "negated tier matches other tiers",
+			path:        "/work/dev/module/file.tf",
+			configTiers: map[string]bool{"!prod": true},
+			want:        true,
+		},
+		{
+			name:        "negated tier matches staging",
+			path:        "/work/staging/file.tf",
+			configTiers: map[string]bool{"!prod": true},
+			want:        true,
+		},
+		{
+			name:        "negated tier skips the excluded tier",
+			path:        "/work/prod/module/file.tf",
+			configTiers: map[string]bool{"!prod": true},
+			want:        false,
+		},
+		{
+			name:        "specific tier takes precedence over negated tier",
+			path:        "/work/dev/module/file.tf",
+			configTiers: map[string]bool{"!prod": true, "dev": false},
+			want:        false,
+		},
+		{
+			name:        "excluded tier falls back to wildcard",
+			path:        "/work/prod/module/file.tf",
+			configTiers: map[string]bool{"!prod": true, "*": false},
+			want:        false,
+		},
+		{
+			name:        "negated directory path tier",
+			path:        "/work/environments/production/vpc/main.tf",
+			configTiers: map[string]bool{"!environments/production": true},
+			want:        false,
+		},
+		{
+			name:        "backslash-separated path",
+			path:        `C:\work\dev\module\file.tf`,
+			configTiers: map[string]bool{"dev": true, "prod": false},
+			want:        true,
+		},
+		{
+			name:        "backslash-separated path outside the tiers",
+			path:        `C:\work\other\module\file.tf`,
+			configTiers: map[string]bool{"dev": true, "prod": true},
+			want:        false,
+		},
+		{
+			name:        "mixed separators",
+			path:        `C:\work/prod\module/file.tf`,
+			configTiers: map[string]bool{"dev": true, "prod": false},
+			want:        false,
+		},
+		{
+			name:        "directory path tier in backslash-separated path",
+			path:        `C:\work\environments\production\vpc\main.tf`,
+			configTiers: map[string]bool{"environments/production": true, "*": false},
+			want:        true,
+		},
+		{
+			name:        "backslash-separated directory path tier",
+			path:        "/work/environments/production/vpc/main.tf",
+			configTiers: map[string]bool{`environments\production`: true, "*": false},
+			want:        true,
+		},
+		{
+			name:        "backslash-separated directory path tier does not match its last directory alone",
+			path:        "/work/production/vpc/main.tf",
+			configTiers: map[string]bool{`environments\production`: true, "*": false},
+			want:        false,
+		},
+		{
+			name:        "negated tier in backslash-separated path",
+			path:        `C:\work\prod\vpc\main.tf`,
+			configTiers: map[string]bool{"!prod": true},
+			want:        false,
+		},
+		{
+			name:        "backslash-separated path outside a negated tier",
+			path:        `C:\work\dev\vpc\main.tf`,
+			configTiers: map[string]bool{"!prod": true},
+			want:        true,
+		},
+		{
+			name:        "tier pattern matches a directory",
+			path:        "/work/region-us-east-1/main.tf",
+			configTiers: map[string]bool{"region-*": true},
+			want:        true,
+		},
+		{
+			name:        "tier pattern matches whole directory names",
+			path:        "/work/old-region-us-east-1/main.tf",
+			configTiers: map[string]bool{"region-*": true},
+			want:        false,
+		},
+		{
+			name:        "specific tier takes precedence over tier pattern",
+			path:        "/work/region-us-east-1/main.tf",
+			configTiers: map[string]bool{"region-*": true, "region-us-east-1": false},
+			want:        false,
+		},
+		{
+			name:        "directory path tier pattern",
+			path:        "/work/regions/eu-west-1/main.tf",
+			configTiers: map[string]bool{"regions/eu-*": true},
+			want:        true,
+		},
 	}
 
 	for _, tc := range tests {
@@ -658,9 +827,9 @@ func TestMatchModuleSource(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := matchModuleSource(tt.source, tt.pattern)
+			got := MatchModuleSource(tt.source, tt.pattern)
 			if got != tt.want {
-				t.Errorf("matchModuleSource(%q, %q) = %v, want %v",
+				t.Errorf("MatchModuleSource(%q, %q) = %v, want %v",
 					tt.source, tt.pattern, got, tt.want)
 			}
 		})
@@ -861,17 +1030,13 @@ module "pre_release" {
 
 			if tt.name == "wildcard as default with different version for dev" {
 				// Call ScanAndUpdateModules once with both wildcard and specific tier
-				err := ScanAndUpdateModules(
+				_, err := ScanAndUpdateModules(
 					tmpDir,
 					"test-module/aws",
-					true,
-					semver.MustParse("2.0.0"),
-					nil,
 					"2.0.0",
 					tt.configTiers,
 					version.StrategyExact,
-					false,
-					false,
+					Options{},
 				)
 				if err != nil {
 					t.Fatalf("ScanAndUpdateModules failed: %v", err)
@@ -900,22 +1065,13 @@ module "pre_release" {
 
 			if tt.name == "foundations labels module with wildcard tier" {
 				// Call ScanAndUpdateModules for foundations-labels-module
-				constraint, err := semver.NewConstraint(">= 3.1.5, < 4.0.0")
-				if err != nil {
-					t.Fatalf("Failed to create version constraint: %v", err)
-				}
-
-				err = ScanAndUpdateModules(
+				_, err := ScanAndUpdateModules(
 					tmpDir,
 					"foundations-labels-module",
-					false,      // not exact version
-					nil,        // no exact version
-					constraint, // range constraint
 					">= 3.1.5, < 4.0.0",
 					tt.configTiers,
 					version.StrategyRange,
-					false,
-					false,
+					Options{},
 				)
 				if err != nil {
 					t.Fatalf("ScanAndUpdateModules failed: %v", err)
@@ -944,22 +1100,13 @@ module "pre_release" {
 
 			if tt.name == "pre-1.0 version should not convert to range" {
 				// Call ScanAndUpdateModules for pre-1.0 version
-				constraint, err := semver.NewConstraint(">= 0.9.5, < 1.0.0")
-				if err != nil {
-					t.Fatalf("Failed to create version constraint: %v", err)
-				}
-
-				err = ScanAndUpdateModules(
+				_, err := ScanAndUpdateModules(
 					tmpDir,
 					"foundations-labels-module",
-					false,      // not exact version
-					nil,        // no exact version
-					constraint, // range constraint
 					">= 0.9.5, < 1.0.0",
 					tt.configTiers,
 					version.StrategyRange,
-					false,
-					false,
+					Options{},
 				)
 				if err != nil {
 					t.Fatalf("ScanAndUpdateModules failed: %v", err)
@@ -987,17 +1134,13 @@ module "pre_release" {
 			}
 
 			// Call ScanAndUpdateModules once for other test cases
-			err := ScanAndUpdateModules(
+			_, err := ScanAndUpdateModules(
 				tmpDir,
 				"test-module/aws",
-				true,
-				semver.MustParse("2.0.0"),
-				nil,
 				"2.0.0",
 				tt.configTiers,
 				version.StrategyExact,
-				false,
-				false,
+				Options{},
 			)
 			if err != nil {
 				t.Fatalf("ScanAndUpdateModules failed: %v", err)
@@ -1019,3 +1162,2016 @@ module "pre_release" {
 		})
 	}
 }
+
+func TestIgnoreMatcher(t *testing.T) {
+	tmpDir := t.TempDir()
+	ignoreContent := `
+# vendored copies
+.terraform/
+modules/
+!modules/keep/
+*.bak.tf
+/root-only.tf
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, ".terraformignore"), []byte(ignoreContent), 0644); err != nil {
+		t.Fatalf("Failed to write ignore file: %v", err)
+	}
+
+	m, err := loadIgnoreMatcher(tmpDir)
+	if err != nil {
+		t.Fatalf("loadIgnoreMatcher failed: %v", err)
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{".terraform", true, true},
+		{"dev/.terraform", true, true},
+		{".terraform", false, false},
+		{"modules", true, true},
+		{"dev/modules", true, true},
+		{"modules/keep", true, false},
+		{"dev/main.tf", false, false},
+		{"dev/old.bak.tf", false, true},
+		{"root-only.tf", false, true},
+		{"dev/root-only.tf", false, false},
+	}
+
+	for _, tc := range tests {
+		if got := m.Match(tc.path, tc.isDir); got != tc.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", tc.path, tc.isDir, got, tc.want)
+		}
+	}
+}
+
+func TestScanAndUpdateModules_RespectIgnore(t *testing.T) {
+	content := `
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+`
+	files := []string{
+		"main.tf",
+		"modules/vendored/main.tf",
+		"modules/keep/main.tf",
+	}
+
+	setup := func(t *testing.T) string {
+		tmpDir := t.TempDir()
+		for _, f := range files {
+			fullPath := filepath.Join(tmpDir, f)
+			if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+		}
+		ignoreContent := "modules/\n!modules/keep/\n"
+		if err := os.WriteFile(filepath.Join(tmpDir, ".terraformignore"), []byte(ignoreContent), 0644); err != nil {
+			t.Fatalf("Failed to write ignore file: %v", err)
+		}
+		return tmpDir
+	}
+
+	tests := []struct {
+		name        string
+		opts        Options
+		wantChanged map[string]bool
+	}{
+		{
+			name: "ignore files honored",
+			opts: Options{RespectIgnore: true},
+			wantChanged: map[string]bool{
+				"main.tf":                  true,
+				"modules/vendored/main.tf": false,
+				"modules/keep/main.tf":     false, // the parent directory is excluded
+			},
+		},
+		{
+			name: "ignore files not honored by default",
+			opts: Options{},
+			wantChanged: map[string]bool{
+				"main.tf":                  true,
+				"modules/vendored/main.tf": true,
+				"modules/keep/main.tf":     true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := setup(t)
+
+			_, err := ScanAndUpdateModules(tmpDir, "test-module/aws", "2.0.0", nil, version.StrategyExact, tt.opts)
+			if err != nil {
+				t.Fatalf("ScanAndUpdateModules failed: %v", err)
+			}
+
+			for f, shouldChange := range tt.wantChanged {
+				updated, err := os.ReadFile(filepath.Join(tmpDir, f))
+				if err != nil {
+					t.Fatalf("Failed to read file: %v", err)
+				}
+				if changed := string(updated) != content; changed != shouldChange {
+					t.Errorf("File %s: expected changed=%v, got changed=%v", f, shouldChange, changed)
+				}
+			}
+		})
+	}
+
+	t.Run("ignore root above scanned directory", func(t *testing.T) {
+		tmpDir := setup(t)
+
+		opts := Options{RespectIgnore: true, IgnoreRoot: tmpDir}
+		_, err := ScanAndUpdateModules(filepath.Join(tmpDir, "modules"), "test-module/aws", "2.0.0", nil, version.StrategyExact, opts)
+		if err != nil {
+			t.Fatalf("ScanAndUpdateModules failed: %v", err)
+		}
+
+		updated, err := os.ReadFile(filepath.Join(tmpDir, "modules/vendored/main.tf"))
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		if string(updated) != content {
+			t.Errorf("expected ignored file to be untouched, got:\n%s", updated)
+		}
+	})
+}
+
+func TestUpdateModuleVersionInFile_Backup(t *testing.T) {
+	content := `
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+`
+	setup := func(t *testing.T) string {
+		tfFile := filepath.Join(t.TempDir(), "main.tf")
+		if err := os.WriteFile(tfFile, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		return tfFile
+	}
+
+	t.Run("backup contains original contents", func(t *testing.T) {
+		tfFile := setup(t)
+
+		changed, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, Options{Backup: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !changed {
+			t.Fatal("expected a change, got false")
+		}
+
+		backup, err := os.ReadFile(tfFile + DefaultBackupSuffix)
+		if err != nil {
+			t.Fatalf("Failed to read backup: %v", err)
+		}
+		if string(backup) != content {
+			t.Errorf("backup content = %q, want %q", string(backup), content)
+		}
+	})
+
+	t.Run("custom suffix", func(t *testing.T) {
+		tfFile := setup(t)
+
+		_, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, Options{Backup: true, BackupSuffix: ".orig"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := os.Stat(tfFile + ".orig"); err != nil {
+			t.Errorf("expected backup with custom suffix: %v", err)
+		}
+	})
+
+	t.Run("no backup in dry run", func(t *testing.T) {
+		tfFile := setup(t)
+
+		_, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, Options{Backup: true, DryRun: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := os.Stat(tfFile + DefaultBackupSuffix); !os.IsNotExist(err) {
+			t.Errorf("expected no backup in dry run, got err=%v", err)
+		}
+	})
+
+	t.Run("existing backup", func(t *testing.T) {
+		tfFile := setup(t)
+		if err := os.WriteFile(tfFile+DefaultBackupSuffix, []byte("old backup"), 0644); err != nil {
+			t.Fatalf("Failed to write backup: %v", err)
+		}
+
+		_, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, Options{Backup: true})
+		if err == nil {
+			t.Fatal("expected error for existing backup, got nil")
+		}
+		updated, _ := os.ReadFile(tfFile)
+		if string(updated) != content {
+			t.Error("file should not be modified when the backup fails")
+		}
+
+		_, _, _, err = UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, Options{Backup: true, OverwriteBackup: true})
+		if err != nil {
+			t.Fatalf("Unexpected error with overwrite: %v", err)
+		}
+		backup, _ := os.ReadFile(tfFile + DefaultBackupSuffix)
+		if string(backup) != content {
+			t.Errorf("backup content = %q, want %q", string(backup), content)
+		}
+	})
+
+	t.Run("file backed up once per run", func(t *testing.T) {
+		tfFile := setup(t)
+		opts := Options{Backup: true, BackedUp: make(map[string]bool)}
+
+		if _, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, opts); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "3.0.0", version.StrategyExact, opts); err != nil {
+			t.Fatalf("Unexpected error on second update: %v", err)
+		}
+
+		backup, _ := os.ReadFile(tfFile + DefaultBackupSuffix)
+		if string(backup) != content {
+			t.Errorf("backup content = %q, want original %q", string(backup), content)
+		}
+	})
+}
+
+func TestUpdateModuleVersionInFile_AtomicWrite(t *testing.T) {
+	content := `
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+`
+	t.Run("keeps file mode and leaves no temp files", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		tfFile := filepath.Join(tmpDir, "main.tf")
+		if err := os.WriteFile(tfFile, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := os.Chmod(tfFile, 0600); err != nil {
+			t.Fatalf("Failed to change file permissions: %v", err)
+		}
+
+		changed, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, Options{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !changed {
+			t.Fatal("expected a change, got false")
+		}
+
+		info, err := os.Stat(tfFile)
+		if err != nil {
+			t.Fatalf("Failed to stat file: %v", err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("file mode = %v, want %v", info.Mode().Perm(), os.FileMode(0600))
+		}
+
+		entries, err := os.ReadDir(tmpDir)
+		if err != nil {
+			t.Fatalf("Failed to read dir: %v", err)
+		}
+		if len(entries) != 1 {
+			t.Errorf("expected only the updated file in %s, got %d entries", tmpDir, len(entries))
+		}
+	})
+
+	t.Run("writes through symlinks", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		target := filepath.Join(tmpDir, "target.tf")
+		link := filepath.Join(tmpDir, "link.tf")
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+
+		if _, _, _, err := UpdateModuleVersionInFile(link, "test-module/aws", "2.0.0", version.StrategyExact, Options{}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		info, err := os.Lstat(link)
+		if err != nil {
+			t.Fatalf("Failed to stat link: %v", err)
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			t.Error("expected link to remain a symlink")
+		}
+		updated, _ := os.ReadFile(target)
+		if !strings.Contains(string(updated), `version = "2.0.0"`) {
+			t.Errorf("expected target to be updated, got:\n%s", updated)
+		}
+	})
+}
+
+func TestUpdateModuleVersionInFile_PreservesMode(t *testing.T) {
+	content := `
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+`
+	for _, mode := range []os.FileMode{0640, 0750, 0600} {
+		t.Run(mode.String(), func(t *testing.T) {
+			tfFile := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(tfFile, []byte(content), mode); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+			if err := os.Chmod(tfFile, mode); err != nil {
+				t.Fatalf("Failed to change file permissions: %v", err)
+			}
+
+			changed, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, Options{Backup: true})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !changed {
+				t.Fatal("expected a change, got false")
+			}
+
+			for _, f := range []string{tfFile, tfFile + DefaultBackupSuffix} {
+				info, err := os.Stat(f)
+				if err != nil {
+					t.Fatalf("Failed to stat %s: %v", f, err)
+				}
+				if info.Mode().Perm() != mode {
+					t.Errorf("%s mode = %v, want %v", filepath.Base(f), info.Mode().Perm(), mode)
+				}
+			}
+		})
+	}
+}
+
+func TestScanAndUpdateModules_FollowSymlinks(t *testing.T) {
+	content := `
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+`
+	setup := func(t *testing.T) string {
+		repo := t.TempDir()
+		moduleDir := filepath.Join(repo, "modules", "app")
+		if err := os.MkdirAll(moduleDir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(moduleDir, "main.tf"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if err := os.MkdirAll(filepath.Join(repo, "live"), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.Symlink(moduleDir, filepath.Join(repo, "live", "app")); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+		// A link back to an ancestor creates a loop
+		if err := os.Symlink(repo, filepath.Join(moduleDir, "loop")); err != nil {
+			t.Fatalf("Failed to create symlink: %v", err)
+		}
+		return repo
+	}
+
+	t.Run("each real file visited once", func(t *testing.T) {
+		repo := setup(t)
+
+		visits := make(map[string]int)
+		err := walkTree(repo, true, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if strings.HasSuffix(path, ".tf") {
+				real, _ := filepath.EvalSymlinks(path)
+				visits[real]++
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("walkTree failed: %v", err)
+		}
+
+		if len(visits) != 1 {
+			t.Fatalf("expected a single .tf file, got %v", visits)
+		}
+		for f, n := range visits {
+			if n != 1 {
+				t.Errorf("%s visited %d times, want 1", f, n)
+			}
+		}
+	})
+
+	tests := []struct {
+		name       string
+		opts       Options
+		wantUpdate bool
+	}{
+		{name: "symlinked subtree followed", opts: Options{FollowSymlinks: true}, wantUpdate: true},
+		{name: "symlinks not followed by default", opts: Options{}, wantUpdate: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := setup(t)
+
+			_, err := ScanAndUpdateModules(filepath.Join(repo, "live"), "test-module/aws", "2.0.0", nil, version.StrategyExact, tt.opts)
+			if err != nil {
+				t.Fatalf("ScanAndUpdateModules failed: %v", err)
+			}
+
+			updated, err := os.ReadFile(filepath.Join(repo, "modules", "app", "main.tf"))
+			if err != nil {
+				t.Fatalf("Failed to read file: %v", err)
+			}
+			if got := strings.Contains(string(updated), `version = "2.0.0"`); got != tt.wantUpdate {
+				t.Errorf("updated = %v, want %v; content:\n%s", got, tt.wantUpdate, updated)
+			}
+
+			info, err := os.Lstat(filepath.Join(repo, "live", "app"))
+			if err != nil || info.Mode()&os.ModeSymlink == 0 {
+				t.Errorf("expected live/app to remain a symlink")
+			}
+		})
+	}
+}
+
+func TestUpdateModuleVersionInFile_GitRef(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		pattern    string
+		target     string
+		strategy   version.Strategy
+		wantMod    bool
+		wantSource string
+		wantOld    string
+		wantNew    string
+		frozen     []FrozenVersion
+		resolve    func(existingVersion string) (string, error)
+	}{
+		{
+			name:       "bump v-prefixed ref",
+			source:     "git::https://example.com/org/vpc.git//modules/vpc?ref=v1.2.0",
+			pattern:    "modules/vpc",
+			target:     "1.4.0",
+			strategy:   version.StrategyExact,
+			wantMod:    true,
+			wantSource: "git::https://example.com/org/vpc.git//modules/vpc?ref=v1.4.0",
+			wantOld:    "v1.2.0",
+			wantNew:    "v1.4.0",
+		},
+		{
+			name:       "bump plain ref and keep other parameters",
+			source:     "git::ssh://git@example.com/org/vpc.git?depth=1&ref=1.2.0",
+			pattern:    "org/vpc",
+			target:     "2.0.0",
+			strategy:   version.StrategyDynamic,
+			wantMod:    true,
+			wantSource: "git::ssh://git@example.com/org/vpc.git?depth=1&ref=2.0.0",
+			wantOld:    "1.2.0",
+			wantNew:    "2.0.0",
+		},
+		{
+			name:       "github shorthand",
+			source:     "github.com/org/terraform-aws-vpc?ref=v1.2.0",
+			pattern:    "org/terraform-aws-vpc",
+			target:     "1.3.0",
+			strategy:   version.StrategyExact,
+			wantMod:    true,
+			wantSource: "github.com/org/terraform-aws-vpc?ref=v1.3.0",
+			wantOld:    "v1.2.0",
+			wantNew:    "v1.3.0",
+		},
+		{
+			name:       "range target satisfied by existing ref",
+			source:     "git::https://example.com/org/vpc.git?ref=v1.2.0",
+			pattern:    "org/vpc",
+			target:     ">=1.0.0,<2.0.0",
+			strategy:   version.StrategyRange,
+			wantMod:    false,
+			wantSource: "git::https://example.com/org/vpc.git?ref=v1.2.0",
+		},
+		{
+			name:       "range target not satisfied is skipped",
+			source:     "git::https://example.com/org/vpc.git?ref=v1.2.0",
+			pattern:    "org/vpc",
+			target:     ">=2.0.0,<3.0.0",
+			strategy:   version.StrategyRange,
+			wantMod:    false,
+			wantSource: "git::https://example.com/org/vpc.git?ref=v1.2.0",
+		},
+		{
+			name:       "non-semver ref is skipped",
+			source:     "git::https://example.com/org/vpc.git?ref=main",
+			pattern:    "org/vpc",
+			target:     "2.0.0",
+			strategy:   version.StrategyExact,
+			wantMod:    false,
+			wantSource: "git::https://example.com/org/vpc.git?ref=main",
+		},
+		{
+			name:       "frozen ref stays",
+			source:     "git::https://example.com/org/vpc.git?ref=v1.2.0",
+			pattern:    "org/vpc",
+			target:     "2.0.0",
+			strategy:   version.StrategyExact,
+			frozen:     []FrozenVersion{{Source: "org/vpc", Version: "1.2.0"}},
+			wantMod:    false,
+			wantSource: "git::https://example.com/org/vpc.git?ref=v1.2.0",
+		},
+		{
+			name:       "frozen ref with tag prefix stays",
+			source:     "git::https://example.com/org/vpc.git?ref=v1.2.0",
+			pattern:    "org/vpc",
+			target:     "2.0.0",
+			strategy:   version.StrategyExact,
+			frozen:     []FrozenVersion{{Source: "org/vpc", Version: "v1.2.0"}},
+			wantMod:    false,
+			wantSource: "git::https://example.com/org/vpc.git?ref=v1.2.0",
+		},
+		{
+			name:     "bump resolves target from ref",
+			source:   "git::https://example.com/org/vpc.git?ref=v1.2.0",
+			pattern:  "org/vpc",
+			target:   "5.0.0",
+			strategy: version.StrategyExact,
+			resolve: func(existing string) (string, error) {
+				return version.BumpVersion(existing, "patch")
+			},
+			wantMod:    true,
+			wantSource: "git::https://example.com/org/vpc.git?ref=v1.2.1",
+			wantOld:    "v1.2.0",
+			wantNew:    "v1.2.1",
+		},
+		{
+			name:       "local path is never versioned",
+			source:     "./modules/vpc",
+			pattern:    "modules/vpc",
+			target:     "2.0.0",
+			strategy:   version.StrategyExact,
+			wantMod:    false,
+			wantSource: "./modules/vpc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := fmt.Sprintf(`
+module "vpc" {
+  source = %q
+}
+`, tt.source)
+			tfFile := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(tfFile, []byte(content), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			changed, oldVersion, newVersion, err := UpdateModuleVersionInFile(tfFile, tt.pattern, tt.target, tt.strategy, Options{Force: true, Frozen: tt.frozen, ResolveTarget: tt.resolve, Output: io.Discard})
+			if err != nil {
+				t.Fatalf("UpdateModuleVersionInFile error: %v", err)
+			}
+			if changed != tt.wantMod {
+				t.Fatalf("expected changed=%v, got %v", tt.wantMod, changed)
+			}
+			if tt.wantMod && (oldVersion != tt.wantOld || newVersion != tt.wantNew) {
+				t.Errorf("expected %s -> %s, got %s -> %s", tt.wantOld, tt.wantNew, oldVersion, newVersion)
+			}
+
+			data, err := os.ReadFile(tfFile)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			updated := string(data)
+			if !strings.Contains(updated, fmt.Sprintf("source = %q", tt.wantSource)) {
+				t.Errorf("expected source %q, got:\n%s", tt.wantSource, updated)
+			}
+			if strings.Contains(updated, "version") {
+				t.Errorf("expected no version attribute to be added, got:\n%s", updated)
+			}
+		})
+	}
+}
+
+func TestScanAndUpdateModules_Terragrunt(t *testing.T) {
+	content := `
+include "root" {
+  path = find_in_parent_folders()
+}
+
+terraform {
+  source = "git::https://example.com/org/modules.git//modules/vpc?ref=v1.2.0"
+}
+
+inputs = {
+  cidr = "10.0.0.0/16"
+}
+`
+	local := `
+terraform {
+  source = "../../modules/vpc"
+}
+`
+	for _, terragrunt := range []bool{false, true} {
+		t.Run(fmt.Sprintf("terragrunt=%v", terragrunt), func(t *testing.T) {
+			workDir := t.TempDir()
+			remoteFile := filepath.Join(workDir, "dev", "vpc", TerragruntFile)
+			localFile := filepath.Join(workDir, "dev", "local", TerragruntFile)
+			for path, data := range map[string]string{remoteFile: content, localFile: local} {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatalf("failed to create directory: %v", err)
+				}
+				if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+					t.Fatalf("failed to write file: %v", err)
+				}
+			}
+
+			opts := Options{Terragrunt: terragrunt, Output: io.Discard}
+			changes, err := ScanAndUpdateModules(workDir, "modules/vpc", "1.4.0", map[string]bool{"dev": true}, version.StrategyExact, opts)
+			if err != nil {
+				t.Fatalf("ScanAndUpdateModules error: %v", err)
+			}
+
+			wantSource := "git::https://example.com/org/modules.git//modules/vpc?ref=v1.2.0"
+			if terragrunt {
+				wantSource = "git::https://example.com/org/modules.git//modules/vpc?ref=v1.4.0"
+				if len(changes) != 1 || changes[0].File != remoteFile || changes[0].OldVersion != "v1.2.0" || changes[0].NewVersion != "v1.4.0" {
+					t.Errorf("expected one change v1.2.0 -> v1.4.0 in %s, got %+v", remoteFile, changes)
+				}
+			} else if len(changes) != 0 {
+				t.Errorf("expected terragrunt.hcl to be ignored without Terragrunt, got %+v", changes)
+			}
+
+			data, err := os.ReadFile(remoteFile)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if !strings.Contains(string(data), fmt.Sprintf("source = %q", wantSource)) || !strings.Contains(string(data), `cidr = "10.0.0.0/16"`) {
+				t.Errorf("expected source %q with the rest of the file kept, got:\n%s", wantSource, data)
+			}
+
+			data, err = os.ReadFile(localFile)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if string(data) != local {
+				t.Errorf("expected local source to be left alone, got:\n%s", data)
+			}
+		})
+	}
+}
+
+func TestUpdateModuleVersionInFile_OnMissingVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		action      string
+		wantErr     bool
+		wantWarning bool
+	}{
+		{name: "default warns", action: "", wantWarning: true},
+		{name: "warn", action: "warn", wantWarning: true},
+		{name: "skip", action: "skip"},
+		{name: "error", action: "error", wantErr: true},
+	}
+
+	content := `
+module "vpc" {
+  source = "registry.example.com/test-module/aws"
+}
+`
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tfFile := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(tfFile, []byte(content), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			var out strings.Builder
+			changed, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, Options{OnMissingVersion: tt.action, Output: &out})
+			if tt.wantErr {
+				if !errors.Is(err, ErrMissingVersion) {
+					t.Fatalf("expected missing version error, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("UpdateModuleVersionInFile error: %v", err)
+			}
+			if changed {
+				t.Error("expected no change")
+			}
+
+			if gotWarning := strings.Contains(out.String(), "has no version attribute"); gotWarning != tt.wantWarning {
+				t.Errorf("expected warning=%v, got output %q", tt.wantWarning, out.String())
+			}
+
+			data, _ := os.ReadFile(tfFile)
+			if string(data) != content {
+				t.Errorf("Expected file to remain unchanged. Got:\n%s", string(data))
+			}
+		})
+	}
+}
+
+func TestUpdateModuleVersionInFile_OnInvalidExisting(t *testing.T) {
+	tests := []struct {
+		name        string
+		action      string
+		wantChange  bool
+		wantErr     bool
+		wantWarning bool
+	}{
+		{name: "default overwrites", action: "", wantChange: true},
+		{name: "overwrite", action: "overwrite", wantChange: true},
+		{name: "warn", action: "warn", wantWarning: true},
+		{name: "error", action: "error", wantErr: true},
+	}
+
+	content := `
+module "vpc" {
+  source  = "registry.example.com/test-module/aws"
+  version = "invalid"
+}
+`
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tfFile := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(tfFile, []byte(content), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			var out strings.Builder
+			changed, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, Options{OnInvalidExisting: tt.action, Output: &out})
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidVersion) {
+					t.Fatalf("expected invalid version error, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("UpdateModuleVersionInFile error: %v", err)
+			}
+			if changed != tt.wantChange {
+				t.Errorf("expected changed=%v, got %v", tt.wantChange, changed)
+			}
+
+			if gotWarning := strings.Contains(out.String(), `has an invalid version "invalid"`); gotWarning != tt.wantWarning {
+				t.Errorf("expected warning=%v, got output %q", tt.wantWarning, out.String())
+			}
+
+			data, _ := os.ReadFile(tfFile)
+			if gotChange := string(data) != content; gotChange != tt.wantChange {
+				t.Errorf("expected file changed=%v. Got:\n%s", tt.wantChange, string(data))
+			}
+		})
+	}
+}
+
+func TestUpdateModuleVersionInFile_SourceMatch(t *testing.T) {
+	content := `
+module "vpc" {
+  source  = "registry.example.com/aws/vpc"
+  version = "1.0.0"
+}
+
+module "nested" {
+  source  = "registry.example.com/foo/aws/vpc/bar"
+  version = "1.0.0"
+}
+`
+
+	tests := []struct {
+		name        string
+		match       string
+		pattern     string
+		wantChanged []string
+	}{
+		{name: "segment matches any window", pattern: "aws/vpc", wantChanged: []string{"aws/vpc", "foo/aws/vpc/bar"}},
+		{name: "explicit segment", match: "segment", pattern: "aws/vpc", wantChanged: []string{"aws/vpc", "foo/aws/vpc/bar"}},
+		{name: "exact matches the full source", match: "exact", pattern: "registry.example.com/aws/vpc", wantChanged: []string{"aws/vpc"}},
+		{name: "exact does not match segments", match: "exact", pattern: "aws/vpc"},
+		{name: "regex", match: "regex", pattern: `^registry\.example\.com/(foo/)?aws/vpc/bar$`, wantChanged: []string{"foo/aws/vpc/bar"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tfFile := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(tfFile, []byte(content), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			var changed []string
+			opts := Options{SourceMatch: tt.match, Output: io.Discard, OnDecision: func(d Decision) {
+				if d.OldVersion != d.NewVersion {
+					changed = append(changed, strings.TrimPrefix(d.Source, "registry.example.com/"))
+				}
+			}}
+			if _, _, _, err := UpdateModuleVersionInFile(tfFile, tt.pattern, "2.0.0", version.StrategyExact, opts); err != nil {
+				t.Fatalf("UpdateModuleVersionInFile error: %v", err)
+			}
+
+			if strings.Join(changed, " ") != strings.Join(tt.wantChanged, " ") {
+				t.Errorf("changed %v, want %v", changed, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestUpdateModuleVersionInFile_VersionPlacement(t *testing.T) {
+	content := `
+module "test" {
+  # the module under test
+  source = "registry.example.com/test-module/aws" # pinned by hclsemver
+  name   = "test"
+
+  tags = {
+    env = "dev"
+  }
+}
+`
+	tests := []struct {
+		name      string
+		placement string
+		want      string
+	}{
+		{
+			name: "end",
+			want: `
+module "test" {
+  # the module under test
+  source = "registry.example.com/test-module/aws" # pinned by hclsemver
+  name   = "test"
+
+  tags = {
+    env = "dev"
+  }
+  version = "2.0.0"
+}
+`,
+		},
+		{
+			name:      "after source",
+			placement: "after_source",
+			want: `
+module "test" {
+  # the module under test
+  source  = "registry.example.com/test-module/aws" # pinned by hclsemver
+  version = "2.0.0"
+  name    = "test"
+
+  tags = {
+    env = "dev"
+  }
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tfFile := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(tfFile, []byte(content), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			opts := Options{Force: true, VersionPlacement: tt.placement, Output: io.Discard}
+			changed, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, opts)
+			if err != nil || !changed {
+				t.Fatalf("UpdateModuleVersionInFile = %v, %v", changed, err)
+			}
+
+			data, _ := os.ReadFile(tfFile)
+			if string(data) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", data, tt.want)
+			}
+
+			// The rewritten body must still parse as the same module
+			versions, err := ReadModuleVersions(tfFile, "test-module/aws")
+			if err != nil || len(versions) != 1 || versions[0].Version != "2.0.0" {
+				t.Errorf("ReadModuleVersions = %v, %v", versions, err)
+			}
+		})
+	}
+}
+
+func TestUpdateModuleVersionInFile_LineEndings(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "crlf",
+			content: "module \"test\" {\r\n  source  = \"registry.example.com/test-module/aws\"\r\n  version = \"1.0.0\"\r\n}\r\n",
+			want:    "module \"test\" {\r\n  source  = \"registry.example.com/test-module/aws\"\r\n  version = \"2.0.0\"\r\n}\r\n",
+		},
+		{
+			name:    "crlf with added version",
+			content: "module \"test\" {\r\n  source = \"registry.example.com/test-module/aws\"\r\n}\r\n",
+			want:    "module \"test\" {\r\n  source  = \"registry.example.com/test-module/aws\"\r\n  version = \"2.0.0\"\r\n}\r\n",
+		},
+		{
+			name:    "crlf without final newline",
+			content: "module \"test\" {\r\n  source = \"registry.example.com/test-module/aws\"\r\n}",
+			want:    "module \"test\" {\r\n  source  = \"registry.example.com/test-module/aws\"\r\n  version = \"2.0.0\"\r\n}",
+		},
+		{
+			name:    "lf without final newline",
+			content: "module \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"1.0.0\"\n}",
+			want:    "module \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"2.0.0\"\n}",
+		},
+		{
+			name:    "mixed line endings",
+			content: "# lf\nmodule \"test\" {\r\n  source = \"registry.example.com/test-module/aws\"\r\n}\r\n",
+			want:    "# lf\nmodule \"test\" {\r\n  source  = \"registry.example.com/test-module/aws\"\r\n  version = \"2.0.0\"\n}\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tfFile := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(tfFile, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			opts := Options{Force: true, Output: io.Discard}
+			changed, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, opts)
+			if err != nil || !changed {
+				t.Fatalf("UpdateModuleVersionInFile = %v, %v", changed, err)
+			}
+
+			data, _ := os.ReadFile(tfFile)
+			if string(data) != tt.want {
+				t.Errorf("got %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateModuleVersionInFile_Format(t *testing.T) {
+	tfFile := filepath.Join(t.TempDir(), "main.tf")
+	content := `module "test" {
+  source = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+    name = "test"
+}
+
+resource "null_resource" "misaligned" {
+triggers = {
+  a = "1"
+  bbb = "2"
+  }
+}
+`
+	if err := os.WriteFile(tfFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	changed, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, Options{Output: io.Discard})
+	if err != nil || !changed {
+		t.Fatalf("UpdateModuleVersionInFile = %v, %v", changed, err)
+	}
+
+	// A written file takes the canonical layout throughout, not only on the changed lines
+	want := `module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "2.0.0"
+  name    = "test"
+}
+
+resource "null_resource" "misaligned" {
+  triggers = {
+    a   = "1"
+    bbb = "2"
+  }
+}
+`
+	data, _ := os.ReadFile(tfFile)
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestUpdateModuleVersionInFile_VersionOnly(t *testing.T) {
+	tfFile := filepath.Join(t.TempDir(), "main.tf")
+	content := `module "test" {
+  # pinned by the platform team
+  source  = "registry.example.com/test-module/aws" # keep
+  version = "1.0.0"
+
+  name        = "test-${var.env}"
+  description = <<-EOT
+    Heredocs   keep their  spacing,
+      "quotes" and ${var.env} templates.
+  EOT
+  tags = {
+    "Team" = "platform"
+  }
+}
+`
+	if err := os.WriteFile(tfFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	changed, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, Options{Output: io.Discard})
+	if err != nil || !changed {
+		t.Fatalf("UpdateModuleVersionInFile = %v, %v", changed, err)
+	}
+
+	// Only the version value is rewritten; the source, its comments and the other
+	// attributes keep their bytes
+	want := strings.Replace(content, `version = "1.0.0"`, `version = "2.0.0"`, 1)
+	data, _ := os.ReadFile(tfFile)
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestUpdateModuleVersionInFile_MetaArguments(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		force   bool
+		want    string
+	}{
+		{
+			name: "count before source",
+			content: `module "test" {
+  count   = 2
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+`,
+			want: `module "test" {
+  count   = 2
+  source  = "registry.example.com/test-module/aws"
+  version = "2.0.0"
+}
+`,
+		},
+		{
+			name: "for_each after version",
+			content: `module "test" {
+  source   = "registry.example.com/test-module/aws"
+  version  = "1.0.0"
+  for_each = var.envs
+
+  name = each.key
+}
+`,
+			want: `module "test" {
+  source   = "registry.example.com/test-module/aws"
+  version  = "2.0.0"
+  for_each = var.envs
+
+  name = each.key
+}
+`,
+		},
+		{
+			name: "for_each expression",
+			content: `module "test" {
+  for_each = { for env in var.envs : env => env if env != "version" }
+  source   = "registry.example.com/test-module/aws"
+  version  = "1.0.0"
+  depends_on = [module.network]
+}
+`,
+			want: `module "test" {
+  for_each   = { for env in var.envs : env => env if env != "version" }
+  source     = "registry.example.com/test-module/aws"
+  version    = "2.0.0"
+  depends_on = [module.network]
+}
+`,
+		},
+		{
+			name: "forced version with count",
+			content: `module "test" {
+  count  = var.enabled ? 1 : 0
+  source = "registry.example.com/test-module/aws"
+}
+`,
+			force: true,
+			want: `module "test" {
+  count   = var.enabled ? 1 : 0
+  source  = "registry.example.com/test-module/aws"
+  version = "2.0.0"
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tfFile := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(tfFile, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			// The meta-arguments keep their values and their place before or after version
+			opts := Options{Force: tt.force, Output: io.Discard}
+			changed, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, opts)
+			if err != nil || !changed {
+				t.Fatalf("UpdateModuleVersionInFile = %v, %v", changed, err)
+			}
+
+			data, _ := os.ReadFile(tfFile)
+			if string(data) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", data, tt.want)
+			}
+
+			versions, err := ReadModuleVersions(tfFile, "test-module/aws")
+			if err != nil || len(versions) != 1 || versions[0].Version != "2.0.0" {
+				t.Errorf("ReadModuleVersions = %v, %v", versions, err)
+			}
+		})
+	}
+}
+
+func TestUpdateModuleVersionInFile_SameSourceBlocks(t *testing.T) {
+	content := `module "newer" {
+  source  = "registry.example.com/test-module/aws"
+  version = "3.0.0"
+}
+
+module "unversioned" {
+  source = "registry.example.com/test-module/aws"
+}
+
+module "older" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+
+module "current" {
+  source  = "registry.example.com/test-module/aws"
+  version = "2.0.0"
+}
+`
+	want := `module "newer" {
+  source  = "registry.example.com/test-module/aws"
+  version = "3.0.0"
+}
+
+module "unversioned" {
+  source  = "registry.example.com/test-module/aws"
+  version = "2.0.0"
+}
+
+module "older" {
+  source  = "registry.example.com/test-module/aws"
+  version = "2.0.0"
+}
+
+module "current" {
+  source  = "registry.example.com/test-module/aws"
+  version = "2.0.0"
+}
+`
+	tfFile := filepath.Join(t.TempDir(), "main.tf")
+	if err := os.WriteFile(tfFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	// Each block is decided on its own version, in file order: the protection keeping
+	// 3.0.0 in the first block applies neither to the forced block nor to the older one
+	var decisions []Decision
+	opts := Options{Force: true, Output: io.Discard, OnDecision: func(d Decision) { decisions = append(decisions, d) }}
+	changes, _, err := updateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyDynamic, opts)
+	if err != nil {
+		t.Fatalf("updateModuleVersionInFile error: %v", err)
+	}
+
+	data, _ := os.ReadFile(tfFile)
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+
+	wantDecisions := []struct {
+		line     int
+		old, new string
+	}{{3, "3.0.0", "3.0.0"}, {7, "", "2.0.0"}, {12, "1.0.0", "2.0.0"}, {17, "2.0.0", "2.0.0"}}
+	if len(decisions) != len(wantDecisions) {
+		t.Fatalf("got %d decisions, want %d: %+v", len(decisions), len(wantDecisions), decisions)
+	}
+	for i, w := range wantDecisions {
+		if d := decisions[i]; d.Line != w.line || d.OldVersion != w.old || d.NewVersion != w.new {
+			t.Errorf("decision %d: got line %d %q -> %q, want line %d %q -> %q", i+1, d.Line, d.OldVersion, d.NewVersion, w.line, w.old, w.new)
+		}
+	}
+
+	// Each changed block is reported as its own change; the unchanged blocks are not
+	wantChanges := []struct {
+		line     int
+		old, new string
+	}{{7, "", "2.0.0"}, {12, "1.0.0", "2.0.0"}}
+	if len(changes) != len(wantChanges) {
+		t.Fatalf("got %d changes, want %d: %+v", len(changes), len(wantChanges), changes)
+	}
+	for i, w := range wantChanges {
+		if c := changes[i]; c.Line != w.line || c.OldVersion != w.old || c.NewVersion != w.new {
+			t.Errorf("change %d: got line %d %q -> %q, want line %d %q -> %q", i+1, c.Line, c.OldVersion, c.NewVersion, w.line, w.old, w.new)
+		}
+	}
+}
+
+func TestUpdateModuleVersionInFile_Annotate(t *testing.T) {
+	content := `module "plain" {
+  # the module under test
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+  name    = "plain"
+}
+
+module "commented" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0" # pinned by the platform team
+}
+
+module "missing" {
+  source = "registry.example.com/test-module/aws"
+}
+
+module "git" {
+  source = "git::https://example.com/test-module/aws.git?ref=v1.0.0"
+}
+`
+	tfFile := filepath.Join(t.TempDir(), "main.tf")
+	if err := os.WriteFile(tfFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	update := func(target string, strategy version.Strategy) {
+		t.Helper()
+		opts := Options{Annotate: true, Force: true, VersionPlacement: "after_source", Output: io.Discard}
+		changed, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", target, strategy, opts)
+		if err != nil || !changed {
+			t.Fatalf("UpdateModuleVersionInFile = %v, %v", changed, err)
+		}
+	}
+
+	update("2.0.0", version.StrategyExact)
+	want := `module "plain" {
+  # the module under test
+  source  = "registry.example.com/test-module/aws"
+  version = "2.0.0" # managed by hclsemver (exact strategy)
+  name    = "plain"
+}
+
+module "commented" {
+  source  = "registry.example.com/test-module/aws"
+  version = "2.0.0" # pinned by the platform team # managed by hclsemver (exact strategy)
+}
+
+module "missing" {
+  source  = "registry.example.com/test-module/aws"
+  version = "2.0.0" # managed by hclsemver (exact strategy)
+}
+
+module "git" {
+  source = "git::https://example.com/test-module/aws.git?ref=v2.0.0" # managed by hclsemver (exact strategy)
+}
+`
+	data, _ := os.ReadFile(tfFile)
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+
+	// A second run rewrites the annotations in place instead of adding more
+	update("3.0.0", version.StrategyDynamic)
+	want = strings.NewReplacer(`"2.0.0"`, `"3.0.0"`, "v2.0.0", "v3.0.0", "exact strategy", "dynamic strategy").Replace(want)
+	data, _ = os.ReadFile(tfFile)
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+
+	// The annotated file still parses to the versions written
+	versions, err := ReadModuleVersions(tfFile, "test-module/aws")
+	if err != nil {
+		t.Fatalf("ReadModuleVersions: %v", err)
+	}
+	for _, v := range versions {
+		if strings.TrimPrefix(v.Version, "v") != "3.0.0" {
+			t.Errorf("got version %q, want 3.0.0", v.Version)
+		}
+	}
+}
+
+func TestUpdateModuleVersionInFile_VersionExpressions(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     string
+		wantChanged bool
+		wantOld     string
+	}{
+		{name: "quoted literal", version: `"1.0.0"`, wantChanged: true, wantOld: "1.0.0"},
+		{name: "heredoc literal", version: "<<EOT\n1.0.0\nEOT", wantChanged: true, wantOld: "1.0.0"},
+		{name: "variable reference", version: "var.vpc_version"},
+		{name: "interpolation", version: `"${var.vpc_version}"`},
+		{name: "function call", version: `format("%s", "1.0.0")`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := `
+module "vpc" {
+  source  = "registry.example.com/test-module/aws"
+  version = ` + tt.version + `
+}
+`
+			tfFile := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(tfFile, []byte(content), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			var out strings.Builder
+			changed, oldVersion, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, Options{Output: &out})
+			if err != nil {
+				t.Fatalf("UpdateModuleVersionInFile error: %v", err)
+			}
+			if changed != tt.wantChanged {
+				t.Fatalf("expected changed=%v, got %v", tt.wantChanged, changed)
+			}
+
+			data, _ := os.ReadFile(tfFile)
+			if !tt.wantChanged {
+				if !strings.Contains(out.String(), "has a version that is not a string literal") {
+					t.Errorf("expected warning, got output %q", out.String())
+				}
+				if string(data) != content {
+					t.Errorf("Expected file to remain unchanged. Got:\n%s", string(data))
+				}
+				return
+			}
+
+			if oldVersion != tt.wantOld {
+				t.Errorf("expected old version %q, got %q", tt.wantOld, oldVersion)
+			}
+			if !strings.Contains(string(data), `version = "2.0.0"`) {
+				t.Errorf("expected version 2.0.0, got:\n%s", string(data))
+			}
+		})
+	}
+}
+
+func TestUpdateModuleVersionInFile_SyncSourceRef(t *testing.T) {
+	tests := []struct {
+		name        string
+		ref         string
+		version     string
+		target      string
+		sync        bool
+		wantChanged bool
+		wantRef     string
+		wantVersion string
+		wantWarning string
+	}{
+		{name: "disagreeing ref is reported", ref: "v1.2.0", version: "1.2.0", target: "1.4.0", wantChanged: true, wantRef: "v1.2.0", wantVersion: "1.4.0", wantWarning: `has source ref "v1.2.0" that disagrees with version "1.4.0"`},
+		{name: "ref and version updated together", ref: "v1.2.0", version: "1.2.0", target: "1.4.0", sync: true, wantChanged: true, wantRef: "v1.4.0", wantVersion: "1.4.0"},
+		{name: "stale ref reconciled with current version", ref: "1.1.0", version: "1.4.0", target: "1.4.0", sync: true, wantChanged: true, wantRef: "1.4.0", wantVersion: "1.4.0"},
+		{name: "agreeing ref left alone", ref: "v1.4.0", version: "1.4.0", target: "1.4.0", sync: true, wantRef: "v1.4.0", wantVersion: "1.4.0"},
+		{name: "ref within range agrees", ref: "v1.5.0", version: ">= 1.0.0, < 2.0.0", target: ">= 1.0.0, < 2.0.0", wantRef: "v1.5.0", wantVersion: ">= 1.0.0, < 2.0.0"},
+		{name: "ref outside range cannot be synced", ref: "v2.1.0", version: ">= 1.0.0, < 2.0.0", target: ">= 1.0.0, < 2.0.0", sync: true, wantRef: "v2.1.0", wantVersion: ">= 1.0.0, < 2.0.0", wantWarning: `cannot sync its source ref "v2.1.0" to range ">= 1.0.0, < 2.0.0"`},
+		{name: "non-semver ref not compared", ref: "main", version: "1.2.0", target: "1.4.0", sync: true, wantChanged: true, wantRef: "main", wantVersion: "1.4.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := fmt.Sprintf(`
+module "vpc" {
+  source  = "git::https://example.com/org/vpc.git//modules/vpc?ref=%s"
+  version = %q
+}
+`, tt.ref, tt.version)
+			tfFile := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(tfFile, []byte(content), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			var warnings []Warning
+			opts := Options{SyncSourceRef: tt.sync, Output: io.Discard, OnWarning: func(w Warning) { warnings = append(warnings, w) }}
+			changed, _, _, err := UpdateModuleVersionInFile(tfFile, "modules/vpc", tt.target, version.StrategyDynamic, opts)
+			if err != nil {
+				t.Fatalf("UpdateModuleVersionInFile error: %v", err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("expected changed=%v, got %v", tt.wantChanged, changed)
+			}
+
+			data, err := os.ReadFile(tfFile)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			updated := string(data)
+			if !strings.Contains(updated, "?ref="+tt.wantRef+`"`) || !strings.Contains(updated, fmt.Sprintf("version = %q", tt.wantVersion)) {
+				t.Errorf("expected ref %s and version %q, got:\n%s", tt.wantRef, tt.wantVersion, updated)
+			}
+
+			if tt.wantWarning == "" {
+				if len(warnings) != 0 {
+					t.Errorf("expected no warnings, got %+v", warnings)
+				}
+			} else if len(warnings) != 1 || !strings.Contains(warnings[0].Reason, tt.wantWarning) {
+				t.Errorf("expected warning %q, got %+v", tt.wantWarning, warnings)
+			}
+		})
+	}
+}
+
+func TestUpdateModuleVersionInFile_InterpolatedSource(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		pattern     string
+		partial     bool
+		wantChanged bool
+		wantWarning bool
+	}{
+		// Trimming the quotes used to leave "${local.registry}/test-module/aws", which matched
+		{name: "suffix matches without opt-in", source: `"${local.registry}/test-module/aws"`, pattern: "test-module/aws", wantWarning: true},
+		{name: "suffix matches with opt-in", source: `"${local.registry}/test-module/aws"`, pattern: "test-module/aws", partial: true, wantChanged: true},
+		{name: "registry host is not static", source: `"${local.registry}/test-module/aws"`, pattern: "registry.example.com/test-module/aws", partial: true},
+		{name: "suffix does not match", source: `"${local.registry}/other/aws"`, pattern: "test-module/aws", partial: true},
+		{name: "interpolation inside the pattern", source: `"registry.example.com/${var.name}/aws"`, pattern: "test-module/aws", partial: true},
+		{name: "interpolation only", source: `"${local.source}"`, pattern: "test-module/aws", partial: true},
+		{name: "reference", source: `local.source`, pattern: "local.source", partial: true},
+		{name: "git source", source: `"git::https://${local.host}/org/test-module.git?ref=v1.0.0"`, pattern: "test-module", partial: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := `
+module "vpc" {
+  source  = ` + tt.source + `
+  version = "1.0.0"
+}
+`
+			tfFile := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(tfFile, []byte(content), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			var warnings []Warning
+			opts := Options{Output: io.Discard, MatchPartialSource: tt.partial, OnWarning: func(w Warning) { warnings = append(warnings, w) }}
+			changed, _, _, err := UpdateModuleVersionInFile(tfFile, tt.pattern, "2.0.0", version.StrategyExact, opts)
+			if err != nil {
+				t.Fatalf("UpdateModuleVersionInFile error: %v", err)
+			}
+			if changed != tt.wantChanged {
+				t.Fatalf("expected changed=%v, got %v", tt.wantChanged, changed)
+			}
+			if tt.wantWarning != (len(warnings) == 1) {
+				t.Errorf("expected warning=%v, got %v", tt.wantWarning, warnings)
+			}
+			if tt.wantWarning && !strings.Contains(warnings[0].Reason, "match_partial_source") {
+				t.Errorf("expected the warning to name match_partial_source, got %q", warnings[0].Reason)
+			}
+
+			data, _ := os.ReadFile(tfFile)
+			if !tt.wantChanged {
+				if string(data) != content {
+					t.Errorf("Expected file to remain unchanged. Got:\n%s", string(data))
+				}
+				return
+			}
+			if !strings.Contains(string(data), `version = "2.0.0"`) || !strings.Contains(string(data), tt.source) {
+				t.Errorf("expected version 2.0.0 and the source kept, got:\n%s", string(data))
+			}
+			if versions, _ := ReadModuleVersions(tfFile, tt.pattern); len(versions) != 0 {
+				t.Errorf("expected ReadModuleVersions to leave out interpolated sources, got %v", versions)
+			}
+		})
+	}
+}
+
+func TestUpdateModuleVersionInFile_Frozen(t *testing.T) {
+	content := `
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.2.3"
+}
+`
+	tests := []struct {
+		name    string
+		tier    string
+		frozen  []FrozenVersion
+		wantMod bool
+	}{
+		{
+			name:    "frozen version stays",
+			tier:    "prd",
+			frozen:  []FrozenVersion{{Source: "test-module/aws", Version: "1.2.3"}},
+			wantMod: false,
+		},
+		{
+			name:    "frozen in matching tier",
+			tier:    "prd",
+			frozen:  []FrozenVersion{{Source: "test-module/aws", Tier: "prd", Version: "1.2.3"}},
+			wantMod: false,
+		},
+		{
+			name:    "frozen in another tier",
+			tier:    "stg",
+			frozen:  []FrozenVersion{{Source: "test-module/aws", Tier: "prd", Version: "1.2.3"}},
+			wantMod: true,
+		},
+		{
+			name:    "different frozen version",
+			tier:    "prd",
+			frozen:  []FrozenVersion{{Source: "test-module/aws", Version: "1.2.2"}},
+			wantMod: true,
+		},
+		{
+			name:    "different frozen source",
+			tier:    "prd",
+			frozen:  []FrozenVersion{{Source: "other-module/aws", Version: "1.2.3"}},
+			wantMod: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), tt.tier)
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				t.Fatalf("failed to create directory: %v", err)
+			}
+			tfFile := filepath.Join(dir, "main.tf")
+			if err := os.WriteFile(tfFile, []byte(content), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			var out strings.Builder
+			changed, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, Options{Frozen: tt.frozen, Output: &out})
+			if err != nil {
+				t.Fatalf("UpdateModuleVersionInFile error: %v", err)
+			}
+			if changed != tt.wantMod {
+				t.Fatalf("expected changed=%v, got %v", tt.wantMod, changed)
+			}
+
+			data, _ := os.ReadFile(tfFile)
+			if tt.wantMod {
+				if !strings.Contains(string(data), `version = "2.0.0"`) {
+					t.Errorf("Expected version to be updated. Got:\n%s", string(data))
+				}
+			} else {
+				if string(data) != content {
+					t.Errorf("Expected frozen version to stay. Got:\n%s", string(data))
+				}
+				if !strings.Contains(out.String(), "frozen") {
+					t.Errorf("Expected frozen message, got %q", out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestScanAndUpdateModules_ChangeLocation(t *testing.T) {
+	files := map[string]string{
+		// Each changed module is reported at its version attribute, on lines 4 and 9
+		"version.tf": `
+module "first" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+
+module "second" {
+  source = "registry.example.com/test-module/aws"
+	version = "1.5.0"
+}
+`,
+		// A forced version is located at the source attribute
+		"forced.tf": `module "forced" {
+  name   = "forced"
+  source = "registry.example.com/test-module/aws"
+}
+`,
+		"git.tf": `
+
+module "git" {
+    source = "git::https://example.com/org/test-module.git?ref=v1.0.0"
+}
+`,
+	}
+	want := map[string][][2]int{
+		"version.tf": {{4, 3}, {9, 2}},
+		"forced.tf":  {{3, 3}},
+		"git.tf":     {{4, 5}},
+	}
+
+	workDir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(workDir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	var out strings.Builder
+	changes, err := ScanAndUpdateModules(workDir, "test-module", "2.0.0", nil, version.StrategyExact, Options{Force: true, DryRun: true, Output: &out})
+	if err != nil {
+		t.Fatalf("ScanAndUpdateModules error: %v", err)
+	}
+	got := make(map[string][][2]int)
+	for _, c := range changes {
+		name := filepath.Base(c.File)
+		got[name] = append(got[name], [2]int{c.Line, c.Column})
+		if location := fmt.Sprintf("%s:%d:%d", c.File, c.Line, c.Column); c.Location() != location || !strings.Contains(out.String(), location) {
+			t.Errorf("%s: expected location %s in the report, got %q", name, location, out.String())
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got lines and columns %v, want %v", got, want)
+	}
+}
+
+func TestScanAndUpdateModules_Files(t *testing.T) {
+	content := `
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+`
+	workDir := t.TempDir()
+	outside := t.TempDir()
+	paths := map[string]string{
+		"a":       filepath.Join(workDir, "dev", "a.tf"),
+		"b":       filepath.Join(workDir, "dev", "nested", "b.tf"),
+		"c":       filepath.Join(workDir, "dev", "c.tf"),
+		"ignored": filepath.Join(workDir, "dev", "vendor", "d.tf"),
+		"outside": filepath.Join(outside, "dev", "e.tf"),
+	}
+	for _, path := range paths {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(workDir, ".gitignore"), []byte("vendor/\n"), 0o600); err != nil {
+		t.Fatalf("failed to write ignore file: %v", err)
+	}
+
+	opts := Options{
+		RespectIgnore: true,
+		Output:        io.Discard,
+		Files: []string{
+			paths["a"],
+			paths["b"],
+			paths["ignored"],
+			paths["outside"],
+			filepath.Join(workDir, "dev", "deleted.tf"),
+			filepath.Join(workDir, ".gitignore"),
+		},
+	}
+	changes, err := ScanAndUpdateModules(workDir, "test-module/aws", "2.0.0", map[string]bool{"dev": true}, version.StrategyExact, opts)
+	if err != nil {
+		t.Fatalf("ScanAndUpdateModules error: %v", err)
+	}
+	if len(changes) != 2 || changes[0].File != paths["a"] || changes[1].File != paths["b"] {
+		t.Errorf("expected changes to a.tf and b.tf in order, got %+v", changes)
+	}
+
+	for name, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		wantUpdated := name == "a" || name == "b"
+		if updated := strings.Contains(string(data), `version = "2.0.0"`); updated != wantUpdated {
+			t.Errorf("%s: expected updated=%v, got:\n%s", name, wantUpdated, data)
+		}
+	}
+}
+
+func TestReadModuleVersions(t *testing.T) {
+	content := `
+module "registry" {
+  source  = "registry.example.com/org/vpc/aws"
+  version = "~> 1.2"
+}
+
+module "git" {
+  source = "git::https://example.com/org/vpc.git?ref=v1.3.0"
+}
+
+module "unversioned" {
+  source = "registry.example.com/org/vpc/aws"
+}
+
+module "local" {
+  source = "./org/vpc"
+}
+
+module "other" {
+  source  = "registry.example.com/org/s3/aws"
+  version = "2.0.0"
+}
+`
+	filename := filepath.Join(t.TempDir(), "main.tf")
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write tf file: %v", err)
+	}
+
+	got, err := ReadModuleVersions(filename, "org/vpc")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []ModuleVersion{
+		{File: filename, Source: "registry.example.com/org/vpc/aws", Version: "~> 1.2"},
+		{File: filename, Source: "git::https://example.com/org/vpc.git?ref=v1.3.0", Version: "v1.3.0"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if err := os.WriteFile(filename, []byte("module {"), 0644); err != nil {
+		t.Fatalf("Failed to write tf file: %v", err)
+	}
+	if _, err := ReadModuleVersions(filename, "org/vpc"); !errors.Is(err, ErrParse) {
+		t.Errorf("expected ErrParse, got %v", err)
+	}
+}
+
+func TestUpdateModuleVersionInFile_IgnoreMarkers(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantMsg string
+	}{
+		{
+			name: "ignored block",
+			content: `
+# hclsemver:ignore
+module "pinned" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+
+module "updated" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+`,
+			want: `
+# hclsemver:ignore
+module "pinned" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+
+module "updated" {
+  source  = "registry.example.com/test-module/aws"
+  version = "2.0.0"
+}
+`,
+			wantMsg: "is marked hclsemver:ignore",
+		},
+		{
+			name: "marker with reason among other comments",
+			content: `
+// Pinned for the migration
+// hclsemver:ignore until v2 is rolled out
+module "pinned" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+`,
+			wantMsg: "is marked hclsemver:ignore",
+		},
+		{
+			name: "ignored file",
+			content: `# hclsemver:ignore-file
+
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+`,
+			wantMsg: "is marked hclsemver:ignore-file",
+		},
+		{
+			name: "file marker below content does not apply",
+			content: `
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+# hclsemver:ignore-file
+`,
+			want: `
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "2.0.0"
+}
+# hclsemver:ignore-file
+`,
+		},
+		{
+			name: "unrelated comment",
+			content: `
+# hclsemver:ignored-by-nobody
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+`,
+			want: `
+# hclsemver:ignored-by-nobody
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "2.0.0"
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tfFile := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(tfFile, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			// Markers win over force
+			var out strings.Builder
+			changed, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, Options{Force: true, Output: &out})
+			if err != nil {
+				t.Fatalf("UpdateModuleVersionInFile error: %v", err)
+			}
+
+			want := tt.want
+			if want == "" {
+				want = tt.content
+			}
+			if changed != (want != tt.content) {
+				t.Errorf("expected changed=%v, got %v", want != tt.content, changed)
+			}
+			data, _ := os.ReadFile(tfFile)
+			if string(data) != want {
+				t.Errorf("unexpected file contents:\n%s", string(data))
+			}
+			if tt.wantMsg != "" && !strings.Contains(out.String(), tt.wantMsg) {
+				t.Errorf("expected message %q, got %q", tt.wantMsg, out.String())
+			}
+		})
+	}
+}
+
+func TestUpdateModuleVersionInFile_Skips(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		opts    Options
+		want    []Skip
+	}{
+		{
+			name:    "no source match",
+			content: "module \"other\" {\n  source  = \"registry.example.com/other-module/aws\"\n  version = \"1.0.0\"\n}\n",
+			want:    []Skip{{Reason: SkipNoMatch}},
+		},
+		{
+			name:    "no version attribute",
+			content: "module \"test\" {\n  source = \"registry.example.com/test-module/aws\"\n}\n",
+			opts:    Options{OnMissingVersion: "skip"},
+			want:    []Skip{{Source: "registry.example.com/test-module/aws", Reason: SkipNoVersion}},
+		},
+		{
+			name:    "frozen",
+			content: "module \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"1.0.0\"\n}\n",
+			opts:    Options{Frozen: []FrozenVersion{{Source: "test-module/aws", Version: "1.0.0"}}},
+			want:    []Skip{{Source: "registry.example.com/test-module/aws", Reason: SkipFrozen}},
+		},
+		{
+			name:    "ignored block",
+			content: "# hclsemver:ignore\nmodule \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"1.0.0\"\n}\n",
+			want:    []Skip{{Source: "registry.example.com/test-module/aws", Reason: SkipIgnored}},
+		},
+		{
+			name:    "ignored file",
+			content: "# hclsemver:ignore-file\nmodule \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"1.0.0\"\n}\n",
+			want:    []Skip{{Reason: SkipIgnored}},
+		},
+		{
+			name:    "updated",
+			content: "module \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"1.0.0\"\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tfFile := filepath.Join(t.TempDir(), "main.tf")
+			if err := os.WriteFile(tfFile, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			var skips []Skip
+			opts := tt.opts
+			opts.Output = io.Discard
+			opts.OnSkipped = func(s Skip) { skips = append(skips, s) }
+			if _, _, _, err := UpdateModuleVersionInFile(tfFile, "test-module/aws", "2.0.0", version.StrategyExact, opts); err != nil {
+				t.Fatalf("UpdateModuleVersionInFile error: %v", err)
+			}
+
+			if len(skips) != len(tt.want) {
+				t.Fatalf("expected skips %+v, got %+v", tt.want, skips)
+			}
+			for i, want := range tt.want {
+				want.File = tfFile
+				if skips[i] != want {
+					t.Errorf("expected skip %+v, got %+v", want, skips[i])
+				}
+			}
+		})
+	}
+}
+
+func TestScanAndUpdateModules_SkipTierExcluded(t *testing.T) {
+	workDir := t.TempDir()
+	content := "module \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"1.0.0\"\n}\n"
+	for _, tier := range []string{"dev", "prd"} {
+		if err := os.MkdirAll(filepath.Join(workDir, tier), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(workDir, tier, "main.tf"), []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	var skips []Skip
+	opts := Options{DryRun: true, Output: io.Discard, OnSkipped: func(s Skip) { skips = append(skips, s) }}
+	if _, err := ScanAndUpdateModules(workDir, "test-module/aws", "2.0.0", map[string]bool{"dev": true}, version.StrategyExact, opts); err != nil {
+		t.Fatalf("ScanAndUpdateModules error: %v", err)
+	}
+
+	want := Skip{File: filepath.Join(workDir, "prd", "main.tf"), Reason: SkipTierExcluded}
+	if len(skips) != 1 || skips[0] != want {
+		t.Errorf("expected skips [%+v], got %+v", want, skips)
+	}
+}
+
+func TestScanAndUpdateModules_FileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		broken  string
+		opts    Options
+		wantErr error
+	}{
+		{
+			name:    "missing version",
+			broken:  "module \"test\" {\n  source = \"registry.example.com/test-module/aws\"\n}\n",
+			opts:    Options{OnMissingVersion: "error"},
+			wantErr: ErrMissingVersion,
+		},
+		{
+			name:    "invalid existing version",
+			broken:  "module \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"invalid\"\n}\n",
+			opts:    Options{OnInvalidExisting: "error"},
+			wantErr: ErrInvalidVersion,
+		},
+	}
+
+	valid := "module \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"1.0.0\"\n}\n"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			// The broken file is visited first, so the valid one is only updated if the scan goes on
+			brokenFile, validFile := filepath.Join(workDir, "a.tf"), filepath.Join(workDir, "b.tf")
+			if err := os.WriteFile(brokenFile, []byte(tt.broken), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			if err := os.WriteFile(validFile, []byte(valid), 0o600); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			opts := tt.opts
+			opts.Output = io.Discard
+			changes, err := ScanAndUpdateModules(workDir, "test-module/aws", "2.0.0", nil, version.StrategyExact, opts)
+			if !errors.Is(err, tt.wantErr) || !strings.Contains(err.Error(), brokenFile) {
+				t.Fatalf("expected an error for %s wrapping %v, got %v", brokenFile, tt.wantErr, err)
+			}
+			if len(changes) != 1 || changes[0].File != validFile {
+				t.Errorf("expected only %s to change, got %+v", validFile, changes)
+			}
+			if data, _ := os.ReadFile(validFile); !strings.Contains(string(data), `version = "2.0.0"`) {
+				t.Errorf("expected %s to be updated, got:\n%s", validFile, data)
+			}
+			if data, _ := os.ReadFile(brokenFile); string(data) != tt.broken {
+				t.Errorf("expected %s to remain unchanged, got:\n%s", brokenFile, data)
+			}
+		})
+	}
+}
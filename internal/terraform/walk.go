@@ -0,0 +1,84 @@
+package terraform
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// walkTree walks root like filepath.WalkDir. When followSymlinks is set, symlinked
+// directories are descended into and symlinked files are reported, with every real
+// directory and file visited at most once so symlink loops terminate.
+func walkTree(root string, followSymlinks bool, fn fs.WalkDirFunc) error {
+	if !followSymlinks {
+		return filepath.WalkDir(root, fn)
+	}
+
+	w := &symlinkWalker{visited: make(map[string]bool), fn: fn}
+	return w.walk(root)
+}
+
+// symlinkWalker tracks the real paths already visited while following symlinks
+type symlinkWalker struct {
+	visited map[string]bool
+	fn      fs.WalkDirFunc
+}
+
+// walk visits the tree at path, reporting entries under path even when path
+// itself is a symlink to a directory elsewhere
+func (w *symlinkWalker) walk(path string) error {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return w.fn(path, nil, err)
+	}
+
+	err = filepath.WalkDir(real, func(p string, d fs.DirEntry, err error) error {
+		// Report paths relative to the (possibly symlinked) starting point
+		reported := path + strings.TrimPrefix(p, real)
+		if err != nil {
+			return w.fn(reported, d, err)
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			return w.walkSymlink(reported)
+		}
+
+		if w.visited[p] {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		w.visited[p] = true
+
+		return w.fn(reported, d, nil)
+	})
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// walkSymlink follows a symlink found during the walk
+func (w *symlinkWalker) walkSymlink(path string) error {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		// Dangling links are skipped, like WalkDir skips what it cannot stat
+		return nil
+	}
+	if w.visited[real] {
+		return nil
+	}
+
+	info, err := os.Stat(real)
+	if err != nil {
+		return nil
+	}
+	if info.IsDir() {
+		return w.walk(path)
+	}
+
+	w.visited[real] = true
+	return w.fn(path, fs.FileInfoToDirEntry(info), nil)
+}
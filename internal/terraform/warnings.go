@@ -0,0 +1,52 @@
+package terraform
+
+import "fmt"
+
+// Warning describes a matching module block that was left unchanged for a reason
+// worth reporting, such as a missing version attribute
+type Warning struct {
+	// Source is the module source as written in the file
+	Source string
+	File   string
+	// Reason completes the sentence "Module <source> ...", e.g. "has no version attribute"
+	Reason string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("Module %q in file %s %s", w.Source, w.File, w.Reason)
+}
+
+// warn reports w to opts.OnWarning, or prints it when no handler is set
+func (o Options) warn(w Warning) {
+	if o.OnWarning != nil {
+		o.OnWarning(w)
+		return
+	}
+	fmt.Fprintf(o.output(), "Warning: %s. Skipping.\n", w)
+}
+
+// SummarizeWarnings de-duplicates warnings by source and reason, returning one line per
+// group in the order the groups were first seen, e.g.
+// `Module "org/vpc/aws" has no version attribute in 14 files`
+func SummarizeWarnings(warnings []Warning) []string {
+	type key struct{ source, reason string }
+	var order []key
+	files := make(map[key][]string)
+	for _, w := range warnings {
+		k := key{w.Source, w.Reason}
+		if _, ok := files[k]; !ok {
+			order = append(order, k)
+		}
+		files[k] = append(files[k], w.File)
+	}
+
+	lines := make([]string, 0, len(order))
+	for _, k := range order {
+		if n := len(files[k]); n > 1 {
+			lines = append(lines, fmt.Sprintf("Module %q %s in %d files", k.source, k.reason, n))
+		} else {
+			lines = append(lines, fmt.Sprintf("Module %q %s in file %s", k.source, k.reason, files[k][0]))
+		}
+	}
+	return lines
+}
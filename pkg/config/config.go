@@ -1,82 +1,615 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/david1155/hclsemver/pkg/version"
 	"gopkg.in/yaml.v3"
 )
 
 type VersionConfig struct {
-	Strategy version.Strategy `json:"strategy,omitempty" yaml:"strategy,omitempty"`
-	Version  string           `json:"version,omitempty" yaml:"version,omitempty"`
-	Force    *bool            `json:"force,omitempty" yaml:"force,omitempty"`
+	Strategy   version.Strategy `json:"strategy,omitempty" yaml:"strategy,omitempty"`
+	Version    string           `json:"version,omitempty" yaml:"version,omitempty"`
+	Force      *bool            `json:"force,omitempty" yaml:"force,omitempty"`
+	CollapseOr *bool            `json:"collapse_or,omitempty" yaml:"collapse_or,omitempty"`
+	// OnMissingVersion is one of the MissingVersion* actions
+	OnMissingVersion string `json:"on_missing_version,omitempty" yaml:"on_missing_version,omitempty"`
+	// OnInvalidExisting is one of the InvalidExisting* actions
+	OnInvalidExisting string `json:"on_invalid_existing,omitempty" yaml:"on_invalid_existing,omitempty"`
+	// VersionPlacement is one of the VersionPlacement* positions for a version added by force
+	VersionPlacement string `json:"version_placement,omitempty" yaml:"version_placement,omitempty"`
+	// MinVersion is the lowest version that may ever be written
+	MinVersion string `json:"min_version,omitempty" yaml:"min_version,omitempty"`
+	// MaxVersion is the highest version that may ever be written
+	MaxVersion string `json:"max_version,omitempty" yaml:"max_version,omitempty"`
+	// MaxVersionPolicy is one of the MaxVersion* policies for results above MaxVersion
+	MaxVersionPolicy string `json:"max_version_policy,omitempty" yaml:"max_version_policy,omitempty"`
+	// BlockedBumpPolicy is one of the BlockedBump* policies for bumps the strategy blocks
+	BlockedBumpPolicy string `json:"blocked_bump_policy,omitempty" yaml:"blocked_bump_policy,omitempty"`
+	// PreserveStyle keeps the spacing style of the existing constraint when writing ranges
+	PreserveStyle *bool `json:"preserve_style,omitempty" yaml:"preserve_style,omitempty"`
+	// SortOrBranches orders the OR branches of written ranges by their lower bound
+	SortOrBranches *bool `json:"sort_or_branches,omitempty" yaml:"sort_or_branches,omitempty"`
+	// OutputFormat is one of the OutputFormat* spacings for written ranges
+	OutputFormat string `json:"output_format,omitempty" yaml:"output_format,omitempty"`
+	// Pre10Ranges is one of the Pre10Ranges* treatments of range targets below 1.0.0
+	Pre10Ranges string `json:"pre_1_0_ranges,omitempty" yaml:"pre_1_0_ranges,omitempty"`
+	// RangeMerge is how an existing range is combined with a range target: keep,
+	// intersect or union
+	RangeMerge string `json:"range_merge,omitempty" yaml:"range_merge,omitempty"`
+	// PrereleaseRange bounds the range written for an exact pre-release target:
+	// next_major, same_major or lock_target
+	PrereleaseRange string `json:"prerelease_range,omitempty" yaml:"prerelease_range,omitempty"`
 }
 
 type ModuleConfig struct {
-	Source   string                 `json:"source" yaml:"source"`
-	Strategy version.Strategy       `json:"strategy,omitempty" yaml:"strategy,omitempty"`
-	Force    bool                   `json:"force,omitempty" yaml:"force,omitempty"`
-	Versions map[string]interface{} `json:"versions" yaml:"versions"` // tier -> version or VersionConfig
+	Source     string           `json:"source" yaml:"source"`
+	Name       string           `json:"name,omitempty" yaml:"name,omitempty"` // label in reports, such as "VPC module"; matching uses Source
+	Strategy   version.Strategy `json:"strategy,omitempty" yaml:"strategy,omitempty"`
+	Force      bool             `json:"force,omitempty" yaml:"force,omitempty"`
+	CollapseOr bool             `json:"collapse_or,omitempty" yaml:"collapse_or,omitempty"`
+	// OnMissingVersion is one of the MissingVersion* actions
+	OnMissingVersion string `json:"on_missing_version,omitempty" yaml:"on_missing_version,omitempty"`
+	// OnInvalidExisting is one of the InvalidExisting* actions
+	OnInvalidExisting string `json:"on_invalid_existing,omitempty" yaml:"on_invalid_existing,omitempty"`
+	// VersionPlacement is one of the VersionPlacement* positions for a version added by force
+	VersionPlacement string `json:"version_placement,omitempty" yaml:"version_placement,omitempty"`
+	// PreserveStyle keeps the spacing style of the existing constraint when writing ranges
+	PreserveStyle bool `json:"preserve_style,omitempty" yaml:"preserve_style,omitempty"`
+	// SortOrBranches orders the OR branches of written ranges by their lower bound
+	SortOrBranches bool `json:"sort_or_branches,omitempty" yaml:"sort_or_branches,omitempty"`
+	// OutputFormat is one of the OutputFormat* spacings for written ranges
+	OutputFormat string `json:"output_format,omitempty" yaml:"output_format,omitempty"`
+	// Pre10Ranges is one of the Pre10Ranges* treatments of range targets below 1.0.0
+	Pre10Ranges string `json:"pre_1_0_ranges,omitempty" yaml:"pre_1_0_ranges,omitempty"`
+	// RangeMerge is how an existing range is combined with a range target: keep,
+	// intersect or union
+	RangeMerge string `json:"range_merge,omitempty" yaml:"range_merge,omitempty"`
+	// PrereleaseRange bounds the range written for an exact pre-release target:
+	// next_major, same_major or lock_target
+	PrereleaseRange string `json:"prerelease_range,omitempty" yaml:"prerelease_range,omitempty"`
+	// MatchPartialSource matches interpolated sources such as "${local.registry}/vpc/aws"
+	// on the static text after their last interpolation
+	MatchPartialSource bool `json:"match_partial_source,omitempty" yaml:"match_partial_source,omitempty"`
+	// Match is one of the Match* ways Source is matched against module sources
+	Match string `json:"match,omitempty" yaml:"match,omitempty"`
+	// SyncSourceRef updates the ref in the source of a module with a version attribute
+	// together with that attribute
+	SyncSourceRef bool `json:"sync_source_ref,omitempty" yaml:"sync_source_ref,omitempty"`
+	// PostUpdateHook is run on each file the module changes, in place of the config's
+	PostUpdateHook StringList             `json:"post_update_hook,omitempty" yaml:"post_update_hook,omitempty"`
+	Versions       map[string]interface{} `json:"versions" yaml:"versions"` // tier -> version or VersionConfig
 }
 
+// Ways a module's source is matched against the sources in the files
+const (
+	// MatchSegment matches sources containing the configured source as consecutive path
+	// segments, so "aws/vpc" matches "registry.example.com/aws/vpc"
+	MatchSegment = "segment"
+	// MatchExact matches only sources equal to the configured source
+	MatchExact = "exact"
+	// MatchRegex matches sources with the configured source as a regular expression
+	MatchRegex = "regex"
+)
+
+// Actions for a matching module without a version attribute when force is not set
+const (
+	MissingVersionSkip  = "skip"
+	MissingVersionWarn  = "warn"
+	MissingVersionError = "error"
+)
+
+// Actions for a matching module whose existing version is not a valid version or range
+const (
+	// InvalidExistingOverwrite replaces the value with the target
+	InvalidExistingOverwrite = "overwrite"
+	// InvalidExistingWarn warns and leaves the module unchanged
+	InvalidExistingWarn = "warn"
+	// InvalidExistingError fails the file
+	InvalidExistingError = "error"
+)
+
+// Positions for a version attribute added by force
+const (
+	// VersionPlacementEnd appends the attribute to the module block
+	VersionPlacementEnd = "end"
+	// VersionPlacementAfterSource places the attribute on the line after source
+	VersionPlacementAfterSource = "after_source"
+)
+
+// Spacings for written ranges
+const (
+	// OutputFormatSpaced writes ">= 1.0.0, < 2.0.0"
+	OutputFormatSpaced = "spaced"
+	// OutputFormatCompact writes ">=1.0.0,<2.0.0"
+	OutputFormatCompact = "compact"
+)
+
+// Treatments of range targets starting below 1.0.0 with the range and dynamic strategies
+const (
+	// Pre10RangesCollapse pins a range such as ">=0.2.0,<0.3.0" to its minimum version
+	Pre10RangesCollapse = "collapse"
+	// Pre10RangesKeep writes the range as it is written for 1.0.0 and above
+	Pre10RangesKeep = "keep"
+)
+
+// FreezeEntry pins a module version that must never be changed until the entry is removed
+type FreezeEntry struct {
+	Source  string `json:"source" yaml:"source"`                 // Module source pattern to match
+	Tier    string `json:"tier,omitempty" yaml:"tier,omitempty"` // Tier the entry applies to; empty or "*" for all tiers
+	Version string `json:"version" yaml:"version"`               // Current version that is frozen
+}
+
+// Policies for an exact version above max_version
+const (
+	MaxVersionClamp = "clamp"
+	MaxVersionError = "error"
+)
+
+// Policies for a bump blocked by the patch_only or major_lock strategy
+const (
+	BlockedBumpKeep  = "keep"
+	BlockedBumpError = "error"
+)
+
+// Layouts of the work dir
+const (
+	// LayoutTiered expects one subdirectory per tier, named after the tier key
+	LayoutTiered = "tiered"
+	// LayoutFlat treats the whole work dir as a single tier; tier keys are labels only
+	LayoutFlat = "flat"
+)
+
 type Config struct {
-	Modules []ModuleConfig `json:"modules" yaml:"modules"`
+	// Extends names a base config, relative to this file, whose modules this config overrides
+	Extends string `json:"extends,omitempty" yaml:"extends,omitempty"`
+	// Layout is one of the Layout* values; empty means LayoutTiered
+	Layout string `json:"layout,omitempty" yaml:"layout,omitempty"`
+	// TierDirs maps tier keys to the directories, relative to the work dir, holding their
+	// files; tiers without an entry use a directory named after the tier key
+	TierDirs map[string]StringList `json:"tier_dirs,omitempty" yaml:"tier_dirs,omitempty"`
+	Modules  []ModuleConfig        `json:"modules" yaml:"modules"`
+	// Freeze lists module versions that are left untouched
+	Freeze []FreezeEntry `json:"freeze,omitempty" yaml:"freeze,omitempty"`
+	// PostUpdateHook is a command run on each changed file, with the file path appended
+	// as its last argument; see HookCommand
+	PostUpdateHook StringList `json:"post_update_hook,omitempty" yaml:"post_update_hook,omitempty"`
 }
 
-// UnmarshalVersionConfig handles both string and object version configurations
+// StringList is a list of strings that may also be written as a single string
+type StringList []string
+
+// UnmarshalJSON accepts either a string or an array of strings
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = StringList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("expected a string or a list of strings")
+	}
+	*l = list
+	return nil
+}
+
+// UnmarshalYAML accepts either a string or a sequence of strings
+func (l *StringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = StringList{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return fmt.Errorf("expected a string or a list of strings")
+	}
+	*l = list
+	return nil
+}
+
+// UnmarshalYAML decodes a module, keeping unquoted numeric versions such as 2.0 as written
+// instead of letting them become numbers that have lost their text
+func (m *ModuleConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == "versions" {
+				joinTierListKeys(node.Content[i+1])
+				quoteNumericVersions(node.Content[i+1])
+			}
+		}
+	}
+	type plain ModuleConfig
+	return node.Decode((*plain)(m))
+}
+
+// joinTierListKeys rewrites the tier keys of a versions mapping written as a YAML list,
+// such as [dev, stg], to the comma-joined form "dev,stg"
+func joinTierListKeys(versions *yaml.Node) {
+	versions = resolveAlias(versions)
+	if versions.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(versions.Content); i += 2 {
+		key := versions.Content[i]
+		if key.Kind != yaml.SequenceNode {
+			continue
+		}
+		tiers := make([]string, 0, len(key.Content))
+		for _, tier := range key.Content {
+			tiers = append(tiers, tier.Value)
+		}
+		versions.Content[i] = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: strings.Join(tiers, ",")}
+	}
+}
+
+// versionFields are the tier config keys holding a version
+var versionFields = map[string]bool{"version": true, "min_version": true, "max_version": true}
+
+// quoteNumericVersions retags the numeric versions of a versions mapping as strings: tier
+// values written as a bare version and the version fields of tier configs, including
+// those merged in from anchors
+func quoteNumericVersions(versions *yaml.Node) {
+	versions = resolveAlias(versions)
+	if versions.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 1; i < len(versions.Content); i += 2 {
+		tier := resolveAlias(versions.Content[i])
+		switch tier.Kind {
+		case yaml.ScalarNode:
+			quoteNumber(tier)
+		case yaml.MappingNode:
+			quoteVersionFields(tier)
+		}
+	}
+}
+
+// quoteVersionFields retags the numeric version fields of a tier config mapping as strings
+func quoteVersionFields(tier *yaml.Node) {
+	for i := 0; i+1 < len(tier.Content); i += 2 {
+		key, value := tier.Content[i], resolveAlias(tier.Content[i+1])
+		switch {
+		case key.Value == "<<" && value.Kind == yaml.MappingNode:
+			quoteVersionFields(value)
+		case key.Value == "<<" && value.Kind == yaml.SequenceNode:
+			for _, merged := range value.Content {
+				if merged = resolveAlias(merged); merged.Kind == yaml.MappingNode {
+					quoteVersionFields(merged)
+				}
+			}
+		case versionFields[key.Value]:
+			quoteNumber(value)
+		}
+	}
+}
+
+// quoteNumber retags an integer or float scalar as a string, keeping its text
+func quoteNumber(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && (node.ShortTag() == "!!int" || node.ShortTag() == "!!float") {
+		node.Tag = "!!str"
+	}
+}
+
+// resolveAlias returns the node an alias refers to, or node itself
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// versionString converts a version written as a scalar to its string form. Numbers decoded
+// with their text, as integers or json.Number, are accepted; a float has lost its text,
+// "2.0" having become 2, so it must be quoted.
+func versionString(field string, data interface{}) (string, error) {
+	switch v := data.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return "", fmt.Errorf("%s %v must be quoted, e.g. \"%v\"", field, v, v)
+	default:
+		return "", fmt.Errorf("%s must be a version string, got %T %v", field, data, data)
+	}
+}
+
+// listComparator matches a single comparison written as an element of a comparator list,
+// such as ">=1.0.0" or "< 2"
+var listComparator = regexp.MustCompile(`^(>=|<=|!=|~>|>|<|=|~|\^)\s*(v?[0-9][0-9A-Za-z.+-]*)$`)
+
+// comparatorList joins a version written as a list of comparisons, such as
+// [">=1.0.0", "<2.0.0"], into the constraint ">= 1.0.0, < 2.0.0". Each element must be
+// a string holding one operator and a version.
+func comparatorList(field string, list []interface{}) (string, error) {
+	if len(list) == 0 {
+		return "", fmt.Errorf("%s list must hold at least one comparison", field)
+	}
+	comparisons := make([]string, len(list))
+	for i, element := range list {
+		s, ok := element.(string)
+		if !ok {
+			return "", fmt.Errorf("%s list element %d must be a comparison string such as \">= 1.0.0\", got %T %v", field, i+1, element, element)
+		}
+		m := listComparator.FindStringSubmatch(strings.TrimSpace(s))
+		if m == nil {
+			return "", fmt.Errorf("%s list element %d %q is not a single comparison such as \">= 1.0.0\"", field, i+1, s)
+		}
+		if _, err := semver.NewVersion(m[2]); err != nil {
+			return "", fmt.Errorf("%s list element %d %q has an invalid version: %w", field, i+1, s, err)
+		}
+		comparisons[i] = m[1] + " " + m[2]
+	}
+	return strings.Join(comparisons, ", "), nil
+}
+
+// HookCommand returns the post_update_hook of a module, or the config's when the module has
+// none, as program and arguments. A hook written as a single string is split on whitespace.
+func HookCommand(config *Config, moduleConfig ModuleConfig) []string {
+	hook := moduleConfig.PostUpdateHook
+	if len(hook) == 0 {
+		hook = config.PostUpdateHook
+	}
+	if len(hook) == 1 {
+		return strings.Fields(hook[0])
+	}
+	return hook
+}
+
+// ModuleName returns the name of a module for reports, or its source when it has none
+func ModuleName(moduleConfig ModuleConfig) string {
+	if moduleConfig.Name != "" {
+		return moduleConfig.Name
+	}
+	return moduleConfig.Source
+}
+
+// GetTierDirs returns the directories, relative to the work dir, that hold the files of
+// a tier: its tier_dirs entry, or a directory named after the tier. Directories written
+// with \ are returned separated by /, so they join to the same path on every platform.
+func GetTierDirs(config *Config, tier string) []string {
+	dirs := []string{tier}
+	if listed, ok := config.TierDirs[tier]; ok && len(listed) > 0 {
+		dirs = listed
+	}
+	normalized := make([]string, len(dirs))
+	for i, dir := range dirs {
+		normalized[i] = strings.ReplaceAll(dir, `\`, "/")
+	}
+	return normalized
+}
+
+// UnmarshalVersionConfig handles both string and object version configurations, including
+// objects built from YAML anchors and merge keys
 func UnmarshalVersionConfig(data interface{}) (VersionConfig, error) {
 	switch v := data.(type) {
 	case string:
 		return VersionConfig{Version: v}, nil
+	case json.Number, int, float64, bool:
+		ver, err := versionString("version", v)
+		if err != nil {
+			return VersionConfig{}, err
+		}
+		return VersionConfig{Version: ver}, nil
+	case []interface{}:
+		ver, err := comparatorList("version", v)
+		if err != nil {
+			return VersionConfig{}, err
+		}
+		return VersionConfig{Version: ver}, nil
 	case map[string]interface{}:
 		var config VersionConfig
 		if strategy, ok := v["strategy"].(string); ok {
 			config.Strategy = version.Strategy(strategy)
 		}
-		if version, ok := v["version"].(string); ok {
-			config.Version = version
+		for field, dst := range map[string]*string{"version": &config.Version, "min_version": &config.MinVersion, "max_version": &config.MaxVersion} {
+			if value, ok := v[field]; ok {
+				var ver string
+				var err error
+				if list, isList := value.([]interface{}); isList && field == "version" {
+					ver, err = comparatorList(field, list)
+				} else {
+					ver, err = versionString(field, value)
+				}
+				if err != nil {
+					return VersionConfig{}, err
+				}
+				*dst = ver
+			}
 		}
 		if force, ok := v["force"].(bool); ok {
 			config.Force = &force
 		}
+		if collapseOr, ok := v["collapse_or"].(bool); ok {
+			config.CollapseOr = &collapseOr
+		}
+		if onMissing, ok := v["on_missing_version"].(string); ok {
+			config.OnMissingVersion = onMissing
+		}
+		if onInvalid, ok := v["on_invalid_existing"].(string); ok {
+			config.OnInvalidExisting = onInvalid
+		}
+		if placement, ok := v["version_placement"].(string); ok {
+			config.VersionPlacement = placement
+		}
+		if policy, ok := v["max_version_policy"].(string); ok {
+			config.MaxVersionPolicy = policy
+		}
+		if policy, ok := v["blocked_bump_policy"].(string); ok {
+			config.BlockedBumpPolicy = policy
+		}
+		if preserveStyle, ok := v["preserve_style"].(bool); ok {
+			config.PreserveStyle = &preserveStyle
+		}
+		if sortOr, ok := v["sort_or_branches"].(bool); ok {
+			config.SortOrBranches = &sortOr
+		}
+		if format, ok := v["output_format"].(string); ok {
+			config.OutputFormat = format
+		}
+		if pre10, ok := v["pre_1_0_ranges"].(string); ok {
+			config.Pre10Ranges = pre10
+		}
+		if merge, ok := v["range_merge"].(string); ok {
+			config.RangeMerge = merge
+		}
+		if prerelease, ok := v["prerelease_range"].(string); ok {
+			config.PrereleaseRange = prerelease
+		}
 		return config, nil
+	case map[interface{}]interface{}:
+		// yaml.v3 decodes a mapping with any non-string key, e.g. one merged in with
+		// "<<: *anchor" next to a numeric key, this way
+		converted := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			converted[fmt.Sprint(key)] = value
+		}
+		return UnmarshalVersionConfig(converted)
 	default:
 		return VersionConfig{}, fmt.Errorf("invalid version config type: %T", data)
 	}
 }
 
-// GetEffectiveVersionConfig returns the effective version configuration for a tier,
-// considering wildcards and module defaults
-func GetEffectiveVersionConfig(moduleConfig ModuleConfig, tier string) (VersionConfig, error) {
-	// Try to get tier-specific config
-	if versionData, ok := moduleConfig.Versions[tier]; ok {
-		return UnmarshalVersionConfig(versionData)
+// NegatedTier returns the tier a version key such as "!prod" excludes. A negated key
+// applies to every tier except that one.
+func NegatedTier(key string) (string, bool) {
+	return strings.CutPrefix(key, "!")
+}
+
+// TierList returns the tiers of a version key such as "dev,stg" that lists several tiers
+// sharing one version config
+func TierList(key string) ([]string, bool) {
+	if !strings.Contains(key, ",") {
+		return nil, false
+	}
+	tiers := strings.Split(key, ",")
+	for i, tier := range tiers {
+		tiers[i] = strings.TrimSpace(tier)
 	}
+	return tiers, true
+}
+
+// TierPattern reports whether a version key such as "region-*" is a glob pattern naming
+// every tier directory it matches, with the syntax of path.Match. The wildcard "*" is not
+// a pattern.
+func TierPattern(key string) bool {
+	return key != "*" && strings.ContainsAny(key, "*?[")
+}
 
-	// Try to get wildcard config
-	if versionData, ok := moduleConfig.Versions["*"]; ok {
-		return UnmarshalVersionConfig(versionData)
+// MatchTierPattern reports whether tier is matched by the tier pattern, each directory of
+// a tier path such as "regions/us-east-1" by the directory of the pattern at its place
+func MatchTierPattern(pattern, tier string) bool {
+	matched, err := path.Match(pattern, tier)
+	return err == nil && matched
+}
+
+// TierPatternKey returns the most specific tier pattern of a module matching tier
+func TierPatternKey(moduleConfig ModuleConfig, tier string) (string, bool) {
+	if patterns := tierPatterns(moduleConfig, tier); len(patterns) > 0 {
+		return patterns[0], true
 	}
+	return "", false
+}
 
-	return VersionConfig{}, fmt.Errorf("no version configuration found for tier %s", tier)
+// tierPatterns returns the tier patterns of a module matching tier, most specific first:
+// those with the most characters outside their wildcards, then in lexical order
+func tierPatterns(moduleConfig ModuleConfig, tier string) []string {
+	var patterns []string
+	for key := range moduleConfig.Versions {
+		if TierPattern(key) && MatchTierPattern(key, tier) {
+			patterns = append(patterns, key)
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if li, lj := patternLiterals(patterns[i]), patternLiterals(patterns[j]); li != lj {
+			return li > lj
+		}
+		return patterns[i] < patterns[j]
+	})
+	return patterns
 }
 
-// GetEffectiveStrategy returns the effective strategy for a tier, considering wildcards and module defaults
-func GetEffectiveStrategy(moduleConfig ModuleConfig, tier string) version.Strategy {
-	// Try to get tier-specific config
-	if versionData, ok := moduleConfig.Versions[tier]; ok {
-		if config, err := UnmarshalVersionConfig(versionData); err == nil && config.Strategy != "" {
-			return config.Strategy
+// patternLiterals returns the number of characters a tier pattern matches literally. A
+// character class such as "[ab]" matches a single character of several, like "?", so it
+// counts as a wildcard position however many characters it lists.
+func patternLiterals(pattern string) int {
+	n := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?':
+		case '[':
+			for i++; i < len(pattern) && pattern[i] != ']'; i++ {
+				if pattern[i] == '\\' {
+					i++
+				}
+			}
+		case '\\':
+			i++
+			n++
+		default:
+			n++
 		}
 	}
+	return n
+}
 
-	// Try to get wildcard config
-	if versionData, ok := moduleConfig.Versions["*"]; ok {
-		if config, err := UnmarshalVersionConfig(versionData); err == nil && config.Strategy != "" {
-			return config.Strategy
+// tierKeys returns the version keys that may configure a tier, most specific first: the
+// tier itself, a tier list naming it, the tier patterns matching it, a negated key that
+// does not exclude it, then the wildcard
+func tierKeys(moduleConfig ModuleConfig, tier string) []string {
+	keys := []string{tier}
+	_, list := TierList(tier)
+	if _, negated := NegatedTier(tier); !negated && !list && !TierPattern(tier) && tier != "*" {
+		for key := range moduleConfig.Versions {
+			if tiers, ok := TierList(key); ok && slices.Contains(tiers, tier) {
+				keys = append(keys, key)
+			}
+		}
+		keys = append(keys, tierPatterns(moduleConfig, tier)...)
+		for key := range moduleConfig.Versions {
+			if excluded, ok := NegatedTier(key); ok && excluded != tier {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return append(keys, "*")
+}
+
+// GetEffectiveVersionConfig returns the effective version configuration for a tier,
+// considering negated tiers, wildcards and module defaults
+func GetEffectiveVersionConfig(moduleConfig ModuleConfig, tier string) (VersionConfig, error) {
+	for _, key := range tierKeys(moduleConfig, tier) {
+		if versionData, ok := moduleConfig.Versions[key]; ok {
+			return UnmarshalVersionConfig(versionData)
+		}
+	}
+
+	return VersionConfig{}, fmt.Errorf("no version configuration found for tier %s", tier)
+}
+
+// GetEffectiveStrategy returns the effective strategy for a tier, considering wildcards and module defaults
+func GetEffectiveStrategy(moduleConfig ModuleConfig, tier string) version.Strategy {
+	// Try the tier-specific, negated tier and wildcard configs in turn
+	for _, key := range tierKeys(moduleConfig, tier) {
+		if versionData, ok := moduleConfig.Versions[key]; ok {
+			if config, err := UnmarshalVersionConfig(versionData); err == nil && config.Strategy != "" {
+				return config.Strategy
+			}
 		}
 	}
 
@@ -92,26 +625,183 @@ func GetEffectiveStrategy(moduleConfig ModuleConfig, tier string) version.Strate
 // GetEffectiveForce returns the effective force setting for a tier,
 // considering tier-specific config, wildcard config, and module defaults
 func GetEffectiveForce(moduleConfig ModuleConfig, tier string) bool {
-	// Try to get tier-specific config
-	if versionData, ok := moduleConfig.Versions[tier]; ok {
-		if config, err := UnmarshalVersionConfig(versionData); err == nil && config.Force != nil {
-			return *config.Force
+	return getEffectiveBool(moduleConfig, tier, func(c VersionConfig) *bool { return c.Force }, moduleConfig.Force)
+}
+
+// getEffectiveBool resolves a boolean option from the tier-specific config, then a
+// negated tier config and the wildcard config, falling back to the module-level value
+func getEffectiveBool(moduleConfig ModuleConfig, tier string, field func(VersionConfig) *bool, moduleValue bool) bool {
+	for _, key := range tierKeys(moduleConfig, tier) {
+		if versionData, ok := moduleConfig.Versions[key]; ok {
+			if config, err := UnmarshalVersionConfig(versionData); err == nil && field(config) != nil {
+				return *field(config)
+			}
 		}
 	}
+	return moduleValue
+}
 
-	// Try to get wildcard config
-	if versionData, ok := moduleConfig.Versions["*"]; ok {
-		if config, err := UnmarshalVersionConfig(versionData); err == nil && config.Force != nil {
-			return *config.Force
+// GetEffectiveStrategyOptions returns the strategy tuning options for a tier,
+// considering tier-specific config, wildcard config, and module defaults
+func GetEffectiveStrategyOptions(moduleConfig ModuleConfig, tier string) version.StrategyOptions {
+	return version.StrategyOptions{
+		CollapseOr:           getEffectiveBool(moduleConfig, tier, func(c VersionConfig) *bool { return c.CollapseOr }, moduleConfig.CollapseOr),
+		MinVersion:           getEffectiveString(moduleConfig, tier, func(c VersionConfig) string { return c.MinVersion }, ""),
+		MaxVersion:           getEffectiveString(moduleConfig, tier, func(c VersionConfig) string { return c.MaxVersion }, ""),
+		ErrorAboveMaxVersion: getEffectiveString(moduleConfig, tier, func(c VersionConfig) string { return c.MaxVersionPolicy }, "") == MaxVersionError,
+		ErrorOnBlockedBump:   getEffectiveString(moduleConfig, tier, func(c VersionConfig) string { return c.BlockedBumpPolicy }, "") == BlockedBumpError,
+		PreserveStyle:        getEffectiveBool(moduleConfig, tier, func(c VersionConfig) *bool { return c.PreserveStyle }, moduleConfig.PreserveStyle),
+		SortOrBranches:       getEffectiveBool(moduleConfig, tier, func(c VersionConfig) *bool { return c.SortOrBranches }, moduleConfig.SortOrBranches),
+		CompactOutput:        getEffectiveString(moduleConfig, tier, func(c VersionConfig) string { return c.OutputFormat }, moduleConfig.OutputFormat) == OutputFormatCompact,
+		KeepPre10Ranges:      getEffectiveString(moduleConfig, tier, func(c VersionConfig) string { return c.Pre10Ranges }, moduleConfig.Pre10Ranges) == Pre10RangesKeep,
+		RangeMerge:           version.RangeMerge(getEffectiveString(moduleConfig, tier, func(c VersionConfig) string { return c.RangeMerge }, moduleConfig.RangeMerge)),
+		PrereleaseRange:      version.PrereleaseRange(getEffectiveString(moduleConfig, tier, func(c VersionConfig) string { return c.PrereleaseRange }, moduleConfig.PrereleaseRange)),
+	}
+}
+
+// GetEffectiveOnMissingVersion returns the action for modules without a version attribute
+// in a tier, considering tier-specific config, wildcard config, and module defaults
+func GetEffectiveOnMissingVersion(moduleConfig ModuleConfig, tier string) string {
+	if action := getEffectiveString(moduleConfig, tier, func(c VersionConfig) string { return c.OnMissingVersion }, moduleConfig.OnMissingVersion); action != "" {
+		return action
+	}
+	return MissingVersionWarn
+}
+
+// GetEffectiveOnInvalidExisting returns the action for modules whose existing version is
+// invalid in a tier, considering tier-specific config, wildcard config, and module defaults
+func GetEffectiveOnInvalidExisting(moduleConfig ModuleConfig, tier string) string {
+	if action := getEffectiveString(moduleConfig, tier, func(c VersionConfig) string { return c.OnInvalidExisting }, moduleConfig.OnInvalidExisting); action != "" {
+		return action
+	}
+	return InvalidExistingOverwrite
+}
+
+// GetEffectiveVersionPlacement returns where force adds a missing version attribute in a
+// tier, considering tier-specific config, wildcard config, and module defaults
+func GetEffectiveVersionPlacement(moduleConfig ModuleConfig, tier string) string {
+	if placement := getEffectiveString(moduleConfig, tier, func(c VersionConfig) string { return c.VersionPlacement }, moduleConfig.VersionPlacement); placement != "" {
+		return placement
+	}
+	return VersionPlacementEnd
+}
+
+// getEffectiveString resolves a string option from the tier-specific config, then a
+// negated tier config and the wildcard config, falling back to the module-level value
+func getEffectiveString(moduleConfig ModuleConfig, tier string, field func(VersionConfig) string, moduleValue string) string {
+	for _, key := range tierKeys(moduleConfig, tier) {
+		if versionData, ok := moduleConfig.Versions[key]; ok {
+			if config, err := UnmarshalVersionConfig(versionData); err == nil && field(config) != "" {
+				return field(config)
+			}
 		}
 	}
+	return moduleValue
+}
+
+// Format is the syntax a config file is parsed as
+type Format string
 
-	// Fall back to module-level force
-	return moduleConfig.Force
+const (
+	// FormatAuto reads a .toml file as TOML and tries JSON first and then YAML otherwise
+	FormatAuto Format = ""
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
+)
+
+// ParseFormat returns the config format named by s: json, yaml, toml, or empty for FormatAuto
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(strings.ToLower(s)); format {
+	case FormatAuto, FormatJSON, FormatYAML, FormatTOML:
+		return format, nil
+	}
+	return "", fmt.Errorf("invalid config format '%s' (expected json, yaml or toml)", s)
 }
 
-// LoadConfig loads and parses the configuration file
+// LoadConfig loads and parses the configuration file, resolving any chain of
+// extended base configs before validating the result
 func LoadConfig(path string) (*Config, error) {
+	return LoadConfigWithFormat(path, FormatAuto)
+}
+
+// LoadConfigWithFormat is LoadConfig parsing the file at path as format instead of detecting
+// it. Extended base configs are still detected, since they may be written
+// in another format.
+func LoadConfigWithFormat(path string, format Format) (*Config, error) {
+	config, err := loadConfigChain(path, format, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateConfig(config); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	expandTierLists(config)
+	return config, nil
+}
+
+// expandTierLists replaces every tier list key such as "dev,stg" with one key per tier,
+// so code walking the tiers of a module sees single tiers only. A tier that also has a key
+// of its own keeps it.
+func expandTierLists(config *Config) {
+	for i, module := range config.Modules {
+		versions := make(map[string]interface{}, len(module.Versions))
+		for key, versionData := range module.Versions {
+			if _, list := TierList(key); !list {
+				versions[key] = versionData
+			}
+		}
+		for key, versionData := range module.Versions {
+			tiers, _ := TierList(key)
+			for _, tier := range tiers {
+				if _, ok := versions[tier]; !ok {
+					versions[tier] = versionData
+				}
+			}
+		}
+		config.Modules[i].Versions = versions
+	}
+}
+
+// loadConfigChain reads the config at path as format and merges it over its base config,
+// if any. visiting holds the absolute paths of the configs currently being loaded, to
+// detect cycles.
+func loadConfigChain(path string, format Format, visiting map[string]bool) (*Config, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("resolving config path: %w", err)
+	}
+	if visiting[absPath] {
+		return nil, fmt.Errorf("cyclic extends: %s", path)
+	}
+	visiting[absPath] = true
+	defer delete(visiting, absPath)
+
+	config, err := parseConfigFile(path, format)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Extends == "" {
+		return config, nil
+	}
+
+	basePath := config.Extends
+	if !filepath.IsAbs(basePath) {
+		basePath = filepath.Join(filepath.Dir(path), basePath)
+	}
+	base, err := loadConfigChain(basePath, FormatAuto, visiting)
+	if err != nil {
+		return nil, fmt.Errorf("loading base config %s: %w", config.Extends, err)
+	}
+
+	return mergeConfigs(base, config), nil
+}
+
+// parseConfigFile reads a single config file as format without resolving extends
+func parseConfigFile(path string, format Format) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("reading config file: %w", err)
@@ -123,21 +813,287 @@ func LoadConfig(path string) (*Config, error) {
 
 	var config Config
 
-	// Try JSON first, then YAML if that fails
-	if err := json.Unmarshal(data, &config); err != nil {
+	// Unless the format is given, read .toml files as TOML and try JSON first, then YAML
+	// if that fails. Numbers are kept as json.Number so an unquoted version keeps its text.
+	if format == FormatAuto && strings.EqualFold(filepath.Ext(path), ".toml") {
+		format = FormatTOML
+	}
+	switch format {
+	case FormatJSON:
+		if err := decodeJSON(data, &config); err != nil {
+			return nil, fmt.Errorf("parsing config file as json: %w", err)
+		}
+	case FormatYAML:
 		if err := yaml.Unmarshal(data, &config); err != nil {
-			return nil, fmt.Errorf("parsing config file: %w", err)
+			return nil, fmt.Errorf("parsing config file as yaml: %w", err)
+		}
+	case FormatTOML:
+		if err := decodeTOML(data, &config); err != nil {
+			return nil, fmt.Errorf("parsing config file as toml: %w", err)
+		}
+	default:
+		if err := decodeJSON(data, &config); err != nil {
+			if err := yaml.Unmarshal(data, &config); err != nil {
+				return nil, fmt.Errorf("parsing config file: %w", err)
+			}
 		}
 	}
 
 	return &config, nil
 }
 
-// GetTiersFromConfig returns all unique tiers mentioned in the config
+// decodeJSON is json.Unmarshal with numbers decoded as json.Number
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return fmt.Errorf("unexpected data after JSON value")
+	}
+	return nil
+}
+
+// mergeConfigs overlays child on base: the first child module with a source replaces the
+// base module with that source in place, and the remaining child modules are appended in order.
+// Freeze entries from both configs apply, and a layout, post_update_hook or tier_dirs
+// entry set in child overrides base.
+func mergeConfigs(base, child *Config) *Config {
+	merged := &Config{Layout: base.Layout, PostUpdateHook: base.PostUpdateHook, Modules: make([]ModuleConfig, len(base.Modules))}
+	if child.Layout != "" {
+		merged.Layout = child.Layout
+	}
+	if len(child.PostUpdateHook) > 0 {
+		merged.PostUpdateHook = child.PostUpdateHook
+	}
+	for _, tierDirs := range []map[string]StringList{base.TierDirs, child.TierDirs} {
+		for tier, dirs := range tierDirs {
+			if merged.TierDirs == nil {
+				merged.TierDirs = make(map[string]StringList)
+			}
+			merged.TierDirs[tier] = dirs
+		}
+	}
+	copy(merged.Modules, base.Modules)
+	merged.Freeze = append(append([]FreezeEntry(nil), base.Freeze...), child.Freeze...)
+
+	index := make(map[string]int, len(merged.Modules))
+	for i, module := range merged.Modules {
+		index[module.Source] = i
+	}
+
+	// Only the first child entry for a source replaces the base entry; later entries with
+	// the same source are kept, so that ValidateConfig still sees duplicates within child
+	placed := make(map[string]bool, len(child.Modules))
+	for _, module := range child.Modules {
+		if i, ok := index[module.Source]; ok && !placed[module.Source] {
+			merged.Modules[i] = module
+			placed[module.Source] = true
+			continue
+		}
+		placed[module.Source] = true
+		merged.Modules = append(merged.Modules, module)
+	}
+
+	return merged
+}
+
+// ValidateConfig checks every module tier for settings that can never be applied,
+// returning all problems found joined into a single error
+func ValidateConfig(config *Config) error {
+	var errs []error
+	switch config.Layout {
+	case "", LayoutTiered, LayoutFlat:
+	default:
+		errs = append(errs, fmt.Errorf("invalid layout '%s' (expected tiered or flat)", config.Layout))
+	}
+	tierDirTiers := make([]string, 0, len(config.TierDirs))
+	for tier := range config.TierDirs {
+		tierDirTiers = append(tierDirTiers, tier)
+	}
+	sort.Strings(tierDirTiers)
+	for _, tier := range tierDirTiers {
+		if tier == "*" {
+			errs = append(errs, fmt.Errorf("tier_dirs: the wildcard tier has no directory"))
+			continue
+		}
+		if len(config.TierDirs[tier]) == 0 {
+			errs = append(errs, fmt.Errorf("tier_dirs: tier %s has no directories", tier))
+		}
+		for _, dir := range config.TierDirs[tier] {
+			if dir == "" || filepath.IsAbs(dir) || !filepath.IsLocal(filepath.FromSlash(dir)) {
+				errs = append(errs, fmt.Errorf("tier_dirs: tier %s: directory '%s' must be a relative path inside the work dir", tier, dir))
+			}
+		}
+	}
+	for i, entry := range config.Freeze {
+		if entry.Source == "" || entry.Version == "" {
+			errs = append(errs, fmt.Errorf("freeze entry %d: source and version are required", i+1))
+		}
+	}
+
+	// seen maps each source and tier to the first module entry configuring it
+	seen := make(map[[2]string]int)
+
+	for i, module := range config.Modules {
+		tiers := make([]string, 0, len(module.Versions))
+		for tier := range module.Versions {
+			tiers = append(tiers, tier)
+		}
+		sort.Strings(tiers)
+
+		// listedIn maps each tier named by a tier list to the first list naming it
+		listedIn := make(map[string]string)
+		var negated []string
+		for _, tier := range tiers {
+			if listed, ok := TierList(tier); ok {
+				for _, name := range listed {
+					if name == "" || name == "*" || strings.HasPrefix(name, "!") {
+						errs = append(errs, fmt.Errorf("module %s tier %s: a tier list must name single tiers, e.g. dev,stg", module.Source, tier))
+						break
+					}
+					if first, ok := listedIn[name]; ok {
+						errs = append(errs, fmt.Errorf("module %s tier %s: listed in both %s and %s", module.Source, name, first, tier))
+						continue
+					}
+					listedIn[name] = tier
+				}
+			}
+			if excluded, ok := NegatedTier(tier); ok {
+				negated = append(negated, tier)
+				if excluded == "" || excluded == "*" || strings.HasPrefix(excluded, "!") || TierPattern(excluded) {
+					errs = append(errs, fmt.Errorf("module %s tier %s: a negated tier must name a single tier, e.g. !prod", module.Source, tier))
+				}
+			} else if TierPattern(tier) {
+				if _, err := path.Match(tier, ""); err != nil {
+					errs = append(errs, fmt.Errorf("module %s tier %s: invalid tier pattern: %w", module.Source, tier, err))
+				}
+			}
+		}
+		if len(negated) > 1 {
+			errs = append(errs, fmt.Errorf("module %s: at most one negated tier is allowed, got %s", module.Source, strings.Join(negated, ", ")))
+		}
+
+		switch module.Match {
+		case "", MatchSegment, MatchExact:
+		case MatchRegex:
+			if _, err := regexp.Compile(module.Source); err != nil {
+				errs = append(errs, fmt.Errorf("module %s: invalid source regular expression: %w", module.Source, err))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("module %s: invalid match '%s' (expected segment, exact or regex)", module.Source, module.Match))
+		}
+
+		for _, tier := range tiers {
+			key := [2]string{module.Source, tier}
+			if first, ok := seen[key]; ok {
+				errs = append(errs, fmt.Errorf("module %s tier %s: configured more than once (module entries %d and %d)", module.Source, tier, first+1, i+1))
+			} else {
+				seen[key] = i
+			}
+
+			versionConfig, err := GetEffectiveVersionConfig(module, tier)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("module %s tier %s: %w", module.Source, tier, err))
+				continue
+			}
+
+			switch action := GetEffectiveOnMissingVersion(module, tier); action {
+			case MissingVersionSkip, MissingVersionWarn, MissingVersionError:
+			default:
+				errs = append(errs, fmt.Errorf("module %s tier %s: invalid on_missing_version '%s' (expected skip, warn or error)", module.Source, tier, action))
+			}
+
+			switch action := GetEffectiveOnInvalidExisting(module, tier); action {
+			case InvalidExistingOverwrite, InvalidExistingWarn, InvalidExistingError:
+			default:
+				errs = append(errs, fmt.Errorf("module %s tier %s: invalid on_invalid_existing '%s' (expected overwrite, warn or error)", module.Source, tier, action))
+			}
+
+			switch placement := GetEffectiveVersionPlacement(module, tier); placement {
+			case VersionPlacementEnd, VersionPlacementAfterSource:
+			default:
+				errs = append(errs, fmt.Errorf("module %s tier %s: invalid version_placement '%s' (expected end or after_source)", module.Source, tier, placement))
+			}
+
+			switch format := getEffectiveString(module, tier, func(c VersionConfig) string { return c.OutputFormat }, module.OutputFormat); format {
+			case "", OutputFormatSpaced, OutputFormatCompact:
+			default:
+				errs = append(errs, fmt.Errorf("module %s tier %s: invalid output_format '%s' (expected spaced or compact)", module.Source, tier, format))
+			}
+
+			switch pre10 := getEffectiveString(module, tier, func(c VersionConfig) string { return c.Pre10Ranges }, module.Pre10Ranges); pre10 {
+			case "", Pre10RangesCollapse, Pre10RangesKeep:
+			default:
+				errs = append(errs, fmt.Errorf("module %s tier %s: invalid pre_1_0_ranges '%s' (expected collapse or keep)", module.Source, tier, pre10))
+			}
+
+			switch merge := version.RangeMerge(getEffectiveString(module, tier, func(c VersionConfig) string { return c.RangeMerge }, module.RangeMerge)); merge {
+			case "", version.RangeMergeKeep, version.RangeMergeIntersect, version.RangeMergeUnion:
+			default:
+				errs = append(errs, fmt.Errorf("module %s tier %s: invalid range_merge '%s' (expected keep, intersect or union)", module.Source, tier, merge))
+			}
+
+			switch prerelease := version.PrereleaseRange(getEffectiveString(module, tier, func(c VersionConfig) string { return c.PrereleaseRange }, module.PrereleaseRange)); prerelease {
+			case "", version.PrereleaseRangeNextMajor, version.PrereleaseRangeSameMajor, version.PrereleaseRangeLockTarget:
+			default:
+				errs = append(errs, fmt.Errorf("module %s tier %s: invalid prerelease_range '%s' (expected next_major, same_major or lock_target)", module.Source, tier, prerelease))
+			}
+
+			strategyOpts := GetEffectiveStrategyOptions(module, tier)
+			var floor, ceiling *semver.Version
+			if strategyOpts.MinVersion != "" {
+				if floor, err = semver.NewVersion(strategyOpts.MinVersion); err != nil {
+					errs = append(errs, fmt.Errorf("module %s tier %s: min_version must be an exact version, got '%s'", module.Source, tier, strategyOpts.MinVersion))
+				}
+			}
+			if strategyOpts.MaxVersion != "" {
+				if ceiling, err = semver.NewVersion(strategyOpts.MaxVersion); err != nil {
+					errs = append(errs, fmt.Errorf("module %s tier %s: max_version must be an exact version, got '%s'", module.Source, tier, strategyOpts.MaxVersion))
+				}
+			}
+			if floor != nil && ceiling != nil && floor.GreaterThan(ceiling) {
+				errs = append(errs, fmt.Errorf("module %s tier %s: min_version '%s' is above max_version '%s'", module.Source, tier, strategyOpts.MinVersion, strategyOpts.MaxVersion))
+			}
+			switch policy := getEffectiveString(module, tier, func(c VersionConfig) string { return c.MaxVersionPolicy }, ""); policy {
+			case "", MaxVersionClamp, MaxVersionError:
+			default:
+				errs = append(errs, fmt.Errorf("module %s tier %s: invalid max_version_policy '%s' (expected clamp or error)", module.Source, tier, policy))
+			}
+			switch policy := getEffectiveString(module, tier, func(c VersionConfig) string { return c.BlockedBumpPolicy }, ""); policy {
+			case "", BlockedBumpKeep, BlockedBumpError:
+			default:
+				errs = append(errs, fmt.Errorf("module %s tier %s: invalid blocked_bump_policy '%s' (expected keep or error)", module.Source, tier, policy))
+			}
+
+			// The exact and patch_only strategies only accept exact versions
+			if strategy := GetEffectiveStrategy(module, tier); strategy == version.StrategyExact || strategy == version.StrategyPatchOnly {
+				if isVer, _, _, err := version.ParseVersionOrRange(versionConfig.Version); err == nil && !isVer {
+					errs = append(errs, fmt.Errorf("module %s tier %s: %s strategy cannot use range '%s'", module.Source, tier, strategy, versionConfig.Version))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// GetTiersFromConfig returns all unique tiers mentioned in the config. A negated tier
+// such as "!prod" mentions the tier it excludes, and a tier list such as "dev,stg" each
+// tier it names.
 func GetTiersFromConfig(config *Config) map[string]bool {
 	tiers := make(map[string]bool)
 	for _, module := range config.Modules {
 		for tier := range module.Versions {
+			if listed, ok := TierList(tier); ok {
+				for _, name := range listed {
+					tiers[name] = true
+				}
+				continue
+			}
+			if excluded, ok := NegatedTier(tier); ok {
+				tier = excluded
+			}
 			tiers[tier] = true
 		}
 	}
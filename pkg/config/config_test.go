@@ -1,11 +1,16 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/david1155/hclsemver/pkg/version"
+	"gopkg.in/yaml.v3"
 )
 
 func TestLoadConfig_YAML(t *testing.T) {
@@ -74,6 +79,156 @@ modules:
 	}
 }
 
+func TestLoadConfig_YAMLAnchors(t *testing.T) {
+	yamlContent := `
+modules:
+  - source: "test-module/aws"
+    versions:
+      "*": &base
+        strategy: "range"
+        version: "2.0.0"
+        force: true
+  - source: "other-module/aws"
+    versions:
+      dev: *base
+      prod:
+        <<: *base
+        strategy: "exact"
+      staging:
+        <<: *base
+        1: "numeric key"
+`
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte(yamlContent), 0o600); err != nil {
+		t.Fatalf("failed to write YAML file: %v", err)
+	}
+
+	config, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	other := config.Modules[1]
+	tests := []struct {
+		tier     string
+		strategy version.Strategy
+	}{
+		{"dev", version.StrategyRange},
+		{"prod", version.StrategyExact},
+		{"staging", version.StrategyRange},
+	}
+	for _, tc := range tests {
+		vc, err := GetEffectiveVersionConfig(other, tc.tier)
+		if err != nil {
+			t.Fatalf("tier %s: %v", tc.tier, err)
+		}
+		if vc.Version != "2.0.0" {
+			t.Errorf("tier %s: expected version 2.0.0, got %q", tc.tier, vc.Version)
+		}
+		if got := GetEffectiveStrategy(other, tc.tier); got != tc.strategy {
+			t.Errorf("tier %s: expected strategy %s, got %s", tc.tier, tc.strategy, got)
+		}
+		if !GetEffectiveForce(other, tc.tier) {
+			t.Errorf("tier %s: expected force from the anchor", tc.tier)
+		}
+	}
+}
+
+func TestLoadConfig_NumericVersions(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+modules:
+  - source: "test-module/aws"
+    versions:
+      "*": &base
+        version: 2.0
+        min_version: 1.5
+      dev: 2
+      stg: 2.0
+      prd:
+        <<: *base
+        max_version: 3
+`,
+		"config.json": `{"modules": [{"source": "test-module/aws", "versions": {
+  "*": {"version": 2.0, "min_version": 1.5},
+  "dev": 2,
+  "stg": 2.0,
+  "prd": {"version": 2.0, "min_version": 1.5, "max_version": 3}
+}}]}`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(configFile, []byte(content), 0o600); err != nil {
+				t.Fatalf("failed to write config file: %v", err)
+			}
+			config, err := LoadConfig(configFile)
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+
+			want := map[string]VersionConfig{
+				"*":   {Version: "2.0", MinVersion: "1.5"},
+				"dev": {Version: "2"},
+				"stg": {Version: "2.0"},
+				"prd": {Version: "2.0", MinVersion: "1.5", MaxVersion: "3"},
+			}
+			for tier, w := range want {
+				got, err := UnmarshalVersionConfig(config.Modules[0].Versions[tier])
+				if err != nil {
+					t.Fatalf("tier %s: %v", tier, err)
+				}
+				if got.Version != w.Version || got.MinVersion != w.MinVersion || got.MaxVersion != w.MaxVersion {
+					t.Errorf("tier %s: got %+v, want %+v", tier, got, w)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfig_ComparatorLists(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+modules:
+  - source: "test-module/aws"
+    versions:
+      dev: [">=1.0.0", "<2.0.0"]
+      prd:
+        strategy: range
+        version:
+          - ">= 1.2"
+          - "< 1.5"
+`
+	if err := os.WriteFile(configFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	config, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	want := map[string]string{"dev": ">= 1.0.0, < 2.0.0", "prd": ">= 1.2, < 1.5"}
+	for tier, w := range want {
+		got, err := UnmarshalVersionConfig(config.Modules[0].Versions[tier])
+		if err != nil {
+			t.Fatalf("tier %s: %v", tier, err)
+		}
+		if got.Version != w {
+			t.Errorf("tier %s: got version %q, want %q", tier, got.Version, w)
+		}
+	}
+
+	schema := schemaForTest(t)
+	var sample map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &sample); err != nil {
+		t.Fatalf("failed to parse sample: %v", err)
+	}
+	for _, problem := range validateSchema(schema, schema, sample, "config") {
+		t.Error(problem)
+	}
+}
+
 func TestLoadConfig_JSON(t *testing.T) {
 	jsonContent := `{
 		"modules": [
@@ -234,11 +389,22 @@ func TestGetTiersFromConfig(t *testing.T) {
 		},
 	}
 
+	config.Modules = append(config.Modules, ModuleConfig{
+		Source:   "negated-module",
+		Versions: map[string]interface{}{"!qa": "1.0.0"},
+	}, ModuleConfig{
+		Source:   "listed-module",
+		Versions: map[string]interface{}{"uat, perf": "1.0.0"},
+	})
+
 	tiers := GetTiersFromConfig(config)
 	expectedTiers := map[string]bool{
 		"dev":     true,
 		"staging": true,
 		"prod":    true,
+		"qa":      true,
+		"uat":     true,
+		"perf":    true,
 	}
 
 	if len(tiers) != len(expectedTiers) {
@@ -419,6 +585,108 @@ func TestGetEffectiveVersionConfig(t *testing.T) {
 				Version: "1.0.0",
 			},
 		},
+		{
+			name:         "negated tier applies to other tiers",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"!prod": "2.0.0"}},
+			tier:         "dev",
+			want:         VersionConfig{Version: "2.0.0"},
+		},
+		{
+			name:         "negated tier applies to staging",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"!prod": "2.0.0"}},
+			tier:         "staging",
+			want:         VersionConfig{Version: "2.0.0"},
+		},
+		{
+			name:         "negated tier does not apply to the excluded tier",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"!prod": "2.0.0"}},
+			tier:         "prod",
+			wantErr:      true,
+		},
+		{
+			name:         "excluded tier falls back to wildcard",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"!prod": "2.0.0", "*": "1.0.0"}},
+			tier:         "prod",
+			want:         VersionConfig{Version: "1.0.0"},
+		},
+		{
+			name:         "negated tier takes precedence over wildcard",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"!prod": "2.0.0", "*": "1.0.0"}},
+			tier:         "dev",
+			want:         VersionConfig{Version: "2.0.0"},
+		},
+		{
+			name:         "explicit tier takes precedence over negated tier",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"!prod": "2.0.0", "dev": "3.0.0"}},
+			tier:         "dev",
+			want:         VersionConfig{Version: "3.0.0"},
+		},
+		{
+			name:         "tier pattern applies to matching tiers",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"region-*": "2.0.0", "*": "1.0.0"}},
+			tier:         "region-us-east-1",
+			want:         VersionConfig{Version: "2.0.0"},
+		},
+		{
+			name:         "tier pattern does not apply to other tiers",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"region-*": "2.0.0", "*": "1.0.0"}},
+			tier:         "global",
+			want:         VersionConfig{Version: "1.0.0"},
+		},
+		{
+			name:         "explicit tier takes precedence over tier pattern",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"region-*": "2.0.0", "region-us-east-1": "3.0.0"}},
+			tier:         "region-us-east-1",
+			want:         VersionConfig{Version: "3.0.0"},
+		},
+		{
+			name:         "more specific tier pattern takes precedence",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"region-*": "2.0.0", "region-eu-*": "3.0.0"}},
+			tier:         "region-eu-west-1",
+			want:         VersionConfig{Version: "3.0.0"},
+		},
+		{
+			name:         "literal prefix outranks a character class",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"region-[ab]*": "2.0.0", "region-a*": "3.0.0"}},
+			tier:         "region-a1",
+			want:         VersionConfig{Version: "3.0.0"},
+		},
+		{
+			name:         "character class outranks a shorter literal prefix",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"region-[ab]-*": "2.0.0", "region-*": "3.0.0"}},
+			tier:         "region-a-1",
+			want:         VersionConfig{Version: "2.0.0"},
+		},
+		{
+			name:         "tier pattern takes precedence over negated tier",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"region-*": "2.0.0", "!prod": "3.0.0"}},
+			tier:         "region-eu-west-1",
+			want:         VersionConfig{Version: "2.0.0"},
+		},
+		{
+			name:         "tier list applies to each listed tier",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"dev, staging": "2.0.0"}},
+			tier:         "staging",
+			want:         VersionConfig{Version: "2.0.0"},
+		},
+		{
+			name:         "tier list does not apply to other tiers",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"dev,staging": "2.0.0"}},
+			tier:         "prod",
+			wantErr:      true,
+		},
+		{
+			name:         "explicit tier takes precedence over tier list",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"dev,staging": "2.0.0", "dev": "3.0.0"}},
+			tier:         "dev",
+			want:         VersionConfig{Version: "3.0.0"},
+		},
+		{
+			name:         "tier list takes precedence over negated tier",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"dev,staging": "2.0.0", "!prod": "3.0.0"}},
+			tier:         "dev",
+			want:         VersionConfig{Version: "2.0.0"},
+		},
 	}
 
 	for _, tc := range tests {
@@ -473,9 +741,82 @@ func TestUnmarshalVersionConfig(t *testing.T) {
 				Version: "1.0.0",
 			},
 		},
+		{
+			name: "object with non-string keys",
+			input: map[interface{}]interface{}{
+				"strategy": "range",
+				"version":  "1.0.0",
+				1:          "ignored",
+			},
+			want: VersionConfig{
+				Strategy: version.StrategyRange,
+				Version:  "1.0.0",
+			},
+		},
+		{
+			name:  "integer version",
+			input: 2,
+			want:  VersionConfig{Version: "2"},
+		},
+		{
+			name:  "JSON number keeps its text",
+			input: json.Number("2.0"),
+			want:  VersionConfig{Version: "2.0"},
+		},
+		{
+			name:    "float version must be quoted",
+			input:   2.0,
+			wantErr: true,
+		},
+		{
+			name:    "object with float version",
+			input:   map[string]interface{}{"version": 2.5},
+			wantErr: true,
+		},
 		{
 			name:    "invalid type",
-			input:   123,
+			input:   true,
+			wantErr: true,
+		},
+		{
+			name:  "comparator list",
+			input: []interface{}{">=1.0.0", "<2.0.0"},
+			want:  VersionConfig{Version: ">= 1.0.0, < 2.0.0"},
+		},
+		{
+			name: "object with comparator list",
+			input: map[string]interface{}{
+				"strategy": "range",
+				"version":  []interface{}{"~> 1.2", " != 1.4.0 "},
+			},
+			want: VersionConfig{
+				Strategy: version.StrategyRange,
+				Version:  "~> 1.2, != 1.4.0",
+			},
+		},
+		{
+			name:    "comparator list element without an operator",
+			input:   []interface{}{"1.0.0", "<2.0.0"},
+			wantErr: true,
+		},
+		{
+			name:    "comparator list element with several comparisons",
+			input:   []interface{}{">=1.0.0, <2.0.0"},
+			wantErr: true,
+		},
+		{
+			name:    "comparator list element that is not a string",
+			input:   []interface{}{">=1.0.0", 2},
+			wantErr: true,
+		},
+		{
+			name:    "empty comparator list",
+			input:   []interface{}{},
+			wantErr: true,
+		},
+		{
+			name:    "comparator list for min_version",
+			input:   map[string]interface{}{"min_version": []interface{}{">=1.0.0"}},
 			wantErr: true,
 		},
 	}
@@ -592,6 +933,60 @@ func TestGetEffectiveForce(t *testing.T) {
 			tier: "dev",
 			want: true,
 		},
+		{
+			name: "tier force false overrides module force true",
+			moduleConfig: ModuleConfig{
+				Source: "test-module",
+				Force:  true,
+				Versions: map[string]interface{}{
+					"dev": map[string]interface{}{
+						"force":   false,
+						"version": "1.0.0",
+					},
+				},
+			},
+			tier: "dev",
+			want: false,
+		},
+		{
+			name: "scalar wildcard inherits module force",
+			moduleConfig: ModuleConfig{
+				Source:   "test-module",
+				Force:    true,
+				Versions: map[string]interface{}{"*": "1.0.0"},
+			},
+			tier: "dev",
+			want: true,
+		},
+		{
+			name: "scalar tier inherits wildcard force false over module force true",
+			moduleConfig: ModuleConfig{
+				Source: "test-module",
+				Force:  true,
+				Versions: map[string]interface{}{
+					"*": map[string]interface{}{
+						"force":   false,
+						"version": "1.0.0",
+					},
+					"dev": "1.0.0",
+				},
+			},
+			tier: "dev",
+			want: false,
+		},
+		{
+			name: "scalar tier and scalar wildcard inherit module force",
+			moduleConfig: ModuleConfig{
+				Source: "test-module",
+				Force:  true,
+				Versions: map[string]interface{}{
+					"*":   "1.0.0",
+					"dev": "1.0.0",
+				},
+			},
+			tier: "dev",
+			want: true,
+		},
 	}
 
 	for _, tc := range tests {
@@ -603,3 +998,1744 @@ func TestGetEffectiveForce(t *testing.T) {
 		})
 	}
 }
+
+func TestGetEffectiveForce_FromYAML(t *testing.T) {
+	yamlContent := `
+modules:
+  - source: "test-module"
+    force: true
+    versions:
+      "*": "1.0.0"
+      dev:
+        force: false
+        version: "1.0.0"
+      stg: "1.0.0"
+`
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte(yamlContent), 0o600); err != nil {
+		t.Fatalf("failed to write YAML file: %v", err)
+	}
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	module := cfg.Modules[0]
+	if GetEffectiveForce(module, "dev") {
+		t.Error("dev: expected tier force: false to override module force: true")
+	}
+	if !GetEffectiveForce(module, "stg") {
+		t.Error("stg: expected module force: true to be inherited")
+	}
+	if !GetEffectiveForce(module, "prd") {
+		t.Error("prd: expected module force: true to be inherited through the scalar wildcard")
+	}
+}
+
+func TestGetEffectiveStrategyOptions(t *testing.T) {
+	tests := []struct {
+		name           string
+		moduleConfig   ModuleConfig
+		tier           string
+		wantCollapseOr bool
+		wantMinVersion string
+		wantMaxVersion string
+		wantMaxError   bool
+		wantPreserve   bool
+		wantSortOr     bool
+		wantBlockedErr bool
+		wantCompact    bool
+		wantKeepPre10  bool
+		wantRangeMerge version.RangeMerge
+		wantPrerelease version.PrereleaseRange
+	}{
+		{
+			name: "defaults",
+			moduleConfig: ModuleConfig{
+				Source:   "test-module",
+				Versions: map[string]interface{}{"dev": "1.0.0"},
+			},
+			tier:           "dev",
+			wantCollapseOr: false,
+		},
+		{
+			name: "module collapse_or",
+			moduleConfig: ModuleConfig{
+				Source:     "test-module",
+				CollapseOr: true,
+				Versions:   map[string]interface{}{"dev": "1.0.0"},
+			},
+			tier:           "dev",
+			wantCollapseOr: true,
+		},
+		{
+			name: "tier collapse_or overrides module",
+			moduleConfig: ModuleConfig{
+				Source:     "test-module",
+				CollapseOr: true,
+				Versions: map[string]interface{}{
+					"dev": map[string]interface{}{
+						"collapse_or": false,
+						"version":     "1.0.0",
+					},
+				},
+			},
+			tier:           "dev",
+			wantCollapseOr: false,
+		},
+		{
+			name: "wildcard collapse_or",
+			moduleConfig: ModuleConfig{
+				Source: "test-module",
+				Versions: map[string]interface{}{
+					"*": map[string]interface{}{
+						"collapse_or": true,
+						"version":     "1.0.0",
+					},
+					"dev": "1.0.0",
+				},
+			},
+			tier:           "dev",
+			wantCollapseOr: true,
+		},
+		{
+			name: "tier min_version overrides wildcard",
+			moduleConfig: ModuleConfig{
+				Source: "test-module",
+				Versions: map[string]interface{}{
+					"*": map[string]interface{}{
+						"min_version": "1.0.0",
+						"version":     "1.0.0",
+					},
+					"dev": map[string]interface{}{
+						"min_version": "1.5.0",
+						"version":     "1.0.0",
+					},
+					"stg": "1.0.0",
+				},
+			},
+			tier:           "dev",
+			wantMinVersion: "1.5.0",
+		},
+		{
+			name: "wildcard min_version",
+			moduleConfig: ModuleConfig{
+				Source: "test-module",
+				Versions: map[string]interface{}{
+					"*": map[string]interface{}{
+						"min_version": "1.0.0",
+						"version":     "1.0.0",
+					},
+					"stg": "1.0.0",
+				},
+			},
+			tier:           "stg",
+			wantMinVersion: "1.0.0",
+		},
+		{
+			name: "max_version with error policy",
+			moduleConfig: ModuleConfig{
+				Source: "test-module",
+				Versions: map[string]interface{}{
+					"*": map[string]interface{}{
+						"max_version": "2.9.0",
+						"version":     "2.0.0",
+					},
+					"prd": map[string]interface{}{
+						"max_version_policy": "error",
+						"version":            "2.0.0",
+					},
+				},
+			},
+			tier:           "prd",
+			wantMaxVersion: "2.9.0",
+			wantMaxError:   true,
+		},
+		{
+			name: "blocked_bump_policy error",
+			moduleConfig: ModuleConfig{
+				Source:   "test-module",
+				Strategy: version.StrategyPatchOnly,
+				Versions: map[string]interface{}{
+					"*": map[string]interface{}{"version": "2.0.0", "blocked_bump_policy": "error"},
+				},
+			},
+			tier:           "prd",
+			wantBlockedErr: true,
+		},
+		{
+			name: "module preserve_style",
+			moduleConfig: ModuleConfig{
+				Source:        "test-module",
+				PreserveStyle: true,
+				Versions:      map[string]interface{}{"dev": "1.0.0"},
+			},
+			tier:         "dev",
+			wantPreserve: true,
+		},
+		{
+			name: "tier preserve_style overrides module",
+			moduleConfig: ModuleConfig{
+				Source:        "test-module",
+				PreserveStyle: true,
+				Versions: map[string]interface{}{
+					"dev": map[string]interface{}{
+						"preserve_style": false,
+						"version":        "1.0.0",
+					},
+				},
+			},
+			tier:         "dev",
+			wantPreserve: false,
+		},
+		{
+			name: "wildcard sort_or_branches",
+			moduleConfig: ModuleConfig{
+				Source: "test-module",
+				Versions: map[string]interface{}{
+					"*": map[string]interface{}{
+						"sort_or_branches": true,
+						"version":          "1.0.0",
+					},
+					"dev": "1.0.0",
+				},
+			},
+			tier:       "dev",
+			wantSortOr: true,
+		},
+		{
+			name: "module output_format compact",
+			moduleConfig: ModuleConfig{
+				Source:       "test-module",
+				OutputFormat: OutputFormatCompact,
+				Versions:     map[string]interface{}{"dev": "1.0.0"},
+			},
+			tier:        "dev",
+			wantCompact: true,
+		},
+		{
+			name: "tier output_format overrides module",
+			moduleConfig: ModuleConfig{
+				Source:       "test-module",
+				OutputFormat: OutputFormatCompact,
+				Versions: map[string]interface{}{
+					"dev": map[string]interface{}{
+						"output_format": "spaced",
+						"version":       "1.0.0",
+					},
+				},
+			},
+			tier:        "dev",
+			wantCompact: false,
+		},
+		{
+			name: "tier pre_1_0_ranges keep",
+			moduleConfig: ModuleConfig{
+				Source: "test-module",
+				Versions: map[string]interface{}{
+					"dev": map[string]interface{}{
+						"pre_1_0_ranges": "keep",
+						"version":        ">=0.2.0,<0.3.0",
+					},
+				},
+			},
+			tier:          "dev",
+			wantKeepPre10: true,
+		},
+		{
+			name: "tier pre_1_0_ranges overrides module",
+			moduleConfig: ModuleConfig{
+				Source:      "test-module",
+				Pre10Ranges: Pre10RangesKeep,
+				Versions: map[string]interface{}{
+					"dev": map[string]interface{}{
+						"pre_1_0_ranges": "collapse",
+						"version":        ">=0.2.0,<0.3.0",
+					},
+				},
+			},
+			tier:          "dev",
+			wantKeepPre10: false,
+		},
+		{
+			name: "tier range_merge overrides module",
+			moduleConfig: ModuleConfig{
+				Source:     "test-module",
+				RangeMerge: "union",
+				Versions: map[string]interface{}{
+					"dev": map[string]interface{}{
+						"range_merge": "intersect",
+						"version":     ">=1.5.0,<2.5.0",
+					},
+					"prd": ">=1.5.0,<2.5.0",
+				},
+			},
+			tier:           "dev",
+			wantRangeMerge: version.RangeMergeIntersect,
+		},
+		{
+			name: "tier prerelease_range overrides module",
+			moduleConfig: ModuleConfig{
+				Source:          "test-module",
+				PrereleaseRange: "lock_target",
+				Versions: map[string]interface{}{
+					"dev": map[string]interface{}{
+						"prerelease_range": "same_major",
+						"version":          "2.0.0-beta.1",
+					},
+					"prd": "2.0.0",
+				},
+			},
+			tier:           "dev",
+			wantPrerelease: version.PrereleaseRangeSameMajor,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := GetEffectiveStrategyOptions(tc.moduleConfig, tc.tier)
+			if got.CollapseOr != tc.wantCollapseOr {
+				t.Errorf("CollapseOr = %v, want %v", got.CollapseOr, tc.wantCollapseOr)
+			}
+			if got.MinVersion != tc.wantMinVersion {
+				t.Errorf("MinVersion = %q, want %q", got.MinVersion, tc.wantMinVersion)
+			}
+			if got.MaxVersion != tc.wantMaxVersion {
+				t.Errorf("MaxVersion = %q, want %q", got.MaxVersion, tc.wantMaxVersion)
+			}
+			if got.ErrorAboveMaxVersion != tc.wantMaxError {
+				t.Errorf("ErrorAboveMaxVersion = %v, want %v", got.ErrorAboveMaxVersion, tc.wantMaxError)
+			}
+			if got.PreserveStyle != tc.wantPreserve {
+				t.Errorf("PreserveStyle = %v, want %v", got.PreserveStyle, tc.wantPreserve)
+			}
+			if got.SortOrBranches != tc.wantSortOr {
+				t.Errorf("SortOrBranches = %v, want %v", got.SortOrBranches, tc.wantSortOr)
+			}
+			if got.ErrorOnBlockedBump != tc.wantBlockedErr {
+				t.Errorf("ErrorOnBlockedBump = %v, want %v", got.ErrorOnBlockedBump, tc.wantBlockedErr)
+			}
+			if got.CompactOutput != tc.wantCompact {
+				t.Errorf("CompactOutput = %v, want %v", got.CompactOutput, tc.wantCompact)
+			}
+			if got.KeepPre10Ranges != tc.wantKeepPre10 {
+				t.Errorf("KeepPre10Ranges = %v, want %v", got.KeepPre10Ranges, tc.wantKeepPre10)
+			}
+			if got.RangeMerge != tc.wantRangeMerge {
+				t.Errorf("RangeMerge = %q, want %q", got.RangeMerge, tc.wantRangeMerge)
+			}
+			if got.PrereleaseRange != tc.wantPrerelease {
+				t.Errorf("PrereleaseRange = %q, want %q", got.PrereleaseRange, tc.wantPrerelease)
+			}
+		})
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      Config
+		wantErrs    []string
+		wantNoError bool
+	}{
+		{
+			name: "exact strategy with exact versions",
+			config: Config{Modules: []ModuleConfig{{
+				Source:   "hashicorp/aws/rds",
+				Strategy: version.StrategyExact,
+				Versions: map[string]interface{}{"dev": "2.0.0", "prd": "1.9.0"},
+			}}},
+			wantNoError: true,
+		},
+		{
+			name: "module exact strategy with range",
+			config: Config{Modules: []ModuleConfig{{
+				Source:   "hashicorp/aws/rds",
+				Strategy: version.StrategyExact,
+				Versions: map[string]interface{}{"dev": ">=1,<2", "prd": "1.9.0"},
+			}}},
+			wantErrs: []string{"module hashicorp/aws/rds tier dev: exact strategy cannot use range '>=1,<2'"},
+		},
+		{
+			name: "tier exact strategy with tilde range",
+			config: Config{Modules: []ModuleConfig{{
+				Source: "hashicorp/aws/vpc",
+				Versions: map[string]interface{}{
+					"dev": ">=1,<2",
+					"stg": map[string]interface{}{"strategy": "exact", "version": "~>2.1.0"},
+				},
+			}}},
+			wantErrs: []string{"module hashicorp/aws/vpc tier stg: exact strategy cannot use range '~>2.1.0'"},
+		},
+		{
+			name: "negated tier",
+			config: Config{Modules: []ModuleConfig{{
+				Source:   "hashicorp/aws/vpc",
+				Versions: map[string]interface{}{"!prod": "2.0.0", "prod": "1.0.0", "*": "1.5.0"},
+			}}},
+			wantNoError: true,
+		},
+		{
+			name: "negated wildcard and empty negation",
+			config: Config{Modules: []ModuleConfig{{
+				Source:   "hashicorp/aws/vpc",
+				Versions: map[string]interface{}{"!*": "2.0.0", "!": "1.0.0"},
+			}}},
+			wantErrs: []string{
+				"module hashicorp/aws/vpc tier !*: a negated tier must name a single tier",
+				"module hashicorp/aws/vpc tier !: a negated tier must name a single tier",
+				"module hashicorp/aws/vpc: at most one negated tier is allowed, got !, !*",
+			},
+		},
+		{
+			name: "tier patterns",
+			config: Config{Modules: []ModuleConfig{{
+				Source:   "hashicorp/aws/vpc",
+				Versions: map[string]interface{}{"region-*": "2.0.0", "region-[": "1.0.0", "!region-*": "1.5.0"},
+			}}},
+			wantErrs: []string{
+				"module hashicorp/aws/vpc tier !region-*: a negated tier must name a single tier",
+				"module hashicorp/aws/vpc tier region-[: invalid tier pattern: syntax error in pattern",
+			},
+		},
+		{
+			name: "wildcard exact strategy inherited by range tier",
+			config: Config{Modules: []ModuleConfig{{
+				Source: "hashicorp/aws/vpc",
+				Versions: map[string]interface{}{
+					"*":   map[string]interface{}{"strategy": "exact", "version": "2.0.0"},
+					"dev": "^2.0.0",
+				},
+			}}},
+			wantErrs: []string{"module hashicorp/aws/vpc tier dev: exact strategy cannot use range '^2.0.0'"},
+		},
+		{
+			name: "min_version must be exact",
+			config: Config{Modules: []ModuleConfig{{
+				Source: "hashicorp/aws/vpc",
+				Versions: map[string]interface{}{
+					"dev": map[string]interface{}{"version": "2.0.0", "min_version": ">=1.0.0"},
+				},
+			}}},
+			wantErrs: []string{"module hashicorp/aws/vpc tier dev: min_version must be an exact version, got '>=1.0.0'"},
+		},
+		{
+			name: "min_version above max_version",
+			config: Config{Modules: []ModuleConfig{{
+				Source: "hashicorp/aws/vpc",
+				Versions: map[string]interface{}{
+					"dev": map[string]interface{}{"version": "2.0.0", "min_version": "3.0.0", "max_version": "2.5.0"},
+				},
+			}}},
+			wantErrs: []string{"module hashicorp/aws/vpc tier dev: min_version '3.0.0' is above max_version '2.5.0'"},
+		},
+		{
+			name: "invalid max_version_policy",
+			config: Config{Modules: []ModuleConfig{{
+				Source: "hashicorp/aws/vpc",
+				Versions: map[string]interface{}{
+					"dev": map[string]interface{}{"version": "2.0.0", "max_version": "2.5.0", "max_version_policy": "warn"},
+				},
+			}}},
+			wantErrs: []string{"invalid max_version_policy 'warn'"},
+		},
+		{
+			name: "invalid blocked_bump_policy",
+			config: Config{Modules: []ModuleConfig{{
+				Source: "hashicorp/aws/vpc",
+				Versions: map[string]interface{}{
+					"dev": map[string]interface{}{"strategy": "patch_only", "version": "2.0.0", "blocked_bump_policy": "warn"},
+				},
+			}}},
+			wantErrs: []string{"invalid blocked_bump_policy 'warn' (expected keep or error)"},
+		},
+		{
+			name: "invalid output_format",
+			config: Config{Modules: []ModuleConfig{{
+				Source:       "hashicorp/aws/vpc",
+				OutputFormat: "tight",
+				Versions:     map[string]interface{}{"dev": "2.0.0"},
+			}}},
+			wantErrs: []string{"invalid output_format 'tight' (expected spaced or compact)"},
+		},
+		{
+			name: "invalid pre_1_0_ranges",
+			config: Config{Modules: []ModuleConfig{{
+				Source:      "hashicorp/aws/vpc",
+				Pre10Ranges: "exact",
+				Versions:    map[string]interface{}{"dev": "0.2.0"},
+			}}},
+			wantErrs: []string{"invalid pre_1_0_ranges 'exact' (expected collapse or keep)"},
+		},
+		{
+			name: "invalid range_merge",
+			config: Config{Modules: []ModuleConfig{{
+				Source:     "hashicorp/aws/vpc",
+				RangeMerge: "overlap",
+				Versions:   map[string]interface{}{"dev": ">=1.0.0,<2.0.0"},
+			}}},
+			wantErrs: []string{"invalid range_merge 'overlap' (expected keep, intersect or union)"},
+		},
+		{
+			name: "invalid prerelease_range",
+			config: Config{Modules: []ModuleConfig{{
+				Source:          "hashicorp/aws/vpc",
+				PrereleaseRange: "same_minor",
+				Versions:        map[string]interface{}{"dev": "2.0.0-beta.1"},
+			}}},
+			wantErrs: []string{"invalid prerelease_range 'same_minor' (expected next_major, same_major or lock_target)"},
+		},
+		{
+			name: "patch_only strategy with range",
+			config: Config{Modules: []ModuleConfig{{
+				Source:   "hashicorp/aws/vpc",
+				Strategy: version.StrategyPatchOnly,
+				Versions: map[string]interface{}{"dev": "~> 2.1.0"},
+			}}},
+			wantErrs: []string{"module hashicorp/aws/vpc tier dev: patch_only strategy cannot use range '~> 2.1.0'"},
+		},
+		{
+			name: "invalid layout",
+			config: Config{Layout: "nested", Modules: []ModuleConfig{
+				{Source: "hashicorp/aws/vpc", Versions: map[string]interface{}{"dev": "1.0.0"}},
+			}},
+			wantErrs: []string{"invalid layout 'nested' (expected tiered or flat)"},
+		},
+		{
+			name: "invalid match",
+			config: Config{Modules: []ModuleConfig{
+				{Source: "hashicorp/aws/vpc", Match: "glob", Versions: map[string]interface{}{"dev": "1.0.0"}},
+			}},
+			wantErrs: []string{"module hashicorp/aws/vpc: invalid match 'glob' (expected segment, exact or regex)"},
+		},
+		{
+			name: "invalid source regular expression",
+			config: Config{Modules: []ModuleConfig{
+				{Source: "hashicorp/(aws/vpc", Match: MatchRegex, Versions: map[string]interface{}{"dev": "1.0.0"}},
+			}},
+			wantErrs: []string{"module hashicorp/(aws/vpc: invalid source regular expression"},
+		},
+		{
+			name: "exact and regex match",
+			config: Config{Modules: []ModuleConfig{
+				{Source: "registry.terraform.io/hashicorp/aws/vpc", Match: MatchExact, Versions: map[string]interface{}{"dev": "1.0.0"}},
+				{Source: `^hashicorp/aws/(rds|aurora)$`, Match: MatchRegex, Versions: map[string]interface{}{"dev": "1.0.0"}},
+			}},
+			wantNoError: true,
+		},
+		{
+			name: "flat layout",
+			config: Config{Layout: LayoutFlat, Modules: []ModuleConfig{
+				{Source: "hashicorp/aws/vpc", Versions: map[string]interface{}{"prd": "1.0.0"}},
+			}},
+			wantNoError: true,
+		},
+		{
+			name: "duplicate source and tier",
+			config: Config{Modules: []ModuleConfig{
+				{Source: "hashicorp/aws/vpc", Versions: map[string]interface{}{"dev": "1.0.0", "prd": "1.0.0"}},
+				{Source: "hashicorp/aws/rds", Versions: map[string]interface{}{"dev": "1.0.0"}},
+				{Source: "hashicorp/aws/vpc", Versions: map[string]interface{}{"dev": "2.0.0"}},
+			}},
+			wantErrs: []string{"module hashicorp/aws/vpc tier dev: configured more than once (module entries 1 and 3)"},
+		},
+		{
+			name: "same source with distinct tiers",
+			config: Config{Modules: []ModuleConfig{
+				{Source: "hashicorp/aws/vpc", Versions: map[string]interface{}{"dev": "1.0.0"}},
+				{Source: "hashicorp/aws/vpc", Strategy: version.StrategyExact, Versions: map[string]interface{}{"prd": "1.0.0"}},
+			}},
+			wantNoError: true,
+		},
+		{
+			name: "range strategy with range",
+			config: Config{Modules: []ModuleConfig{{
+				Source:   "hashicorp/aws/vpc",
+				Strategy: version.StrategyRange,
+				Versions: map[string]interface{}{"dev": ">=1,<2"},
+			}}},
+			wantNoError: true,
+		},
+		{
+			name: "tier list next to a listed tier",
+			config: Config{Modules: []ModuleConfig{{
+				Source:   "hashicorp/aws/vpc",
+				Versions: map[string]interface{}{"dev,stg": "2.0.0", "dev": "3.0.0"},
+			}}},
+			wantNoError: true,
+		},
+		{
+			name: "tier in two tier lists",
+			config: Config{Modules: []ModuleConfig{{
+				Source:   "hashicorp/aws/vpc",
+				Versions: map[string]interface{}{"dev,stg": "2.0.0", "dev,prd": "3.0.0"},
+			}}},
+			wantErrs: []string{"module hashicorp/aws/vpc tier dev: listed in both dev,prd and dev,stg"},
+		},
+		{
+			name: "tier list with a wildcard",
+			config: Config{Modules: []ModuleConfig{{
+				Source:   "hashicorp/aws/vpc",
+				Versions: map[string]interface{}{"dev,*": "2.0.0", "stg,": "2.0.0"},
+			}}},
+			wantErrs: []string{
+				"module hashicorp/aws/vpc tier dev,*: a tier list must name single tiers",
+				"module hashicorp/aws/vpc tier stg,: a tier list must name single tiers",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateConfig(&tc.config)
+			if tc.wantNoError {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			for _, want := range tc.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err.Error(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfig_DuplicateModuleTier(t *testing.T) {
+	yamlContent := `
+modules:
+  - source: "hashicorp/aws/vpc"
+    versions:
+      dev: "1.0.0"
+  - source: "hashicorp/aws/vpc"
+    versions:
+      dev: "2.0.0"
+`
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte(yamlContent), 0o600); err != nil {
+		t.Fatalf("failed to write YAML file: %v", err)
+	}
+
+	_, err := LoadConfig(configFile)
+	if err == nil || !strings.Contains(err.Error(), "module hashicorp/aws/vpc tier dev: configured more than once") {
+		t.Errorf("expected duplicate error, got %v", err)
+	}
+}
+
+func TestLoadConfig_ExtendsDuplicateModuleTier(t *testing.T) {
+	base := `
+modules:
+  - source: "hashicorp/aws/vpc"
+    versions:
+      dev: "1.0.0"
+`
+	tests := []struct {
+		name        string
+		child       string
+		wantErr     string
+		wantSources []string
+	}{
+		{
+			name: "duplicate within the child",
+			child: `
+extends: base.yaml
+modules:
+  - source: "hashicorp/aws/vpc"
+    versions:
+      dev: "2.0.0"
+  - source: "hashicorp/aws/vpc"
+    versions:
+      dev: "3.0.0"
+`,
+			wantErr: "module hashicorp/aws/vpc tier dev: configured more than once",
+		},
+		{
+			name: "same source with distinct tiers in the child",
+			child: `
+extends: base.yaml
+modules:
+  - source: "hashicorp/aws/vpc"
+    versions:
+      dev: "2.0.0"
+  - source: "hashicorp/aws/vpc"
+    versions:
+      prd: "3.0.0"
+`,
+			wantSources: []string{"hashicorp/aws/vpc", "hashicorp/aws/vpc"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, "base.yaml"), []byte(base), 0o600); err != nil {
+				t.Fatalf("failed to write base config: %v", err)
+			}
+			childPath := filepath.Join(tmpDir, "child.yaml")
+			if err := os.WriteFile(childPath, []byte(tt.child), 0o600); err != nil {
+				t.Fatalf("failed to write child config: %v", err)
+			}
+
+			cfg, err := LoadConfig(childPath)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			var sources []string
+			for _, m := range cfg.Modules {
+				sources = append(sources, m.Source)
+			}
+			if !reflect.DeepEqual(sources, tt.wantSources) {
+				t.Errorf("got modules %v, want %v", sources, tt.wantSources)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_TierLists(t *testing.T) {
+	yamlContent := `
+modules:
+  - source: "hashicorp/aws/vpc"
+    versions:
+      "dev,stg": "2.0.0"
+      dev: "3.0.0"
+      ? [qa, uat]
+      : version: 1.5
+        strategy: exact
+`
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte(yamlContent), 0o600); err != nil {
+		t.Fatalf("failed to write YAML file: %v", err)
+	}
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	module := cfg.Modules[0]
+	if len(module.Versions) != 4 {
+		t.Errorf("expected one key per tier, got %v", module.Versions)
+	}
+
+	want := map[string]string{"dev": "3.0.0", "stg": "2.0.0", "qa": "1.5", "uat": "1.5"}
+	for tier, wantVersion := range want {
+		vc, err := GetEffectiveVersionConfig(module, tier)
+		if err != nil || vc.Version != wantVersion {
+			t.Errorf("tier %s: got %+v, %v; want version %s", tier, vc, err, wantVersion)
+		}
+	}
+	if strategy := GetEffectiveStrategy(module, "uat"); strategy != version.StrategyExact {
+		t.Errorf("tier uat: got strategy %s, want exact", strategy)
+	}
+}
+
+func TestLoadConfigWithFormat(t *testing.T) {
+	// A YAML flow mapping that is also valid JSON. encoding/json matches keys
+	// case-insensitively, so read as JSON the "Strategy" note overrides the strategy and
+	// the range version fails validation; YAML keeps the two keys apart.
+	content := `{"modules": [{"source": "hashicorp/aws/vpc", "strategy": "range", "Strategy": "exact", "versions": {"dev": ">=1,<2"}}]}`
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		format  Format
+		wantErr string
+	}{
+		{name: "auto", format: FormatAuto, wantErr: "exact strategy"},
+		{name: "json", format: FormatJSON, wantErr: "exact strategy"},
+		{name: "yaml", format: FormatYAML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfigWithFormat(configFile, tt.format)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfigWithFormat: %v", err)
+			}
+			if strategy := GetEffectiveStrategy(cfg.Modules[0], "dev"); strategy != version.StrategyRange {
+				t.Errorf("got strategy %s, want range", strategy)
+			}
+		})
+	}
+
+	// A forced format reports its own parse error instead of falling back to the other
+	yamlFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(yamlFile, []byte("modules:\n  - source: hashicorp/aws/vpc\n"), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	if _, err := LoadConfigWithFormat(yamlFile, FormatJSON); err == nil || !strings.Contains(err.Error(), "parsing config file as json") {
+		t.Errorf("expected a json parse error, got %v", err)
+	}
+}
+
+func TestLoadConfig_TOML(t *testing.T) {
+	tomlContent := `
+# Modules are an array of tables
+layout = "tiered"
+post_update_hook = ["terraform", "fmt"]
+
+[tier_dirs]
+prd = ["envs/prod", 'envs/prod-eu']
+
+[[modules]]
+source = "hashicorp/aws/vpc"
+strategy = "range"
+
+[modules.versions]
+"dev,stg" = ">=1,<2"
+prd = { version = "1.5.0", strategy = "exact" }
+
+[[modules]]
+source = "hashicorp/aws/rds"
+versions.dev = 2.0 # an unquoted version keeps its text
+
+[[freeze]]
+source = "hashicorp/aws/rds"
+tier = "prd"
+version = """
+1.2.3"""
+`
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.conf")
+	if err := os.WriteFile(configFile, []byte(tomlContent), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	cfg, err := LoadConfigWithFormat(configFile, FormatTOML)
+	if err != nil {
+		t.Fatalf("LoadConfigWithFormat: %v", err)
+	}
+	if cfg.Layout != LayoutTiered || !reflect.DeepEqual(cfg.PostUpdateHook, StringList{"terraform", "fmt"}) {
+		t.Errorf("got layout %q and hook %v", cfg.Layout, cfg.PostUpdateHook)
+	}
+	if got := cfg.TierDirs["prd"]; !reflect.DeepEqual(got, StringList{"envs/prod", "envs/prod-eu"}) {
+		t.Errorf("got prd tier dirs %v", got)
+	}
+	if len(cfg.Modules) != 2 {
+		t.Fatalf("got %d modules, want 2", len(cfg.Modules))
+	}
+	for _, tc := range []struct {
+		module   int
+		tier     string
+		version  string
+		strategy version.Strategy
+	}{
+		{0, "dev", ">=1,<2", version.StrategyRange},
+		{0, "stg", ">=1,<2", version.StrategyRange},
+		{0, "prd", "1.5.0", version.StrategyExact},
+		{1, "dev", "2.0", version.StrategyDynamic},
+	} {
+		vc, err := GetEffectiveVersionConfig(cfg.Modules[tc.module], tc.tier)
+		if err != nil {
+			t.Fatalf("module %d tier %s: %v", tc.module, tc.tier, err)
+		}
+		if vc.Version != tc.version || GetEffectiveStrategy(cfg.Modules[tc.module], tc.tier) != tc.strategy {
+			t.Errorf("module %d tier %s: got %q (%s), want %q (%s)", tc.module, tc.tier, vc.Version, GetEffectiveStrategy(cfg.Modules[tc.module], tc.tier), tc.version, tc.strategy)
+		}
+	}
+	if want := []FreezeEntry{{Source: "hashicorp/aws/rds", Tier: "prd", Version: "1.2.3"}}; !reflect.DeepEqual(cfg.Freeze, want) {
+		t.Errorf("got freeze %+v, want %+v", cfg.Freeze, want)
+	}
+
+	// A .toml file is read as TOML without a forced format, also as an extended base
+	if err := os.WriteFile(filepath.Join(tmpDir, "base.toml"), []byte(tomlContent), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	childFile := filepath.Join(tmpDir, "child.yaml")
+	if err := os.WriteFile(childFile, []byte("extends: base.toml\nmodules: []\n"), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	if cfg, err := LoadConfig(childFile); err != nil || len(cfg.Modules) != 2 {
+		t.Errorf("expected the TOML base config to be loaded, got %v", err)
+	}
+}
+
+func TestLoadConfig_TOMLErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "duplicate key",
+			content: "layout = \"flat\"\nlayout = \"tiered\"\n",
+			wantErr: `toml line 2: key "layout" is defined more than once`,
+		},
+		{
+			name:    "date value",
+			content: "[[modules]]\nsource = \"hashicorp/aws/vpc\"\nversions.dev = 2024-01-01\n",
+			wantErr: "toml line 3: dates and times are not supported",
+		},
+		{
+			name:    "unterminated string",
+			content: "[[modules]]\nsource = \"hashicorp/aws/vpc\n",
+			wantErr: "toml line 2: unterminated string",
+		},
+		{
+			name:    "trailing text",
+			content: "layout = \"flat\" tiered\n",
+			wantErr: `toml line 1: unexpected 't' after value`,
+		},
+		{
+			name:    "table over a value",
+			content: "layout = \"flat\"\n[layout]\n",
+			wantErr: `toml line 2: key "layout" is already defined and is not a table`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(configFile, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write config file: %v", err)
+			}
+			_, err := LoadConfig(configFile)
+			if err == nil || !strings.Contains(err.Error(), "parsing config file as toml") || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected a toml error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Format
+		wantErr string
+	}{
+		{input: "", want: FormatAuto},
+		{input: "json", want: FormatJSON},
+		{input: "YAML", want: FormatYAML},
+		{input: "toml", want: FormatTOML},
+		{input: "ini", wantErr: "invalid config format 'ini' (expected json, yaml or toml)"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.input)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseFormat(%q) = %q, %v, want an error containing %q", tt.input, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseFormat(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestLoadConfig_ExactStrategyWithRange(t *testing.T) {
+	yamlContent := `
+modules:
+  - source: "hashicorp/aws/rds"
+    strategy: "exact"
+    versions:
+      dev: ">=1,<2"
+`
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte(yamlContent), 0o600); err != nil {
+		t.Fatalf("failed to write YAML file: %v", err)
+	}
+
+	_, err := LoadConfig(configFile)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "module hashicorp/aws/rds tier dev") {
+		t.Errorf("error %q does not name the module and tier", err.Error())
+	}
+}
+
+func TestLoadConfig_Extends(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	writeFile("base/base.yaml", `
+layout: flat
+modules:
+  - source: "hashicorp/aws/vpc"
+    strategy: "range"
+    versions:
+      "*": ">=1,<2"
+  - source: "hashicorp/aws/rds"
+    strategy: "exact"
+    versions:
+      dev: "1.0.0"
+`)
+	childPath := writeFile("child.yaml", `
+extends: base/base.yaml
+modules:
+  - source: "hashicorp/aws/rds"
+    strategy: "exact"
+    versions:
+      dev: "2.0.0"
+      prd: "1.5.0"
+  - source: "hashicorp/aws/s3"
+    versions:
+      "*": "3.0.0"
+`)
+
+	cfg, err := LoadConfig(childPath)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	if cfg.Extends != "" {
+		t.Errorf("expected extends to be resolved, got %q", cfg.Extends)
+	}
+	if cfg.Layout != LayoutFlat {
+		t.Errorf("expected layout to be inherited from the base config, got %q", cfg.Layout)
+	}
+
+	var sources []string
+	for _, m := range cfg.Modules {
+		sources = append(sources, m.Source)
+	}
+	wantSources := []string{"hashicorp/aws/vpc", "hashicorp/aws/rds", "hashicorp/aws/s3"}
+	if !reflect.DeepEqual(sources, wantSources) {
+		t.Fatalf("got modules %v, want %v", sources, wantSources)
+	}
+
+	if cfg.Modules[0].Strategy != version.StrategyRange {
+		t.Errorf("expected base module to be inherited, got strategy %q", cfg.Modules[0].Strategy)
+	}
+	wantRds := map[string]interface{}{"dev": "2.0.0", "prd": "1.5.0"}
+	if !reflect.DeepEqual(cfg.Modules[1].Versions, wantRds) {
+		t.Errorf("expected child module to replace base module, got versions %v", cfg.Modules[1].Versions)
+	}
+}
+
+func TestLoadConfig_ExtendsChain(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"root.yaml": `
+modules:
+  - source: "hashicorp/aws/vpc"
+    versions:
+      dev: "1.0.0"
+`,
+		"middle.yaml": `
+extends: root.yaml
+modules:
+  - source: "hashicorp/aws/vpc"
+    versions:
+      dev: "2.0.0"
+`,
+		"leaf.yaml": `
+extends: middle.yaml
+modules:
+  - source: "hashicorp/aws/rds"
+    versions:
+      dev: "1.0.0"
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	cfg, err := LoadConfig(filepath.Join(tmpDir, "leaf.yaml"))
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if len(cfg.Modules) != 2 {
+		t.Fatalf("expected 2 modules, got %d", len(cfg.Modules))
+	}
+	if got := cfg.Modules[0].Versions["dev"]; got != "2.0.0" {
+		t.Errorf("expected middle config to override root, got %v", got)
+	}
+}
+
+func TestLoadConfig_ExtendsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		load    string
+		wantErr string
+	}{
+		{
+			name: "self reference",
+			files: map[string]string{
+				"a.yaml": "extends: a.yaml\nmodules: []\n",
+			},
+			load:    "a.yaml",
+			wantErr: "cyclic extends",
+		},
+		{
+			name: "indirect cycle",
+			files: map[string]string{
+				"a.yaml": "extends: b.yaml\nmodules: []\n",
+				"b.yaml": "extends: ./a.yaml\nmodules: []\n",
+			},
+			load:    "a.yaml",
+			wantErr: "cyclic extends",
+		},
+		{
+			name: "missing base",
+			files: map[string]string{
+				"a.yaml": "extends: missing.yaml\nmodules: []\n",
+			},
+			load:    "a.yaml",
+			wantErr: "loading base config missing.yaml",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for name, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o600); err != nil {
+					t.Fatalf("failed to write %s: %v", name, err)
+				}
+			}
+
+			_, err := LoadConfig(filepath.Join(tmpDir, tc.load))
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("error %q does not contain %q", err.Error(), tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestHookCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		module ModuleConfig
+		want   []string
+	}{
+		{name: "none", want: nil},
+		{name: "config hook split on whitespace", config: Config{PostUpdateHook: StringList{"terraform fmt"}}, want: []string{"terraform", "fmt"}},
+		{name: "list kept as written", config: Config{PostUpdateHook: StringList{"sh", "-c", "terraform fmt \"$0\""}}, want: []string{"sh", "-c", "terraform fmt \"$0\""}},
+		{name: "module hook wins", config: Config{PostUpdateHook: StringList{"terraform fmt"}}, module: ModuleConfig{PostUpdateHook: StringList{"tflint"}}, want: []string{"tflint"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := HookCommand(&tc.config, tc.module); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGetEffectiveOnMissingVersion(t *testing.T) {
+	tests := []struct {
+		name         string
+		moduleConfig ModuleConfig
+		tier         string
+		want         string
+	}{
+		{
+			name: "default",
+			moduleConfig: ModuleConfig{
+				Source:   "test-module",
+				Versions: map[string]interface{}{"dev": "1.0.0"},
+			},
+			tier: "dev",
+			want: MissingVersionWarn,
+		},
+		{
+			name: "module level",
+			moduleConfig: ModuleConfig{
+				Source:           "test-module",
+				OnMissingVersion: MissingVersionError,
+				Versions:         map[string]interface{}{"dev": "1.0.0"},
+			},
+			tier: "dev",
+			want: MissingVersionError,
+		},
+		{
+			name: "wildcard overrides module",
+			moduleConfig: ModuleConfig{
+				Source:           "test-module",
+				OnMissingVersion: MissingVersionError,
+				Versions: map[string]interface{}{
+					"*":   map[string]interface{}{"version": "1.0.0", "on_missing_version": "skip"},
+					"dev": "1.0.0",
+				},
+			},
+			tier: "dev",
+			want: MissingVersionSkip,
+		},
+		{
+			name: "tier overrides wildcard",
+			moduleConfig: ModuleConfig{
+				Source: "test-module",
+				Versions: map[string]interface{}{
+					"*":   map[string]interface{}{"version": "1.0.0", "on_missing_version": "skip"},
+					"prd": map[string]interface{}{"version": "1.0.0", "on_missing_version": "error"},
+				},
+			},
+			tier: "prd",
+			want: MissingVersionError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := GetEffectiveOnMissingVersion(tc.moduleConfig, tc.tier)
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	err := ValidateConfig(&Config{Modules: []ModuleConfig{{
+		Source:           "test-module",
+		OnMissingVersion: "ignore",
+		Versions:         map[string]interface{}{"dev": "1.0.0"},
+	}}})
+	if err == nil || !strings.Contains(err.Error(), "invalid on_missing_version 'ignore'") {
+		t.Errorf("expected invalid on_missing_version error, got %v", err)
+	}
+}
+
+func TestGetEffectiveOnInvalidExisting(t *testing.T) {
+	tests := []struct {
+		name         string
+		moduleConfig ModuleConfig
+		tier         string
+		want         string
+	}{
+		{
+			name: "default",
+			moduleConfig: ModuleConfig{
+				Source:   "test-module",
+				Versions: map[string]interface{}{"dev": "1.0.0"},
+			},
+			tier: "dev",
+			want: InvalidExistingOverwrite,
+		},
+		{
+			name: "module level",
+			moduleConfig: ModuleConfig{
+				Source:            "test-module",
+				OnInvalidExisting: InvalidExistingError,
+				Versions:          map[string]interface{}{"dev": "1.0.0"},
+			},
+			tier: "dev",
+			want: InvalidExistingError,
+		},
+		{
+			name: "tier overrides module",
+			moduleConfig: ModuleConfig{
+				Source:            "test-module",
+				OnInvalidExisting: InvalidExistingError,
+				Versions: map[string]interface{}{
+					"dev": map[string]interface{}{"version": "1.0.0", "on_invalid_existing": "warn"},
+				},
+			},
+			tier: "dev",
+			want: InvalidExistingWarn,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := GetEffectiveOnInvalidExisting(tc.moduleConfig, tc.tier)
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	err := ValidateConfig(&Config{Modules: []ModuleConfig{{
+		Source:            "test-module",
+		OnInvalidExisting: "ignore",
+		Versions:          map[string]interface{}{"dev": "1.0.0"},
+	}}})
+	if err == nil || !strings.Contains(err.Error(), "invalid on_invalid_existing 'ignore'") {
+		t.Errorf("expected invalid on_invalid_existing error, got %v", err)
+	}
+}
+
+func TestGetEffectiveVersionPlacement(t *testing.T) {
+	tests := []struct {
+		name         string
+		moduleConfig ModuleConfig
+		tier         string
+		want         string
+	}{
+		{
+			name:         "default",
+			moduleConfig: ModuleConfig{Source: "test-module", Versions: map[string]interface{}{"dev": "1.0.0"}},
+			tier:         "dev",
+			want:         VersionPlacementEnd,
+		},
+		{
+			name: "module level",
+			moduleConfig: ModuleConfig{
+				Source:           "test-module",
+				VersionPlacement: VersionPlacementAfterSource,
+				Versions:         map[string]interface{}{"dev": "1.0.0"},
+			},
+			tier: "dev",
+			want: VersionPlacementAfterSource,
+		},
+		{
+			name: "tier overrides module",
+			moduleConfig: ModuleConfig{
+				Source:           "test-module",
+				VersionPlacement: VersionPlacementAfterSource,
+				Versions: map[string]interface{}{
+					"prd": map[string]interface{}{"version": "1.0.0", "version_placement": "end"},
+				},
+			},
+			tier: "prd",
+			want: VersionPlacementEnd,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := GetEffectiveVersionPlacement(tc.moduleConfig, tc.tier); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	err := ValidateConfig(&Config{Modules: []ModuleConfig{{
+		Source:           "test-module",
+		VersionPlacement: "before_source",
+		Versions:         map[string]interface{}{"dev": "1.0.0"},
+	}}})
+	if err == nil || !strings.Contains(err.Error(), "invalid version_placement 'before_source'") {
+		t.Errorf("expected invalid version_placement error, got %v", err)
+	}
+}
+
+func TestLoadConfig_TierDirs(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+tier_dirs:
+  prod: environments/production
+  dev:
+    - environments/development
+    - sandbox
+modules:
+  - source: "hashicorp/aws/rds"
+    versions:
+      prod: "2.0.0"
+      dev: "2.1.0"
+`,
+		"config.json": `{
+  "tier_dirs": {"prod": "environments/production", "dev": ["environments/development", "sandbox"]},
+  "modules": [{"source": "hashicorp/aws/rds", "versions": {"prod": "2.0.0", "dev": "2.1.0"}}]
+}`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(configFile, []byte(content), 0o600); err != nil {
+				t.Fatalf("failed to write config file: %v", err)
+			}
+
+			cfg, err := LoadConfig(configFile)
+			if err != nil {
+				t.Fatalf("failed to load config: %v", err)
+			}
+			if got := GetTierDirs(cfg, "prod"); !reflect.DeepEqual(got, []string{"environments/production"}) {
+				t.Errorf("prod dirs = %v", got)
+			}
+			if got := GetTierDirs(cfg, "dev"); !reflect.DeepEqual(got, []string{"environments/development", "sandbox"}) {
+				t.Errorf("dev dirs = %v", got)
+			}
+			if got := GetTierDirs(cfg, "stg"); !reflect.DeepEqual(got, []string{"stg"}) {
+				t.Errorf("unmapped tier dirs = %v, want the tier key", got)
+			}
+		})
+	}
+
+	backslashed := &Config{TierDirs: map[string]StringList{"prod": {`environments\production`}}}
+	if got := GetTierDirs(backslashed, "prod"); !reflect.DeepEqual(got, []string{"environments/production"}) {
+		t.Errorf("backslash-separated prod dirs = %v, want them separated by /", got)
+	}
+	if got := GetTierDirs(backslashed, `environments\staging`); !reflect.DeepEqual(got, []string{"environments/staging"}) {
+		t.Errorf("backslash-separated tier key dirs = %v, want it separated by /", got)
+	}
+
+	err := ValidateConfig(&Config{
+		TierDirs: map[string]StringList{"prod": {"/srv/production"}, "dev": {"../dev"}, "*": {"all"}},
+		Modules:  []ModuleConfig{{Source: "hashicorp/aws/rds", Versions: map[string]interface{}{"prod": "2.0.0"}}},
+	})
+	for _, want := range []string{
+		"tier_dirs: tier prod: directory '/srv/production' must be a relative path inside the work dir",
+		"tier_dirs: tier dev: directory '../dev' must be a relative path inside the work dir",
+		"tier_dirs: the wildcard tier has no directory",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got %v", want, err)
+		}
+	}
+}
+
+func TestLoadConfig_Freeze(t *testing.T) {
+	yamlContent := `
+freeze:
+  - source: "hashicorp/aws/rds"
+    tier: prd
+    version: "1.2.3"
+modules:
+  - source: "hashicorp/aws/rds"
+    versions:
+      prd: "2.0.0"
+`
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configFile, []byte(yamlContent), 0o600); err != nil {
+		t.Fatalf("failed to write YAML file: %v", err)
+	}
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	want := []FreezeEntry{{Source: "hashicorp/aws/rds", Tier: "prd", Version: "1.2.3"}}
+	if !reflect.DeepEqual(cfg.Freeze, want) {
+		t.Errorf("got freeze %+v, want %+v", cfg.Freeze, want)
+	}
+
+	err = ValidateConfig(&Config{Freeze: []FreezeEntry{{Source: "hashicorp/aws/rds"}}})
+	if err == nil || !strings.Contains(err.Error(), "freeze entry 1: source and version are required") {
+		t.Errorf("expected freeze validation error, got %v", err)
+	}
+}
+
+// validateSchema checks value against the subset of JSON Schema emitted by Schema,
+// reporting every violation found under path
+func validateSchema(root, schema map[string]interface{}, value interface{}, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/$defs/")
+		return validateSchema(root, root["$defs"].(map[string]interface{})[name].(map[string]interface{}), value, path)
+	}
+
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		matches := 0
+		for _, option := range oneOf {
+			if len(validateSchema(root, option.(map[string]interface{}), value, path)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return []string{fmt.Sprintf("%s: matches %d of the oneOf schemas", path, matches)}
+		}
+		return nil
+	}
+
+	var problems []string
+	switch schema["type"] {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected string, got %T", path, value)}
+		}
+		if enum, ok := schema["enum"].([]interface{}); ok {
+			found := false
+			for _, e := range enum {
+				found = found || e == s
+			}
+			if !found {
+				problems = append(problems, fmt.Sprintf("%s: %q is not one of %v", path, s, enum))
+			}
+		}
+	case "number":
+		switch value.(type) {
+		case int, float64:
+		default:
+			return []string{fmt.Sprintf("%s: expected number, got %T", path, value)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: expected boolean, got %T", path, value)}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected array, got %T", path, value)}
+		}
+		for i, item := range items {
+			problems = append(problems, validateSchema(root, schema["items"].(map[string]interface{}), item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected object, got %T", path, value)}
+		}
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				if _, ok := obj[r.(string)]; !ok {
+					problems = append(problems, fmt.Sprintf("%s: missing required key %q", path, r))
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for key, v := range obj {
+			if prop, ok := properties[key]; ok {
+				problems = append(problems, validateSchema(root, prop.(map[string]interface{}), v, path+"."+key)...)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					problems = append(problems, fmt.Sprintf("%s: unknown key %q", path, key))
+				}
+			case map[string]interface{}:
+				problems = append(problems, validateSchema(root, extra, v, path+"."+key)...)
+			}
+		}
+	}
+	return problems
+}
+
+// schemaForTest returns Schema as decoded from its JSON encoding
+func schemaForTest(t *testing.T) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(Schema())
+	if err != nil {
+		t.Fatalf("failed to encode schema: %v", err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("failed to decode schema: %v", err)
+	}
+	return schema
+}
+
+func TestSchema_SampleConfigs(t *testing.T) {
+	schema := schemaForTest(t)
+
+	readme, err := os.ReadFile(filepath.Join("..", "..", "README.md"))
+	if err != nil {
+		t.Fatalf("failed to read README: %v", err)
+	}
+
+	// Every complete YAML config in the README must match the schema
+	samples := 0
+	for i, block := range strings.Split(string(readme), "```yaml\n")[1:] {
+		block, _, _ = strings.Cut(block, "```")
+		var sample map[string]interface{}
+		if err := yaml.Unmarshal([]byte(block), &sample); err != nil {
+			continue
+		}
+		if _, ok := sample["modules"]; !ok {
+			continue
+		}
+		samples++
+		for _, problem := range validateSchema(schema, schema, sample, fmt.Sprintf("README yaml block %d", i+1)) {
+			t.Error(problem)
+		}
+	}
+	if samples == 0 {
+		t.Fatal("no sample configs found in README")
+	}
+}
+
+func TestSchema_RejectsInvalidConfigs(t *testing.T) {
+	schema := schemaForTest(t)
+
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name: "unknown strategy",
+			yaml: `
+modules:
+  - source: "hashicorp/aws/vpc"
+    strategy: "latest"
+    versions:
+      dev: "1.0.0"
+`,
+			wantErr: `"latest" is not one of`,
+		},
+		{
+			name: "unknown module key",
+			yaml: `
+modules:
+  - source: "hashicorp/aws/vpc"
+    strategey: "exact"
+    versions:
+      dev: "1.0.0"
+`,
+			wantErr: `unknown key "strategey"`,
+		},
+		{
+			name: "missing source",
+			yaml: `
+modules:
+  - versions:
+      dev: "1.0.0"
+`,
+			wantErr: `missing required key "source"`,
+		},
+		{
+			name: "invalid tier config",
+			yaml: `
+modules:
+  - source: "hashicorp/aws/vpc"
+    versions:
+      dev:
+        force: "yes"
+`,
+			wantErr: "oneOf",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var sample map[string]interface{}
+			if err := yaml.Unmarshal([]byte(tc.yaml), &sample); err != nil {
+				t.Fatalf("failed to parse sample: %v", err)
+			}
+			problems := strings.Join(validateSchema(schema, schema, sample, "config"), "\n")
+			if !strings.Contains(problems, tc.wantErr) {
+				t.Errorf("expected a problem containing %q, got %q", tc.wantErr, problems)
+			}
+		})
+	}
+}
+
+func TestSchema_CoversStructFields(t *testing.T) {
+	schema := schemaForTest(t)
+	props := func(s map[string]interface{}) map[string]interface{} {
+		return s["properties"].(map[string]interface{})
+	}
+
+	module := props(schema)["modules"].(map[string]interface{})["items"].(map[string]interface{})
+	versionConfig := schema["$defs"].(map[string]interface{})["versionConfig"].(map[string]interface{})
+	freeze := props(schema)["freeze"].(map[string]interface{})["items"].(map[string]interface{})
+
+	for _, tc := range []struct {
+		value  interface{}
+		schema map[string]interface{}
+	}{
+		{Config{}, schema},
+		{ModuleConfig{}, module},
+		{VersionConfig{}, versionConfig},
+		{FreezeEntry{}, freeze},
+	} {
+		typ := reflect.TypeOf(tc.value)
+		for i := 0; i < typ.NumField(); i++ {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			if _, ok := props(tc.schema)[name]; !ok {
+				t.Errorf("%s.%s (%s) missing from schema", typ.Name(), typ.Field(i).Name, name)
+			}
+		}
+	}
+}
+
+func TestDiffConfigs(t *testing.T) {
+	oldConfig := &Config{Modules: []ModuleConfig{
+		{
+			Source: "vpc/aws",
+			Versions: map[string]interface{}{
+				"dev":  "1.0.0",
+				"prod": "1.0.0",
+			},
+		},
+		{
+			Source:   "eks/aws",
+			Strategy: version.StrategyDynamic,
+			Versions: map[string]interface{}{"*": "2.0.0"},
+		},
+		{
+			Source:   "rds/aws",
+			Versions: map[string]interface{}{"dev": "3.0.0"},
+		},
+	}}
+	newConfig := &Config{Modules: []ModuleConfig{
+		{
+			Source: "vpc/aws",
+			Versions: map[string]interface{}{
+				"dev":  "1.1.0",
+				"prod": "1.0.0",
+			},
+		},
+		{
+			Source:   "eks/aws",
+			Strategy: version.StrategyExact,
+			Versions: map[string]interface{}{"*": "2.0.0"},
+		},
+		{
+			Source: "s3/aws",
+			Versions: map[string]interface{}{
+				"prod": map[string]interface{}{"version": "1.0.0", "force": true},
+			},
+		},
+	}}
+
+	got := DiffConfigs(oldConfig, newConfig)
+	want := []ConfigDelta{
+		{
+			Module: "vpc/aws", Tier: "dev", Kind: DeltaChanged,
+			Old: &EffectiveTier{Version: "1.0.0", Strategy: version.StrategyDynamic},
+			New: &EffectiveTier{Version: "1.1.0", Strategy: version.StrategyDynamic},
+		},
+		{
+			Module: "eks/aws", Tier: "*", Kind: DeltaChanged,
+			Old: &EffectiveTier{Version: "2.0.0", Strategy: version.StrategyDynamic},
+			New: &EffectiveTier{Version: "2.0.0", Strategy: version.StrategyExact},
+		},
+		{
+			Module: "eks/aws", Tier: "dev", Kind: DeltaChanged,
+			Old: &EffectiveTier{Version: "2.0.0", Strategy: version.StrategyDynamic},
+			New: &EffectiveTier{Version: "2.0.0", Strategy: version.StrategyExact},
+		},
+		{
+			Module: "eks/aws", Tier: "prod", Kind: DeltaChanged,
+			Old: &EffectiveTier{Version: "2.0.0", Strategy: version.StrategyDynamic},
+			New: &EffectiveTier{Version: "2.0.0", Strategy: version.StrategyExact},
+		},
+		{
+			Module: "rds/aws", Tier: "dev", Kind: DeltaRemoved,
+			Old: &EffectiveTier{Version: "3.0.0", Strategy: version.StrategyDynamic},
+		},
+		{
+			Module: "s3/aws", Tier: "prod", Kind: DeltaAdded,
+			New: &EffectiveTier{Version: "1.0.0", Strategy: version.StrategyDynamic, Force: true},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.MarshalIndent(got, "", "  ")
+		t.Errorf("unexpected deltas:\n%s", gotJSON)
+	}
+
+	if deltas := DiffConfigs(oldConfig, oldConfig); len(deltas) != 0 {
+		t.Errorf("expected no deltas between identical configs, got %+v", deltas)
+	}
+}
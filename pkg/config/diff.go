@@ -0,0 +1,151 @@
+package config
+
+import (
+	"sort"
+
+	"github.com/david1155/hclsemver/pkg/version"
+)
+
+// DeltaKind says how the effective config of a module in a tier differs between two configs
+type DeltaKind string
+
+const (
+	// DeltaAdded marks a module and tier only the new config configures
+	DeltaAdded DeltaKind = "added"
+	// DeltaRemoved marks a module and tier only the old config configures
+	DeltaRemoved DeltaKind = "removed"
+	// DeltaChanged marks a module and tier whose version, strategy or force differ
+	DeltaChanged DeltaKind = "changed"
+)
+
+// EffectiveTier is the version, strategy and force a module resolves to in one tier
+type EffectiveTier struct {
+	Version  string           `json:"version"`
+	Strategy version.Strategy `json:"strategy"`
+	Force    bool             `json:"force"`
+}
+
+// ConfigDelta is one difference found by DiffConfigs
+type ConfigDelta struct {
+	Module string    `json:"module"`
+	Tier   string    `json:"tier"`
+	Kind   DeltaKind `json:"kind"`
+	// Old is nil for additions and New is nil for removals
+	Old *EffectiveTier `json:"old,omitempty"`
+	New *EffectiveTier `json:"new,omitempty"`
+}
+
+// DiffConfigs compares the effective version, strategy and force of every module and tier
+// in oldConfig and newConfig, and returns the additions, removals and changes. Modules are
+// matched by source, taking the first of several modules with the same source. Tiers are
+// those of either config; a "*" or "!tier" key is compared as a tier of its own, standing
+// for the tiers a module does not list. Deltas are ordered by module, as listed in oldConfig and
+// then newConfig, and by tier.
+func DiffConfigs(oldConfig, newConfig *Config) []ConfigDelta {
+	tiers := GetTiersFromConfig(oldConfig)
+	for tier := range GetTiersFromConfig(newConfig) {
+		tiers[tier] = true
+	}
+
+	oldModules := modulesBySource(oldConfig)
+	newModules := modulesBySource(newConfig)
+	var sources []string
+	seen := make(map[string]bool)
+	for _, cfg := range []*Config{oldConfig, newConfig} {
+		for _, module := range cfg.Modules {
+			if !seen[module.Source] {
+				seen[module.Source] = true
+				sources = append(sources, module.Source)
+			}
+		}
+	}
+
+	deltas := []ConfigDelta{}
+	for _, source := range sources {
+		oldModule, inOld := oldModules[source]
+		newModule, inNew := newModules[source]
+
+		moduleTiers := make(map[string]bool, len(tiers))
+		for tier := range tiers {
+			moduleTiers[tier] = true
+		}
+		for _, module := range []ModuleConfig{oldModule, newModule} {
+			for key := range module.Versions {
+				if _, negated := NegatedTier(key); negated {
+					moduleTiers[key] = true
+				}
+			}
+		}
+
+		for _, tier := range sortedTiers(moduleTiers) {
+			var before, after *EffectiveTier
+			if inOld {
+				before = effectiveTier(oldModule, tier)
+			}
+			if inNew {
+				after = effectiveTier(newModule, tier)
+			}
+
+			switch {
+			case before == nil && after == nil:
+			case before == nil:
+				deltas = append(deltas, ConfigDelta{Module: source, Tier: tier, Kind: DeltaAdded, New: after})
+			case after == nil:
+				deltas = append(deltas, ConfigDelta{Module: source, Tier: tier, Kind: DeltaRemoved, Old: before})
+			case *before != *after:
+				deltas = append(deltas, ConfigDelta{Module: source, Tier: tier, Kind: DeltaChanged, Old: before, New: after})
+			}
+		}
+	}
+	return deltas
+}
+
+// CoversTier reports whether a tier the module does not list takes its wildcard, tier
+// pattern or negated tier config
+func CoversTier(moduleConfig ModuleConfig, tier string) bool {
+	for key := range moduleConfig.Versions {
+		if excluded, negated := NegatedTier(key); key == "*" || (negated && tier != "*" && excluded != tier) {
+			return true
+		}
+	}
+	_, ok := TierPatternKey(moduleConfig, tier)
+	return ok
+}
+
+// effectiveTier resolves a module for a tier, or returns nil when no version config
+// applies to it
+func effectiveTier(moduleConfig ModuleConfig, tier string) *EffectiveTier {
+	if _, ok := moduleConfig.Versions[tier]; !ok && !CoversTier(moduleConfig, tier) {
+		return nil
+	}
+	vc, err := GetEffectiveVersionConfig(moduleConfig, tier)
+	if err != nil {
+		return nil
+	}
+	return &EffectiveTier{
+		Version:  vc.Version,
+		Strategy: GetEffectiveStrategy(moduleConfig, tier),
+		Force:    GetEffectiveForce(moduleConfig, tier),
+	}
+}
+
+// modulesBySource indexes the modules of cfg by source, keeping the first of duplicates
+func modulesBySource(cfg *Config) map[string]ModuleConfig {
+	modules := make(map[string]ModuleConfig, len(cfg.Modules))
+	for _, module := range cfg.Modules {
+		if _, ok := modules[module.Source]; !ok {
+			modules[module.Source] = module
+		}
+	}
+	return modules
+}
+
+// sortedTiers returns the tiers of a set in order
+func sortedTiers(set map[string]bool) []string {
+	tiers := make([]string, 0, len(set))
+	for tier := range set {
+		tiers = append(tiers, tier)
+	}
+	sort.Strings(tiers)
+	return tiers
+}
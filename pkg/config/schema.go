@@ -0,0 +1,141 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/david1155/hclsemver/pkg/version"
+)
+
+// schemaEnums lists the allowed values of enumerated config keys, by JSON name
+var schemaEnums = map[string][]string{
+	"strategy":            {string(version.StrategyDynamic), string(version.StrategyExact), string(version.StrategyRange), string(version.StrategyPatchOnly), string(version.StrategyMajorLock)},
+	"on_missing_version":  {MissingVersionSkip, MissingVersionWarn, MissingVersionError},
+	"on_invalid_existing": {InvalidExistingOverwrite, InvalidExistingWarn, InvalidExistingError},
+	"version_placement":   {VersionPlacementEnd, VersionPlacementAfterSource},
+	"max_version_policy":  {MaxVersionClamp, MaxVersionError},
+	"blocked_bump_policy": {BlockedBumpKeep, BlockedBumpError},
+	"output_format":       {OutputFormatSpaced, OutputFormatCompact},
+	"pre_1_0_ranges":      {Pre10RangesCollapse, Pre10RangesKeep},
+	"layout":              {LayoutTiered, LayoutFlat},
+	"match":               {MatchSegment, MatchExact, MatchRegex},
+	"range_merge":         {string(version.RangeMergeKeep), string(version.RangeMergeIntersect), string(version.RangeMergeUnion)},
+	"prerelease_range":    {string(version.PrereleaseRangeNextMajor), string(version.PrereleaseRangeSameMajor), string(version.PrereleaseRangeLockTarget)},
+}
+
+// Schema returns a JSON Schema describing the config file format. It is generated from
+// the Config, ModuleConfig, VersionConfig and FreezeEntry structs, so new keys appear
+// automatically; enumerated keys are listed in schemaEnums.
+func Schema() map[string]interface{} {
+	schema := structSchema(reflect.TypeOf(Config{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "hclsemver configuration"
+	schema["$defs"] = map[string]interface{}{
+		"versionConfig": structSchema(reflect.TypeOf(VersionConfig{})),
+	}
+	return schema
+}
+
+// structSchema describes a struct as a closed object, using the JSON names of its fields.
+// Fields without omitempty are required.
+func structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		properties[name] = fieldSchema(name, field.Type)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// fieldSchema describes a single field by its Go type
+func fieldSchema(name string, t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(StringList{}) {
+		return stringListSchema()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		if versionFields[name] {
+			// Unquoted versions such as 2.0 are read as written, and a version may be a
+			// list of comparisons
+			alternatives := []interface{}{
+				map[string]interface{}{"type": "string"},
+				map[string]interface{}{"type": "number"},
+			}
+			if name == "version" {
+				alternatives = append(alternatives, comparatorListSchema())
+			}
+			return map[string]interface{}{"oneOf": alternatives}
+		}
+		schema := map[string]interface{}{"type": "string"}
+		if enum, ok := schemaEnums[name]; ok {
+			schema["enum"] = enum
+		}
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": fieldSchema("", t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	case reflect.Map:
+		if t.Elem() == reflect.TypeOf(StringList{}) {
+			return map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": stringListSchema(),
+			}
+		}
+		// Tier maps hold either a version, as a string, an unquoted number or a list of
+		// comparisons, or a version config object
+		return map[string]interface{}{
+			"type": "object",
+			"additionalProperties": map[string]interface{}{
+				"oneOf": []interface{}{
+					map[string]interface{}{"type": "string"},
+					map[string]interface{}{"type": "number"},
+					comparatorListSchema(),
+					map[string]interface{}{"$ref": "#/$defs/versionConfig"},
+				},
+			},
+		}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// comparatorListSchema describes a version written as a list of comparisons
+func comparatorListSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "array", "minItems": 1, "items": map[string]interface{}{"type": "string"}}
+}
+
+// stringListSchema describes a StringList, written as a string or an array of strings
+func stringListSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+	}
+}
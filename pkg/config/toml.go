@@ -0,0 +1,504 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// decodeTOML decodes a TOML document into v through its JSON form, so a TOML config is
+// read with the same field names and hooks as a JSON one. It supports the subset of TOML
+// a config needs: tables, arrays of tables, dotted and quoted keys, strings, integers,
+// floats, booleans, arrays and inline tables. Numbers are kept as written, like
+// decodeJSON's json.Number, so an unquoted version such as 1.5 keeps its text. Dates and
+// times are rejected.
+func decodeTOML(data []byte, v interface{}) error {
+	doc, err := parseTOML(string(data))
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return decodeJSON(encoded, v)
+}
+
+// tomlTableArray is an array of tables such as [[modules]], kept apart from arrays
+// written as values so that only the former can be extended by a later header
+type tomlTableArray struct {
+	tables []map[string]interface{}
+}
+
+// MarshalJSON encodes the array of tables as a JSON array of objects
+func (a *tomlTableArray) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.tables)
+}
+
+// tomlParser reads a TOML document; line counts the newlines consumed for errors
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+}
+
+// parseTOML returns the root table of a TOML document
+func parseTOML(src string) (map[string]interface{}, error) {
+	p := &tomlParser{src: strings.TrimPrefix(src, "\ufeff"), line: 1}
+	root := make(map[string]interface{})
+	current := root
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return root, nil
+		}
+
+		var err error
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], "[["):
+			p.pos += 2
+			current, err = p.tableHeader(root, "]]", true)
+		case p.peek() == '[':
+			p.pos++
+			current, err = p.tableHeader(root, "]", false)
+		default:
+			err = p.keyValue(current)
+		}
+		if err == nil {
+			err = p.endOfLine()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// errorf reports a parse error at the current line
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("toml line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+// skipSpace skips spaces and tabs
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// skipComment skips a comment up to, but not including, the end of its line
+func (p *tomlParser) skipComment() {
+	if p.peek() != '#' {
+		return
+	}
+	for !p.eof() && p.peek() != '\n' {
+		p.pos++
+	}
+}
+
+// skipBlank skips whitespace, newlines and comments
+func (p *tomlParser) skipBlank() {
+	for {
+		p.skipSpace()
+		p.skipComment()
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], "\r\n"):
+			p.pos += 2
+		case p.peek() == '\n':
+			p.pos++
+		default:
+			return
+		}
+		p.line++
+	}
+}
+
+// endOfLine requires the rest of the line to be blank or a comment
+func (p *tomlParser) endOfLine() error {
+	p.skipSpace()
+	p.skipComment()
+	if p.eof() || p.peek() == '\n' || strings.HasPrefix(p.src[p.pos:], "\r\n") {
+		return nil
+	}
+	return p.errorf("unexpected %q after value", p.peek())
+}
+
+// tableHeader reads the key of a [table] or [[array of tables]] header up to closing and
+// returns the table that following keys are set in
+func (p *tomlParser) tableHeader(root map[string]interface{}, closing string, array bool) (map[string]interface{}, error) {
+	p.skipSpace()
+	keys, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !strings.HasPrefix(p.src[p.pos:], closing) {
+		return nil, p.errorf("expected %q to close the table header", closing)
+	}
+	p.pos += len(closing)
+
+	parent, err := p.descend(root, keys[:len(keys)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := keys[len(keys)-1]
+	existing, ok := parent[last]
+	if array {
+		if !ok {
+			existing = &tomlTableArray{}
+			parent[last] = existing
+		}
+		tables, isArray := existing.(*tomlTableArray)
+		if !isArray {
+			return nil, p.errorf("key %q is already defined and is not an array of tables", last)
+		}
+		table := make(map[string]interface{})
+		tables.tables = append(tables.tables, table)
+		return table, nil
+	}
+	if !ok {
+		table := make(map[string]interface{})
+		parent[last] = table
+		return table, nil
+	}
+	table, isTable := existing.(map[string]interface{})
+	if !isTable {
+		return nil, p.errorf("key %q is already defined and is not a table", last)
+	}
+	return table, nil
+}
+
+// descend returns the table reached from table by keys, creating missing tables. An
+// array of tables is entered at its last table, as TOML requires.
+func (p *tomlParser) descend(table map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for _, key := range keys {
+		switch next := table[key].(type) {
+		case nil:
+			created := make(map[string]interface{})
+			table[key] = created
+			table = created
+		case map[string]interface{}:
+			table = next
+		case *tomlTableArray:
+			if len(next.tables) == 0 {
+				return nil, p.errorf("array of tables %q is empty", key)
+			}
+			table = next.tables[len(next.tables)-1]
+		default:
+			return nil, p.errorf("key %q is already defined and is not a table", key)
+		}
+	}
+	return table, nil
+}
+
+// keyValue reads a "key = value" pair into table
+func (p *tomlParser) keyValue(table map[string]interface{}) error {
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if p.peek() != '=' {
+		return p.errorf("expected \"=\" after key %q", strings.Join(keys, "."))
+	}
+	p.pos++
+	p.skipSpace()
+	value, err := p.value()
+	if err != nil {
+		return err
+	}
+
+	parent, err := p.descend(table, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if _, ok := parent[last]; ok {
+		return p.errorf("key %q is defined more than once", strings.Join(keys, "."))
+	}
+	parent[last] = value
+	return nil
+}
+
+// key reads a possibly dotted key, each part bare or quoted
+func (p *tomlParser) key() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		var part string
+		var err error
+		switch p.peek() {
+		case '"':
+			part, err = p.basicString()
+		case '\'':
+			part, err = p.literalString()
+		default:
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, p.errorf("expected a key, got %q", p.peek())
+			}
+			part = p.src[start:p.pos]
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, part)
+
+		p.skipSpace()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+// isBareKeyChar reports whether c may appear in an unquoted key
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// value reads a value of any supported type
+func (p *tomlParser) value() (interface{}, error) {
+	rest := p.src[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, `"""`):
+		return p.multilineString(`"""`, true)
+	case strings.HasPrefix(rest, "'''"):
+		return p.multilineString("'''", false)
+	case p.peek() == '"':
+		return p.basicString()
+	case p.peek() == '\'':
+		return p.literalString()
+	case p.peek() == '[':
+		return p.array()
+	case p.peek() == '{':
+		return p.inlineTable()
+	}
+
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(" \t\r\n,]}#", rune(p.peek())) {
+		p.pos++
+	}
+	token := p.src[start:p.pos]
+	switch token {
+	case "":
+		return nil, p.errorf("expected a value")
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return p.number(token)
+}
+
+// number converts an integer or float token to a json.Number written as in the file,
+// without underscores and a leading "+"; prefixed integers are converted to decimal
+func (p *tomlParser) number(token string) (json.Number, error) {
+	plain := strings.TrimPrefix(strings.ReplaceAll(token, "_", ""), "+")
+	if len(plain) > 2 && plain[0] == '0' && strings.ContainsRune("xob", rune(plain[1])) {
+		n, err := strconv.ParseInt(plain, 0, 64)
+		if err != nil {
+			return "", p.errorf("invalid integer %q", token)
+		}
+		return json.Number(strconv.FormatInt(n, 10)), nil
+	}
+	if plain == "" || !(plain[0] == '-' || plain[0] >= '0' && plain[0] <= '9') || !json.Valid([]byte(plain)) {
+		if strings.Count(plain, "-") >= 2 || strings.Contains(plain, ":") {
+			return "", p.errorf("dates and times are not supported, quote %q as a string", token)
+		}
+		return "", p.errorf("invalid value %q", token)
+	}
+	return json.Number(plain), nil
+}
+
+// basicString reads a "..." string with escapes
+func (p *tomlParser) basicString() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.peek()
+		switch c {
+		case '"':
+			p.pos++
+			return b.String(), nil
+		case '\\':
+			if err := p.escape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// literalString reads a '...' string, taken as written
+func (p *tomlParser) literalString() (string, error) {
+	p.pos++
+	end := strings.IndexAny(p.src[p.pos:], "'\n")
+	if end < 0 || p.src[p.pos+end] != '\'' {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.src[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+// multilineString reads a string delimited by delim, with escapes in basic strings. A
+// newline right after the opening delimiter is trimmed, and in basic strings a backslash
+// ending a line trims the line break and the whitespace after it.
+func (p *tomlParser) multilineString(delim string, basic bool) (string, error) {
+	p.pos += len(delim)
+	if strings.HasPrefix(p.src[p.pos:], "\r\n") {
+		p.pos += 2
+		p.line++
+	} else if p.peek() == '\n' {
+		p.pos++
+		p.line++
+	}
+
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated multi-line string")
+		}
+		if strings.HasPrefix(p.src[p.pos:], delim) {
+			p.pos += len(delim)
+			return b.String(), nil
+		}
+		c := p.peek()
+		if c == '\\' && basic {
+			after := strings.TrimLeft(p.src[p.pos+1:], " \t")
+			if strings.HasPrefix(after, "\n") || strings.HasPrefix(after, "\r\n") {
+				p.pos++
+				for !p.eof() && strings.ContainsRune(" \t\r\n", rune(p.peek())) {
+					if p.peek() == '\n' {
+						p.line++
+					}
+					p.pos++
+				}
+				continue
+			}
+			if err := p.escape(&b); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if c == '\n' {
+			p.line++
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+}
+
+// escape reads the escape sequence at the current backslash into b
+func (p *tomlParser) escape(b *strings.Builder) error {
+	p.pos++
+	if p.eof() {
+		return p.errorf("unterminated escape sequence")
+	}
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case '"', '\\':
+		b.WriteByte(c)
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+size > len(p.src) {
+			return p.errorf("invalid unicode escape")
+		}
+		code, err := strconv.ParseUint(p.src[p.pos:p.pos+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return p.errorf("invalid unicode escape %q", p.src[p.pos:p.pos+size])
+		}
+		b.WriteRune(rune(code))
+		p.pos += size
+	default:
+		return p.errorf("invalid escape sequence \\%c", c)
+	}
+	return nil
+}
+
+// array reads a [...] array, which may span lines and end in a trailing comma
+func (p *tomlParser) array() ([]interface{}, error) {
+	p.pos++
+	values := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.pos++
+			return values, nil
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, p.errorf("expected \",\" or \"]\" in array")
+		}
+	}
+}
+
+// inlineTable reads a {key = value, ...} table
+func (p *tomlParser) inlineTable() (map[string]interface{}, error) {
+	p.pos++
+	table := make(map[string]interface{})
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.pos++
+		return table, nil
+	}
+	for {
+		p.skipSpace()
+		if err := p.keyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return table, nil
+		default:
+			return nil, p.errorf("expected \",\" or \"}\" in inline table")
+		}
+	}
+}
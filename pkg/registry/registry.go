@@ -0,0 +1,302 @@
+// Package registry resolves module versions from a Terraform module registry
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/david1155/hclsemver/pkg/version"
+)
+
+// DefaultHost is the public Terraform registry
+const DefaultHost = "registry.terraform.io"
+
+// Target versions that are resolved from the registry instead of used as is
+const (
+	// Latest resolves to the newest published stable version
+	Latest = "latest"
+	// LatestMinor resolves to the newest published stable version within the major
+	// version already in use, or the newest overall when there is none
+	LatestMinor = "latest-minor"
+)
+
+// IsSentinel reports whether version is a target resolved from the registry
+func IsSentinel(version string) bool {
+	return version == Latest || version == LatestMinor
+}
+
+// Client queries the module versions API of a Terraform registry. Each module's
+// versions are fetched at most once per client, so a client must not be copied
+// after first use; it is safe for concurrent use.
+type Client struct {
+	// Host is the registry used for sources without a host of their own; defaults to DefaultHost
+	Host string
+	// BaseURL, when set, replaces the modules API endpoint for every source,
+	// e.g. "http://localhost:8080/v1/modules"
+	BaseURL string
+	// HTTPClient performs the requests; defaults to a client with a 30 second timeout
+	HTTPClient *http.Client
+	// CacheDir, when set together with a positive CacheTTL, stores fetched version lists
+	// on disk so later runs within CacheTTL skip the registry
+	CacheDir string
+	// CacheTTL is how long on-disk cache entries stay valid
+	CacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]*cacheEntry
+}
+
+// cacheEntry holds the outcome of one versions lookup; once ensures concurrent
+// callers for the same module share a single request
+type cacheEntry struct {
+	once     sync.Once
+	versions []*semver.Version
+	err      error
+}
+
+// diskCacheEntry is the on-disk form of a cached versions lookup
+type diskCacheEntry struct {
+	URL      string   `json:"url"`
+	Versions []string `json:"versions"`
+}
+
+// NewClient returns a client for the registry at host, or DefaultHost when host is empty
+func NewClient(host string) *Client {
+	if host == "" {
+		host = DefaultHost
+	}
+	return &Client{Host: host, HTTPClient: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// versionsResponse is the body returned by the module versions endpoint
+type versionsResponse struct {
+	Modules []struct {
+		Versions []struct {
+			Version string `json:"version"`
+		} `json:"versions"`
+	} `json:"modules"`
+}
+
+// endpoint returns the versions URL for a source of the form
+// [host/]namespace/name/provider
+func (c *Client) endpoint(source string) (string, error) {
+	parts := strings.Split(strings.Trim(source, "/"), "/")
+	host := c.Host
+	if host == "" {
+		host = DefaultHost
+	}
+
+	switch len(parts) {
+	case 3:
+	case 4:
+		host, parts = parts[0], parts[1:]
+	default:
+		return "", fmt.Errorf("source %q is not a registry address (expected [host/]namespace/name/provider)", source)
+	}
+
+	base := c.BaseURL
+	if base == "" {
+		base = "https://" + host + "/v1/modules"
+	}
+	return strings.TrimRight(base, "/") + "/" + strings.Join(parts, "/") + "/versions", nil
+}
+
+// Versions returns the published versions of source in ascending order.
+// Entries that are not valid semantic versions are skipped. Results, including
+// failures, are cached for the lifetime of the client.
+func (c *Client) Versions(source string) ([]*semver.Version, error) {
+	url, err := c.endpoint(source)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.cache == nil {
+		c.cache = make(map[string]*cacheEntry)
+	}
+	entry, ok := c.cache[url]
+	if !ok {
+		entry = &cacheEntry{}
+		c.cache[url] = entry
+	}
+	c.mu.Unlock()
+
+	entry.once.Do(func() {
+		if versions, ok := c.readDiskCache(url); ok {
+			entry.versions = versions
+			return
+		}
+		entry.versions, entry.err = c.fetch(source, url)
+		if entry.err == nil {
+			c.writeDiskCache(url, entry.versions)
+		}
+	})
+	return entry.versions, entry.err
+}
+
+// fetch requests the versions of source from the registry at url
+func (c *Client) fetch(source, url string) ([]*semver.Version, error) {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("registry lookup for %s failed: %w", source, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry lookup for %s failed: %s", source, resp.Status)
+	}
+
+	var body versionsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("registry lookup for %s failed: invalid response: %w", source, err)
+	}
+
+	var versions []*semver.Version
+	for _, module := range body.Modules {
+		for _, v := range module.Versions {
+			if parsed, err := semver.NewVersion(v.Version); err == nil {
+				versions = append(versions, parsed)
+			}
+		}
+	}
+	sort.Sort(semver.Collection(versions))
+	return versions, nil
+}
+
+// diskCachePath returns the cache file for a versions URL, or "" when disk caching is off
+func (c *Client) diskCachePath(url string) string {
+	if c.CacheDir == "" || c.CacheTTL <= 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.CacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// readDiskCache returns the cached versions for url if a cache file younger than
+// CacheTTL exists
+func (c *Client) readDiskCache(url string) ([]*semver.Version, bool) {
+	path := c.diskCachePath(url)
+	if path == "" {
+		return nil, false
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > c.CacheTTL {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var cached diskCacheEntry
+	if err := json.Unmarshal(data, &cached); err != nil || cached.URL != url {
+		return nil, false
+	}
+
+	versions := make([]*semver.Version, 0, len(cached.Versions))
+	for _, v := range cached.Versions {
+		parsed, err := semver.NewVersion(v)
+		if err != nil {
+			return nil, false
+		}
+		versions = append(versions, parsed)
+	}
+	return versions, true
+}
+
+// writeDiskCache stores versions for url. The cache is an optimisation only, so
+// failures to write it are ignored.
+func (c *Client) writeDiskCache(url string, versions []*semver.Version) {
+	path := c.diskCachePath(url)
+	if path == "" {
+		return
+	}
+
+	cached := diskCacheEntry{URL: url}
+	for _, v := range versions {
+		cached.Versions = append(cached.Versions, v.Original())
+	}
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.CacheDir, 0755); err != nil {
+		return
+	}
+	_ = os.WriteFile(path, data, 0644)
+}
+
+// Resolve returns the concrete version for a sentinel target. For LatestMinor, existing
+// is the version or range currently in the file; its major version is kept when the
+// registry has a stable release in it.
+func (c *Client) Resolve(source, target, existing string) (string, error) {
+	if !IsSentinel(target) {
+		return target, nil
+	}
+
+	versions, err := c.Versions(source)
+	if err != nil {
+		return "", err
+	}
+
+	latest := newestStable(versions, func(*semver.Version) bool { return true })
+	if latest == nil {
+		return "", fmt.Errorf("registry has no stable versions of %s", source)
+	}
+
+	if target == LatestMinor && existing != "" {
+		if major, ok := existingMajor(versions, existing); ok {
+			if v := newestStable(versions, func(v *semver.Version) bool { return v.Major() == major }); v != nil {
+				return v.String(), nil
+			}
+		}
+	}
+	return latest.String(), nil
+}
+
+// newestStable returns the newest version without a pre-release that matches keep
+func newestStable(versions []*semver.Version, keep func(*semver.Version) bool) *semver.Version {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Prerelease() == "" && keep(versions[i]) {
+			return versions[i]
+		}
+	}
+	return nil
+}
+
+// existingMajor returns the major version in use: that of an exact version, or of the
+// newest published version satisfying a range
+func existingMajor(versions []*semver.Version, existing string) (uint64, bool) {
+	if v, err := semver.NewVersion(existing); err == nil {
+		return v.Major(), true
+	}
+
+	expanded, err := version.ExpandTerraformTildeArrow(existing)
+	if err != nil {
+		return 0, false
+	}
+	c, err := semver.NewConstraint(expanded)
+	if err != nil {
+		return 0, false
+	}
+	if v := newestStable(versions, c.Check); v != nil {
+		return v.Major(), true
+	}
+	return 0, false
+}
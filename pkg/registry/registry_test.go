@@ -0,0 +1,177 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestRegistry serves the versions endpoint for the given modules, keyed by
+// namespace/name/provider
+func newTestRegistry(t *testing.T, modules map[string][]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		source := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/modules/"), "/versions")
+		versions, ok := modules[source]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		var entries []string
+		for _, v := range versions {
+			entries = append(entries, fmt.Sprintf(`{"version":%q}`, v))
+		}
+		fmt.Fprintf(w, `{"modules":[{"versions":[%s]}]}`, strings.Join(entries, ","))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestResolve(t *testing.T) {
+	server := newTestRegistry(t, map[string][]string{
+		"org/vpc/aws": {"1.0.0", "1.4.2", "2.0.0", "2.3.1", "3.0.0-beta.1", "1.10.0", "not-a-version"},
+		"org/rc/aws":  {"1.0.0-rc.1"},
+	})
+	client := &Client{BaseURL: server.URL + "/v1/modules"}
+
+	tests := []struct {
+		name     string
+		source   string
+		target   string
+		existing string
+		want     string
+		wantErr  string
+	}{
+		{name: "latest skips pre-releases", source: "org/vpc/aws", target: Latest, want: "2.3.1"},
+		{name: "latest ignores existing", source: "org/vpc/aws", target: Latest, existing: "1.0.0", want: "2.3.1"},
+		{name: "latest-minor keeps exact major", source: "org/vpc/aws", target: LatestMinor, existing: "1.0.0", want: "1.10.0"},
+		{name: "latest-minor keeps range major", source: "org/vpc/aws", target: LatestMinor, existing: "~> 1.4", want: "1.10.0"},
+		{name: "latest-minor without existing", source: "org/vpc/aws", target: LatestMinor, want: "2.3.1"},
+		{name: "latest-minor with unpublished major", source: "org/vpc/aws", target: LatestMinor, existing: "5.0.0", want: "2.3.1"},
+		{name: "source with host", source: "registry.example.com/org/vpc/aws", target: Latest, want: "2.3.1"},
+		{name: "plain version passes through", source: "org/vpc/aws", target: "1.2.3", want: "1.2.3"},
+		{name: "no stable versions", source: "org/rc/aws", target: Latest, wantErr: "no stable versions"},
+		{name: "unknown module", source: "org/missing/aws", target: Latest, wantErr: "404"},
+		{name: "not a registry address", source: "aws/vpc", target: Latest, wantErr: "not a registry address"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := client.Resolve(tc.source, tc.target, tc.existing)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v (result %q)", tc.wantErr, err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolve_Offline(t *testing.T) {
+	server := newTestRegistry(t, nil)
+	url := server.URL
+	server.Close()
+
+	client := &Client{BaseURL: url + "/v1/modules"}
+	_, err := client.Resolve("org/vpc/aws", Latest, "")
+	if err == nil || !strings.Contains(err.Error(), "registry lookup for org/vpc/aws failed") {
+		t.Errorf("expected registry lookup error, got %v", err)
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	tests := []struct {
+		host   string
+		source string
+		want   string
+	}{
+		{"", "org/vpc/aws", "https://registry.terraform.io/v1/modules/org/vpc/aws/versions"},
+		{"tf.example.com", "org/vpc/aws", "https://tf.example.com/v1/modules/org/vpc/aws/versions"},
+		{"tf.example.com", "app.terraform.io/org/vpc/aws", "https://app.terraform.io/v1/modules/org/vpc/aws/versions"},
+	}
+
+	for _, tc := range tests {
+		client := &Client{Host: tc.host}
+		got, err := client.endpoint(tc.source)
+		if err != nil {
+			t.Errorf("endpoint(%q) error: %v", tc.source, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("endpoint(%q) = %q, want %q", tc.source, got, tc.want)
+		}
+	}
+}
+
+func TestVersions_Cache(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		fmt.Fprint(w, `{"modules":[{"versions":[{"version":"1.0.0"},{"version":"1.2.0"}]}]}`)
+	}))
+	defer server.Close()
+
+	t.Run("concurrent lookups share one request", func(t *testing.T) {
+		calls.Store(0)
+		client := &Client{BaseURL: server.URL + "/v1/modules"}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if got, err := client.Resolve("org/vpc/aws", Latest, ""); err != nil || got != "1.2.0" {
+					t.Errorf("Resolve() = %q, %v", got, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if n := calls.Load(); n != 1 {
+			t.Errorf("expected 1 registry request, got %d", n)
+		}
+	})
+
+	t.Run("disk cache is reused across clients", func(t *testing.T) {
+		calls.Store(0)
+		cacheDir := t.TempDir()
+		for i := 0; i < 2; i++ {
+			client := &Client{BaseURL: server.URL + "/v1/modules", CacheDir: cacheDir, CacheTTL: time.Hour}
+			if got, err := client.Resolve("org/vpc/aws", Latest, ""); err != nil || got != "1.2.0" {
+				t.Fatalf("Resolve() = %q, %v", got, err)
+			}
+		}
+
+		if n := calls.Load(); n != 1 {
+			t.Errorf("expected 1 registry request, got %d", n)
+		}
+	})
+
+	t.Run("expired disk cache is refreshed", func(t *testing.T) {
+		calls.Store(0)
+		cacheDir := t.TempDir()
+		for i := 0; i < 2; i++ {
+			client := &Client{BaseURL: server.URL + "/v1/modules", CacheDir: cacheDir, CacheTTL: time.Nanosecond}
+			if _, err := client.Resolve("org/vpc/aws", Latest, ""); err != nil {
+				t.Fatalf("Resolve() error: %v", err)
+			}
+			time.Sleep(time.Millisecond)
+		}
+
+		if n := calls.Load(); n != 2 {
+			t.Errorf("expected 2 registry requests, got %d", n)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/david1155/hclsemver/internal/terraform"
+	"github.com/david1155/hclsemver/pkg/config"
+	"github.com/david1155/hclsemver/pkg/version"
+)
+
+// CheckResult reports whether a version satisfies the constraint of one module block
+type CheckResult struct {
+	// Source is the configured module source the block matched
+	Source string
+	Tier   string
+	File   string
+	// Constraint is the version or range currently in the file
+	Constraint string
+	// Satisfied is true when the checked version satisfies Constraint
+	Satisfied bool
+	// Err is set when Constraint is not a valid version or range; Satisfied is then false
+	Err error
+}
+
+// Check reports, for every configured module and tier, whether candidate satisfies the
+// version constraint currently in the Terraform files under workDir. No file is modified.
+// The tier, module, layout, ignore and file list options of opts apply as in Run.
+func Check(cfg *config.Config, workDir, candidate string, opts Options) ([]CheckResult, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("config is required")
+	}
+
+	ver, err := semver.NewVersion(candidate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version '%s': %w", candidate, err)
+	}
+
+	configTiers, selectedTiers, err := selectTiers(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	flat := isFlat(cfg, opts)
+	var flatTier string
+	scanTiers := tierPathNames(cfg, configTiers)
+	if flat {
+		if flatTier, err = selectFlatTier(configTiers, selectedTiers); err != nil {
+			return nil, err
+		}
+		scanTiers = nil
+	}
+
+	output := opts.Output
+	if output == nil {
+		output = io.Discard
+	}
+
+	findOpts := terraform.Options{
+		RespectIgnore:  opts.RespectIgnore,
+		IgnoreRoot:     workDir,
+		FollowSymlinks: opts.FollowSymlinks,
+		Terragrunt:     opts.Terragrunt,
+		Files:          opts.Files,
+		Output:         output,
+	}
+
+	var results []CheckResult
+	check := func(rootDir string, module config.ModuleConfig, tier string, tiers map[string]bool) error {
+		moduleOpts := findOpts
+		moduleOpts.MatchPartialSource = module.MatchPartialSource
+		moduleOpts.SourceMatch = module.Match
+		found, err := terraform.FindModuleVersions(rootDir, module.Source, tiers, moduleOpts)
+		if err != nil {
+			return fmt.Errorf("error checking module '%s' in tier '%s': %w", module.Source, tier, err)
+		}
+		for _, m := range found {
+			result := CheckResult{Source: module.Source, Tier: tier, File: m.File, Constraint: m.Version}
+			expanded, err := version.ExpandTerraformTildeArrow(m.Version)
+			var constr *semver.Constraints
+			if err == nil {
+				constr, err = semver.NewConstraint(expanded)
+			}
+			if err != nil {
+				result.Err = fmt.Errorf("invalid constraint '%s' in file %s: %w", m.Version, m.File, err)
+			} else {
+				result.Satisfied = constr.Check(ver)
+			}
+			results = append(results, result)
+		}
+		return nil
+	}
+
+	for _, module := range cfg.Modules {
+		if opts.Module != "" && !terraform.MatchModuleSource(module.Source, opts.Module) {
+			continue
+		}
+
+		// A flat layout checks the whole work dir for the processed tier
+		if flat {
+			if _, err := config.GetEffectiveVersionConfig(module, flatTier); err != nil {
+				continue
+			}
+			if err := check(workDir, module, flatTier, scanTiers); err != nil {
+				return results, err
+			}
+			continue
+		}
+
+		tiers, err := moduleTiers(workDir, module, selectedTiers)
+		if err != nil {
+			return results, err
+		}
+
+		// A wildcard-only module applies to the whole work dir, or to the selected tiers
+		if _, ok := module.Versions["*"]; ok && len(module.Versions) == 1 {
+			scanTiers["*"] = true
+			if len(selectedTiers) == 0 {
+				if err := check(workDir, module, "*", scanTiers); err != nil {
+					return results, err
+				}
+				continue
+			}
+			for tier := range selectedTiers {
+				tiers = append(tiers, tier)
+			}
+		}
+
+		sort.Strings(tiers)
+		for _, tier := range tiers {
+			for _, dir := range config.GetTierDirs(cfg, tier) {
+				if err := check(filepath.Join(workDir, dir), module, tier, scanTiers); err != nil {
+					return results, err
+				}
+			}
+		}
+
+		// A negated tier applies to every file outside the excluded tier and the tiers above
+		key, excluded, ok := negatedTierKey(module)
+		if !ok {
+			continue
+		}
+		negatedTiers := negatedPathNames(cfg, module, excluded)
+		if len(selectedTiers) == 0 {
+			if err := check(workDir, module, key, negatedTiers); err != nil {
+				return results, err
+			}
+			continue
+		}
+		var covered []string
+		for tier := range selectedTiers {
+			if _, listed := module.Versions[tier]; listed || tier == excluded {
+				continue
+			}
+			if _, ok := config.TierPatternKey(module, tier); !ok {
+				covered = append(covered, tier)
+			}
+		}
+		sort.Strings(covered)
+		for _, tier := range covered {
+			for _, dir := range config.GetTierDirs(cfg, tier) {
+				if err := check(filepath.Join(workDir, dir), module, tier, negatedTiers); err != nil {
+					return results, err
+				}
+			}
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,62 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// fileHooks collects the commands to run on each changed file, such as post_update_hook
+// commands, in the order the files were first changed, running each distinct command once
+// per file
+type fileHooks struct {
+	// failed is wrapped by the error of a failing command
+	failed error
+	files  []string
+	hooks  map[string][][]string
+}
+
+// add records that file was changed by a module whose hook is command
+func (h *fileHooks) add(file string, command []string) {
+	if len(command) == 0 {
+		return
+	}
+	if h.hooks == nil {
+		h.hooks = make(map[string][][]string)
+	}
+	if _, ok := h.hooks[file]; !ok {
+		h.files = append(h.files, file)
+	}
+	for _, existing := range h.hooks[file] {
+		if strings.Join(existing, "\x00") == strings.Join(command, "\x00") {
+			return
+		}
+	}
+	h.hooks[file] = append(h.hooks[file], command)
+}
+
+// run runs every recorded hook with its file appended as the last argument. Failures are
+// passed to onError, which stops the remaining hooks by returning an error of its own.
+func (h *fileHooks) run(onSuccess func(hook, file string), onError func(error) error) error {
+	for _, file := range h.files {
+		for _, command := range h.hooks[file] {
+			hook := strings.Join(command, " ")
+			var output bytes.Buffer
+			cmd := exec.Command(command[0], append(command[1:], file)...)
+			cmd.Stdout, cmd.Stderr = &output, &output
+			if err := cmd.Run(); err != nil {
+				err = fmt.Errorf("%w: %s on %s: %v", h.failed, hook, file, err)
+				if out := strings.TrimSpace(output.String()); out != "" {
+					err = fmt.Errorf("%w: %s", err, out)
+				}
+				if err := onError(err); err != nil {
+					return err
+				}
+				continue
+			}
+			onSuccess(hook, file)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package runner
+
+import (
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/david1155/hclsemver/internal/terraform"
+	"github.com/david1155/hclsemver/pkg/config"
+)
+
+// Module is one module block found by Inventory
+type Module struct {
+	Source string `json:"source"`
+	// Version is the version attribute, or the ref of a git source without one; it is empty
+	// when the module has neither or its version is not a string literal
+	Version string `json:"version"`
+	File    string `json:"file"`
+	// Tier is the configured tier whose directories hold the file; it is empty without a
+	// config, in a flat layout and for files outside every configured tier
+	Tier string `json:"tier"`
+}
+
+// Inventory returns every module block in the Terraform files under workDir, whether or not
+// the config names its source. No file is modified. cfg may be nil; it is only used to
+// assign tiers. The ignore, symlink and file list options of opts apply as in Run.
+func Inventory(cfg *config.Config, workDir string, opts Options) ([]Module, error) {
+	output := opts.Output
+	if output == nil {
+		output = io.Discard
+	}
+
+	findOpts := terraform.Options{
+		RespectIgnore:  opts.RespectIgnore,
+		IgnoreRoot:     workDir,
+		FollowSymlinks: opts.FollowSymlinks,
+		Files:          opts.Files,
+		Output:         output,
+	}
+	found, err := terraform.FindModules(workDir, findOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	var tiers []string
+	if cfg != nil && !isFlat(cfg, opts) {
+		for tier := range config.GetTiersFromConfig(cfg) {
+			if tier != "*" {
+				tiers = append(tiers, tier)
+			}
+		}
+		sort.Strings(tiers)
+	}
+
+	modules := make([]Module, 0, len(found))
+	for _, m := range found {
+		modules = append(modules, Module{Source: m.Source, Version: m.Version, File: m.File, Tier: fileTier(cfg, tiers, workDir, m.File)})
+	}
+	return modules, nil
+}
+
+// fileTier returns the first of tiers whose directories hold path, matched relative to
+// workDir the way Run matches files to tiers, or "" when none does
+func fileTier(cfg *config.Config, tiers []string, workDir, path string) string {
+	rel, err := filepath.Rel(workDir, path)
+	if err != nil {
+		return ""
+	}
+	for _, tier := range tiers {
+		for _, dir := range config.GetTierDirs(cfg, tier) {
+			if terraform.ShouldProcessTier(rel, map[string]bool{dir: true}) {
+				return tier
+			}
+		}
+	}
+	return ""
+}
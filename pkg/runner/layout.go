@@ -0,0 +1,255 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/david1155/hclsemver/internal/terraform"
+	"github.com/david1155/hclsemver/pkg/config"
+)
+
+// isFlat reports whether the work dir is processed as a single tier
+func isFlat(cfg *config.Config, opts Options) bool {
+	return opts.Flat || cfg.Layout == config.LayoutFlat
+}
+
+// selectFlatTier returns the tier processed in a flat layout: the selected tier, the only
+// configured tier, or "*" when the config has wildcard tiers only. Several tiers would all
+// apply to the same files, so one has to be selected.
+func selectFlatTier(configTiers, selectedTiers map[string]bool) (string, error) {
+	var tiers []string
+	for tier := range selectedTiers {
+		tiers = append(tiers, tier)
+	}
+	if len(tiers) == 0 {
+		for tier := range configTiers {
+			if tier != "*" {
+				tiers = append(tiers, tier)
+			}
+		}
+	}
+	sort.Strings(tiers)
+
+	switch len(tiers) {
+	case 0:
+		return "*", nil
+	case 1:
+		return tiers[0], nil
+	default:
+		return "", fmt.Errorf("flat layout processes a single tier, but tiers %s apply; select one of them", strings.Join(tiers, ", "))
+	}
+}
+
+// frozenForTier returns the freeze entries that apply to tier in a flat layout, where
+// files carry no tier in their path
+func frozenForTier(frozen []terraform.FrozenVersion, tier string) []terraform.FrozenVersion {
+	var entries []terraform.FrozenVersion
+	for _, f := range frozen {
+		if f.Tier == "" || f.Tier == "*" || f.Tier == tier {
+			f.Tier = ""
+			entries = append(entries, f)
+		}
+	}
+	return entries
+}
+
+// tierPathNames returns the configured tiers keyed by the directories holding their files,
+// which is what ShouldProcessTier matches file paths against
+func tierPathNames(cfg *config.Config, configTiers map[string]bool) map[string]bool {
+	names := make(map[string]bool, len(configTiers))
+	for tier, process := range configTiers {
+		if tier == "*" {
+			names[tier] = process
+			continue
+		}
+		for _, dir := range config.GetTierDirs(cfg, tier) {
+			names[dir] = process
+		}
+	}
+	return names
+}
+
+// frozenInTierDirs returns the freeze entries with each tier replaced by the directories
+// holding its files, one entry per directory
+func frozenInTierDirs(cfg *config.Config, frozen []terraform.FrozenVersion) []terraform.FrozenVersion {
+	var entries []terraform.FrozenVersion
+	for _, f := range frozen {
+		if f.Tier == "" || f.Tier == "*" {
+			entries = append(entries, f)
+			continue
+		}
+		for _, dir := range config.GetTierDirs(cfg, f.Tier) {
+			f.Tier = dir
+			entries = append(entries, f)
+		}
+	}
+	return entries
+}
+
+// moduleTiers returns the tiers a module lists by name, sorted and limited to selectedTiers
+// when any are given. A tier pattern such as "region-*" is replaced by the directories
+// under workDir it matches, each a tier of its own, leaving out those the module lists by
+// name; such a tier is selected by its own name or by the pattern. The wildcard and
+// negated keys are left out.
+func moduleTiers(workDir string, module config.ModuleConfig, selectedTiers map[string]bool) ([]string, error) {
+	selected := func(tier string) bool { return len(selectedTiers) == 0 || selectedTiers[tier] }
+
+	listed := make(map[string]bool)
+	for tier := range module.Versions {
+		if _, negated := config.NegatedTier(tier); tier == "*" || negated {
+			continue
+		}
+		if !config.TierPattern(tier) {
+			listed[tier] = selected(tier)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(workDir, filepath.FromSlash(tier)))
+		if err != nil {
+			return nil, fmt.Errorf("module %s tier %s: %w", module.Source, tier, err)
+		}
+		for _, match := range matches {
+			rel, err := filepath.Rel(workDir, match)
+			if err != nil {
+				continue
+			}
+			if info, err := os.Stat(match); err != nil || !info.IsDir() {
+				continue
+			}
+			name := filepath.ToSlash(rel)
+			if _, ok := module.Versions[name]; !ok {
+				listed[name] = listed[name] || selected(name) || selected(tier)
+			}
+		}
+	}
+
+	var tiers []string
+	for tier, ok := range listed {
+		if ok {
+			tiers = append(tiers, tier)
+		}
+	}
+	sort.Strings(tiers)
+	return tiers, nil
+}
+
+// negatedTierKey returns the module's negated version key, such as "!prod", and the tier it
+// excludes. ValidateConfig allows at most one per module.
+func negatedTierKey(module config.ModuleConfig) (string, string, bool) {
+	for key := range module.Versions {
+		if excluded, ok := config.NegatedTier(key); ok {
+			return key, excluded, true
+		}
+	}
+	return "", "", false
+}
+
+// negatedPathNames returns the tiers a pass for a module's negated key matches file paths
+// against: every path outside the directories of the excluded tier, except the directories
+// of the tiers the module lists explicitly, which their own passes process
+func negatedPathNames(cfg *config.Config, module config.ModuleConfig, excluded string) map[string]bool {
+	names := make(map[string]bool)
+	for _, dir := range config.GetTierDirs(cfg, excluded) {
+		names["!"+dir] = true
+	}
+	for tier := range module.Versions {
+		if _, negated := config.NegatedTier(tier); negated || tier == "*" {
+			continue
+		}
+		for _, dir := range config.GetTierDirs(cfg, tier) {
+			names[dir] = false
+		}
+	}
+	return names
+}
+
+// negatedTierCovers reports whether a module's negated key applies to tier, so that the
+// tier can be selected even when no module lists it by name
+func negatedTierCovers(cfg *config.Config, tier string) bool {
+	for _, module := range cfg.Modules {
+		if _, excluded, ok := negatedTierKey(module); ok && excluded != tier {
+			return true
+		}
+	}
+	return false
+}
+
+// tierPatternCovers reports whether a module's tier pattern matches tier, so that a tier
+// directory such as "region-us-east-1" can be selected by its own name
+func tierPatternCovers(cfg *config.Config, tier string) bool {
+	for _, module := range cfg.Modules {
+		if _, ok := config.TierPatternKey(module, tier); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// missingTierDirs returns an error wrapping ErrMissingTierDir for each directory of a tier
+// the modules list by name that does not exist under workDir, and for each tier pattern
+// matching no directory, checking only selectedTiers when any are given. A tier's
+// directories are the tier_dirs configured for it, or the tier name itself.
+func missingTierDirs(cfg *config.Config, workDir string, selectedTiers map[string]bool) []error {
+	tiers := make(map[string]bool)
+	for tier := range selectedTiers {
+		tiers[tier] = true
+	}
+	if len(tiers) == 0 {
+		for _, module := range cfg.Modules {
+			for key := range module.Versions {
+				if _, negated := config.NegatedTier(key); key == "*" || negated {
+					continue
+				}
+				if listed, ok := config.TierList(key); ok {
+					for _, tier := range listed {
+						tiers[tier] = true
+					}
+					continue
+				}
+				tiers[key] = true
+			}
+		}
+	}
+	var errs []error
+	for _, tier := range sortedTiers(tiers) {
+		if config.TierPattern(tier) {
+			matches, _ := filepath.Glob(filepath.Join(workDir, filepath.FromSlash(tier)))
+			found := false
+			for _, match := range matches {
+				if isDir(match) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				errs = append(errs, fmt.Errorf("tier pattern %s: %w: no directory under %s matches it", tier, ErrMissingTierDir, workDir))
+			}
+			continue
+		}
+		for _, dir := range config.GetTierDirs(cfg, tier) {
+			if path := filepath.Join(workDir, filepath.FromSlash(dir)); !isDir(path) {
+				errs = append(errs, fmt.Errorf("tier %s: %w: %s", tier, ErrMissingTierDir, path))
+			}
+		}
+	}
+	return errs
+}
+
+// sortedTiers returns the tiers of a tier set in order, so that a run over several selected
+// tiers reports its changes in the same order every time
+func sortedTiers(tiers map[string]bool) []string {
+	names := make([]string, 0, len(tiers))
+	for tier := range tiers {
+		names = append(names, tier)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isDir reports whether path is an existing directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
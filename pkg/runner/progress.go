@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultProgressInterval is the time between progress lines when Options.ProgressInterval
+// is not positive
+const DefaultProgressInterval = time.Second
+
+// progress counts the distinct files a run has processed and writes how far it has got to
+// a writer, at most once per interval. It is safe for concurrent use, and each line is
+// written with a single Write so that it is never split by other output on the same
+// writer. A nil *progress reports nothing.
+type progress struct {
+	mu       sync.Mutex
+	w        io.Writer
+	interval time.Duration
+	now      func() time.Time
+	last     time.Time
+	total    int
+	seen     map[string]bool
+	tier     string
+}
+
+// newProgress returns a progress writing to w for a run over total files, or nil when w is nil
+func newProgress(w io.Writer, interval time.Duration, total int) *progress {
+	if w == nil {
+		return nil
+	}
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+	p := &progress{w: w, interval: interval, now: time.Now, total: total, seen: make(map[string]bool)}
+	p.last = p.now()
+	return p
+}
+
+// file records that path was processed in tier, writing a progress line when the interval
+// has passed since the last one
+func (p *progress) file(path, tier string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seen[path] = true
+	p.tier = tier
+	if now := p.now(); now.Sub(p.last) >= p.interval {
+		p.last = now
+		p.write(fmt.Sprintf("tier %s", p.tier))
+	}
+}
+
+// done writes the final progress line of the run
+func (p *progress) done() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.write("done")
+}
+
+// write writes a progress line ending in state; the caller holds p.mu
+func (p *progress) write(state string) {
+	fmt.Fprintf(p.w, "Progress: %d/%d files, %s\n", len(p.seen), p.total, state)
+}
@@ -0,0 +1,730 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/david1155/hclsemver/internal/terraform"
+	"github.com/david1155/hclsemver/pkg/config"
+	"github.com/david1155/hclsemver/pkg/registry"
+	"github.com/david1155/hclsemver/pkg/version"
+)
+
+// DefaultBackupSuffix is the suffix used for backups when Options.BackupSuffix is empty
+const DefaultBackupSuffix = terraform.DefaultBackupSuffix
+
+// Errors wrapped by the entries of RunResult.Errors and RunResult.Warnings, for use with errors.Is
+var (
+	// ErrRead marks a file that could not be read
+	ErrRead = terraform.ErrRead
+	// ErrParse marks a file that is not valid HCL
+	ErrParse = terraform.ErrParse
+	// ErrStrategy marks a module the version strategy could not be applied to
+	ErrStrategy = terraform.ErrStrategy
+	// ErrMissingVersion marks a module without a version attribute under on_missing_version: error
+	ErrMissingVersion = terraform.ErrMissingVersion
+	// ErrInvalidVersion marks a module with an invalid version under on_invalid_existing: error
+	ErrInvalidVersion = terraform.ErrInvalidVersion
+	// ErrBackup marks a file whose backup could not be written
+	ErrBackup = terraform.ErrBackup
+	// ErrWrite marks a file that could not be written
+	ErrWrite = terraform.ErrWrite
+	// ErrTooManyChanges is returned by Run when more files would change than
+	// Options.MaxChanges allows
+	ErrTooManyChanges = errors.New("too many changes")
+	// ErrHook marks a post_update_hook that failed on a changed file
+	ErrHook = errors.New("post_update_hook failed")
+	// ErrFormat marks a changed file that terraform fmt failed on
+	ErrFormat = errors.New("terraform fmt failed")
+	// ErrMissingTierDir marks a configured tier without a directory under the work dir,
+	// reported when Options.VerifyTiers is set
+	ErrMissingTierDir = errors.New("tier directory not found")
+)
+
+// Options controls a Run
+type Options struct {
+	// DryRun previews changes without writing files
+	DryRun bool
+	// ReportOnly guarantees a run that writes nothing: it implies DryRun whatever else
+	// asks for changes to be written
+	ReportOnly bool
+	// RespectIgnore skips paths matched by .terraformignore or .gitignore at the work dir root
+	RespectIgnore bool
+	// FollowSymlinks descends into symlinked directories
+	FollowSymlinks bool
+	// Terragrunt also updates the git source refs in terragrunt.hcl files
+	Terragrunt bool
+	// Backup writes a copy of each file before it is modified
+	Backup bool
+	// BackupSuffix is appended to backup file names; defaults to DefaultBackupSuffix
+	BackupSuffix string
+	// OverwriteBackup replaces existing backups instead of failing
+	OverwriteBackup bool
+	// MaxChanges, when positive, previews the run first and fails with ErrTooManyChanges,
+	// without writing any file, when more distinct files would change
+	MaxChanges int
+	// AllowHooks runs the configured post_update_hook commands on the changed files; without
+	// it they are reported in RunResult.Warnings and not run
+	AllowHooks bool
+	// Strict returns the first post_update_hook or terraform fmt failure as the error of
+	// the run instead of collecting it in RunResult.Errors, and fails the run before any
+	// file is processed when VerifyTiers finds a tier directory missing
+	Strict bool
+	// VerifyTiers checks, before processing, that every configured tier other than "*" has
+	// its directories under the work dir, reporting each missing one in RunResult.Warnings
+	// and skipping it instead of failing the modules of the tier; it does nothing in a flat
+	// layout
+	VerifyTiers bool
+	// FailFast returns the first module/tier failure as the error of the run instead of
+	// collecting it in RunResult.Errors and processing the remaining modules
+	FailFast bool
+	// Format runs terraform fmt on each changed file when terraform is on the PATH, on top of
+	// the canonical layout hclwrite already gives the files it writes
+	Format bool
+	// Annotate comments each version written with "# managed by hclsemver (<strategy>
+	// strategy)", updating the comment of an earlier run
+	Annotate bool
+	// OnlyTiers limits the run to these tiers; every tier must be configured for some module
+	OnlyTiers []string
+	// Module limits the run to configured modules whose source matches this pattern
+	Module string
+	// Flat processes workDir as a single tier, as if the config set layout: flat
+	Flat bool
+	// Bump, when set, moves each matching module from its existing version to that version
+	// bumped by one level, applied with the module's strategy, instead of to the configured
+	// version; the config still selects the modules and tiers
+	Bump version.BumpLevel
+	// Registry resolves "latest" and "latest-minor" versions; nil disables network access
+	// and those versions fail
+	Registry *registry.Client
+	// Files, when non-nil, limits the run to these files instead of walking workDir.
+	// Tier, source and ignore filtering still apply, and files outside workDir are skipped.
+	Files []string
+	// Output receives the per-file report and warnings; nil discards them
+	Output io.Writer
+	// Logger receives per-module progress and errors; nil discards them
+	Logger *log.Logger
+	// Debug receives the strategy's reason for every version decision, including those
+	// that leave a version unchanged; nil discards them
+	Debug *log.Logger
+	// Progress receives a line with the files processed out of the files under the work
+	// dir and the current tier, at most every ProgressInterval, and a last line when the
+	// run ends; nil disables progress reporting
+	Progress io.Writer
+	// ProgressInterval is the time between progress lines; defaults to DefaultProgressInterval
+	ProgressInterval time.Duration
+}
+
+// Change describes a version update made, or previewed in dry-run, to a single module block
+type Change struct {
+	Source string `json:"source"`
+	Name   string `json:"name,omitempty"` // configured module name, if any
+	Tier   string `json:"tier"`
+	File   string `json:"file"`
+	// Line and Column locate the version attribute of the module changed in File, or its
+	// source attribute when the version was added or lives in a git ref
+	Line       int              `json:"line"`
+	Column     int              `json:"column"`
+	OldVersion string           `json:"old_version"`
+	NewVersion string           `json:"new_version"`
+	Strategy   version.Strategy `json:"strategy"`
+	// Reason explains which strategy rule determined NewVersion
+	Reason string `json:"reason"`
+	DryRun bool   `json:"dry_run"`
+}
+
+// Decision is the version chosen for one matching module block, whether or not it changed
+type Decision struct {
+	// Source is the configured module source
+	Source string `json:"source"`
+	Tier   string `json:"tier"`
+	File   string `json:"file"`
+	// Line and Column locate the version attribute, or the source attribute of modules
+	// without one
+	Line       int    `json:"line"`
+	Column     int    `json:"column"`
+	OldVersion string `json:"old_version"`
+	NewVersion string `json:"new_version"`
+	// Reason explains which strategy rule determined NewVersion
+	Reason string `json:"reason"`
+}
+
+// Changed reports whether the decision moves the module off its existing version
+func (d Decision) Changed() bool {
+	return version.NormalizeVersionString(d.OldVersion) != version.NormalizeVersionString(d.NewVersion)
+}
+
+// Protected reports whether backward protection kept the existing version
+func (d Decision) Protected() bool {
+	return version.IsProtectedReason(d.Reason)
+}
+
+// SkipReason says why a file or module block was skipped
+type SkipReason = terraform.SkipReason
+
+// Reasons a file or module block is skipped, reported in RunResult.Skips
+const (
+	SkipNoMatch      = terraform.SkipNoMatch
+	SkipTierExcluded = terraform.SkipTierExcluded
+	SkipNoVersion    = terraform.SkipNoVersion
+	SkipFrozen       = terraform.SkipFrozen
+	SkipIgnored      = terraform.SkipIgnored
+)
+
+// Skip is a file or module block left unchanged before a version was decided for it
+type Skip struct {
+	// Source is the configured module source
+	Source string `json:"source"`
+	Tier   string `json:"tier"`
+	File   string `json:"file"`
+	// Module is the source of the skipped module block as written, or empty when the
+	// whole file was skipped
+	Module string     `json:"module,omitempty"`
+	Reason SkipReason `json:"reason"`
+}
+
+// Warning describes a matching module left unchanged with a warning, such as one without
+// a version attribute
+type Warning = terraform.Warning
+
+// RunResult holds the outcome of a Run
+type RunResult struct {
+	// Changes lists every file change in processing order
+	Changes []Change
+	// Decisions lists the version chosen for every matching module block in processing
+	// order, including those left unchanged
+	Decisions []Decision
+	// Skips lists the files and module blocks skipped in each module/tier pass, with the reason
+	Skips []Skip
+	// Errors lists the module/tier failures that were skipped over during the run
+	Errors []error
+	// Warnings lists the files and modules skipped with a warning, wrapping ErrParse or
+	// ErrStrategy, and the post_update_hook commands not run without Options.AllowHooks
+	Warnings []error
+	// ModuleWarnings lists each module left unchanged with a warning, once per file. They are
+	// summarized on Output at the end of the run, de-duplicated by source and reason.
+	ModuleWarnings []Warning
+	// Summary counts the work done for each processed tier, keyed by tier; "*" for
+	// wildcard-only modules scanned across the whole work dir
+	Summary map[string]TierSummary
+}
+
+// FilesChanged returns the number of distinct files changed, or previewed in dry-run
+func (r RunResult) FilesChanged() int {
+	files := make(map[string]bool)
+	for _, c := range r.Changes {
+		files[c.File] = true
+	}
+	return len(files)
+}
+
+// TierSummary counts the work done for one tier in a Run
+type TierSummary struct {
+	// FilesScanned is the number of distinct Terraform files scanned
+	FilesScanned int
+	// FilesChanged is the number of distinct files updated, or previewed in dry-run
+	FilesChanged int
+	// ModulesProtected is the number of module blocks whose existing version was kept by
+	// backward protection instead of being lowered to the target
+	ModulesProtected int
+}
+
+// Run applies the configured module versions to the Terraform files under workDir.
+// Failures for a single module tier are collected in RunResult.Errors and processing
+// continues, unless Options.FailFast is set; invalid options and failures of wildcard-only
+// modules are returned as errors.
+func Run(cfg *config.Config, workDir string, opts Options) (RunResult, error) {
+	var result RunResult
+	if cfg == nil {
+		return result, fmt.Errorf("config is required")
+	}
+	if opts.ReportOnly {
+		opts.DryRun = true
+	}
+	if opts.Bump != "" {
+		if _, err := version.ParseBumpLevel(string(opts.Bump)); err != nil {
+			return result, err
+		}
+	}
+
+	// A limited run is previewed in full first, so that either every change is written or none
+	if opts.MaxChanges > 0 {
+		preview := opts
+		preview.MaxChanges = 0
+		if !opts.DryRun {
+			preview.DryRun = true
+			preview.Output, preview.Logger, preview.Debug, preview.Progress = nil, nil, nil, nil
+		}
+		previewResult, err := Run(cfg, workDir, preview)
+		if err != nil {
+			return previewResult, err
+		}
+		if n := previewResult.FilesChanged(); n > opts.MaxChanges {
+			return previewResult, fmt.Errorf("%w: %d files would change, more than the limit of %d", ErrTooManyChanges, n, opts.MaxChanges)
+		}
+		if opts.DryRun {
+			return previewResult, nil
+		}
+		opts.MaxChanges = 0
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+
+	output := opts.Output
+	if output == nil {
+		output = io.Discard
+	}
+
+	updateOpts := terraform.Options{
+		DryRun:          opts.DryRun,
+		RespectIgnore:   opts.RespectIgnore,
+		IgnoreRoot:      workDir,
+		FollowSymlinks:  opts.FollowSymlinks,
+		Terragrunt:      opts.Terragrunt,
+		Annotate:        opts.Annotate,
+		Backup:          opts.Backup,
+		BackupSuffix:    opts.BackupSuffix,
+		OverwriteBackup: opts.OverwriteBackup,
+		BackedUp:        make(map[string]bool),
+		Output:          output,
+		Files:           opts.Files,
+		OnSkip:          func(err error) { result.Warnings = append(result.Warnings, err) },
+		OnWarning:       func(w Warning) { result.ModuleWarnings = append(result.ModuleWarnings, w) },
+	}
+	if opts.Debug != nil {
+		updateOpts.Debugf = opts.Debug.Printf
+	}
+
+	var prog *progress
+	if opts.Progress != nil {
+		// A file that cannot be visited fails the scan itself, so it is only left out of the total
+		total, _ := terraform.CountFiles(workDir, updateOpts)
+		prog = newProgress(opts.Progress, opts.ProgressInterval, total)
+		defer prog.done()
+	}
+
+	for _, entry := range cfg.Freeze {
+		updateOpts.Frozen = append(updateOpts.Frozen, terraform.FrozenVersion{Source: entry.Source, Tier: entry.Tier, Version: entry.Version})
+	}
+
+	configTiers, selectedTiers, err := selectTiers(cfg, opts)
+	if err != nil {
+		return result, err
+	}
+
+	// In a flat layout every file belongs to the one processed tier
+	flat := isFlat(cfg, opts)
+	scanTiers := configTiers
+	var flatTier string
+	if flat {
+		if flatTier, err = selectFlatTier(configTiers, selectedTiers); err != nil {
+			return result, err
+		}
+		scanTiers = nil
+	} else {
+		// Files are matched against the directories of each tier
+		scanTiers = tierPathNames(cfg, configTiers)
+		updateOpts.Frozen = frozenInTierDirs(cfg, updateOpts.Frozen)
+	}
+
+	// A misspelled or moved tier would otherwise just match no files
+	if opts.VerifyTiers && !flat {
+		missing := missingTierDirs(cfg, workDir, selectedTiers)
+		if opts.Strict && len(missing) > 0 {
+			return result, errors.Join(missing...)
+		}
+		for _, err := range missing {
+			fmt.Fprintf(output, "Warning: %v\n", err)
+			result.Warnings = append(result.Warnings, err)
+		}
+	}
+
+	// target is a validated version or range from the config
+	type target struct {
+		input   string
+		resolve func(existingVersion string) (string, error)
+	}
+
+	// parse reads the configured version/range for a module, resolving registry sentinels
+	parse := func(module config.ModuleConfig, versionConfig config.VersionConfig) (target, error) {
+		input := versionConfig.Version
+		// A bump depends on the version already in each file, whatever the config targets
+		if opts.Bump != "" {
+			resolve := func(existing string) (string, error) {
+				return version.BumpVersion(existing, opts.Bump)
+			}
+			return target{input: input, resolve: resolve}, nil
+		}
+		if registry.IsSentinel(input) {
+			if opts.Registry == nil {
+				return target{}, fmt.Errorf("version '%s' for module '%s' requires registry access (-allow-network)", input, module.Source)
+			}
+
+			// latest-minor depends on the version already in each file
+			if input == registry.LatestMinor {
+				resolve := func(existing string) (string, error) {
+					return opts.Registry.Resolve(module.Source, registry.LatestMinor, existing)
+				}
+				return target{input: input, resolve: resolve}, nil
+			}
+
+			resolved, err := opts.Registry.Resolve(module.Source, input, "")
+			if err != nil {
+				return target{}, fmt.Errorf("error resolving version '%s' for module '%s': %w", input, module.Source, err)
+			}
+			logger.Printf("Resolved version '%s' for module '%s' to %s", input, module.Source, resolved)
+			input = resolved
+		}
+
+		if _, _, _, err := version.ParseVersionOrRange(input); err != nil {
+			return target{}, fmt.Errorf("error parsing version '%s' for module '%s': %w", versionConfig.Version, module.Source, err)
+		}
+		return target{input: input}, nil
+	}
+
+	// Distinct files scanned and changed, and protected modules, per tier
+	scanned := make(map[string]map[string]bool)
+	changed := make(map[string]map[string]bool)
+	protected := make(map[string]int)
+	hooks := fileHooks{failed: ErrHook}
+	formatters := fileHooks{failed: ErrFormat}
+	var terraformBin string
+	if opts.Format && !opts.DryRun {
+		// Without terraform the files keep the layout hclwrite wrote them in
+		terraformBin, _ = exec.LookPath("terraform")
+	}
+
+	// scan runs one module/tier pass and records its changes
+	scan := func(rootDir string, module config.ModuleConfig, tier string, t target, strategy version.Strategy, force bool, tiers map[string]bool) error {
+		if scanned[tier] == nil {
+			scanned[tier] = make(map[string]bool)
+			changed[tier] = make(map[string]bool)
+		}
+
+		scanOpts := updateOpts
+		scanOpts.OnFile = func(path string) {
+			scanned[tier][path] = true
+			prog.file(path, tier)
+		}
+		scanOpts.OnDecision = func(d terraform.Decision) {
+			decision := Decision{
+				Source:     module.Source,
+				Tier:       tier,
+				File:       d.File,
+				Line:       d.Line,
+				Column:     d.Column,
+				OldVersion: d.OldVersion,
+				NewVersion: d.NewVersion,
+				Reason:     d.Reason,
+			}
+			if decision.Protected() {
+				protected[tier]++
+			}
+			result.Decisions = append(result.Decisions, decision)
+		}
+		scanOpts.OnSkipped = func(s terraform.Skip) {
+			if s.Source == "" {
+				// A file skipped as a whole, such as one outside the tier, was processed too
+				prog.file(s.File, tier)
+			}
+			result.Skips = append(result.Skips, Skip{Source: module.Source, Tier: tier, File: s.File, Module: s.Source, Reason: s.Reason})
+		}
+		scanOpts.Force = force
+		scanOpts.StrategyOptions = config.GetEffectiveStrategyOptions(module, tier)
+		scanOpts.OnMissingVersion = config.GetEffectiveOnMissingVersion(module, tier)
+		scanOpts.OnInvalidExisting = config.GetEffectiveOnInvalidExisting(module, tier)
+		scanOpts.VersionPlacement = config.GetEffectiveVersionPlacement(module, tier)
+		scanOpts.MatchPartialSource = module.MatchPartialSource
+		scanOpts.SourceMatch = module.Match
+		scanOpts.SyncSourceRef = module.SyncSourceRef
+		scanOpts.ResolveTarget = t.resolve
+		if flat {
+			scanOpts.Frozen = frozenForTier(updateOpts.Frozen, tier)
+		}
+		changes, err := terraform.ScanAndUpdateModules(rootDir, module.Source, t.input, tiers, strategy, scanOpts)
+		for _, c := range changes {
+			changed[tier][c.File] = true
+			if !c.DryRun {
+				hooks.add(c.File, config.HookCommand(cfg, module))
+				if terraformBin != "" {
+					formatters.add(c.File, []string{terraformBin, "fmt"})
+				}
+			}
+			result.Changes = append(result.Changes, Change{
+				Source:     module.Source,
+				Name:       module.Name,
+				Tier:       tier,
+				File:       c.File,
+				Line:       c.Line,
+				Column:     c.Column,
+				OldVersion: c.OldVersion,
+				NewVersion: c.NewVersion,
+				Strategy:   c.Strategy,
+				Reason:     c.Reason,
+				DryRun:     c.DryRun,
+			})
+		}
+		return err
+	}
+
+	// scanTier runs one module/tier pass over each directory of the tier
+	scanTier := func(module config.ModuleConfig, tier string, t target, strategy version.Strategy, force bool, tiers map[string]bool) error {
+		for _, dir := range config.GetTierDirs(cfg, tier) {
+			path := filepath.Join(workDir, dir)
+			// VerifyTiers has already warned about a missing directory
+			if opts.VerifyTiers && !isDir(path) {
+				continue
+			}
+			if err := scan(path, module, tier, t, strategy, force, tiers); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Failures of a single module tier are collected unless the run fails fast
+	moduleFailed := func(err error) error {
+		if opts.FailFast {
+			return err
+		}
+		logger.Print(err)
+		result.Errors = append(result.Errors, err)
+		return nil
+	}
+
+	// Process each module
+	for _, module := range cfg.Modules {
+		if opts.Module != "" && !terraform.MatchModuleSource(module.Source, opts.Module) {
+			continue
+		}
+
+		// A flat layout applies the module's config for the processed tier to the whole work dir
+		if flat {
+			versionConfig, err := config.GetEffectiveVersionConfig(module, flatTier)
+			if err != nil {
+				// The module is not configured for this tier
+				continue
+			}
+
+			t, err := parse(module, versionConfig)
+			if err != nil {
+				if err := moduleFailed(err); err != nil {
+					return result, err
+				}
+				continue
+			}
+
+			if err := scan(workDir, module, flatTier, t, config.GetEffectiveStrategy(module, flatTier), config.GetEffectiveForce(module, flatTier), scanTiers); err != nil {
+				err = fmt.Errorf("error processing module '%s' in tier '%s': %w", module.Source, flatTier, err)
+				if err := moduleFailed(err); err != nil {
+					return result, err
+				}
+			}
+			continue
+		}
+
+		// If we only have a wildcard tier, use it
+		if len(module.Versions) == 1 {
+			if versionConfig, err := config.GetEffectiveVersionConfig(module, "*"); err == nil {
+				scanTiers["*"] = true
+				strategy := config.GetEffectiveStrategy(module, "*")
+				force := config.GetEffectiveForce(module, "*")
+
+				t, err := parse(module, versionConfig)
+				if err != nil {
+					if err := moduleFailed(err); err != nil {
+						return result, err
+					}
+					continue
+				}
+
+				// With a tier filter, only the selected tier directories are scanned
+				if len(selectedTiers) > 0 {
+					for _, tier := range sortedTiers(selectedTiers) {
+						if err := scanTier(module, tier, t, strategy, force, scanTiers); err != nil {
+							err = fmt.Errorf("error processing module '%s' in tier '%s': %w", module.Source, tier, err)
+							if err := moduleFailed(err); err != nil {
+								return result, err
+							}
+						}
+					}
+					continue
+				}
+
+				if err := scan(workDir, module, "*", t, strategy, force, scanTiers); err != nil {
+					err = fmt.Errorf("error processing module '%s': %w", module.Source, err)
+					if err := moduleFailed(err); err != nil {
+						return result, err
+					}
+				}
+				continue
+			}
+		}
+
+		// Process specific tiers, with tier patterns expanded to the directories they match.
+		// The wildcard tier is only used for inheritance when we have specific tiers, and the
+		// negated tier is processed below.
+		tiers, err := moduleTiers(workDir, module, selectedTiers)
+		if err != nil {
+			if err := moduleFailed(err); err != nil {
+				return result, err
+			}
+			continue
+		}
+		for _, tier := range tiers {
+			// Get effective version config for this tier
+			versionConfig, err := config.GetEffectiveVersionConfig(module, tier)
+			if err != nil {
+				err = fmt.Errorf("error getting version config for module '%s' tier '%s': %w", module.Source, tier, err)
+				if err := moduleFailed(err); err != nil {
+					return result, err
+				}
+				continue
+			}
+
+			// Get effective strategy
+			strategy := config.GetEffectiveStrategy(module, tier)
+
+			// Get effective force setting
+			force := config.GetEffectiveForce(module, tier)
+
+			t, err := parse(module, versionConfig)
+			if err != nil {
+				if err := moduleFailed(err); err != nil {
+					return result, err
+				}
+				continue
+			}
+
+			if err := scanTier(module, tier, t, strategy, force, scanTiers); err != nil {
+				err = fmt.Errorf("error processing module '%s' in tier '%s': %w", module.Source, tier, err)
+				if err := moduleFailed(err); err != nil {
+					return result, err
+				}
+				continue
+			}
+
+			logger.Printf("Successfully processed module '%s' in tier '%s'", config.ModuleName(module), tier)
+		}
+
+		// A negated tier such as "!prod" applies to every file outside the excluded tier
+		// and the tiers listed above
+		key, excluded, ok := negatedTierKey(module)
+		if !ok {
+			continue
+		}
+		versionConfig, err := config.GetEffectiveVersionConfig(module, key)
+		if err != nil {
+			err = fmt.Errorf("error getting version config for module '%s' tier '%s': %w", module.Source, key, err)
+			if err := moduleFailed(err); err != nil {
+				return result, err
+			}
+			continue
+		}
+		t, err := parse(module, versionConfig)
+		if err != nil {
+			if err := moduleFailed(err); err != nil {
+				return result, err
+			}
+			continue
+		}
+		negatedTiers := negatedPathNames(cfg, module, excluded)
+
+		// With a tier filter, the selected tiers it applies to are scanned under their own names
+		if len(selectedTiers) > 0 {
+			for _, tier := range sortedTiers(selectedTiers) {
+				if _, listed := module.Versions[tier]; listed || tier == excluded {
+					continue
+				}
+				if _, ok := config.TierPatternKey(module, tier); ok {
+					continue
+				}
+				if err := scanTier(module, tier, t, config.GetEffectiveStrategy(module, tier), config.GetEffectiveForce(module, tier), negatedTiers); err != nil {
+					err = fmt.Errorf("error processing module '%s' in tier '%s': %w", module.Source, tier, err)
+					if err := moduleFailed(err); err != nil {
+						return result, err
+					}
+				}
+			}
+			continue
+		}
+
+		if err := scan(workDir, module, key, t, config.GetEffectiveStrategy(module, key), config.GetEffectiveForce(module, key), negatedTiers); err != nil {
+			err = fmt.Errorf("error processing module '%s' in tier '%s': %w", module.Source, key, err)
+			if err := moduleFailed(err); err != nil {
+				return result, err
+			}
+			continue
+		}
+		logger.Printf("Successfully processed module '%s' in tier '%s'", config.ModuleName(module), key)
+	}
+
+	// Failures of the commands run on changed files are collected unless the run is strict
+	onCommandError := func(err error) error {
+		if opts.Strict {
+			return err
+		}
+		logger.Print(err)
+		result.Errors = append(result.Errors, err)
+		return nil
+	}
+	if err := formatters.run(func(_, file string) { logger.Printf("Ran terraform fmt on %s", file) }, onCommandError); err != nil {
+		return result, err
+	}
+	if len(hooks.files) > 0 && !opts.AllowHooks {
+		result.Warnings = append(result.Warnings, fmt.Errorf("post_update_hook not run on %d changed file(s): hooks require AllowHooks (-allow-hooks)", len(hooks.files)))
+	} else if err := hooks.run(func(hook, file string) { logger.Printf("Ran post_update_hook '%s' on %s", hook, file) }, onCommandError); err != nil {
+		return result, err
+	}
+
+	result.Summary = make(map[string]TierSummary, len(scanned))
+	for tier, files := range scanned {
+		result.Summary[tier] = TierSummary{FilesScanned: len(files), FilesChanged: len(changed[tier]), ModulesProtected: protected[tier]}
+	}
+
+	for _, line := range terraform.SummarizeWarnings(result.ModuleWarnings) {
+		fmt.Fprintf(output, "Warning: %s\n", line)
+	}
+
+	return result, nil
+}
+
+// selectTiers returns the tiers configured in cfg and the subset selected by
+// opts.OnlyTiers, checking that the tier and module filters match the config
+func selectTiers(cfg *config.Config, opts Options) (map[string]bool, map[string]bool, error) {
+	// Get all tiers from config
+	configTiers := config.GetTiersFromConfig(cfg)
+
+	// Restrict the run to the requested tiers, if any
+	selectedTiers := make(map[string]bool)
+	for _, tier := range opts.OnlyTiers {
+		if tier == "*" || (!configTiers[tier] && !negatedTierCovers(cfg, tier) && !tierPatternCovers(cfg, tier)) {
+			return nil, nil, fmt.Errorf("tier '%s' is not configured for any module", tier)
+		}
+		selectedTiers[tier] = true
+	}
+
+	// Restrict the run to modules matching the requested source pattern, if any
+	if opts.Module != "" {
+		matched := false
+		for _, module := range cfg.Modules {
+			if terraform.MatchModuleSource(module.Source, opts.Module) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, nil, fmt.Errorf("no configured module matches '%s'", opts.Module)
+		}
+	}
+
+	return configTiers, selectedTiers, nil
+}
@@ -0,0 +1,1373 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/david1155/hclsemver/pkg/config"
+	"github.com/david1155/hclsemver/pkg/registry"
+	"github.com/david1155/hclsemver/pkg/version"
+)
+
+const testModule = `
+module "test" {
+  source  = "registry.example.com/test-module/aws"
+  version = "1.0.0"
+}
+`
+
+// writeTierFiles creates a main.tf with testModule in each tier directory under workDir
+func writeTierFiles(t *testing.T, workDir string, tiers ...string) {
+	t.Helper()
+	for _, tier := range tiers {
+		dir := filepath.Join(workDir, tier)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create tier directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte(testModule), 0644); err != nil {
+			t.Fatalf("Failed to write tf file: %v", err)
+		}
+	}
+}
+
+func readTierFile(t *testing.T, workDir, tier string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(workDir, tier, "main.tf"))
+	if err != nil {
+		t.Fatalf("Failed to read tf file: %v", err)
+	}
+	return string(content)
+}
+
+func TestRun(t *testing.T) {
+	cfg := &config.Config{
+		Modules: []config.ModuleConfig{
+			{
+				Source:   "test-module/aws",
+				Strategy: version.StrategyExact,
+				Versions: map[string]interface{}{
+					"dev":  "2.0.0",
+					"prod": "1.5.0",
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		opts        Options
+		wantChanges map[string]string // tier -> new version
+		wantWritten bool
+		wantErr     bool
+	}{
+		{
+			name:        "all tiers",
+			opts:        Options{},
+			wantChanges: map[string]string{"dev": "2.0.0", "prod": "1.5.0"},
+			wantWritten: true,
+		},
+		{
+			name:        "dry run",
+			opts:        Options{DryRun: true},
+			wantChanges: map[string]string{"dev": "2.0.0", "prod": "1.5.0"},
+			wantWritten: false,
+		},
+		{
+			name:        "only tier",
+			opts:        Options{OnlyTiers: []string{"prod"}},
+			wantChanges: map[string]string{"prod": "1.5.0"},
+			wantWritten: true,
+		},
+		{
+			name:    "unknown tier",
+			opts:    Options{OnlyTiers: []string{"staging"}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown module",
+			opts:    Options{Module: "gcp/network"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			writeTierFiles(t, workDir, "dev", "prod")
+
+			result, err := Run(cfg, workDir, tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(result.Errors) != 0 {
+				t.Errorf("Unexpected run errors: %v", result.Errors)
+			}
+
+			if len(result.Changes) != len(tt.wantChanges) {
+				t.Fatalf("got %d changes, want %d: %+v", len(result.Changes), len(tt.wantChanges), result.Changes)
+			}
+			for tier := range tt.wantChanges {
+				if got := result.Summary[tier]; got != (TierSummary{FilesScanned: 1, FilesChanged: 1}) {
+					t.Errorf("tier %s summary = %+v, want one file scanned and changed", tier, got)
+				}
+			}
+			for _, c := range result.Changes {
+				want, ok := tt.wantChanges[c.Tier]
+				if !ok {
+					t.Errorf("unexpected change for tier %s", c.Tier)
+					continue
+				}
+				if c.Source != "test-module/aws" || c.OldVersion != "1.0.0" || c.NewVersion != want || c.DryRun != tt.opts.DryRun {
+					t.Errorf("unexpected change record: %+v", c)
+				}
+				if c.Reason != "used target: not lower than existing version" {
+					t.Errorf("change reason = %q, want the exact strategy upgrade reason", c.Reason)
+				}
+				if c.File != filepath.Join(workDir, c.Tier, "main.tf") {
+					t.Errorf("change file = %s, want file in tier %s", c.File, c.Tier)
+				}
+
+				content := readTierFile(t, workDir, c.Tier)
+				if written := strings.Contains(content, `version = "`+want+`"`); written != tt.wantWritten {
+					t.Errorf("tier %s written = %v, want %v", c.Tier, written, tt.wantWritten)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_CollectsErrors(t *testing.T) {
+	cfg := &config.Config{
+		Modules: []config.ModuleConfig{
+			{
+				Source: "test-module/aws",
+				Versions: map[string]interface{}{
+					"dev":  "not-a-version",
+					"prod": "2.0.0",
+				},
+			},
+		},
+	}
+
+	workDir := t.TempDir()
+	writeTierFiles(t, workDir, "dev", "prod")
+
+	result, err := Run(cfg, workDir, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Error(), "not-a-version") {
+		t.Errorf("expected one error for the invalid dev version, got %v", result.Errors)
+	}
+	if len(result.Changes) != 1 || result.Changes[0].Tier != "prod" {
+		t.Errorf("expected prod to still be processed, got %+v", result.Changes)
+	}
+}
+
+func TestRun_CollectsWildcardModuleErrors(t *testing.T) {
+	cfg := &config.Config{
+		Modules: []config.ModuleConfig{
+			{
+				Source:           "broken-module/aws",
+				OnMissingVersion: config.MissingVersionError,
+				Versions:         map[string]interface{}{"*": "2.0.0"},
+			},
+			{
+				Source:   "test-module/aws",
+				Strategy: version.StrategyExact,
+				Versions: map[string]interface{}{"dev": "2.0.0"},
+			},
+		},
+	}
+
+	workDir := t.TempDir()
+	writeTierFiles(t, workDir, "dev")
+	unversioned := "module \"broken\" {\n  source = \"registry.example.com/broken-module/aws\"\n}\n"
+	if err := os.WriteFile(filepath.Join(workDir, "dev", "broken.tf"), []byte(unversioned), 0644); err != nil {
+		t.Fatalf("Failed to write tf file: %v", err)
+	}
+
+	result, err := Run(cfg, workDir, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Errors) != 1 || !errors.Is(result.Errors[0], ErrMissingVersion) || !strings.Contains(result.Errors[0].Error(), "error processing module 'broken-module/aws'") {
+		t.Errorf("expected one ErrMissingVersion error for the wildcard module, got %v", result.Errors)
+	}
+	if len(result.Changes) != 1 || result.Changes[0].Source != "test-module/aws" {
+		t.Errorf("expected test-module/aws to still be processed, got %+v", result.Changes)
+	}
+
+	if _, err := Run(cfg, workDir, Options{FailFast: true}); !errors.Is(err, ErrMissingVersion) {
+		t.Errorf("expected FailFast to return the wildcard module's error, got %v", err)
+	}
+}
+
+func TestRun_TypedErrors(t *testing.T) {
+	cfg := &config.Config{
+		Modules: []config.ModuleConfig{
+			{
+				Source:           "test-module/aws",
+				Strategy:         version.StrategyExact,
+				OnMissingVersion: config.MissingVersionError,
+				Versions: map[string]interface{}{
+					"dev":  "2.0.0",
+					"prod": "2.0.0",
+				},
+			},
+		},
+	}
+
+	workDir := t.TempDir()
+	writeTierFiles(t, workDir, "dev", "prod")
+	if err := os.WriteFile(filepath.Join(workDir, "dev", "broken.tf"), []byte("module \"x\" {\n"), 0644); err != nil {
+		t.Fatalf("Failed to write tf file: %v", err)
+	}
+	unversioned := "module \"test\" {\n  source = \"registry.example.com/test-module/aws\"\n}\n"
+	if err := os.WriteFile(filepath.Join(workDir, "prod", "main.tf"), []byte(unversioned), 0644); err != nil {
+		t.Fatalf("Failed to write tf file: %v", err)
+	}
+
+	result, err := Run(cfg, workDir, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Warnings) != 1 || !errors.Is(result.Warnings[0], ErrParse) {
+		t.Errorf("expected one ErrParse warning, got %v", result.Warnings)
+	}
+	if len(result.Errors) != 1 || !errors.Is(result.Errors[0], ErrMissingVersion) {
+		t.Errorf("expected one ErrMissingVersion error, got %v", result.Errors)
+	}
+	if len(result.Changes) != 1 || result.Changes[0].Tier != "dev" {
+		t.Errorf("expected dev to still be updated, got %+v", result.Changes)
+	}
+}
+
+func TestRun_Freeze(t *testing.T) {
+	cfg := &config.Config{
+		Modules: []config.ModuleConfig{
+			{
+				Source:   "test-module/aws",
+				Strategy: version.StrategyExact,
+				Versions: map[string]interface{}{
+					"dev":  "2.0.0",
+					"prod": "2.0.0",
+				},
+			},
+		},
+		Freeze: []config.FreezeEntry{{Source: "test-module/aws", Tier: "prod", Version: "1.0.0"}},
+	}
+
+	workDir := t.TempDir()
+	writeTierFiles(t, workDir, "dev", "prod")
+
+	result, err := Run(cfg, workDir, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Changes) != 1 || result.Changes[0].Tier != "dev" {
+		t.Errorf("expected only dev to change, got %+v", result.Changes)
+	}
+	if got := readTierFile(t, workDir, "prod"); got != testModule {
+		t.Errorf("expected frozen prod file to be untouched, got:\n%s", got)
+	}
+}
+
+func TestRun_MaxChanges(t *testing.T) {
+	cfg := &config.Config{
+		Modules: []config.ModuleConfig{
+			{
+				Source:   "test-module/aws",
+				Strategy: version.StrategyExact,
+				Versions: map[string]interface{}{"*": "2.0.0"},
+			},
+		},
+	}
+	tiers := []string{"dev", "stg", "prod"}
+
+	workDir := t.TempDir()
+	writeTierFiles(t, workDir, tiers...)
+
+	result, err := Run(cfg, workDir, Options{MaxChanges: 2})
+	if !errors.Is(err, ErrTooManyChanges) {
+		t.Fatalf("expected ErrTooManyChanges, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "3 files would change, more than the limit of 2") {
+		t.Errorf("unexpected error message: %v", err)
+	}
+	if len(result.Changes) != 3 || !result.Changes[0].DryRun {
+		t.Errorf("expected the 3 previewed changes, got %+v", result.Changes)
+	}
+	for _, tier := range tiers {
+		if got := readTierFile(t, workDir, tier); got != testModule {
+			t.Errorf("expected %s to be untouched, got:\n%s", tier, got)
+		}
+	}
+
+	// Within the limit every change is written
+	result, err = Run(cfg, workDir, Options{MaxChanges: 3})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.FilesChanged() != 3 || result.Changes[0].DryRun {
+		t.Errorf("expected 3 written changes, got %+v", result.Changes)
+	}
+	for _, tier := range tiers {
+		if got := readTierFile(t, workDir, tier); !strings.Contains(got, `version = "2.0.0"`) {
+			t.Errorf("expected %s to be updated, got:\n%s", tier, got)
+		}
+	}
+}
+
+func TestRun_PostUpdateHook(t *testing.T) {
+	twoModules := testModule + `
+module "other" {
+  source  = "registry.example.com/other-module/aws"
+  version = "1.0.0"
+}
+`
+	// The hook appends the file it is run on to a log next to the script
+	hookDir := t.TempDir()
+	hookLog := filepath.Join(hookDir, "hook.log")
+	hookScript := filepath.Join(hookDir, "hook.sh")
+	if err := os.WriteFile(hookScript, []byte("#!/bin/sh\necho \"$1\" >> \""+hookLog+"\"\n"), 0755); err != nil {
+		t.Fatalf("Failed to write hook: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		hook        config.StringList
+		opts        Options
+		wantRuns    int
+		wantErr     bool
+		wantErrs    int
+		wantWarning bool
+	}{
+		{name: "once per changed file", hook: config.StringList{hookScript}, opts: Options{AllowHooks: true}, wantRuns: 2},
+		{name: "not allowed", hook: config.StringList{hookScript}, wantWarning: true},
+		{name: "dry run", hook: config.StringList{hookScript}, opts: Options{AllowHooks: true, DryRun: true}},
+		{name: "failure is reported", hook: config.StringList{"false"}, opts: Options{AllowHooks: true}, wantErrs: 2},
+		{name: "failure with strict", hook: config.StringList{"false"}, opts: Options{AllowHooks: true, Strict: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(hookLog)
+			cfg := &config.Config{
+				PostUpdateHook: tt.hook,
+				Modules: []config.ModuleConfig{
+					{Source: "test-module/aws", Versions: map[string]interface{}{"*": "2.0.0"}},
+					{Source: "other-module/aws", Versions: map[string]interface{}{"*": "2.0.0"}},
+				},
+			}
+			workDir := t.TempDir()
+			for _, tier := range []string{"dev", "stg"} {
+				if err := os.MkdirAll(filepath.Join(workDir, tier), 0755); err != nil {
+					t.Fatalf("Failed to create tier directory: %v", err)
+				}
+				if err := os.WriteFile(filepath.Join(workDir, tier, "main.tf"), []byte(twoModules), 0644); err != nil {
+					t.Fatalf("Failed to write tf file: %v", err)
+				}
+			}
+
+			result, err := Run(cfg, workDir, tt.opts)
+			if tt.wantErr {
+				if !errors.Is(err, ErrHook) {
+					t.Fatalf("expected ErrHook, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(result.Changes) != 4 {
+				t.Errorf("expected 4 changes, got %+v", result.Changes)
+			}
+
+			runs := 0
+			if data, err := os.ReadFile(hookLog); err == nil {
+				runs = len(strings.Split(strings.TrimSpace(string(data)), "\n"))
+			}
+			if runs != tt.wantRuns {
+				t.Errorf("expected %d hook runs, got %d", tt.wantRuns, runs)
+			}
+
+			if len(result.Errors) != tt.wantErrs {
+				t.Errorf("expected %d errors, got %v", tt.wantErrs, result.Errors)
+			}
+			for _, err := range result.Errors {
+				if !errors.Is(err, ErrHook) {
+					t.Errorf("expected ErrHook, got %v", err)
+				}
+			}
+			if gotWarning := len(result.Warnings) == 1 && strings.Contains(result.Warnings[0].Error(), "post_update_hook not run on 2 changed file(s)"); gotWarning != tt.wantWarning {
+				t.Errorf("expected hook warning=%v, got %v", tt.wantWarning, result.Warnings)
+			}
+		})
+	}
+}
+
+func TestRun_Format(t *testing.T) {
+	// A fake terraform on the PATH logs the arguments it is run with, or fails
+	binDir := t.TempDir()
+	fmtLog := filepath.Join(binDir, "fmt.log")
+	writeTerraform := func(script string) {
+		if err := os.WriteFile(filepath.Join(binDir, "terraform"), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatalf("Failed to write terraform: %v", err)
+		}
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	tests := []struct {
+		name     string
+		script   string
+		opts     Options
+		wantRuns int
+		wantErr  bool
+		wantErrs int
+	}{
+		{name: "once per changed file", script: "echo \"$@\" >> \"" + fmtLog + "\"", opts: Options{Format: true}, wantRuns: 2},
+		{name: "not requested", script: "echo \"$@\" >> \"" + fmtLog + "\"", opts: Options{}},
+		{name: "dry run", script: "echo \"$@\" >> \"" + fmtLog + "\"", opts: Options{Format: true, DryRun: true}},
+		{name: "failure is reported", script: "exit 2", opts: Options{Format: true}, wantErrs: 2},
+		{name: "failure with strict", script: "exit 2", opts: Options{Format: true, Strict: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(fmtLog)
+			writeTerraform(tt.script)
+			cfg := &config.Config{
+				Modules: []config.ModuleConfig{
+					{Source: "test-module/aws", Versions: map[string]interface{}{"*": "2.0.0"}},
+				},
+			}
+			workDir := t.TempDir()
+			for _, tier := range []string{"dev", "stg"} {
+				if err := os.MkdirAll(filepath.Join(workDir, tier), 0755); err != nil {
+					t.Fatalf("Failed to create tier directory: %v", err)
+				}
+				if err := os.WriteFile(filepath.Join(workDir, tier, "main.tf"), []byte(testModule), 0644); err != nil {
+					t.Fatalf("Failed to write tf file: %v", err)
+				}
+			}
+
+			result, err := Run(cfg, workDir, tt.opts)
+			if tt.wantErr {
+				if !errors.Is(err, ErrFormat) {
+					t.Fatalf("expected ErrFormat, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var runs []string
+			if data, err := os.ReadFile(fmtLog); err == nil {
+				runs = strings.Split(strings.TrimSpace(string(data)), "\n")
+			}
+			if len(runs) != tt.wantRuns {
+				t.Errorf("expected %d terraform fmt runs, got %q", tt.wantRuns, runs)
+			}
+			for _, run := range runs {
+				if !strings.HasPrefix(run, "fmt ") || !strings.HasSuffix(run, "main.tf") {
+					t.Errorf("expected terraform fmt <file>, got %q", run)
+				}
+			}
+
+			if len(result.Errors) != tt.wantErrs {
+				t.Errorf("expected %d errors, got %v", tt.wantErrs, result.Errors)
+			}
+			for _, err := range result.Errors {
+				if !errors.Is(err, ErrFormat) {
+					t.Errorf("expected ErrFormat, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_FlatLayout(t *testing.T) {
+	twoTiers := map[string]interface{}{"dev": "3.0.0", "prod": "2.0.0"}
+
+	tests := []struct {
+		name        string
+		layout      string
+		versions    map[string]interface{}
+		freeze      []config.FreezeEntry
+		opts        Options
+		wantVersion string // empty when nothing changes
+		wantErr     bool
+	}{
+		{name: "layout flat with a single tier", layout: config.LayoutFlat, versions: map[string]interface{}{"prod": "2.0.0"}, wantVersion: "2.0.0"},
+		{name: "flat option", versions: map[string]interface{}{"prod": "2.0.0"}, opts: Options{Flat: true}, wantVersion: "2.0.0"},
+		{name: "selected tier", layout: config.LayoutFlat, versions: twoTiers, opts: Options{OnlyTiers: []string{"dev"}}, wantVersion: "3.0.0"},
+		{name: "single tier next to a wildcard", layout: config.LayoutFlat, versions: map[string]interface{}{"*": "2.5.0", "dev": "3.0.0"}, wantVersion: "3.0.0"},
+		{name: "several tiers without selection", layout: config.LayoutFlat, versions: twoTiers, wantErr: true},
+		{name: "frozen tier", layout: config.LayoutFlat, versions: map[string]interface{}{"prod": "2.0.0"}, freeze: []config.FreezeEntry{{Source: "test-module/aws", Tier: "prod", Version: "1.0.0"}}},
+		{name: "tiered layout ignores files outside tier dirs", versions: map[string]interface{}{"prod": "2.0.0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Layout:  tt.layout,
+				Modules: []config.ModuleConfig{{Source: "test-module/aws", Strategy: version.StrategyExact, Versions: tt.versions}},
+				Freeze:  tt.freeze,
+			}
+
+			// Files live in the work dir itself and in a directory named after no tier
+			workDir := t.TempDir()
+			writeTierFiles(t, workDir, "", "network")
+
+			result, err := Run(cfg, workDir, tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			wantChanges := 0
+			if tt.wantVersion != "" {
+				wantChanges = 2
+			}
+			if len(result.Changes) != wantChanges {
+				t.Fatalf("got %d changes, want %d: %+v", len(result.Changes), wantChanges, result.Changes)
+			}
+			for _, c := range result.Changes {
+				if c.NewVersion != tt.wantVersion {
+					t.Errorf("change %s: new version %s, want %s", c.File, c.NewVersion, tt.wantVersion)
+				}
+				if !strings.Contains(readTierFile(t, workDir, "network"), `version = "`+tt.wantVersion+`"`) {
+					t.Errorf("expected network/main.tf to be updated to %s", tt.wantVersion)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_TierDirs(t *testing.T) {
+	cfg := &config.Config{
+		TierDirs: map[string]config.StringList{"prod": {"environments/production"}},
+		Modules: []config.ModuleConfig{{
+			Source:   "test-module/aws",
+			Strategy: version.StrategyExact,
+			Versions: map[string]interface{}{"dev": "3.0.0", "prod": "2.0.0"},
+		}},
+		Freeze: []config.FreezeEntry{{Source: "test-module/aws", Tier: "prod", Version: "9.9.9"}},
+	}
+
+	workDir := t.TempDir()
+	writeTierFiles(t, workDir, "dev", "environments/production", "prod")
+
+	result, err := Run(cfg, workDir, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Errors) != 0 {
+		t.Errorf("Unexpected run errors: %v", result.Errors)
+	}
+
+	got := make(map[string]string)
+	for _, c := range result.Changes {
+		rel, _ := filepath.Rel(workDir, c.File)
+		got[filepath.ToSlash(rel)] = c.Tier + " " + c.NewVersion
+	}
+	want := map[string]string{
+		"dev/main.tf":                     "dev 3.0.0",
+		"environments/production/main.tf": "prod 2.0.0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got changes %v, want %v", got, want)
+	}
+	if content := readTierFile(t, workDir, "prod"); content != testModule {
+		t.Errorf("expected the unmapped prod directory to be untouched, got:\n%s", content)
+	}
+
+	// A freeze entry for the tier applies to its mapped directory
+	writeTierFiles(t, workDir, "environments/production")
+	cfg.Freeze[0].Version = "1.0.0"
+	if result, err = Run(cfg, workDir, Options{OnlyTiers: []string{"prod"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Changes) != 0 {
+		t.Errorf("expected the frozen prod tier to be left alone, got %+v", result.Changes)
+	}
+
+	// A directory written with backslashes joins to the same path on every platform
+	writeTierFiles(t, workDir, "environments/production")
+	cfg.TierDirs["prod"] = config.StringList{`environments\production`}
+	cfg.Freeze = nil
+	if result, err = Run(cfg, workDir, Options{OnlyTiers: []string{"prod"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Changes) != 1 || filepath.ToSlash(result.Changes[0].File) != filepath.ToSlash(filepath.Join(workDir, "environments", "production", "main.tf")) {
+		t.Errorf("expected the backslash-separated prod directory to be updated, got %+v", result.Changes)
+	}
+}
+
+func TestRun_NegatedTier(t *testing.T) {
+	cfg := &config.Config{
+		Modules: []config.ModuleConfig{{
+			Source:   "test-module/aws",
+			Strategy: version.StrategyExact,
+			Versions: map[string]interface{}{"!prod": "2.0.0", "qa": "3.0.0"},
+		}},
+	}
+
+	tests := []struct {
+		name string
+		opts Options
+		want map[string]string
+	}{
+		{
+			name: "all tiers",
+			want: map[string]string{"dev/main.tf": "!prod 2.0.0", "staging/main.tf": "!prod 2.0.0", "qa/main.tf": "qa 3.0.0"},
+		},
+		{
+			name: "selected tier",
+			opts: Options{OnlyTiers: []string{"staging"}},
+			want: map[string]string{"staging/main.tf": "staging 2.0.0"},
+		},
+		{
+			name: "selected excluded tier",
+			opts: Options{OnlyTiers: []string{"prod"}},
+			want: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			writeTierFiles(t, workDir, "dev", "staging", "qa", "prod")
+
+			result, err := Run(cfg, workDir, tt.opts)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(result.Errors) != 0 {
+				t.Errorf("Unexpected run errors: %v", result.Errors)
+			}
+
+			got := make(map[string]string)
+			for _, c := range result.Changes {
+				rel, _ := filepath.Rel(workDir, c.File)
+				got[filepath.ToSlash(rel)] = c.Tier + " " + c.NewVersion
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got changes %v, want %v", got, tt.want)
+			}
+			if content := readTierFile(t, workDir, "prod"); content != testModule {
+				t.Errorf("expected the excluded prod tier to be untouched, got:\n%s", content)
+			}
+		})
+	}
+}
+
+func TestRun_NilConfig(t *testing.T) {
+	if _, err := Run(nil, t.TempDir(), Options{}); err == nil {
+		t.Error("Expected error for nil config, got nil")
+	}
+}
+
+func TestRun_RegistryVersions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"modules":[{"versions":[{"version":"1.0.0"},{"version":"1.4.0"},{"version":"2.1.0"},{"version":"3.0.0-rc.1"}]}]}`)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Modules: []config.ModuleConfig{
+			{
+				Source:   "org/vpc/aws",
+				Strategy: version.StrategyExact,
+				Versions: map[string]interface{}{
+					"dev":  registry.Latest,
+					"prod": registry.LatestMinor,
+				},
+			},
+		},
+	}
+
+	registryModule := strings.Replace(testModule, "test-module/aws", "org/vpc/aws", 1)
+	writeFiles := func(t *testing.T, workDir string) {
+		t.Helper()
+		for _, tier := range []string{"dev", "prod"} {
+			if err := os.MkdirAll(filepath.Join(workDir, tier), 0755); err != nil {
+				t.Fatalf("Failed to create tier directory: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(workDir, tier, "main.tf"), []byte(registryModule), 0644); err != nil {
+				t.Fatalf("Failed to write tf file: %v", err)
+			}
+		}
+	}
+
+	t.Run("resolves sentinels", func(t *testing.T) {
+		workDir := t.TempDir()
+		writeFiles(t, workDir)
+
+		opts := Options{Registry: &registry.Client{BaseURL: server.URL + "/v1/modules"}}
+		result, err := Run(cfg, workDir, opts)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(result.Errors) != 0 {
+			t.Fatalf("Unexpected result errors: %v", result.Errors)
+		}
+		if got := readTierFile(t, workDir, "dev"); !strings.Contains(got, `version = "2.1.0"`) {
+			t.Errorf("expected dev at latest 2.1.0, got:\n%s", got)
+		}
+		if got := readTierFile(t, workDir, "prod"); !strings.Contains(got, `version = "1.4.0"`) {
+			t.Errorf("expected prod at latest minor 1.4.0, got:\n%s", got)
+		}
+	})
+
+	t.Run("resolves latest minor for git refs", func(t *testing.T) {
+		workDir := t.TempDir()
+		writeFiles(t, workDir)
+		gitModule := `module "vpc" {
+  source = "git::https://example.com/org/vpc/aws?ref=v1.0.0"
+}
+`
+		if err := os.WriteFile(filepath.Join(workDir, "prod", "main.tf"), []byte(gitModule), 0644); err != nil {
+			t.Fatalf("Failed to write tf file: %v", err)
+		}
+
+		opts := Options{Registry: &registry.Client{BaseURL: server.URL + "/v1/modules"}}
+		result, err := Run(cfg, workDir, opts)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(result.Errors) != 0 {
+			t.Fatalf("Unexpected result errors: %v", result.Errors)
+		}
+		if got := readTierFile(t, workDir, "prod"); !strings.Contains(got, `?ref=v1.4.0"`) {
+			t.Errorf("expected prod ref at latest minor v1.4.0, got:\n%s", got)
+		}
+	})
+
+	t.Run("requires registry", func(t *testing.T) {
+		workDir := t.TempDir()
+		writeFiles(t, workDir)
+
+		result, err := Run(cfg, workDir, Options{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(result.Errors) != 2 || !strings.Contains(result.Errors[0].Error(), "requires registry access") {
+			t.Errorf("expected registry access errors, got %v", result.Errors)
+		}
+		if got := readTierFile(t, workDir, "dev"); got != registryModule {
+			t.Errorf("expected dev file to be untouched, got:\n%s", got)
+		}
+	})
+}
+
+func TestRun_RegistryLookedUpOncePerSource(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		fmt.Fprint(w, `{"modules":[{"versions":[{"version":"1.0.0"},{"version":"1.4.0"}]}]}`)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Modules: []config.ModuleConfig{
+			{
+				Source:   "test-org/test-module/aws",
+				Strategy: version.StrategyExact,
+				Versions: map[string]interface{}{
+					"dev":  registry.Latest,
+					"stg":  registry.LatestMinor,
+					"prod": registry.Latest,
+				},
+			},
+		},
+	}
+
+	workDir := t.TempDir()
+	content := strings.Replace(testModule, "test-module/aws", "test-org/test-module/aws", 1)
+	for _, tier := range []string{"dev", "stg", "prod"} {
+		if err := os.MkdirAll(filepath.Join(workDir, tier), 0755); err != nil {
+			t.Fatalf("Failed to create tier directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(workDir, tier, "main.tf"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write tf file: %v", err)
+		}
+	}
+
+	result, err := Run(cfg, workDir, Options{Registry: &registry.Client{BaseURL: server.URL + "/v1/modules"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Changes) != 3 {
+		t.Errorf("expected all three tiers to change, got %+v (errors %v)", result.Changes, result.Errors)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected 1 registry request for three tiers, got %d", n)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	cfg := &config.Config{
+		Modules: []config.ModuleConfig{
+			{
+				Source: "test-module/aws",
+				Versions: map[string]interface{}{
+					"dev":  "2.0.0",
+					"prod": "2.0.0",
+				},
+			},
+		},
+	}
+
+	workDir := t.TempDir()
+	writeTierFiles(t, workDir, "dev", "prod")
+	ranged := strings.Replace(testModule, `version = "1.0.0"`, `version = "~> 1.2"`, 1)
+	if err := os.WriteFile(filepath.Join(workDir, "prod", "main.tf"), []byte(ranged), 0644); err != nil {
+		t.Fatalf("Failed to write tf file: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		candidate string
+		opts      Options
+		want      map[string]bool
+		wantErr   bool
+	}{
+		{name: "satisfies exact only", candidate: "1.0.0", want: map[string]bool{"dev": true, "prod": false}},
+		{name: "satisfies range only", candidate: "1.5.0", want: map[string]bool{"dev": false, "prod": true}},
+		{name: "satisfies neither", candidate: "2.0.0", want: map[string]bool{"dev": false, "prod": false}},
+		{name: "tier filter", candidate: "1.5.0", opts: Options{OnlyTiers: []string{"prod"}}, want: map[string]bool{"prod": true}},
+		{name: "invalid version", candidate: "not-a-version", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := Check(cfg, workDir, tc.candidate, tc.opts)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			got := make(map[string]bool)
+			for _, r := range results {
+				if r.Source != "test-module/aws" || r.Err != nil {
+					t.Errorf("unexpected result %+v", r)
+				}
+				got[r.Tier] = r.Satisfied
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("got results %v, want %v", got, tc.want)
+			}
+			for tier, want := range tc.want {
+				if got[tier] != want {
+					t.Errorf("tier %s: satisfied = %v, want %v", tier, got[tier], want)
+				}
+			}
+		})
+	}
+
+	if got := readTierFile(t, workDir, "dev"); got != testModule {
+		t.Errorf("expected check to leave files untouched, got:\n%s", got)
+	}
+}
+
+func TestInventory(t *testing.T) {
+	cfg := &config.Config{
+		Modules: []config.ModuleConfig{{Source: "test-module/aws", Versions: map[string]interface{}{"dev": "2.0.0"}}},
+	}
+
+	workDir := t.TempDir()
+	writeTierFiles(t, workDir, "dev", "network")
+	unconfigured := `
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "~> 5.0"
+}
+
+module "local" {
+  source = "./modules/local"
+}
+`
+	if err := os.WriteFile(filepath.Join(workDir, "dev", "vpc.tf"), []byte(unconfigured), 0644); err != nil {
+		t.Fatalf("Failed to write tf file: %v", err)
+	}
+
+	modules, err := Inventory(cfg, workDir, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got := make(map[string]Module)
+	for _, m := range modules {
+		rel, _ := filepath.Rel(workDir, m.File)
+		m.File = filepath.ToSlash(rel)
+		got[m.File+" "+m.Source] = m
+	}
+	want := map[string]Module{
+		"dev/main.tf registry.example.com/test-module/aws":     {Source: "registry.example.com/test-module/aws", Version: "1.0.0", File: "dev/main.tf", Tier: "dev"},
+		"dev/vpc.tf terraform-aws-modules/vpc/aws":             {Source: "terraform-aws-modules/vpc/aws", Version: "~> 5.0", File: "dev/vpc.tf", Tier: "dev"},
+		"dev/vpc.tf ./modules/local":                           {Source: "./modules/local", File: "dev/vpc.tf", Tier: "dev"},
+		"network/main.tf registry.example.com/test-module/aws": {Source: "registry.example.com/test-module/aws", Version: "1.0.0", File: "network/main.tf"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got inventory %v, want %v", got, want)
+	}
+	if content := readTierFile(t, workDir, "dev"); content != testModule {
+		t.Errorf("expected the inventory to leave files untouched, got:\n%s", content)
+	}
+
+	// Without a config no tier is assigned
+	if modules, err = Inventory(nil, workDir, Options{}); err != nil || len(modules) != len(want) {
+		t.Fatalf("Inventory without config = %v, %v", modules, err)
+	}
+	for _, m := range modules {
+		if m.Tier != "" {
+			t.Errorf("expected no tier without a config, got %+v", m)
+		}
+	}
+}
+
+func TestRun_AggregatesWarnings(t *testing.T) {
+	cfg := &config.Config{
+		Modules: []config.ModuleConfig{
+			{
+				Source:   "test-module/aws",
+				Strategy: version.StrategyExact,
+				Versions: map[string]interface{}{
+					"dev":  "2.0.0",
+					"prod": "2.0.0",
+				},
+			},
+		},
+	}
+
+	workDir := t.TempDir()
+	unversioned := "module \"test\" {\n  source = \"registry.example.com/test-module/aws\"\n}\n"
+	for _, file := range []string{"dev/a.tf", "dev/b.tf", "prod/main.tf"} {
+		path := filepath.Join(workDir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create tier directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(unversioned), 0644); err != nil {
+			t.Fatalf("Failed to write tf file: %v", err)
+		}
+	}
+
+	var out strings.Builder
+	result, err := Run(cfg, workDir, Options{Output: &out})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(result.ModuleWarnings) != 3 {
+		t.Errorf("expected one module warning per file, got %+v", result.ModuleWarnings)
+	}
+	want := `Warning: Module "registry.example.com/test-module/aws" has no version attribute in 3 files`
+	if got := strings.Count(out.String(), "has no version attribute"); got != 1 || !strings.Contains(out.String(), want) {
+		t.Errorf("expected a single aggregated warning %q, got:\n%s", want, out.String())
+	}
+}
+
+func TestRun_FailFast(t *testing.T) {
+	// The broken module is listed first and fails because its tier directory is missing
+	cfg := &config.Config{
+		Modules: []config.ModuleConfig{
+			{Source: "broken-module/aws", Versions: map[string]interface{}{"qa": "1.0.0"}},
+			{Source: "test-module/aws", Strategy: version.StrategyExact, Versions: map[string]interface{}{"dev": "2.0.0"}},
+		},
+	}
+
+	workDir := t.TempDir()
+	tfFile := filepath.Join(workDir, "dev", "main.tf")
+	if err := os.MkdirAll(filepath.Dir(tfFile), 0755); err != nil {
+		t.Fatalf("Failed to create tier directory: %v", err)
+	}
+	if err := os.WriteFile(tfFile, []byte("module \"test\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"1.0.0\"\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write tf file: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		failFast    bool
+		wantErr     bool
+		wantErrors  int
+		wantChanges int
+	}{
+		{name: "collects failures", wantErrors: 1, wantChanges: 1},
+		{name: "fail fast", failFast: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Run(cfg, workDir, Options{DryRun: true, FailFast: tt.failFast, Output: &strings.Builder{}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error=%v, got %v", tt.wantErr, err)
+			}
+			if len(result.Errors) != tt.wantErrors || len(result.Changes) != tt.wantChanges {
+				t.Errorf("expected %d error(s) and %d change(s), got %v and %+v", tt.wantErrors, tt.wantChanges, result.Errors, result.Changes)
+			}
+		})
+	}
+}
+
+func TestRun_ReportOnly(t *testing.T) {
+	cfg := &config.Config{
+		Modules: []config.ModuleConfig{{
+			Source:   "test-module/aws",
+			Strategy: version.StrategyExact,
+			Force:    true,
+			Versions: map[string]interface{}{"dev": "2.0.0"},
+		}},
+	}
+
+	workDir := t.TempDir()
+	files := map[string]string{
+		filepath.Join(workDir, "dev", "versioned.tf"):   "module \"a\" {\n  source  = \"registry.example.com/test-module/aws\"\n  version = \"1.0.0\"\n}\n",
+		filepath.Join(workDir, "dev", "unversioned.tf"): "module \"b\" {\n  source = \"registry.example.com/test-module/aws\"\n}\n",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create tier directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write tf file: %v", err)
+		}
+	}
+
+	// ReportOnly wins over a run asking for writes, backups and hooks
+	result, err := Run(cfg, workDir, Options{ReportOnly: true, Backup: true, AllowHooks: true, Output: &strings.Builder{}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Changes) != 2 {
+		t.Errorf("expected both changes to be previewed, got %+v", result.Changes)
+	}
+	for _, c := range result.Changes {
+		if !c.DryRun {
+			t.Errorf("expected a dry-run change, got %+v", c)
+		}
+	}
+	for path, content := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read tf file: %v", err)
+		}
+		if string(data) != content {
+			t.Errorf("%s was modified:\n%s", path, data)
+		}
+	}
+	if entries, _ := os.ReadDir(filepath.Join(workDir, "dev")); len(entries) != len(files) {
+		t.Errorf("expected no files to be created, got %d entries", len(entries))
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var out strings.Builder
+	p := newProgress(&out, time.Second, 4)
+	clock := p.last
+	p.now = func() time.Time { return clock }
+
+	// A line is written once the interval has passed since the last one
+	steps := []struct {
+		path    string
+		tier    string
+		advance time.Duration
+	}{
+		{"dev/a.tf", "dev", 500 * time.Millisecond},
+		{"dev/b.tf", "dev", 500 * time.Millisecond},
+		{"dev/b.tf", "dev", 200 * time.Millisecond},
+		{"stg/c.tf", "stg", 900 * time.Millisecond},
+	}
+	for _, s := range steps {
+		clock = clock.Add(s.advance)
+		p.file(s.path, s.tier)
+	}
+	p.done()
+
+	want := "Progress: 2/4 files, tier dev\n" +
+		"Progress: 3/4 files, tier stg\n" +
+		"Progress: 3/4 files, done\n"
+	if out.String() != want {
+		t.Errorf("got progress:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	// A nil progress, as used without Options.Progress, reports nothing
+	var none *progress
+	none.file("dev/a.tf", "dev")
+	none.done()
+}
+
+func TestProgress_Concurrent(t *testing.T) {
+	var out strings.Builder
+	p := newProgress(&out, time.Second, 50)
+	var ticks atomic.Int64
+	start := p.last
+	p.now = func() time.Time { return start.Add(time.Duration(ticks.Add(1)) * time.Second) }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p.file(fmt.Sprintf("dev/%d.tf", i), "dev")
+		}(i)
+	}
+	wg.Wait()
+	p.done()
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 51 {
+		t.Fatalf("expected a line per file and a last line, got %d:\n%s", len(lines), out.String())
+	}
+	for i, line := range lines[:50] {
+		if want := fmt.Sprintf("Progress: %d/50 files, tier dev", i+1); line != want {
+			t.Errorf("line %d: got %q, want %q", i+1, line, want)
+		}
+	}
+	if want := "Progress: 50/50 files, done"; lines[50] != want {
+		t.Errorf("last line: got %q, want %q", lines[50], want)
+	}
+}
+
+func TestRun_Progress(t *testing.T) {
+	cfg := &config.Config{
+		Modules: []config.ModuleConfig{{
+			Source:   "test-module/aws",
+			Versions: map[string]interface{}{"dev": "2.0.0", "stg": "2.0.0"},
+		}},
+	}
+
+	workDir := t.TempDir()
+	for _, tier := range []string{"dev", "stg"} {
+		if err := os.MkdirAll(filepath.Join(workDir, tier), 0755); err != nil {
+			t.Fatalf("Failed to create tier directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(workDir, tier, "main.tf"), []byte(testModule), 0644); err != nil {
+			t.Fatalf("Failed to write tf file: %v", err)
+		}
+	}
+
+	var out, progressOut strings.Builder
+	if _, err := Run(cfg, workDir, Options{DryRun: true, Output: &out, Progress: &progressOut}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, want := progressOut.String(), "Progress: 2/2 files, done\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("expected progress to end with %q, got:\n%s", want, got)
+	}
+	if strings.Contains(out.String(), "Progress:") {
+		t.Errorf("progress written to the report output:\n%s", out.String())
+	}
+}
+
+func TestRun_SelectedTiersOrder(t *testing.T) {
+	cfg := &config.Config{
+		Modules: []config.ModuleConfig{
+			{
+				Source:   "test-module/aws",
+				Strategy: version.StrategyExact,
+				Versions: map[string]interface{}{"*": "2.0.0"},
+			},
+			{
+				Source:   "test-module/aws",
+				Strategy: version.StrategyExact,
+				Versions: map[string]interface{}{"!qa": "3.0.0"},
+			},
+		},
+	}
+
+	tiers := []string{"dev", "prod", "stg"}
+	for i := 0; i < 8; i++ {
+		workDir := t.TempDir()
+		writeTierFiles(t, workDir, tiers...)
+
+		// Selected tiers are processed in order whatever order they were given in
+		result, err := Run(cfg, workDir, Options{DryRun: true, OnlyTiers: []string{"stg", "prod", "dev"}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(result.Changes) != 6 {
+			t.Fatalf("expected 6 changes, got %+v", result.Changes)
+		}
+		for j, c := range result.Changes {
+			if want := tiers[j%3]; c.Tier != want {
+				t.Fatalf("change %d is in tier %s, want %s: %+v", j, c.Tier, want, result.Changes)
+			}
+		}
+	}
+}
+
+func TestRun_VerifyTiers(t *testing.T) {
+	cfg := &config.Config{
+		TierDirs: map[string]config.StringList{"prd": {"prd-eu", "prd-us"}},
+		Modules: []config.ModuleConfig{{
+			Source:   "test-module/aws",
+			Strategy: version.StrategyExact,
+			Versions: map[string]interface{}{"*": "2.0.0", "dev": "2.0.0", "stg": "2.0.0", "prd": "2.0.0", "region-*": "2.0.0"},
+		}},
+	}
+
+	workDir := t.TempDir()
+	for _, dir := range []string{"dev", "prd-eu"} {
+		if err := os.MkdirAll(filepath.Join(workDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create tier directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(workDir, dir, "main.tf"), []byte(testModule), 0644); err != nil {
+			t.Fatalf("Failed to write tf file: %v", err)
+		}
+	}
+	wantMissing := []string{
+		"tier prd: tier directory not found: " + filepath.Join(workDir, "prd-us"),
+		"tier pattern region-*: tier directory not found: no directory under " + workDir + " matches it",
+		"tier stg: tier directory not found: " + filepath.Join(workDir, "stg"),
+	}
+
+	t.Run("warns", func(t *testing.T) {
+		var out strings.Builder
+		result, err := Run(cfg, workDir, Options{DryRun: true, VerifyTiers: true, Output: &out})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(result.Warnings) != len(wantMissing) {
+			t.Fatalf("expected %d warnings, got %v", len(wantMissing), result.Warnings)
+		}
+		for i, want := range wantMissing {
+			if !errors.Is(result.Warnings[i], ErrMissingTierDir) || result.Warnings[i].Error() != want {
+				t.Errorf("warning %d = %v, want %q wrapping ErrMissingTierDir", i, result.Warnings[i], want)
+			}
+			if !strings.Contains(out.String(), "Warning: "+want+"\n") {
+				t.Errorf("expected warning %q in the output:\n%s", want, out.String())
+			}
+		}
+		if len(result.Errors) != 0 {
+			t.Errorf("expected the missing directories to be skipped, got errors: %v", result.Errors)
+		}
+		if len(result.Changes) != 2 {
+			t.Errorf("expected the existing tiers to be processed, got %d changes", len(result.Changes))
+		}
+	})
+
+	t.Run("selected tiers only", func(t *testing.T) {
+		result, err := Run(cfg, workDir, Options{DryRun: true, VerifyTiers: true, Strict: true, OnlyTiers: []string{"dev"}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(result.Warnings) != 0 {
+			t.Errorf("expected no warnings, got %v", result.Warnings)
+		}
+	})
+
+	t.Run("strict fails before processing", func(t *testing.T) {
+		result, err := Run(cfg, workDir, Options{VerifyTiers: true, Strict: true})
+		if !errors.Is(err, ErrMissingTierDir) {
+			t.Fatalf("expected ErrMissingTierDir, got %v", err)
+		}
+		for _, want := range wantMissing {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected %q in the error, got %v", want, err)
+			}
+		}
+		if len(result.Changes) != 0 {
+			t.Errorf("expected no changes, got %v", result.Changes)
+		}
+		if data, _ := os.ReadFile(filepath.Join(workDir, "dev", "main.tf")); string(data) != testModule {
+			t.Errorf("expected dev/main.tf to be left unchanged, got:\n%s", data)
+		}
+	})
+
+	t.Run("off by default", func(t *testing.T) {
+		result, err := Run(cfg, workDir, Options{DryRun: true, Strict: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(result.Warnings) != 0 {
+			t.Errorf("expected no warnings, got %v", result.Warnings)
+		}
+	})
+}
+
+func TestRun_TierPatterns(t *testing.T) {
+	cfg := &config.Config{
+		Modules: []config.ModuleConfig{{
+			Source:   "test-module/aws",
+			Strategy: version.StrategyExact,
+			Versions: map[string]interface{}{"region-*": "2.0.0", "region-special": "3.0.0"},
+		}},
+	}
+
+	tests := []struct {
+		name      string
+		onlyTiers []string
+		want      map[string]string
+	}{
+		{
+			name: "all tiers",
+			want: map[string]string{"region-us-east-1": "2.0.0", "region-eu-west-1": "2.0.0", "region-special": "3.0.0", "global": "1.0.0"},
+		},
+		{
+			name:      "tier matched by the pattern",
+			onlyTiers: []string{"region-eu-west-1"},
+			want:      map[string]string{"region-us-east-1": "1.0.0", "region-eu-west-1": "2.0.0", "region-special": "1.0.0", "global": "1.0.0"},
+		},
+		{
+			name:      "pattern",
+			onlyTiers: []string{"region-*"},
+			want:      map[string]string{"region-us-east-1": "2.0.0", "region-eu-west-1": "2.0.0", "region-special": "1.0.0", "global": "1.0.0"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			writeTierFiles(t, workDir, "region-us-east-1", "region-eu-west-1", "region-special", "global")
+
+			result, err := Run(cfg, workDir, Options{OnlyTiers: tc.onlyTiers})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for dir, want := range tc.want {
+				data, err := os.ReadFile(filepath.Join(workDir, dir, "main.tf"))
+				if err != nil {
+					t.Fatalf("Failed to read tf file: %v", err)
+				}
+				if !strings.Contains(string(data), `version = "`+want+`"`) {
+					t.Errorf("%s: expected version %s, got:\n%s", dir, want, data)
+				}
+			}
+
+			// Each matching directory is processed, and summarized, as a tier of its own
+			for dir, want := range tc.want {
+				if _, ok := result.Summary[dir]; ok != (want != "1.0.0") {
+					t.Errorf("%s: summarized %v, want %v: %+v", dir, ok, want != "1.0.0", result.Summary)
+				}
+			}
+		})
+	}
+}
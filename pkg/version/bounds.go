@@ -0,0 +1,146 @@
+package version
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// applyMinVersion raises a computed version or range so nothing below minVersion is allowed.
+// An exact version below the floor is replaced by the floor, range branches are cut at the
+// floor, and a range entirely below the floor is replaced by a range starting at it.
+func applyMinVersion(result, minVersion string) (string, error) {
+	if minVersion == "" {
+		return result, nil
+	}
+
+	floor, err := semver.NewVersion(minVersion)
+	if err != nil {
+		return "", fmt.Errorf("min_version must be an exact version (e.g., '1.2.0'), got: %s", minVersion)
+	}
+
+	if v, err := semver.NewVersion(result); err == nil {
+		if v.LessThan(floor) {
+			return preserveVersionMetadata(floor), nil
+		}
+		return result, nil
+	}
+
+	expanded, err := ExpandTerraformTildeArrow(result)
+	if err != nil {
+		return "", err
+	}
+
+	var branches []string
+	raised := false
+	for _, branch := range strings.Split(expanded, "||") {
+		branch = strings.TrimSpace(branch)
+		iv, ok := parseInterval(branch)
+		if !ok {
+			// Not a simple interval, so intersect it with the floor explicitly when it reaches below
+			if c, err := semver.NewConstraint(branch); err == nil {
+				if lowest := findLowestVersionInRange(c); lowest != nil && !lowest.LessThan(floor) {
+					branches = append(branches, branch)
+					continue
+				}
+			}
+			branches = append(branches, branch+", >="+floor.String())
+			raised = true
+			continue
+		}
+
+		// Drop branches that lie entirely below the floor
+		if iv.upper != nil && (iv.upper.LessThan(floor) || (iv.upper.Equal(floor) && !iv.upperIncl)) {
+			raised = true
+			continue
+		}
+
+		if iv.lower == nil || iv.lower.LessThan(floor) {
+			iv.lower, iv.lowerIncl = floor, true
+			raised = true
+		}
+		branches = append(branches, iv.String())
+	}
+
+	if !raised {
+		return result, nil
+	}
+	if len(branches) == 0 {
+		return ConvertToRangeVersion(floor.Original())
+	}
+	return normalizeVersionString(strings.Join(branches, " || ")), nil
+}
+
+// applyMaxVersion caps a computed version or range so nothing above maxVersion is allowed.
+// Range branches are cut at the cap, which is inclusive. An exact version above the cap is
+// replaced by the cap, or rejected when errorOnExceed is set; the same applies to a range
+// lying entirely above the cap.
+func applyMaxVersion(result, maxVersion string, errorOnExceed bool) (string, error) {
+	if maxVersion == "" {
+		return result, nil
+	}
+
+	ceiling, err := semver.NewVersion(maxVersion)
+	if err != nil {
+		return "", fmt.Errorf("max_version must be an exact version (e.g., '2.9.0'), got: %s", maxVersion)
+	}
+
+	exceeded := func() (string, error) {
+		if errorOnExceed {
+			return "", fmt.Errorf("version %s exceeds max_version %s", result, maxVersion)
+		}
+		return preserveVersionMetadata(ceiling), nil
+	}
+
+	if v, err := semver.NewVersion(result); err == nil {
+		if v.GreaterThan(ceiling) {
+			return exceeded()
+		}
+		return result, nil
+	}
+
+	expanded, err := ExpandTerraformTildeArrow(result)
+	if err != nil {
+		return "", err
+	}
+
+	var branches []string
+	capped := false
+	for _, branch := range strings.Split(expanded, "||") {
+		branch = strings.TrimSpace(branch)
+		iv, ok := parseInterval(branch)
+		if !ok {
+			// Not a simple interval, so intersect it with the cap explicitly when it reaches above
+			if c, err := semver.NewConstraint(branch); err == nil {
+				if highest := findHighestVersionInRange(c); highest != nil && !highest.GreaterThan(ceiling) {
+					branches = append(branches, branch)
+					continue
+				}
+			}
+			branches = append(branches, branch+", <="+ceiling.String())
+			capped = true
+			continue
+		}
+
+		// Drop branches that lie entirely above the cap
+		if iv.lower != nil && (iv.lower.GreaterThan(ceiling) || (iv.lower.Equal(ceiling) && !iv.lowerIncl)) {
+			capped = true
+			continue
+		}
+
+		if iv.upper == nil || iv.upper.GreaterThan(ceiling) {
+			iv.upper, iv.upperIncl = ceiling, true
+			capped = true
+		}
+		branches = append(branches, iv.String())
+	}
+
+	if !capped {
+		return result, nil
+	}
+	if len(branches) == 0 {
+		return exceeded()
+	}
+	return normalizeVersionString(strings.Join(branches, " || ")), nil
+}
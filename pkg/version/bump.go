@@ -0,0 +1,112 @@
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// bumpOperators are the operators of the comparisons that set the lower bound of a range,
+// or pin the version with "="
+var bumpOperators = map[string]bool{">=": true, "=>": true, ">": true, "~>": true, "~": true, "^": true, "=": true}
+
+// BumpVersion increments existing by one level. An exact version such as "1.2.3" becomes
+// "1.3.0" for BumpMinor, keeping a "v" prefix; a range has its lower bound bumped in place
+// and written with as many components as before, so "~> 1.2" becomes "~> 1.3" and
+// ">= 1.2.0, < 2.0.0" becomes ">= 1.3.0, < 2.0.0". A range without exactly one lower
+// bound, a bound with no component at the level, and a bump that leaves the range empty
+// are errors.
+func BumpVersion(existing string, level BumpLevel) (string, error) {
+	component, err := bumpComponent(level)
+	if err != nil {
+		return "", err
+	}
+	existing = strings.TrimSpace(existing)
+	if existing == "" {
+		return "", fmt.Errorf("no version to bump")
+	}
+
+	if v, err := semver.StrictNewVersion(strings.TrimPrefix(existing, "v")); err == nil {
+		next := bump(v, level)
+		if strings.HasPrefix(existing, "v") {
+			return "v" + next.String(), nil
+		}
+		return next.String(), nil
+	}
+
+	var bounds [][]int
+	for _, m := range constraintComparison.FindAllStringSubmatchIndex(existing, -1) {
+		if m[2] >= 0 && bumpOperators[existing[m[2]:m[3]]] {
+			bounds = append(bounds, m)
+		}
+	}
+	if len(bounds) != 1 {
+		return "", fmt.Errorf("cannot bump %q: expected one lower bound, found %d", existing, len(bounds))
+	}
+
+	start, end := bounds[0][4], bounds[0][5]
+	bound := existing[start:end]
+	core, suffix := bound, ""
+	if i := strings.IndexAny(bound, "-+"); i >= 0 {
+		core, suffix = bound[:i], bound[i:]
+	}
+	parts := strings.Split(core, ".")
+	if len(parts) <= component {
+		return "", fmt.Errorf("cannot bump the %s version of %q: its lower bound %s has no %s component", level, existing, bound, level)
+	}
+	v, err := semver.NewVersion(completeVersion(core) + suffix)
+	if err != nil {
+		return "", fmt.Errorf("cannot bump %q: invalid lower bound %s: %w", existing, bound, err)
+	}
+
+	next := bump(v, level)
+	components := []uint64{next.Major(), next.Minor(), next.Patch()}
+	for i := range parts {
+		parts[i] = strconv.FormatUint(components[i], 10)
+	}
+	bumped := existing[:start] + strings.Join(parts, ".") + existing[end:]
+
+	expanded, err := ExpandTerraformTildeArrow(bumped)
+	if err != nil {
+		return "", fmt.Errorf("cannot bump %q: %w", existing, err)
+	}
+	if _, empty := emptyBranch(expanded); empty {
+		return "", fmt.Errorf("cannot bump the %s version of %q: %q allows no version", level, existing, bumped)
+	}
+	return bumped, nil
+}
+
+// ParseBumpLevel returns the bump level named by s: major, minor or patch
+func ParseBumpLevel(s string) (BumpLevel, error) {
+	level := BumpLevel(s)
+	if _, err := bumpComponent(level); err != nil {
+		return "", err
+	}
+	return level, nil
+}
+
+// bumpComponent returns the index of the version component level increments
+func bumpComponent(level BumpLevel) (int, error) {
+	switch level {
+	case BumpMajor:
+		return 0, nil
+	case BumpMinor:
+		return 1, nil
+	case BumpPatch:
+		return 2, nil
+	}
+	return 0, fmt.Errorf("invalid bump level '%s' (expected major, minor or patch)", level)
+}
+
+// bump increments v at level, resetting the lower components
+func bump(v *semver.Version, level BumpLevel) semver.Version {
+	switch level {
+	case BumpMajor:
+		return v.IncMajor()
+	case BumpMinor:
+		return v.IncMinor()
+	}
+	return v.IncPatch()
+}
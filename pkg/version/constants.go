@@ -12,4 +12,47 @@ const (
 	StrategyDynamic Strategy = "dynamic"
 	StrategyExact   Strategy = "exact"
 	StrategyRange   Strategy = "range"
+	// StrategyPatchOnly writes exact versions like StrategyExact, but only patch updates;
+	// minor and major bumps keep the existing version
+	StrategyPatchOnly Strategy = "patch_only"
+	// StrategyMajorLock applies targets like StrategyDynamic, but never across a major
+	// version boundary
+	StrategyMajorLock Strategy = "major_lock"
+)
+
+// RangeMerge says how the range and dynamic strategies combine an existing range with a
+// range target
+type RangeMerge string
+
+const (
+	// RangeMergeKeep keeps either the existing range or the target, as the strategy decides
+	RangeMergeKeep RangeMerge = "keep"
+	// RangeMergeIntersect writes the versions both ranges allow, when they overlap
+	RangeMergeIntersect RangeMerge = "intersect"
+	// RangeMergeUnion writes the versions either range allows
+	RangeMergeUnion RangeMerge = "union"
+)
+
+// PrereleaseRange says how StrategyRange bounds the range it writes for an exact
+// pre-release target such as "2.0.0-beta.1"
+type PrereleaseRange string
+
+const (
+	// PrereleaseRangeNextMajor allows everything below the next major version,
+	// ">= 2.0.0-beta.1, < 3.0.0", as for stable targets
+	PrereleaseRangeNextMajor PrereleaseRange = "next_major"
+	// PrereleaseRangeSameMajor stops below the release the target is a pre-release of,
+	// ">= 2.0.0-beta.1, < 2.0.0", so the stable release is not allowed
+	PrereleaseRangeSameMajor PrereleaseRange = "same_major"
+	// PrereleaseRangeLockTarget writes the target as an exact version, "2.0.0-beta.1"
+	PrereleaseRangeLockTarget PrereleaseRange = "lock_target"
+)
+
+// BumpLevel is the component of a version that BumpVersion increments
+type BumpLevel string
+
+const (
+	BumpMajor BumpLevel = "major"
+	BumpMinor BumpLevel = "minor"
+	BumpPatch BumpLevel = "patch"
 )
@@ -0,0 +1,168 @@
+package version
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// applyPatchOnly implements StrategyPatchOnly: the target is written as with StrategyExact,
+// but only when it keeps the major and minor version of the existing version. Before 1.0.0
+// a minor bump is the breaking one, which the same rule already blocks. Blocked bumps keep
+// the existing version, or fail with opts.ErrorOnBlockedBump.
+func applyPatchOnly(targetVersion, existingVersion string, opts StrategyOptions) (string, string, error) {
+	targetVer, err := parseExactVersion(targetVersion)
+	if err != nil {
+		return "", "", fmt.Errorf("%s strategy requires an exact version (e.g., '2.1.1'), got: %s", StrategyPatchOnly, targetVersion)
+	}
+
+	if existingVersion == "" {
+		return targetVer.String(), "used target: no existing version", nil
+	}
+
+	// Without an exact existing version there is no patch line to stay on
+	existingVer, err := parseExactVersion(existingVersion)
+	if err != nil {
+		return existingVersion, "kept existing: not an exact version (patch_only)", nil
+	}
+
+	if existingVer.GreaterThan(targetVer) {
+		return existingVer.String(), "kept existing: higher version (backward protection)", nil
+	}
+
+	bump := ""
+	switch {
+	case targetVer.Major() != existingVer.Major():
+		bump = "major"
+	case targetVer.Minor() != existingVer.Minor():
+		bump = "minor"
+	default:
+		return targetVer.String(), "used target: patch update", nil
+	}
+
+	return blockedBump(StrategyPatchOnly, bump, targetVersion, existingVersion, opts)
+}
+
+// applyMajorLock implements StrategyMajorLock: the target is applied as with
+// StrategyDynamic, but only when it stays within the major version of the existing version
+// or, for a range, of its lowest version. A range target has its upper bound lowered below
+// the next major. Before 1.0.0 each minor version counts as a major one. Blocked bumps keep
+// the existing version, or fail with opts.ErrorOnBlockedBump.
+func applyMajorLock(targetVersion, existingVersion string, opts StrategyOptions) (string, string, error) {
+	if existingVersion == "" {
+		return applyDynamicStrategy(targetVersion, existingVersion, opts)
+	}
+
+	existingLow, err := lowestVersion(existingVersion)
+	if err != nil {
+		return existingVersion, "kept existing: no version to lock to (major_lock)", nil
+	}
+	targetLow, err := lowestVersion(targetVersion)
+	if err != nil {
+		return "", "", fmt.Errorf("%s strategy requires a version or range, got: %s", StrategyMajorLock, targetVersion)
+	}
+
+	next := nextMajor(existingLow)
+	if !targetLow.LessThan(next) {
+		return blockedBump(StrategyMajorLock, "major", targetVersion, existingVersion, opts)
+	}
+
+	target := targetVersion
+	if _, err := parseExactVersion(targetVersion); err != nil {
+		if target, err = capBelow(targetVersion, next); err != nil {
+			return "", "", err
+		}
+	}
+
+	// A target lower than the existing major is left to the dynamic strategy's backward
+	// protection
+	result, reason, err := applyDynamicStrategy(target, existingVersion, opts)
+	if err == nil && target != targetVersion && NormalizeVersionString(result) == NormalizeVersionString(target) {
+		reason += "; upper bound kept below " + next.String() + " (major_lock)"
+	}
+	return result, reason, err
+}
+
+// blockedBump keeps the existing version for a bump the strategy does not allow, or
+// fails with opts.ErrorOnBlockedBump
+func blockedBump(strategy Strategy, bump, targetVersion, existingVersion string, opts StrategyOptions) (string, string, error) {
+	if opts.ErrorOnBlockedBump {
+		return "", "", fmt.Errorf("%s strategy blocks the %s bump from %s to %s", strategy, bump, existingVersion, targetVersion)
+	}
+	return existingVersion, fmt.Sprintf("kept existing: %s bump blocked (%s)", bump, strategy), nil
+}
+
+// lowestVersion returns an exact version, or the lowest version a range allows
+func lowestVersion(input string) (*semver.Version, error) {
+	if v, err := parseExactVersion(input); err == nil {
+		return v, nil
+	}
+	expanded, err := ExpandTerraformTildeArrow(input)
+	if err != nil {
+		return nil, err
+	}
+	c, err := semver.NewConstraint(expanded)
+	if err != nil {
+		return nil, err
+	}
+	if v := findLowestVersionInRange(c); v != nil {
+		return v, nil
+	}
+	return nil, fmt.Errorf("range %s allows no version", input)
+}
+
+// nextMajor returns the first version of the next major version of v, where each minor
+// version before 1.0.0 counts as a major one
+func nextMajor(v *semver.Version) *semver.Version {
+	if v.Major() == 0 {
+		return semver.New(0, v.Minor()+1, 0, "", "")
+	}
+	return semver.New(v.Major()+1, 0, 0, "", "")
+}
+
+// capBelow lowers the upper bound of every branch of a range below ceiling, dropping
+// branches that lie entirely at or above it
+func capBelow(input string, ceiling *semver.Version) (string, error) {
+	expanded, err := ExpandTerraformTildeArrow(input)
+	if err != nil {
+		return "", err
+	}
+
+	var branches []string
+	capped := false
+	for _, branch := range strings.Split(expanded, "||") {
+		branch = strings.TrimSpace(branch)
+		iv, ok := parseInterval(branch)
+		if !ok {
+			// Not a simple interval, so intersect it with the ceiling explicitly when it reaches it
+			if c, err := semver.NewConstraint(branch); err == nil {
+				if highest := findHighestVersionInRange(c); highest != nil && highest.LessThan(ceiling) && !unboundedAbove(c) {
+					branches = append(branches, branch)
+					continue
+				}
+			}
+			branches = append(branches, branch+", <"+ceiling.String())
+			capped = true
+			continue
+		}
+
+		if iv.lower != nil && !iv.lower.LessThan(ceiling) {
+			capped = true
+			continue
+		}
+		if iv.upper == nil || iv.upper.GreaterThan(ceiling) || (iv.upper.Equal(ceiling) && iv.upperIncl) {
+			iv.upper, iv.upperIncl = ceiling, false
+			capped = true
+		}
+		branches = append(branches, iv.String())
+	}
+
+	if !capped {
+		return input, nil
+	}
+	if len(branches) == 0 {
+		return "", fmt.Errorf("range %s allows no version below %s", input, ceiling)
+	}
+	return normalizeVersionString(strings.Join(branches, " || ")), nil
+}
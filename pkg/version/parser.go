@@ -2,6 +2,7 @@ package version
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/Masterminds/semver/v3"
@@ -14,67 +15,151 @@ func ParseVersionOrRange(input string) (bool, *semver.Version, *semver.Constrain
 		return false, nil, nil, fmt.Errorf("empty version input")
 	}
 
-	v, errVer := semver.NewVersion(input)
+	v, errVer := parseExactVersion(input)
 	if errVer == nil {
 		return true, v, nil, nil
 	}
 
-	tfInput := ExpandTerraformTildeArrow(input)
+	tfInput, err := ExpandTerraformTildeArrow(joinSpaceAnds(stripBoundVPrefixes(input)))
+	if err != nil {
+		return false, nil, nil, err
+	}
 	c, errConstr := semver.NewConstraint(tfInput)
-	if errConstr == nil {
-		return false, nil, c, nil
+	if errConstr != nil {
+		return false, nil, nil, errConstr
+	}
+
+	// A contradictory range would otherwise parse and silently match nothing
+	if branch, empty := emptyBranch(tfInput); empty {
+		if strings.Contains(tfInput, "||") {
+			return false, nil, nil, fmt.Errorf("range %s has a branch that allows no version: %s", input, branch)
+		}
+		return false, nil, nil, fmt.Errorf("range %s allows no version", input)
 	}
-	return false, nil, nil, errConstr
+	return false, nil, c, nil
 }
 
-// ExpandTerraformTildeArrow scans for "~>" => ">=X.Y.Z,<X+1.0.0"
-func ExpandTerraformTildeArrow(version string) string {
-	if version == "" {
+// parseExactVersion parses an input naming exactly one version, either bare ("1.2.3") or
+// with Terraform's explicit equality operator ("= 1.2.3")
+func parseExactVersion(input string) (*semver.Version, error) {
+	if rest, ok := strings.CutPrefix(strings.TrimSpace(input), "="); ok {
+		input = strings.TrimSpace(rest)
+	}
+	return semver.NewVersion(input)
+}
+
+// boundVPrefix matches a "v" before the version of a comparison, as in ">= v1.0.0"
+var boundVPrefix = regexp.MustCompile(`(^|[\s,|=<>~^!])v([0-9])`)
+
+// stripBoundVPrefixes removes the "v" from the versions of the comparisons of a range, so
+// ">= v1.0.0, < v2.0.0" becomes ">= 1.0.0, < 2.0.0". An exact version is returned as
+// written, keeping its "v".
+func stripBoundVPrefixes(version string) string {
+	if _, err := parseExactVersion(version); err == nil {
 		return version
 	}
+	return boundVPrefix.ReplaceAllString(version, "$1$2")
+}
+
+// spaceAnd matches the whitespace Terraform also accepts between the comparisons of an AND,
+// as in ">= 1.0.0 < 2.0.0": a version followed by the next comparison's operator
+var spaceAnd = regexp.MustCompile(`([0-9A-Za-z*])\s+(>=|<=|!=|~>|>|<|=|~|\^)`)
+
+// joinSpaceAnds rewrites comparisons joined by whitespace with commas, so
+// ">= 1.0.0 < 2.0.0" becomes ">= 1.0.0, < 2.0.0"
+func joinSpaceAnds(version string) string {
+	return spaceAnd.ReplaceAllString(version, "$1, $2")
+}
+
+// orBranches splits version into its OR branches, trimmed and with the comparisons of each
+// joined by commas, so a branch written ">= 1.0.0 < 2.0.0" splits on its AND like one
+// written ">= 1.0.0, < 2.0.0"
+func orBranches(version string) []string {
+	branches := strings.Split(joinSpaceAnds(version), "||")
+	for i, branch := range branches {
+		branches[i] = strings.TrimSpace(branch)
+	}
+	return branches
+}
+
+// hasSpaceAnds reports whether version joins comparisons with whitespace instead of commas
+func hasSpaceAnds(version string) bool {
+	return spaceAnd.MatchString(version)
+}
+
+// hasEqualityOperator reports whether input is an exact version written with "="
+func hasEqualityOperator(input string) bool {
+	return strings.HasPrefix(strings.TrimSpace(input), "=")
+}
+
+// tildeArrowComparison matches one "~>" comparison with its version, e.g. "~> 1.2"
+var tildeArrowComparison = regexp.MustCompile(`~>\s*[^\s,|]*`)
+
+// ExpandTerraformTildeArrow scans for "~>" => ">=X.Y.Z,<X.Y+1.0", or ">=X.Y.0,<X+1.0.0"
+// for "~>X.Y". Each comparison of an
+// AND is expanded on its own, so "~>1.2, <1.5" keeps its upper bound. It fails when a "~>"
+// is not followed by a valid version.
+func ExpandTerraformTildeArrow(version string) (string, error) {
+	if version == "" {
+		return version, nil
+	}
 
 	// If it's not a tilde arrow version, return as is
 	if !strings.Contains(version, "~>") {
-		return version
+		return version, nil
 	}
 
 	var result []string
+	var expandErr error
 	for _, part := range strings.Split(version, "||") {
 		part = strings.TrimSpace(part)
-		if strings.HasPrefix(part, "~>") {
-			part = strings.TrimPrefix(part, "~>")
-			part = strings.TrimSpace(part)
-			result = append(result, buildRangeFromTildePart(part))
-		} else {
-			result = append(result, part)
-		}
+		result = append(result, tildeArrowComparison.ReplaceAllStringFunc(part, func(comparison string) string {
+			expanded, err := buildRangeFromTildePart(strings.TrimPrefix(comparison, "~>"))
+			if err != nil && expandErr == nil {
+				expandErr = err
+			}
+			return expanded
+		}))
+	}
+	if expandErr != nil {
+		return "", expandErr
 	}
 
-	return strings.Join(result, " || ")
+	return strings.Join(result, " || "), nil
 }
 
-func buildRangeFromTildePart(version string) string {
-	version = strings.TrimSpace(version)
-	if version == "" {
-		return "~>MISSING"
+// expandOrKeep returns version with its tilde arrows expanded, or as written when one of
+// them is invalid, leaving ParseVersionOrRange to report the error
+func expandOrKeep(version string) string {
+	if expanded, err := ExpandTerraformTildeArrow(version); err == nil {
+		return expanded
 	}
+	return version
+}
 
-	parts := strings.Split(version, ".")
-	if len(parts) > 3 {
-		return "~>INVALID"
+// buildRangeFromTildePart expands the version of a "~>" comparison into a range
+func buildRangeFromTildePart(version string) (string, error) {
+	version = strings.TrimSpace(version)
+	if version == "" {
+		return "", fmt.Errorf("invalid tilde-arrow version: \"~>\" has no version")
 	}
 
-	// Parse the version
+	// Parse the version; only the components before any pre-release or build metadata count
+	core, _, _ := strings.Cut(strings.SplitN(version, "+", 2)[0], "-")
 	ver, err := semver.NewVersion(version)
-	if err != nil {
-		return ">=0.0.0, <1.0.0"
+	if err != nil || strings.Count(core, ".") > 2 {
+		return "", fmt.Errorf("invalid tilde-arrow version %q", version)
 	}
 
-	// Calculate the next major version
-	nextMajor := ver.Major() + 1
+	// As in Terraform, only the rightmost component given may increase: "~>1.2.3" locks
+	// the minor version and "~>1.2" or "~>1" the major version
+	upper := fmt.Sprintf("%d.0.0", ver.Major()+1)
+	if strings.Count(core, ".") == 2 {
+		upper = fmt.Sprintf("%d.%d.0", ver.Major(), ver.Minor()+1)
+	}
 
 	// Return the range without spaces after operators
-	return fmt.Sprintf(">=%d.%d.%d, <%d.0.0", ver.Major(), ver.Minor(), ver.Patch(), nextMajor)
+	return fmt.Sprintf(">=%d.%d.%d, <%s", ver.Major(), ver.Minor(), ver.Patch(), upper), nil
 }
 
 func readToken(s string) (token, remainder string) {
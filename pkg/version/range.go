@@ -2,17 +2,136 @@ package version
 
 import (
 	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
 
 	"github.com/Masterminds/semver/v3"
 )
 
-// findHighestVersionInRange tries to find the highest version that satisfies the constraints
-// using binary search for better performance O(log n)
+// exclusionComparison matches one "!=" comparison of a constraint, e.g. "!= 1.5.0"
+var exclusionComparison = regexp.MustCompile(`!=\s*v?[^\s,|]+`)
+
+// withoutExclusions returns c with its "!=" comparisons removed, and whether it had any.
+// Excluded versions make the range non-contiguous, which the bound searches below cannot
+// handle, so bounds are searched in the relaxed range and then stepped past exclusions.
+func withoutExclusions(c *semver.Constraints) (*semver.Constraints, bool) {
+	if !strings.Contains(c.String(), "!=") {
+		return c, false
+	}
+
+	var branches []string
+	for _, branch := range strings.Split(c.String(), "||") {
+		branch = strings.Join(strings.Fields(exclusionComparison.ReplaceAllString(branch, "")), " ")
+		if branch == "" {
+			branch = ">=0.0.0"
+		}
+		branches = append(branches, branch)
+	}
+	relaxed, err := semver.NewConstraint(strings.Join(branches, " || "))
+	if err != nil {
+		return c, false
+	}
+	return relaxed, true
+}
+
+// isExcluded reports whether v lies within c apart from one of its "!=" comparisons
+func isExcluded(c *semver.Constraints, v *semver.Version) bool {
+	relaxed, ok := withoutExclusions(c)
+	return ok && !c.Check(v) && relaxed.Check(v)
+}
+
+// nextSearchVersion returns the version after v in the major.minor.patch grid searched by
+// the bound finders, or nil past the end of it
+func nextSearchVersion(v *semver.Version) *semver.Version {
+	major, minor, patch := v.Major(), v.Minor(), v.Patch()+1
+	if patch > MAX_PATCH {
+		minor, patch = minor+1, 0
+	}
+	if minor > MAX_MINOR {
+		major, minor = major+1, 0
+	}
+	if major > MAX_MAJOR {
+		return nil
+	}
+	return semver.New(major, minor, patch, "", "")
+}
+
+// previousSearchVersion returns the version before v in the major.minor.patch grid searched
+// by the bound finders, or nil before 0.0.0
+func previousSearchVersion(v *semver.Version) *semver.Version {
+	major, minor, patch := v.Major(), v.Minor(), v.Patch()
+	switch {
+	case patch > 0:
+		patch--
+	case minor > 0:
+		minor, patch = minor-1, MAX_PATCH
+	case major > 0:
+		major, minor, patch = major-1, MAX_MINOR, MAX_PATCH
+	default:
+		return nil
+	}
+	return semver.New(major, minor, patch, "", "")
+}
+
+// stepPastExclusions moves a bound found in the relaxed form of c to the nearest version c
+// includes, searching upwards for a lower bound and downwards for an upper bound until
+// limit, the opposite bound of the relaxed range, is passed. It returns nil when no
+// version in between is included.
+func stepPastExclusions(c *semver.Constraints, v, limit *semver.Version, up bool) *semver.Version {
+	if limit == nil {
+		return nil
+	}
+	for v != nil {
+		if (up && v.GreaterThan(limit)) || (!up && v.LessThan(limit)) {
+			return nil
+		}
+		if c.Check(v) {
+			return v
+		}
+		if up {
+			v = nextSearchVersion(v)
+		} else {
+			v = previousSearchVersion(v)
+		}
+	}
+	return nil
+}
+
+// unboundedProbe is a version far beyond any real release, admitted only by ranges
+// without an upper bound
+var unboundedProbe = semver.New(1<<31, 0, 0, "", "")
+
+// unboundedAbove reports whether a range, or one of its OR branches, has no upper bound,
+// such as ">= 1.0.0". The highest version the searches find for it is only the ceiling of
+// the searched grid, not a real maximum.
+func unboundedAbove(c *semver.Constraints) bool {
+	return c != nil && c.Check(unboundedProbe)
+}
+
+// findHighestVersionInRange returns the highest version that satisfies the constraints. The
+// textual upper bound is used when it lies within the range; a bound excluded with "!=" is
+// stepped down to the previous included version, and otherwise versions are probed.
 func findHighestVersionInRange(c *semver.Constraints) *semver.Version {
 	if c == nil {
 		return nil
 	}
 
+	if v, err := getMaxVersionFromConstraint(c); err == nil && c.Check(v) {
+		return v
+	}
+
+	if relaxed, ok := withoutExclusions(c); ok {
+		return stepPastExclusions(c, findHighestVersionInRange(relaxed), findLowestVersionInRange(relaxed), false)
+	}
+	return probeHighestVersionInRange(c)
+}
+
+// probeHighestVersionInRange tries to find the highest version that satisfies the constraints
+// using binary search for better performance O(log n)
+func probeHighestVersionInRange(c *semver.Constraints) *semver.Version {
+
 	// Try strategic points first for quick exit
 	strategicPoints := []struct{ major, minor, patch uint64 }{
 		{major: MAX_MAJOR, minor: MAX_MINOR, patch: MAX_PATCH},             // Maximum possible
@@ -157,12 +276,74 @@ func findHighestVersionInRange(c *semver.Constraints) *semver.Version {
 	return finalVer
 }
 
-// findLowestVersionInRange tries to find the lowest version that satisfies the constraints
+// findLowestVersionInRange returns the lowest version that satisfies the constraints. The
+// textual lower bound is returned verbatim, with any pre-release and build metadata, when
+// it lies within the range; a bound excluded with "!=" is stepped up to the next included
+// version, and otherwise integer versions are probed. A pre-release bound is
+// accepted when its release version satisfies the constraints, since semver only matches
+// pre-releases against comparisons that name one.
 func findLowestVersionInRange(c *semver.Constraints) *semver.Version {
 	if c == nil {
 		return nil
 	}
 
+	if v, err := getMinVersionFromConstraint(c); err == nil {
+		release, _ := v.SetPrerelease("")
+		release, _ = release.SetMetadata("")
+		if c.Check(v) || (v.Prerelease() != "" && c.Check(&release)) {
+			return v
+		}
+	}
+
+	if relaxed, ok := withoutExclusions(c); ok {
+		return stepPastExclusions(c, findLowestVersionInRange(relaxed), findHighestVersionInRange(relaxed), true)
+	}
+	return probeLowestVersionInRange(c)
+}
+
+// lowerBound is the minimum of a range together with whether the range includes it, so
+// ">1.0.0" reads as starting just above 1.0.0 rather than at a version of the search grid
+type lowerBound struct {
+	version   *semver.Version
+	inclusive bool
+}
+
+// compareLowerBounds orders two lower bounds by the ranges they start: by version, and of
+// equal versions an inclusive bound before an exclusive one
+func compareLowerBounds(a, b lowerBound) int {
+	if c := compareVersions(a.version, b.version); c != 0 {
+		return c
+	}
+	switch {
+	case a.inclusive == b.inclusive:
+		return 0
+	case a.inclusive:
+		return -1
+	}
+	return 1
+}
+
+// findLowerBound returns the lower bound of the constraints with its strictness, or nil
+// when nothing satisfies them. An exclusive bound such as ">1.0.0" is kept as written when
+// the next patch version is the lowest one satisfying the constraints; otherwise the bound
+// is the inclusive version found by findLowestVersionInRange.
+func findLowerBound(c *semver.Constraints) *lowerBound {
+	lowest := findLowestVersionInRange(c)
+	if lowest == nil {
+		return nil
+	}
+	if bound, err := getLowerBoundFromConstraint(c); err == nil && !bound.inclusive {
+		if next := bound.version.IncPatch(); next.Equal(lowest) {
+			return &bound
+		}
+	}
+	return &lowerBound{version: lowest, inclusive: true}
+}
+
+// probeLowestVersionInRange searches integer major.minor.patch versions for the lowest one
+// satisfying the constraints
+func probeLowestVersionInRange(c *semver.Constraints) *semver.Version {
+
 	var lowestVer *semver.Version
 
 	// Binary search for major version
@@ -272,147 +453,140 @@ func findLowestVersionInRange(c *semver.Constraints) *semver.Version {
 	return finalVer
 }
 
-// RangesOverlap tries some sample versions from 0.0.0..(MAX_MAJOR,MAX_MINOR,MAX_PATCH).
+// RangesOverlap reports whether some version satisfies both constraints. Every stretch of
+// versions two constraints share starts at 0.0.0, at a version one of them names, or at the
+// patch, minor or major version right after one, which covers the bounds implied by "~",
+// "^" and wildcards; checking those candidates is therefore exact. Pre-release versions are
+// only considered where a constraint names them.
 func RangesOverlap(a, b *semver.Constraints) bool {
+	_, ok := RangeIntersection(a, b)
+	return ok
+}
+
+// RangeIntersection returns the versions both constraints allow, as one interval per
+// stretch of versions joined with "||", such as ">= 1.5.0, < 2.0.0" for ">=1.0.0,<2.0.0"
+// and ">=1.5.0,<2.5.0", and reports false when they share no version. The stretches start
+// and end at the candidates RangesOverlap checks, so between two consecutive candidates
+// both constraints allow all release versions or none; a stretch holding one version is
+// written as that version.
+func RangeIntersection(a, b *semver.Constraints) (*semver.Constraints, bool) {
 	if a == nil || b == nil {
-		return false
-	}
-
-	// Quick boundary check
-	aMin := findLowestVersionInRange(a)
-	aMax := findHighestVersionInRange(a)
-	bMin := findLowestVersionInRange(b)
-	bMax := findHighestVersionInRange(b)
-
-	// If either range is empty, they can't overlap
-	if aMin == nil || aMax == nil || bMin == nil || bMax == nil {
-		// Fall back to linear search for complex cases
-		return linearSearchOverlap(a, b)
-	}
-
-	// If one range is completely before or after the other, they can't overlap
-	if aMax.LessThan(bMin) || bMax.LessThan(aMin) {
-		return false
-	}
-
-	// For ranges that might overlap, check strategic points
-	// Check the boundaries
-	if a.Check(bMin) || a.Check(bMax) || b.Check(aMin) || b.Check(aMax) {
-		return true
-	}
-
-	// Check the midpoint of the overlapping region
-	if aMin.LessThan(bMax) && bMin.LessThan(aMax) {
-		// Calculate multiple strategic points in the overlapping region
-		points := []struct{ major, minor, patch uint64 }{
-			{
-				major: (aMin.Major() + bMax.Major()) / 2,
-				minor: (aMin.Minor() + bMax.Minor()) / 2,
-				patch: (aMin.Patch() + bMax.Patch()) / 2,
-			},
-			{
-				major: aMin.Major(),
-				minor: bMax.Minor(),
-				patch: (aMin.Patch() + bMax.Patch()) / 2,
-			},
-			{
-				major: bMin.Major(),
-				minor: aMax.Minor(),
-				patch: (aMin.Patch() + bMax.Patch()) / 2,
-			},
-		}
+		return nil, false
+	}
+
+	candidates := overlapCandidates(a, b)
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].LessThan(candidates[j]) })
+	candidates = slices.CompactFunc(candidates, func(x, y *semver.Version) bool { return x.Equal(y) })
 
-		// Try each strategic point
-		for _, p := range points {
-			testVer, _ := semver.NewVersion(fmt.Sprintf("%d.%d.%d", p.major, p.minor, p.patch))
-			if a.Check(testVer) && b.Check(testVer) {
-				return true
+	var stretches []interval
+	var start *semver.Version
+	for i, v := range candidates {
+		if a.Check(v) && b.Check(v) {
+			if start == nil {
+				start = v
 			}
+			if i < len(candidates)-1 {
+				continue
+			}
+			v = nil
+		} else if start == nil {
+			continue
 		}
+		stretches = append(stretches, stretchInterval(start, v))
+		start = nil
+	}
+	if len(stretches) == 0 {
+		return nil, false
+	}
 
-		// Try quarter points in the overlapping region
-		quarterMajor := (aMin.Major()*3 + bMax.Major()) / 4
-		quarterMinor := (aMin.Minor()*3 + bMax.Minor()) / 4
-		quarterPatch := (aMin.Patch()*3 + bMax.Patch()) / 4
-		quarterVer, _ := semver.NewVersion(fmt.Sprintf("%d.%d.%d", quarterMajor, quarterMinor, quarterPatch))
+	parts := make([]string, len(stretches))
+	for i, iv := range stretches {
+		parts[i] = iv.String()
+	}
+	intersection, err := semver.NewConstraint(strings.Join(parts, " || "))
+	if err != nil {
+		return nil, false
+	}
+	return intersection, true
+}
 
-		threeQuarterMajor := (aMin.Major() + bMax.Major()*3) / 4
-		threeQuarterMinor := (aMin.Minor() + bMax.Minor()*3) / 4
-		threeQuarterPatch := (aMin.Patch() + bMax.Patch()*3) / 4
-		threeQuarterVer, _ := semver.NewVersion(fmt.Sprintf("%d.%d.%d", threeQuarterMajor, threeQuarterMinor, threeQuarterPatch))
+// stretchInterval returns the interval from start up to end, excluded, or unbounded above
+// when end is nil. A stretch holding start alone, the next patch version ending it or a
+// bound without pre-release versions not allowing it, is the exact version start.
+func stretchInterval(start, end *semver.Version) interval {
+	point := interval{lower: start, upper: start, lowerIncl: true, upperIncl: true}
+	if end != nil && (end.Equal(semver.New(start.Major(), start.Minor(), start.Patch()+1, "", "")) || (start.Prerelease() != "" && end.Prerelease() == "")) {
+		return point
+	}
+	iv := interval{lower: start, upper: end, lowerIncl: true}
+	if start.Equal(semver.New(0, 0, 0, "", "")) {
+		iv.lower = nil
+	}
+	return iv
+}
 
-		if (a.Check(quarterVer) && b.Check(quarterVer)) || (a.Check(threeQuarterVer) && b.Check(threeQuarterVer)) {
-			return true
+// rangeContains reports whether outer allows every version inner allows. Each OR branch of
+// inner is an interval, checked at the versions where either constraint can change.
+func rangeContains(outer, inner *semver.Constraints) bool {
+	for _, v := range overlapCandidates(outer, inner) {
+		if inner.Check(v) && !outer.Check(v) {
+			return false
 		}
 	}
+	return true
+}
 
-	// For complex cases or potential overlaps, use binary search with reduced range
-	start := max(0, min(int(aMin.Major()), int(bMin.Major())))
-	end := min(MAX_MAJOR, max(int(aMax.Major()), int(bMax.Major())))
+// highestBranch returns the OR branch of c that allows its highest versions, preferring
+// the branch with the higher minimum on a tie. A constraint without OR branches is
+// returned as it is.
+func highestBranch(c *semver.Constraints) *semver.Constraints {
+	branches := strings.Split(c.String(), "||")
+	if len(branches) < 2 {
+		return c
+	}
 
-	// Try strategic points at each major version
-	for major := start; major <= end; major++ {
-		// Try strategic minor versions
-		strategicMinors := []int{0, MAX_MINOR / 4, MAX_MINOR / 2, (MAX_MINOR * 3) / 4, MAX_MINOR}
-		for _, minor := range strategicMinors {
-			testVer, _ := semver.NewVersion(fmt.Sprintf("%d.%d.0", major, minor))
-			if a.Check(testVer) && b.Check(testVer) {
-				return true
-			}
+	var top *semver.Constraints
+	var topMax, topMin *semver.Version
+	for _, branch := range branches {
+		bc, err := semver.NewConstraint(strings.TrimSpace(branch))
+		if err != nil {
+			return c
 		}
-
-		// Binary search for minor version if strategic points didn't find overlap
-		left, right := 0, MAX_MINOR
-		for left <= right {
-			minor := (left + right) / 2
-			testVer, _ := semver.NewVersion(fmt.Sprintf("%d.%d.0", major, minor))
-
-			if a.Check(testVer) && b.Check(testVer) {
-				return true
-			}
-
-			// Try both ranges to determine search direction
-			aValid := a.Check(testVer)
-			bValid := b.Check(testVer)
-
-			if !aValid && !bValid {
-				// If neither range accepts this minor version, try higher
-				left = minor + 1
-			} else if aValid || bValid {
-				// If at least one range accepts this minor version, try patch versions
-				if searchPatchVersions(a, b, major, minor) {
-					return true
-				}
-				// Try lower minor version as well
-				right = minor - 1
-			} else {
-				// Try both directions by splitting the search
-				if minor > 0 && searchMinorRange(a, b, major, 0, minor-1) {
-					return true
-				}
-				if minor < MAX_MINOR && searchMinorRange(a, b, major, minor+1, MAX_MINOR) {
-					return true
-				}
-				break
-			}
+		maxVer, minVer := findHighestVersionInRange(bc), findLowestVersionInRange(bc)
+		if maxVer == nil {
+			continue
 		}
+		if top == nil || maxVer.GreaterThan(topMax) || (maxVer.Equal(topMax) && minVer != nil && topMin != nil && minVer.GreaterThan(topMin)) {
+			top, topMax, topMin = bc, maxVer, minVer
+		}
+	}
+	if top == nil {
+		return c
 	}
+	return top
+}
 
-	// Final check: try a small linear search around the boundaries
-	boundaryRange := 2 // Check 2 versions before and after each boundary
-	for _, major := range []int{start - boundaryRange, start, end, end + boundaryRange} {
-		if major < 0 || major > MAX_MAJOR {
-			continue
-		}
-		for minor := 0; minor <= min(5, MAX_MINOR); minor++ {
-			for patch := 0; patch <= min(5, MAX_PATCH); patch++ {
-				testVer, _ := semver.NewVersion(fmt.Sprintf("%d.%d.%d", major, minor, patch))
-				if a.Check(testVer) && b.Check(testVer) {
-					return true
-				}
+// overlapCandidates returns 0.0.0 and, for every version the constraints name, that version
+// and the next patch, minor and major versions, each also followed by its next patch for
+// exclusive bounds
+func overlapCandidates(constraints ...*semver.Constraints) []*semver.Version {
+	candidates := []*semver.Version{semver.New(0, 0, 0, "", "")}
+	for _, c := range constraints {
+		for _, m := range constraintComparison.FindAllStringSubmatch(c.String(), -1) {
+			core, suffix := m[2], ""
+			if i := strings.IndexAny(core, "-+"); i >= 0 {
+				core, suffix = core[:i], core[i:]
+			}
+			core = strings.NewReplacer("x", "0", "X", "0", "*", "0").Replace(core)
+			v, err := semver.NewVersion(completeVersion(core) + suffix)
+			if err != nil {
+				continue
+			}
+			for _, bound := range []semver.Version{*v, v.IncPatch(), v.IncMinor(), v.IncMajor()} {
+				next := bound.IncPatch()
+				candidates = append(candidates, &bound, &next)
 			}
 		}
 	}
-
-	return false
+	return candidates
 }
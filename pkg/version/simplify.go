@@ -0,0 +1,323 @@
+package version
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// interval is a contiguous version range; a nil bound is unbounded
+type interval struct {
+	lower, upper         *semver.Version
+	lowerIncl, upperIncl bool
+}
+
+// parseInterval converts a single OR branch made of comparison operators into an interval.
+// Branches using other operators or pre-release versions, and branches allowing no version,
+// are reported as not representable.
+func parseInterval(branch string) (interval, bool) {
+	iv, ok := parseBounds(branch)
+	if !ok || iv.empty() {
+		return interval{}, false
+	}
+	return iv, true
+}
+
+// parseBounds is parseInterval without the check that the interval allows any version
+func parseBounds(branch string) (interval, bool) {
+	var iv interval
+	for _, part := range strings.Split(joinSpaceAnds(branch), ",") {
+		part = strings.ReplaceAll(part, " ", "")
+		if part == "" {
+			return interval{}, false
+		}
+
+		op := ""
+		for _, candidate := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(part, candidate) {
+				op = candidate
+				break
+			}
+		}
+
+		v, err := semver.StrictNewVersion(completeVersion(strings.TrimPrefix(part, op)))
+		if err != nil || v.Prerelease() != "" {
+			return interval{}, false
+		}
+
+		switch op {
+		case ">=", ">":
+			incl := op == ">="
+			if iv.lower == nil || v.GreaterThan(iv.lower) || (v.Equal(iv.lower) && !incl) {
+				iv.lower, iv.lowerIncl = v, incl
+			}
+		case "<=", "<":
+			incl := op == "<="
+			if iv.upper == nil || v.LessThan(iv.upper) || (v.Equal(iv.upper) && !incl) {
+				iv.upper, iv.upperIncl = v, incl
+			}
+		default:
+			// An exact version is a single point, or nothing when outside the bounds so far
+			if !iv.contains(v) {
+				return interval{lower: v, upper: v}, true
+			}
+			iv = interval{lower: v, upper: v, lowerIncl: true, upperIncl: true}
+		}
+	}
+
+	return iv, true
+}
+
+// empty reports whether the bounds of the interval contradict each other, so it contains
+// no version
+func (iv interval) empty() bool {
+	if iv.lower == nil || iv.upper == nil {
+		return false
+	}
+	return iv.lower.GreaterThan(iv.upper) || (iv.lower.Equal(iv.upper) && !(iv.lowerIncl && iv.upperIncl))
+}
+
+// emptyBranch returns the first OR branch of version whose comparisons contradict each
+// other, such as ">= 2.0.0, < 2.0.0", so that it allows no version
+func emptyBranch(version string) (string, bool) {
+	for _, branch := range strings.Split(version, "||") {
+		if iv, ok := parseBounds(branch); ok && iv.empty() {
+			return strings.TrimSpace(branch), true
+		}
+	}
+	return "", false
+}
+
+// completeVersion pads a partial version such as "1" or "1.2" to three components
+func completeVersion(v string) string {
+	if n := strings.Count(v, "."); n < 2 && v != "" {
+		v += strings.Repeat(".0", 2-n)
+	}
+	return v
+}
+
+// contains reports whether v lies within the interval
+func (iv interval) contains(v *semver.Version) bool {
+	if iv.lower != nil && (v.LessThan(iv.lower) || (v.Equal(iv.lower) && !iv.lowerIncl)) {
+		return false
+	}
+	if iv.upper != nil && (v.GreaterThan(iv.upper) || (v.Equal(iv.upper) && !iv.upperIncl)) {
+		return false
+	}
+	return true
+}
+
+// touches reports whether next, which starts no earlier than iv, overlaps or abuts iv
+func (iv interval) touches(next interval) bool {
+	if iv.upper == nil || next.lower == nil {
+		return true
+	}
+	if next.lower.LessThan(iv.upper) {
+		return true
+	}
+	return next.lower.Equal(iv.upper) && (iv.upperIncl || next.lowerIncl)
+}
+
+// String renders the interval in the normalized constraint format
+func (iv interval) String() string {
+	if iv.lower != nil && iv.upper != nil && iv.lower.Equal(iv.upper) {
+		return iv.lower.String()
+	}
+
+	var parts []string
+	if iv.lower != nil {
+		op := "> "
+		if iv.lowerIncl {
+			op = ">= "
+		}
+		parts = append(parts, op+iv.lower.String())
+	}
+	if iv.upper != nil {
+		op := "< "
+		if iv.upperIncl {
+			op = "<= "
+		}
+		parts = append(parts, op+iv.upper.String())
+	}
+	if len(parts) == 0 {
+		return ">= 0.0.0"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// simplifyConstraint merges OR branches that overlap or are adjacent, dropping branches
+// fully contained in another, so ">=1,<2 || >=1.5,<1.8" becomes ">= 1.0.0, < 2.0.0".
+// The input is returned unchanged when no branches merge or a branch cannot be
+// represented as a simple interval (e.g. it uses pre-release versions).
+func simplifyConstraint(version string) string {
+	branches := strings.Split(version, "||")
+	if len(branches) < 2 {
+		return version
+	}
+
+	intervals := make([]interval, 0, len(branches))
+	for _, branch := range branches {
+		iv, ok := parseInterval(branch)
+		if !ok {
+			return version
+		}
+		intervals = append(intervals, iv)
+	}
+
+	merged := mergeIntervals(intervals)
+	if len(merged) == len(branches) {
+		return version
+	}
+
+	parts := make([]string, len(merged))
+	for i, iv := range merged {
+		parts[i] = iv.String()
+	}
+	return strings.Join(parts, " || ")
+}
+
+// mergeIntervals orders intervals by their lower bound and merges those that overlap or
+// are adjacent
+func mergeIntervals(intervals []interval) []interval {
+	sort.SliceStable(intervals, func(i, j int) bool {
+		a, b := intervals[i], intervals[j]
+		if a.lower == nil || b.lower == nil {
+			return a.lower == nil && b.lower != nil
+		}
+		if !a.lower.Equal(b.lower) {
+			return a.lower.LessThan(b.lower)
+		}
+		return a.lowerIncl && !b.lowerIncl
+	})
+
+	merged := []interval{intervals[0]}
+	for _, next := range intervals[1:] {
+		cur := &merged[len(merged)-1]
+		if !cur.touches(next) {
+			merged = append(merged, next)
+			continue
+		}
+		// Extend the current interval when next reaches further
+		if cur.upper != nil {
+			if next.upper == nil || next.upper.GreaterThan(cur.upper) {
+				cur.upper, cur.upperIncl = next.upper, next.upperIncl
+			} else if next.upper.Equal(cur.upper) && next.upperIncl {
+				cur.upperIncl = true
+			}
+		}
+	}
+	return merged
+}
+
+// intersect returns the versions in both iv and other, reporting false when they do not
+// overlap
+func (iv interval) intersect(other interval) (interval, bool) {
+	result := iv
+	if other.lower != nil && (result.lower == nil || other.lower.GreaterThan(result.lower) || (other.lower.Equal(result.lower) && !other.lowerIncl)) {
+		result.lower, result.lowerIncl = other.lower, other.lowerIncl
+	}
+	if other.upper != nil && (result.upper == nil || other.upper.LessThan(result.upper) || (other.upper.Equal(result.upper) && !other.upperIncl)) {
+		result.upper, result.upperIncl = other.upper, other.upperIncl
+	}
+	return result, !result.empty()
+}
+
+// mergeRanges combines two ranges by interval arithmetic on their OR branches: with
+// RangeMergeIntersect into the versions both allow, so ">=1.0.0,<2.0.0" and
+// ">=1.5.0,<2.5.0" give ">= 1.5.0, < 2.0.0", and with RangeMergeUnion into the versions
+// either allows, merging branches that overlap. It reports false when either is an exact
+// version or has a branch that is not a simple interval, and when intersected ranges do
+// not overlap.
+func mergeRanges(existingVersion, targetVersion string, mode RangeMerge) (string, bool) {
+	if mode != RangeMergeIntersect && mode != RangeMergeUnion {
+		return "", false
+	}
+
+	var sides [2][]interval
+	for i, version := range []string{existingVersion, targetVersion} {
+		expanded, err := ExpandTerraformTildeArrow(version)
+		if err != nil {
+			return "", false
+		}
+		if isVer, _, _, err := ParseVersionOrRange(expanded); err != nil || isVer {
+			return "", false
+		}
+		for _, branch := range strings.Split(expanded, "||") {
+			iv, ok := parseInterval(branch)
+			if !ok {
+				return "", false
+			}
+			sides[i] = append(sides[i], iv)
+		}
+	}
+
+	var intervals []interval
+	if mode == RangeMergeUnion {
+		intervals = append(sides[0], sides[1]...)
+	} else {
+		for _, a := range sides[0] {
+			for _, b := range sides[1] {
+				if iv, ok := a.intersect(b); ok {
+					intervals = append(intervals, iv)
+				}
+			}
+		}
+		if len(intervals) == 0 {
+			return "", false
+		}
+	}
+
+	merged := mergeIntervals(intervals)
+	parts := make([]string, len(merged))
+	for i, iv := range merged {
+		parts[i] = iv.String()
+	}
+	return strings.Join(parts, " || "), true
+}
+
+// sortOrBranches orders the OR branches of version by their lower bound, ascending, so
+// ">=3,<4 || >=1,<2" becomes ">= 1.0.0, < 2.0.0 || >= 3.0.0, < 4.0.0". Branches are
+// written in the normalized format; version is returned unchanged when the lower bound
+// of a branch cannot be read.
+func sortOrBranches(version string) string {
+	if !strings.Contains(version, "||") {
+		return version
+	}
+
+	type branch struct {
+		text  string
+		lower *semver.Version
+	}
+	var branches []branch
+	for _, part := range strings.Split(version, "||") {
+		part = joinSpaceAnds(strings.TrimSpace(part))
+		expanded, err := ExpandTerraformTildeArrow(part)
+		if err != nil {
+			return version
+		}
+		c, err := semver.NewConstraint(expanded)
+		if err != nil {
+			return version
+		}
+		lower, err := getMinVersionFromConstraint(c)
+		if err != nil {
+			return version
+		}
+
+		text := normalizeVersionString(part)
+		if iv, ok := parseInterval(part); ok {
+			text = iv.String()
+		}
+		branches = append(branches, branch{text: text, lower: lower})
+	}
+
+	sort.SliceStable(branches, func(i, j int) bool {
+		return branches[i].lower.LessThan(branches[j].lower)
+	})
+	parts := make([]string, len(branches))
+	for i, b := range branches {
+		parts[i] = b.text
+	}
+	return strings.Join(parts, " || ")
+}
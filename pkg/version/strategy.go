@@ -2,6 +2,7 @@ package version
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/Masterminds/semver/v3"
@@ -61,120 +62,332 @@ func DecideVersionOrRange(
 	newRange *semver.Constraints,
 	newInput string,
 ) string {
+	result, _ := DecideVersionOrRangeWithReason(oldIsVer, oldVer, oldRange, oldInput, newIsVer, newVer, newRange, newInput)
+	return result
+}
+
+// DecideVersionOrRangeWithReason is DecideVersionOrRange that also explains which rule
+// determined the result, e.g. "kept existing: higher minimum bound (backward protection)"
+func DecideVersionOrRangeWithReason(
+	oldIsVer bool,
+	oldVer *semver.Version,
+	oldRange *semver.Constraints,
+	oldInput string,
+	newIsVer bool,
+	newVer *semver.Version,
+	newRange *semver.Constraints,
+	newInput string,
+) (string, string) {
 	switch {
 	case oldIsVer && newIsVer:
 		// Use enhanced version comparison
 		comp := compareVersions(oldVer, newVer)
 		if comp > 0 {
-			return oldVer.Original()
+			return oldVer.Original(), "kept existing: higher version (backward protection)"
 		}
-		return newVer.Original()
+		return newVer.Original(), "used target: not lower than existing version"
 
 	case oldIsVer && !newIsVer:
+		// A version the new range explicitly excludes is never kept
+		if isExcluded(newRange, oldVer) {
+			return newInput, "used target: existing version is excluded by target range"
+		}
 		// If old version is exact and new is a range, first check if old version is higher than any version in the range
 		maxVer := findHighestVersionInRange(newRange)
-		if maxVer != nil && compareVersions(oldVer, maxVer) > 0 {
-			return oldVer.Original()
+		if maxVer != nil && !unboundedAbove(newRange) && compareVersions(oldVer, maxVer) > 0 {
+			return oldVer.Original(), "kept existing: version above target range (backward protection)"
 		}
 		// If old version fits in the new range, keep old version for consistency
 		if newRange.Check(oldVer) {
-			return oldVer.Original()
+			return oldVer.Original(), "kept existing: version satisfies target range"
 		}
 		// For backward protection, if old version is higher than the minimum of the new range,
 		// keep the old version
-		minVer := findLowestVersionInRange(newRange)
-		if minVer != nil && compareVersions(oldVer, minVer) > 0 {
-			return oldVer.Original()
+		minBound := findLowerBound(newRange)
+		if minBound != nil && compareLowerBounds(lowerBound{version: oldVer, inclusive: true}, *minBound) > 0 {
+			return oldVer.Original(), "kept existing: version above target minimum (backward protection)"
 		}
 		// Otherwise use the new range
-		return newInput
+		return newInput, "used target: existing version below target range"
 
 	case !oldIsVer && newIsVer:
 		if oldRange != nil {
+			// If new version fits in old range, keep old range for consistency
+			if oldRange.Check(newVer) {
+				return oldInput, "kept existing: range contains target"
+			}
 			// If old range has a higher minimum version, keep old range
-			minVer := findLowestVersionInRange(oldRange)
-			if minVer != nil && compareVersions(minVer, newVer) > 0 {
-				return oldInput
+			minBound := findLowerBound(oldRange)
+			if minBound != nil && compareLowerBounds(*minBound, lowerBound{version: newVer, inclusive: true}) > 0 {
+				return oldInput, "kept existing: higher minimum bound (backward protection)"
 			}
-			// If old range has a higher maximum version, keep old range
+			// If old range has a higher maximum version, keep old range. An open-ended
+			// range has no real maximum to compare.
 			maxVer := findHighestVersionInRange(oldRange)
-			if maxVer != nil && compareVersions(maxVer, newVer) > 0 {
-				return oldInput
-			}
-			// If new version fits in old range, keep old range for consistency
-			if oldRange.Check(newVer) {
-				return oldInput
+			if maxVer != nil && !unboundedAbove(oldRange) && compareVersions(maxVer, newVer) > 0 {
+				return oldInput, "kept existing: higher maximum bound (backward protection)"
 			}
 		}
 		// Use new exact version
-		return newVer.Original()
+		return newVer.Original(), "used target: existing range below target"
 
 	default:
 		// Both are ranges
 		if oldRange == nil || newRange == nil {
-			return newInput
+			return newInput, "used target"
 		}
 
+		// OR chains are sets of intervals: a target whose every branch lies within a
+		// branch of the existing range is kept, whichever branch that is
+		orChains := strings.Contains(oldRange.String(), "||") || strings.Contains(newRange.String(), "||")
+		if orChains && rangeContains(oldRange, newRange) {
+			return oldInput, "kept existing: range contains target"
+		}
+
+		// Terraform installs the newest version a constraint allows, so the bounds of OR
+		// chains are compared on their highest branches rather than their global minimum
+		// and maximum; overlap below is still checked across every branch
+		oldTop, newTop := highestBranch(oldRange), highestBranch(newRange)
+
 		// Find highest and lowest versions in both ranges
-		oldMaxVer := findHighestVersionInRange(oldRange)
-		newMaxVer := findHighestVersionInRange(newRange)
-		oldMinVer := findLowestVersionInRange(oldRange)
-		newMinVer := findLowestVersionInRange(newRange)
+		oldMaxVer := findHighestVersionInRange(oldTop)
+		newMaxVer := findHighestVersionInRange(newTop)
+		// Minimums are compared with their strictness, so ">1.0.0" starts above ">=1.0.0"
+		// and below ">=1.0.1-rc.1"
+		oldMin := findLowerBound(oldTop)
+		newMin := findLowerBound(newTop)
 
 		// If old range has higher minimum version than new range, keep old range
-		if oldMinVer != nil && newMinVer != nil && compareVersions(oldMinVer, newMinVer) > 0 {
-			return oldInput
+		if oldMin != nil && newMin != nil && compareLowerBounds(*oldMin, *newMin) > 0 {
+			return oldInput, "kept existing: higher minimum bound (backward protection)"
+		}
+
+		// An open-ended old range such as ">= 1.0.0" has no real maximum: its highest version
+		// is only the search ceiling, so a target raising its minimum is used
+		oldOpen := unboundedAbove(oldTop)
+		if oldOpen && oldMin != nil && newMin != nil && compareLowerBounds(*newMin, *oldMin) > 0 {
+			return newInput, "used target: raises the minimum of an open-ended range"
+		}
+
+		// A target lying wholly within the existing range is kept for consistency
+		if rangeContains(oldRange, newRange) {
+			return oldInput, "kept existing: range contains target"
 		}
 
 		// If old range has higher version than new range, keep old range
-		if oldMaxVer != nil && newMaxVer != nil && compareVersions(oldMaxVer, newMaxVer) > 0 {
-			return oldInput
+		if !oldOpen && oldMaxVer != nil && newMaxVer != nil && compareVersions(oldMaxVer, newMaxVer) > 0 {
+			return oldInput, "kept existing: higher maximum bound (backward protection)"
 		}
 
 		// If ranges overlap, keep old range for consistency
 		if RangesOverlap(oldRange, newRange) {
-			return oldInput
+			return oldInput, "kept existing: ranges overlap"
 		}
 
-		return newInput
+		return newInput, "used target: ranges do not overlap"
 	}
 }
 
+// IsProtectedReason reports whether a strategy reason records backward protection: the
+// existing version or range was kept because moving to the target would have lowered it.
+// Only reasons carrying the explicit "(backward protection)" marker count, so a target the
+// existing range already contains is not reported as protected.
+func IsProtectedReason(reason string) bool {
+	return strings.HasPrefix(reason, "kept existing:") && strings.HasSuffix(reason, "(backward protection)")
+}
+
+// StrategyOptions tunes how a strategy computes its result
+type StrategyOptions struct {
+	// CollapseOr narrows an OR-combined range target to the branch containing the existing version
+	CollapseOr bool
+	// MinVersion is an absolute floor: nothing below it is ever written, even when both
+	// the target and the existing version are lower
+	MinVersion string
+	// MaxVersion is an inclusive cap: exact versions above it are clamped to it and range
+	// upper bounds are lowered to it
+	MaxVersion string
+	// ErrorAboveMaxVersion rejects results above MaxVersion instead of clamping them
+	ErrorAboveMaxVersion bool
+	// PreserveStyle writes range results with the operator and separator spacing of the
+	// existing constraint
+	PreserveStyle bool
+	// SortOrBranches writes the OR branches of range results ordered by their lower bound
+	SortOrBranches bool
+	// CompactOutput writes range results without spaces after operators or around commas,
+	// e.g. ">=1.0.0,<2.0.0"; PreserveStyle still follows the existing constraint
+	CompactOutput bool
+	// ErrorOnBlockedBump fails instead of keeping the existing version when
+	// StrategyPatchOnly or StrategyMajorLock blocks the bump to the target
+	ErrorOnBlockedBump bool
+	// KeepPre10Ranges writes range targets starting below 1.0.0 as ranges instead of
+	// pinning them to their minimum version with the range and dynamic strategies
+	KeepPre10Ranges bool
+	// RangeMerge combines an existing range with a range target by their intersection or
+	// union with StrategyRange and StrategyDynamic; empty is RangeMergeKeep
+	RangeMerge RangeMerge
+	// PrereleaseRange bounds the range StrategyRange writes for an exact pre-release
+	// target; empty is PrereleaseRangeNextMajor
+	PrereleaseRange PrereleaseRange
+}
+
 // ApplyVersionStrategy applies the specified strategy to convert between version formats
 func ApplyVersionStrategy(strategy Strategy, targetVersion string, existingVersion string) (string, error) {
+	return ApplyVersionStrategyWithOptions(strategy, targetVersion, existingVersion, StrategyOptions{})
+}
+
+// ApplyVersionStrategyWithOptions is ApplyVersionStrategy with optional behaviour enabled by opts
+func ApplyVersionStrategyWithOptions(strategy Strategy, targetVersion string, existingVersion string, opts StrategyOptions) (string, error) {
+	result, _, err := ApplyVersionStrategyWithReason(strategy, targetVersion, existingVersion, opts)
+	return result, err
+}
+
+// ApplyVersionStrategyWithReason is ApplyVersionStrategyWithOptions that also explains
+// which rule determined the result, e.g. "kept existing: higher minimum bound (backward protection)", for
+// debugging why a version was or was not bumped
+func ApplyVersionStrategyWithReason(strategy Strategy, targetVersion string, existingVersion string, opts StrategyOptions) (string, string, error) {
+	result, reason, err := applyVersionStrategy(strategy, joinSpaceAnds(stripBoundVPrefixes(targetVersion)), joinSpaceAnds(stripBoundVPrefixes(existingVersion)), opts)
+	if err != nil {
+		return "", "", err
+	}
+
+	bounded, err := applyMinVersion(result, opts.MinVersion)
+	if err != nil {
+		return "", "", err
+	}
+	if bounded != result {
+		reason += "; raised to min_version " + opts.MinVersion
+	}
+
+	result = bounded
+	if bounded, err = applyMaxVersion(result, opts.MaxVersion, opts.ErrorAboveMaxVersion); err != nil {
+		return "", "", err
+	}
+	if bounded != result {
+		reason += "; capped at max_version " + opts.MaxVersion
+	}
+
+	result = bounded
+	if opts.SortOrBranches {
+		result = sortOrBranches(result)
+	}
+	result = keepEqualityOperator(result, existingVersion)
+	result = keepVPrefix(result, existingVersion)
+	result = keepVPrefixedBounds(result, existingVersion)
+	if opts.CompactOutput {
+		result = compactRange(result)
+	}
+	result = keepSpaceAnds(result, existingVersion)
+	if opts.PreserveStyle {
+		result = applyExistingStyle(result, existingVersion)
+	}
+	return result, reason, nil
+}
+
+// keepEqualityOperator returns the existing version unchanged when it is written with "="
+// and names the same version as result, so a kept "= 1.2.3" is not rewritten as "1.2.3"
+func keepEqualityOperator(result, existingVersion string) string {
+	if !hasEqualityOperator(existingVersion) {
+		return result
+	}
+	existingVer, err := parseExactVersion(existingVersion)
+	if err != nil {
+		return result
+	}
+	if resultVer, err := semver.NewVersion(result); err == nil && resultVer.Equal(existingVer) && resultVer.Metadata() == existingVer.Metadata() {
+		return existingVersion
+	}
+	return result
+}
+
+// keepVPrefix writes an exact result with a leading "v" when the existing exact version
+// has one, so "v1.2.3" is upgraded to "v2.0.0" rather than "2.0.0"
+func keepVPrefix(result, existingVersion string) string {
+	existing := strings.TrimSpace(existingVersion)
+	if !strings.HasPrefix(existing, "v") || strings.HasPrefix(result, "v") {
+		return result
+	}
+	if _, err := semver.NewVersion(existing); err != nil {
+		return result
+	}
+	if _, err := semver.NewVersion(result); err != nil {
+		return result
+	}
+	return "v" + result
+}
+
+// keepVPrefixedBounds returns the existing range unchanged when it writes the versions of
+// its comparisons with a "v" and is the same range as result, so a kept
+// ">= v1.0.0, < v2.0.0" is not rewritten without them
+func keepVPrefixedBounds(result, existingVersion string) string {
+	stripped := stripBoundVPrefixes(existingVersion)
+	if stripped != existingVersion && NormalizeVersionString(result) == NormalizeVersionString(stripped) {
+		return existingVersion
+	}
+	return result
+}
+
+// keepSpaceAnds returns the existing constraint unchanged when it joins its comparisons
+// with spaces and is the same constraint as result, so a kept ">= 1.0.0 < 2.0.0" is not
+// rewritten with a comma
+func keepSpaceAnds(result, existingVersion string) string {
+	if hasSpaceAnds(existingVersion) && NormalizeVersionString(result) == NormalizeVersionString(existingVersion) {
+		return existingVersion
+	}
+	return result
+}
+
+func applyVersionStrategy(strategy Strategy, targetVersion string, existingVersion string, opts StrategyOptions) (string, string, error) {
 	switch strategy {
 	case StrategyExact:
 		// First, parse both versions
-		targetVer, err := semver.NewVersion(targetVersion)
+		targetVer, err := parseExactVersion(targetVersion)
 		if err != nil {
-			return "", fmt.Errorf("exact strategy requires an exact version (e.g., '2.1.1'), got: %s", targetVersion)
+			return "", "", fmt.Errorf("exact strategy requires an exact version (e.g., '2.1.1'), got: %s", targetVersion)
 		}
 
 		// If no existing version, use target version
 		if existingVersion == "" {
-			return targetVer.String(), nil
+			return targetVer.String(), "used target: no existing version", nil
 		}
 
 		// Parse existing version
-		existingVer, err := semver.NewVersion(existingVersion)
+		existingVer, err := parseExactVersion(existingVersion)
 		if err != nil {
 			// If existing version is invalid, use target version
-			return targetVer.String(), nil
+			return targetVer.String(), "used target: existing version is not exact", nil
 		}
 
 		// For backward compatibility protection, if existing version is higher, keep it
 		if existingVer.GreaterThan(targetVer) {
-			return existingVer.String(), nil
+			return existingVer.String(), "kept existing: higher version (backward protection)", nil
 		}
 
-		return targetVer.String(), nil
+		return targetVer.String(), "used target: not lower than existing version", nil
 
-	case StrategyRange:
-		return ApplyRangeStrategy(targetVersion, existingVersion)
-	case StrategyDynamic:
-		return ApplyDynamicStrategy(targetVersion, existingVersion)
+	case StrategyRange, StrategyDynamic:
+		if result, ok := mergeRanges(existingVersion, targetVersion, opts.RangeMerge); ok {
+			if opts.RangeMerge == RangeMergeIntersect {
+				return result, "merged: intersection of existing and target ranges", nil
+			}
+			return result, "merged: union of existing and target ranges", nil
+		}
+		if strategy == StrategyDynamic {
+			return applyDynamicStrategy(targetVersion, existingVersion, opts)
+		}
+		result, err := applyRangeStrategy(targetVersion, existingVersion, opts)
+		if err != nil {
+			return "", "", err
+		}
+		if existingVersion != "" && NormalizeVersionString(result) == NormalizeVersionString(existingVersion) {
+			return result, "kept existing: range strategy", nil
+		}
+		return result, "used target: range strategy", nil
+	case StrategyPatchOnly:
+		return applyPatchOnly(targetVersion, existingVersion, opts)
+	case StrategyMajorLock:
+		return applyMajorLock(targetVersion, existingVersion, opts)
 	default:
-		return targetVersion, nil
+		return targetVersion, "used target: unknown strategy", nil
 	}
 }
 
@@ -210,11 +423,9 @@ func splitRangeAtVersion(r *semver.Constraints, v *semver.Version) (*semver.Cons
 
 	// Handle OR conditions
 	if strings.Contains(rangeStr, "||") {
-		parts := strings.Split(rangeStr, "||")
 		var beforeParts, afterParts []string
 
-		for _, part := range parts {
-			part = strings.TrimSpace(part)
+		for _, part := range orBranches(rangeStr) {
 			c, err := semver.NewConstraint(part)
 			if err != nil {
 				continue
@@ -264,11 +475,10 @@ func splitRangeAtVersion(r *semver.Constraints, v *semver.Version) (*semver.Cons
 
 // handleComplexRange processes complex version ranges, handling OR conditions and splits at 1.0.0
 func handleComplexRange(version string) (string, error) {
-	// Split by OR conditions first
-	parts := strings.Split(version, "||")
-	for _, part := range parts {
+	// Split by OR conditions first, each an AND of comparisons
+	for _, part := range orBranches(version) {
 		// Parse each part as a constraint
-		c, err := semver.NewConstraint(strings.TrimSpace(part))
+		c, err := semver.NewConstraint(part)
 		if err != nil {
 			continue
 		}
@@ -282,8 +492,7 @@ func handleComplexRange(version string) (string, error) {
 		// If this is a pre-1.0 version, return it with its metadata
 		if isPre100Version(v) {
 			// Try to extract the exact version with metadata from the original string
-			rangeParts := strings.Split(strings.TrimSpace(part), ",")
-			for _, rangePart := range rangeParts {
+			for _, rangePart := range strings.Split(part, ",") {
 				rangePart = strings.TrimSpace(rangePart)
 				if strings.Contains(rangePart, v.String()) {
 					// Extract the exact version with metadata
@@ -316,6 +525,15 @@ func handleComplexRange(version string) (string, error) {
 }
 
 func ConvertToRangeVersion(version string) (string, error) {
+	return convertToRangeVersion(version, PrereleaseRangeNextMajor)
+}
+
+// convertToRangeVersion implements ConvertToRangeVersion, bounding the range written for
+// an exact pre-release version as prerelease says
+func convertToRangeVersion(version string, prerelease PrereleaseRange) (string, error) {
+	// Comparisons joined by whitespace are split on commas below
+	version = joinSpaceAnds(version)
+
 	// Handle complex ranges first
 	if strings.Contains(version, "||") || strings.Contains(version, "~>") {
 		return handleComplexRange(version)
@@ -355,21 +573,60 @@ func ConvertToRangeVersion(version string) (string, error) {
 		return v.Original(), nil
 	}
 
+	if v.Prerelease() != "" {
+		switch prerelease {
+		case PrereleaseRangeSameMajor:
+			return normalizeVersionString(fmt.Sprintf(">=%s,<%d.%d.%d", v.String(), v.Major(), v.Minor(), v.Patch())), nil
+		case PrereleaseRangeLockTarget:
+			return v.Original(), nil
+		}
+	}
+
 	// Convert to range >=current,<next-major with consistent spacing
 	return normalizeVersionString(fmt.Sprintf(">=%s,<%d.0.0", v.String(), v.Major()+1)), nil
 }
 
+// selectOrBranch returns the OR branch of rangeStr containing the existing version,
+// or rangeStr unchanged when it has no OR branches or none of them match
+func selectOrBranch(rangeStr string, existingIsVer bool, existingVer *semver.Version, existingRange *semver.Constraints) string {
+	if !strings.Contains(rangeStr, "||") {
+		return rangeStr
+	}
+
+	// A range existing version is located by its lowest version
+	v := existingVer
+	if !existingIsVer {
+		v = findLowestVersionInRange(existingRange)
+	}
+	if v == nil {
+		return rangeStr
+	}
+
+	for _, part := range orBranches(rangeStr) {
+		c, err := semver.NewConstraint(part)
+		if err != nil {
+			continue
+		}
+		if c.Check(v) {
+			return part
+		}
+	}
+	return rangeStr
+}
+
 func ApplyRangeStrategy(targetVersion, existingVersion string) (string, error) {
+	return applyRangeStrategy(joinSpaceAnds(targetVersion), joinSpaceAnds(existingVersion), StrategyOptions{})
+}
+
+func applyRangeStrategy(targetVersion, existingVersion string, opts StrategyOptions) (string, error) {
 	// If no existing version, convert target to range
 	if existingVersion == "" {
-		// Expand tilde arrow notation first
-		expandedTarget := ExpandTerraformTildeArrow(targetVersion)
-		return ConvertToRangeVersion(expandedTarget)
+		return convertToRange(targetVersion, opts)
 	}
 
 	// Expand tilde arrow notation
-	expandedTarget := ExpandTerraformTildeArrow(targetVersion)
-	expandedExisting := ExpandTerraformTildeArrow(existingVersion)
+	expandedTarget := expandOrKeep(targetVersion)
+	expandedExisting := expandOrKeep(existingVersion)
 
 	// Parse target version
 	targetIsVer, targetVer, targetRange, err := ParseVersionOrRange(expandedTarget)
@@ -381,7 +638,15 @@ func ApplyRangeStrategy(targetVersion, existingVersion string) (string, error) {
 	existingIsVer, existingVer, existingRange, err := ParseVersionOrRange(expandedExisting)
 	if err != nil {
 		// If existing version is invalid, convert target to range
-		return ConvertToRangeVersion(expandedTarget)
+		return convertToRange(targetVersion, opts)
+	}
+
+	// Keep only the OR branch relevant to the existing version
+	if opts.CollapseOr && !targetIsVer {
+		if branch := selectOrBranch(expandedTarget, existingIsVer, existingVer, existingRange); branch != expandedTarget {
+			expandedTarget = branch
+			targetRange, _ = semver.NewConstraint(branch)
+		}
 	}
 
 	// Handle pre-1.0 versions
@@ -394,7 +659,7 @@ func ApplyRangeStrategy(targetVersion, existingVersion string) (string, error) {
 	}
 
 	// Handle pre-1.0 target range
-	if !targetIsVer && targetRange != nil {
+	if !targetIsVer && targetRange != nil && !opts.KeepPre10Ranges {
 		minVer := findLowestVersionInRange(targetRange)
 		if isPre100Version(minVer) {
 			// If existing version is higher, keep it
@@ -421,76 +686,131 @@ func ApplyRangeStrategy(targetVersion, existingVersion string) (string, error) {
 	// If existing is a range and target is a version that fits in it, keep existing range
 	if !existingIsVer && existingRange != nil && targetIsVer && targetVer != nil {
 		if existingRange.Check(targetVer) {
-			return normalizeVersionString(expandedExisting), nil
+			return normalizeRange(existingVersion, expandedExisting), nil
 		}
 	}
 
 	// If existing is a range with higher minimum version, keep it
 	if !existingIsVer && existingRange != nil && targetIsVer && targetVer != nil {
-		existingMinVer := findLowestVersionInRange(existingRange)
-		if existingMinVer != nil && existingMinVer.GreaterThan(targetVer) {
-			return normalizeVersionString(expandedExisting), nil
+		existingMin := findLowerBound(existingRange)
+		if existingMin != nil && compareLowerBounds(*existingMin, lowerBound{version: targetVer, inclusive: true}) > 0 {
+			return normalizeRange(existingVersion, expandedExisting), nil
 		}
 	}
 
 	// If target is already a range, normalize and return it
 	if !targetIsVer && targetRange != nil {
-		return normalizeVersionString(expandedTarget), nil
+		return normalizeRange(targetVersion, expandedTarget), nil
 	}
 
 	// Otherwise convert target to range
-	return ConvertToRangeVersion(expandedTarget)
+	return convertToRangeVersion(expandedTarget, opts.PrereleaseRange)
+}
+
+// isTildeOrChain reports whether version is an OR of "~>" constraints, such as
+// "~>1.2.3 || ~>2.0.0", whose branches name release lines deliberately
+func isTildeOrChain(version string) bool {
+	return strings.Contains(version, "||") && strings.Contains(version, "~>")
+}
+
+// normalizeRange normalizes expanded, the tilde-arrow expansion of original. The branches
+// of a tilde OR chain are normalized one by one and kept in their order, rather than being
+// merged where they meet, so "~>1.2 || ~>2.0" stays two branches.
+func normalizeRange(original, expanded string) string {
+	if !isTildeOrChain(original) {
+		return normalizeVersionString(expanded)
+	}
+	parts := strings.Split(expanded, "||")
+	for i, part := range parts {
+		parts[i] = normalizeVersionString(strings.TrimSpace(part))
+	}
+	return strings.Join(parts, " || ")
 }
 
+// convertToRange is ConvertToRangeVersion for a target that may use tilde arrows, keeping
+// the branches of a tilde OR chain. With opts.KeepPre10Ranges a range target starting below
+// 1.0.0 is kept a range, and opts.PrereleaseRange bounds an exact pre-release target.
+func convertToRange(targetVersion string, opts StrategyOptions) (string, error) {
+	expanded, err := ExpandTerraformTildeArrow(targetVersion)
+	if err != nil {
+		return "", err
+	}
+	if opts.KeepPre10Ranges {
+		if isVer, _, _, err := ParseVersionOrRange(expanded); err == nil && !isVer {
+			return normalizeRange(targetVersion, expanded), nil
+		}
+	}
+	result, err := convertToRangeVersion(expanded, opts.PrereleaseRange)
+	if err != nil || result != normalizeVersionString(expanded) {
+		return result, err
+	}
+	return normalizeRange(targetVersion, expanded), nil
+}
+
+// ApplyDynamicStrategy keeps the existing version or range where it is compatible with
+// the target and moves to the target otherwise
 func ApplyDynamicStrategy(targetVersion, existingVersion string) (string, error) {
+	result, _, err := ApplyDynamicStrategyWithReason(targetVersion, existingVersion)
+	return result, err
+}
+
+// ApplyDynamicStrategyWithReason is ApplyDynamicStrategy that also explains which rule
+// determined the result
+func ApplyDynamicStrategyWithReason(targetVersion, existingVersion string) (string, string, error) {
+	return applyDynamicStrategy(joinSpaceAnds(targetVersion), joinSpaceAnds(existingVersion), StrategyOptions{})
+}
+
+// applyDynamicStrategy implements ApplyDynamicStrategyWithReason; opts.KeepPre10Ranges
+// keeps range targets starting below 1.0.0 from being pinned to their minimum version
+func applyDynamicStrategy(targetVersion, existingVersion string, opts StrategyOptions) (string, string, error) {
 	// If no existing version, use target as is
 	if existingVersion == "" {
 		// For pre-1.0 ranges, convert to exact version
-		if _, err := semver.NewConstraint(targetVersion); err == nil && strings.Contains(targetVersion, ">") {
+		if _, err := semver.NewConstraint(targetVersion); err == nil && strings.Contains(targetVersion, ">") && !opts.KeepPre10Ranges {
 			c, _ := semver.NewConstraint(targetVersion)
 			minVer := findLowestVersionInRange(c)
 			if isPre100Version(minVer) {
-				return preserveVersionMetadata(minVer), nil
+				return preserveVersionMetadata(minVer), "used target: pre-1.0 range pinned to its minimum", nil
 			}
 		}
-		return targetVersion, nil
+		return targetVersion, "used target: no existing version", nil
 	}
 
 	// Expand tilde arrow notation first
-	expandedTarget := ExpandTerraformTildeArrow(targetVersion)
-	expandedExisting := ExpandTerraformTildeArrow(existingVersion)
+	expandedTarget := expandOrKeep(targetVersion)
+	expandedExisting := expandOrKeep(existingVersion)
 
 	// Parse target version/range
 	targetIsVer, targetVer, targetRange, err := ParseVersionOrRange(expandedTarget)
 	if err != nil {
-		return "", fmt.Errorf("invalid target version: %w", err)
+		return "", "", fmt.Errorf("invalid target version: %w", err)
 	}
 
 	// Parse existing version/range
 	existingIsVer, existingVer, existingRange, err := ParseVersionOrRange(expandedExisting)
 	if err != nil {
 		// If existing version is invalid, use target as is
-		return targetVersion, nil
+		return targetVersion, "used target: existing version is invalid", nil
 	}
 
 	// Handle pre-1.0 target version
 	if targetIsVer && isPre100Version(targetVer) {
 		// If existing version is higher, keep it
 		if existingIsVer && existingVer != nil && existingVer.GreaterThan(targetVer) {
-			return preserveVersionMetadata(existingVer), nil
+			return preserveVersionMetadata(existingVer), "kept existing: higher pre-1.0 version (backward protection)", nil
 		}
 		// If existing is a range and its minimum version is higher, keep it
 		if !existingIsVer && existingRange != nil {
 			minVer := findLowestVersionInRange(existingRange)
 			if minVer != nil && isPre100Version(minVer) && minVer.GreaterThan(targetVer) {
-				return normalizeVersionString(expandedExisting), nil
+				return normalizeVersionString(expandedExisting), "kept existing: higher pre-1.0 minimum bound (backward protection)", nil
 			}
 		}
-		return preserveVersionMetadata(targetVer), nil
+		return preserveVersionMetadata(targetVer), "used target: pre-1.0 version", nil
 	}
 
 	// Handle pre-1.0 target range
-	if !targetIsVer && targetRange != nil {
+	if !targetIsVer && targetRange != nil && !opts.KeepPre10Ranges {
 		targetMinVer := findLowestVersionInRange(targetRange)
 		if isPre100Version(targetMinVer) {
 			// If existing is a range, check if it's higher
@@ -499,28 +819,28 @@ func ApplyDynamicStrategy(targetVersion, existingVersion string) (string, error)
 				if existingMinVer != nil && existingMinVer.GreaterThan(targetMinVer) {
 					// If both are pre-1.0 ranges, keep the existing range
 					if isPre100Version(existingMinVer) {
-						return normalizeVersionString(expandedExisting), nil
+						return normalizeVersionString(expandedExisting), "kept existing: higher pre-1.0 minimum bound (backward protection)", nil
 					}
 				}
 			}
 			// If existing is exact and higher, keep it
 			if existingIsVer && existingVer != nil && existingVer.GreaterThan(targetMinVer) {
-				return preserveVersionMetadata(existingVer), nil
+				return preserveVersionMetadata(existingVer), "kept existing: version above pre-1.0 target minimum (backward protection)", nil
 			}
 			// Convert pre-1.0 range to exact version
-			return preserveVersionMetadata(targetMinVer), nil
+			return preserveVersionMetadata(targetMinVer), "used target: pre-1.0 range pinned to its minimum", nil
 		}
 	}
 
 	// If existing is pre-1.0 exact version and higher than target, keep it
 	if existingIsVer && existingVer != nil && isPre100Version(existingVer) {
 		if targetIsVer && targetVer != nil && existingVer.GreaterThan(targetVer) {
-			return preserveVersionMetadata(existingVer), nil
+			return preserveVersionMetadata(existingVer), "kept existing: higher pre-1.0 version (backward protection)", nil
 		}
 		if !targetIsVer && targetRange != nil {
 			minVer := findLowestVersionInRange(targetRange)
 			if minVer != nil && existingVer.GreaterThan(minVer) {
-				return preserveVersionMetadata(existingVer), nil
+				return preserveVersionMetadata(existingVer), "kept existing: pre-1.0 version above target minimum (backward protection)", nil
 			}
 		}
 	}
@@ -531,12 +851,12 @@ func ApplyDynamicStrategy(targetVersion, existingVersion string) (string, error)
 		if isPre100Version(existingMinVer) {
 			// If target is post-1.0, keep existing range
 			if targetIsVer && targetVer != nil && targetVer.Major() > 0 {
-				return normalizeVersionString(expandedExisting), nil
+				return normalizeVersionString(expandedExisting), "kept existing: pre-1.0 range is not moved to a post-1.0 target", nil
 			}
 			if !targetIsVer && targetRange != nil {
 				targetMinVer := findLowestVersionInRange(targetRange)
 				if targetMinVer != nil && targetMinVer.Major() > 0 {
-					return normalizeVersionString(expandedExisting), nil
+					return normalizeVersionString(expandedExisting), "kept existing: pre-1.0 range is not moved to a post-1.0 target", nil
 				}
 			}
 		}
@@ -552,47 +872,129 @@ func ApplyDynamicStrategy(targetVersion, existingVersion string) (string, error)
 	}
 
 	// Use DecideVersionOrRange to handle all cases consistently
-	result := DecideVersionOrRange(
+	result, reason := DecideVersionOrRangeWithReason(
 		existingIsVer, existingVer, existingRange, expandedExisting,
 		targetIsVer, targetVer, targetRange, expandedTarget,
 	)
 
-	// Normalize the result
-	return normalizeVersionString(result), nil
+	// Normalize the result, keeping the branches of a tilde OR chain it came from
+	switch result {
+	case expandedExisting:
+		return normalizeRange(existingVersion, result), reason, nil
+	case expandedTarget:
+		return normalizeRange(targetVersion, result), reason, nil
+	default:
+		return normalizeVersionString(result), reason, nil
+	}
 }
 
-// getMinVersionFromConstraint extracts the minimum version from a constraint
+// constraintComparison matches one comparison of a constraint: an optional operator
+// followed by a version, e.g. ">= 0.0.2", "~1.2" or "1.x"
+var constraintComparison = regexp.MustCompile(`(>=|<=|!=|~>|=>|=<|>|<|=|~|\^)?\s*v?([0-9xX*][^\s,|]*)`)
+
+// getMinVersionFromConstraint returns the lowest version allowed by a constraint, read
+// directly from the lower bounds of its comparisons. Within an AND group the highest bound
+// applies and across OR groups the lowest; an exclusive bound such as ">0.0.4" yields the
+// next patch version (0.0.5), and a group without a lower bound yields 0.0.0. The version
+// is returned as written, so build metadata is kept.
 func getMinVersionFromConstraint(c *semver.Constraints) (*semver.Version, error) {
-	// Start with a very low version to find the minimum that satisfies the constraint
-	v, err := semver.NewVersion("0.0.0")
+	bound, err := getLowerBoundFromConstraint(c)
 	if err != nil {
 		return nil, err
 	}
+	if !bound.inclusive {
+		next := bound.version.IncPatch()
+		return &next, nil
+	}
+	return bound.version, nil
+}
 
-	// Keep incrementing until we find a version that satisfies the constraint
-	for !c.Check(v) {
-		if v.Major() == 0 {
-			if v.Minor() == 99 {
-				v, _ = semver.NewVersion("1.0.0")
-			} else {
-				v, _ = semver.NewVersion(fmt.Sprintf("0.%d.0", v.Minor()+1))
+// getLowerBoundFromConstraint is getMinVersionFromConstraint keeping an exclusive bound
+// as written, so ">0.0.4" yields 0.0.4 marked exclusive. Of equal bounds within an AND
+// group the exclusive one applies, and across OR groups the inclusive one.
+func getLowerBoundFromConstraint(c *semver.Constraints) (lowerBound, error) {
+	var lowest *lowerBound
+	for _, branch := range strings.Split(c.String(), "||") {
+		zero, err := semver.NewVersion("0.0.0")
+		if err != nil {
+			return lowerBound{}, err
+		}
+		floor := lowerBound{version: zero, inclusive: true}
+
+		for _, m := range constraintComparison.FindAllStringSubmatch(branch, -1) {
+			op, ver := m[1], m[2]
+			if op == "<" || op == "<=" || op == "=<" || op == "!=" {
+				continue
+			}
+
+			// Wildcard components impose no bound beyond the fixed ones; pre-release and
+			// build suffixes are kept as written
+			core, suffix := ver, ""
+			if i := strings.IndexAny(ver, "-+"); i >= 0 {
+				core, suffix = ver[:i], ver[i:]
 			}
-		} else {
-			break // Don't go beyond major version 1
+			core = strings.NewReplacer("x", "0", "X", "0", "*", "0").Replace(core)
+			v, err := semver.NewVersion(completeVersion(core) + suffix)
+			if err != nil {
+				return lowerBound{}, fmt.Errorf("invalid version %q in constraint %q: %w", m[2], c.String(), err)
+			}
+
+			// An exclusive bound starts just above the version
+			if bound := (lowerBound{version: v, inclusive: op != ">"}); compareLowerBounds(bound, floor) > 0 {
+				floor = bound
+			}
+		}
+
+		if lowest == nil || compareLowerBounds(floor, *lowest) < 0 {
+			lowest = &floor
 		}
 	}
+	return *lowest, nil
+}
 
-	// Extract the exact version with metadata from the original constraint string
-	parts := strings.Split(c.String(), ",")
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if strings.Contains(part, v.String()) {
-			// Try to extract version with metadata
-			if exactV, err := semver.NewVersion(strings.TrimLeft(part, ">=<")); err == nil {
-				return exactV, nil
+// getMaxVersionFromConstraint returns the highest version allowed by a constraint, read
+// from the upper bounds of its comparisons: an inclusive bound as written, and an exclusive
+// one such as "<2.0.0" as the version before it in the searched grid (1.50.50). Within an
+// AND group the lowest bound applies and across OR groups the highest. It fails when a
+// group has no explicit upper bound or uses an operator with an implied one, such as "~>".
+func getMaxVersionFromConstraint(c *semver.Constraints) (*semver.Version, error) {
+	var highest *semver.Version
+	for _, branch := range strings.Split(c.String(), "||") {
+		var ceiling *semver.Version
+		for _, m := range constraintComparison.FindAllStringSubmatch(branch, -1) {
+			op, ver := m[1], m[2]
+			switch op {
+			case ">", ">=", "=>", "!=":
+				continue
+			case "<", "<=", "=<":
+			default:
+				return nil, fmt.Errorf("constraint %q has an implied upper bound", c.String())
+			}
+			if strings.ContainsAny(ver, "xX*") {
+				return nil, fmt.Errorf("constraint %q has a wildcard upper bound", c.String())
+			}
+
+			v, err := semver.NewVersion(ver)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version %q in constraint %q: %w", ver, c.String(), err)
+			}
+			// An exclusive bound ends just below the version
+			if op == "<" {
+				if v = previousSearchVersion(v); v == nil {
+					return nil, fmt.Errorf("constraint %q allows no version", c.String())
+				}
+			}
+			if ceiling == nil || v.LessThan(ceiling) {
+				ceiling = v
 			}
 		}
-	}
 
-	return v, nil
+		if ceiling == nil {
+			return nil, fmt.Errorf("constraint %q has no upper bound", c.String())
+		}
+		if highest == nil || ceiling.GreaterThan(highest) {
+			highest = ceiling
+		}
+	}
+	return highest, nil
 }
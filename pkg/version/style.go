@@ -0,0 +1,78 @@
+package version
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// styleOperator matches a comparison operator and the spacing before its version
+	styleOperator = regexp.MustCompile(`(>=|<=|!=|~>|>|<|=|~|\^)(\s*)[0-9v]`)
+	// styleComma matches an AND separator with its surrounding spacing
+	styleComma = regexp.MustCompile(`\s*,\s*`)
+	// styleOr matches an OR separator with its surrounding spacing
+	styleOr = regexp.MustCompile(`\s*\|\|\s*`)
+)
+
+// applyExistingStyle rewrites a range result with the operator, comma and OR spacing of
+// the existing constraint, e.g. ">= 2.0.0, < 3.0.0" becomes ">=2.0.0,<3.0.0" when the
+// existing value is ">=1,<2", and ANDs are joined by spaces when the existing value joins
+// its comparisons that way. Exact versions and separators the existing value does not use
+// are left as they are.
+func applyExistingStyle(result, existing string) string {
+	if existing == "" || !styleOperator.MatchString(result) {
+		return result
+	}
+
+	orSep := " || "
+	if m := styleOr.FindString(existing); m != "" {
+		orSep = m
+	}
+	commaSep := ", "
+	if m := styleComma.FindString(existing); m != "" {
+		commaSep = m
+	} else if hasSpaceAnds(existing) {
+		commaSep = " "
+	}
+	opSpace, hasOp := " ", false
+	if m := styleOperator.FindStringSubmatch(existing); m != nil {
+		opSpace, hasOp = m[2], true
+	}
+
+	branches := styleOr.Split(strings.TrimSpace(result), -1)
+	for i, branch := range branches {
+		parts := styleComma.Split(branch, -1)
+		for j, part := range parts {
+			if hasOp {
+				part = styleOperator.ReplaceAllStringFunc(part, func(m string) string {
+					sub := styleOperator.FindStringSubmatch(m)
+					return sub[1] + opSpace + m[len(m)-1:]
+				})
+			}
+			parts[j] = part
+		}
+		branches[i] = strings.Join(parts, commaSep)
+	}
+	return strings.Join(branches, orSep)
+}
+
+// compactRange writes a range result without spaces between operators and versions or
+// around commas, e.g. ">= 1.0.0, < 2.0.0" becomes ">=1.0.0,<2.0.0". OR separators keep
+// their spaces and exact versions are left as they are.
+func compactRange(result string) string {
+	if _, err := parseExactVersion(result); err == nil || !styleOperator.MatchString(result) {
+		return result
+	}
+	branches := styleOr.Split(strings.TrimSpace(result), -1)
+	for i, branch := range branches {
+		parts := styleComma.Split(branch, -1)
+		for j, part := range parts {
+			parts[j] = styleOperator.ReplaceAllStringFunc(part, func(m string) string {
+				sub := styleOperator.FindStringSubmatch(m)
+				return sub[1] + m[len(m)-1:]
+			})
+		}
+		branches[i] = strings.Join(parts, ",")
+	}
+	return strings.Join(branches, " || ")
+}
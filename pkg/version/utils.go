@@ -5,34 +5,23 @@ import (
 	"strings"
 )
 
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-func max(a, b int) int {
-	if a > b {
-		return a
-	}
-	return b
-}
-
 func atoi(s string) int {
 	n, _ := strconv.Atoi(s)
 	return n
 }
 
-// normalizeVersionString ensures consistent formatting of version strings
+// normalizeVersionString ensures consistent formatting of version strings,
+// merging redundant branches of OR expressions
 func normalizeVersionString(version string) string {
+	version = joinSpaceAnds(version)
+
 	// Handle complex ranges with OR
 	if strings.Contains(version, "||") {
 		parts := strings.Split(version, "||")
 		for i, part := range parts {
 			parts[i] = normalizeVersionString(strings.TrimSpace(part))
 		}
-		return strings.Join(parts, " || ")
+		return simplifyConstraint(strings.Join(parts, " || "))
 	}
 
 	// Remove all spaces first
@@ -43,6 +32,7 @@ func normalizeVersionString(version string) string {
 	version = strings.ReplaceAll(version, "<=", "<= ")
 	version = strings.ReplaceAll(version, ">", "> ")
 	version = strings.ReplaceAll(version, "<", "< ")
+	version = strings.ReplaceAll(version, "!=", "!= ")
 	version = strings.ReplaceAll(version, ",", ", ")
 
 	// Fix any incorrect spacing
@@ -60,6 +50,9 @@ func normalizeVersionString(version string) string {
 
 // NormalizeVersionString ensures consistent formatting of version strings
 func NormalizeVersionString(version string) string {
+	// Comparisons joined by spaces are the same AND as comma-joined ones
+	version = joinSpaceAnds(version)
+
 	// Remove all spaces first
 	version = strings.ReplaceAll(version, " ", "")
 
@@ -1,6 +1,10 @@
 package version
 
 import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"strings"
 	"testing"
 
 	"github.com/Masterminds/semver/v3"
@@ -19,14 +23,36 @@ func TestParse(t *testing.T) {
 		{"v2.0.0", true, "2.0.0", false}, // 'v' prefix stripped by .String()
 		{"invalid_version", false, "", true},
 
+		// Explicit equality
+		{"= 1.2.3", true, "1.2.3", false},
+		{"=1.2.3", true, "1.2.3", false},
+
 		// Ranges (constraints)
 		{">=1.0.0,<2.0.0", false, "", false},
 		{"^1.5.0", false, "", false},
 		{"~>3.1.2", false, "", false},
 		{"~>3", false, "", false},
+		{"~>1.2, <1.5.0", false, "", false},
+		{">= v1.0.0, < v2.0.0", false, "", false},
+		{"~> v1.2", false, "", false},
+		{"~>0 0", false, "", false},
+		{"~>INVALID", false, "", true},
+		{"~>1.2.3junk", false, "", true},
+		{"~>1.x", false, "", true},
+		{"~>", false, "", true},
 
 		// Spaces in operators
 		{">= 1, < 2", false, "", false}, // after we remove spaces, => ">=1,<2"
+
+		// Ranges allowing no version
+		{">=1.2.3,<1.2.3", false, "", true},
+		{">2.0.0,<2.0.0", false, "", true},
+		{">2.0.0,<=2.0.0", false, "", true},
+		{">=2.0.0, <1.0.0", false, "", true},
+		{">= 2 < 2", false, "", true},
+		{"1.5.0, <1.0.0", false, "", true},
+		{">=1.0.0,<2.0.0 || >3.0.0,<3.0.0", false, "", true},
+		{">=1.2.3,<=1.2.3", false, "", false},
 	}
 
 	for _, tc := range tests {
@@ -58,6 +84,62 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParseVersionOrRangeEmpty(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr string
+	}{
+		{">2.0.0,<2.0.0", "range >2.0.0,<2.0.0 allows no version"},
+		{"~>1.2, <1.0", "range ~>1.2, <1.0 allows no version"},
+		{">=1.0.0,<2.0.0 || >=3.0.0,<3.0.0", "range >=1.0.0,<2.0.0 || >=3.0.0,<3.0.0 has a branch that allows no version: >=3.0.0,<3.0.0"},
+	}
+
+	for _, tc := range tests {
+		_, _, _, err := ParseVersionOrRange(tc.input)
+		if err == nil || err.Error() != tc.wantErr {
+			t.Errorf("input=%q got error %v, want %q", tc.input, err, tc.wantErr)
+		}
+	}
+
+	// An empty target range is reported instead of being written
+	if _, err := ApplyVersionStrategy(StrategyDynamic, ">=1.2.3,<1.2.3", "1.0.0"); err == nil || !strings.Contains(err.Error(), "allows no version") {
+		t.Errorf("ApplyVersionStrategy with an empty target: got error %v", err)
+	}
+}
+
+func FuzzParseVersionOrRange(f *testing.F) {
+	for _, seed := range []string{
+		"1.2.3", "= 1.2.3", "v2.0.0", ">=1.0.0,<2.0.0", ">= 1.0.0 < 2.0.0", "^1.5.0", "~1.2", "~>3.1.2",
+		"~>", "~> ", "~>1.2.3.4", "~>INVALID", "~>1.2.3junk", "~>1.2.3, <1.5.0", "~>1.2.3 || ~>2.0.0",
+		"||", "~>1 || || ~>2", ">=1 || <2 || ~>3 || =4 || !=5", "1.x", "*", "",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		isVer, ver, constr, err := ParseVersionOrRange(input)
+		switch {
+		case err != nil:
+			if isVer || ver != nil || constr != nil {
+				t.Fatalf("ParseVersionOrRange(%q) = %v, %v, %v with error %v", input, isVer, ver, constr, err)
+			}
+		case isVer:
+			if ver == nil || constr != nil {
+				t.Fatalf("ParseVersionOrRange(%q) is a version but returned %v, %v", input, ver, constr)
+			}
+		default:
+			if ver != nil || constr == nil {
+				t.Fatalf("ParseVersionOrRange(%q) is a range but returned %v, %v", input, ver, constr)
+			}
+			// Every accepted "~>" is expanded with Terraform's meaning, never left to the
+			// constraint parser's own reading of it
+			if expanded, err := ExpandTerraformTildeArrow(joinSpaceAnds(input)); err != nil || strings.Contains(expanded, "~>") {
+				t.Fatalf("ParseVersionOrRange(%q) accepted %q with expansion error %v", input, expanded, err)
+			}
+			constr.Check(semver.MustParse("1.0.0"))
+		}
+	})
+}
+
 func TestRangesOverlap(t *testing.T) {
 	cases := []struct {
 		a             string
@@ -69,6 +151,17 @@ func TestRangesOverlap(t *testing.T) {
 		{"~>3", ">=2.0.0,<4.0.0", true},
 		{"^1.2.3", "~1.2", true},
 		{">1.0.0 <1.2.0 || >=2.0.0 <2.1.0", "1.x", true},
+		// Excluded versions are not part of either range
+		{">=1.0.0, <2.0.0, !=1.5.0", ">=1.5.0, <=1.5.0", false},
+		{">=1.0.0, <2.0.0, !=1.5.0", ">=1.5.0, <=1.5.1", true},
+		{">=1.0.0, <=1.5.0", ">=1.5.0, <2.0.0, !=1.5.0", false},
+		{">=1.5.0, <=1.5.1, !=1.5.1", ">=1.5.1, <2.0.0", false},
+		// Overlaps at a single version or beyond the sampled points
+		{"=3.1.3", "<3.2.3", true},
+		{"~>2.1.1, <=2.1.1", "<2.2.0", true},
+		{">=1.3.3", "~>0.3.2 || =3.0.1", true},
+		{">=25.0.0", ">=24.0.0, <26.0.0", true},
+		{"^0.0.3", ">0.0.3", false},
 	}
 
 	for _, tc := range cases {
@@ -85,6 +178,158 @@ func TestRangesOverlap(t *testing.T) {
 	}
 }
 
+func TestRangeIntersection(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want string // empty when the ranges are disjoint
+	}{
+		{">=1.0.0,<2.0.0", ">=1.5.0,<2.5.0", ">= 1.5.0, < 2.0.0"},
+		{">=1.0.0,<2.0.0", ">=1.5.0,<1.6.0", ">= 1.5.0, < 1.6.0"},
+		{"^1.2.3", "~1.2", ">= 1.2.3, < 1.3.0"},
+		{">=2.0.0", "<3.0.0", ">= 2.0.0, < 3.0.0"},
+		{"<2.0.0", "<3.0.0", "< 2.0.0"},
+		{">=2.0.0", ">=1.0.0", ">= 2.0.0"},
+		{"=3.1.3", "<3.2.3", "3.1.3"},
+		{">=1.0.0, <=1.5.0", ">=1.5.0, <2.0.0", "1.5.0"},
+		{">=1.0.0, <2.0.0, !=1.5.0", ">=1.4.0, <1.6.0", ">= 1.4.0, < 1.5.0 || >= 1.5.1, < 1.6.0"},
+		{">1.0.0 <1.2.0 || >=2.0.0 <2.1.0", "1.x", ">= 1.0.1, < 1.2.0"},
+		{">=2.0.0-beta.1, <2.0.0-rc.1", ">=2.0.0-alpha.1", ">= 2.0.0-beta.1, < 2.0.0-rc.1"},
+		{">=2.0.0,<3.0.0", ">=3.0.0,<4.0.0", ""},
+		{"^0.0.3", ">0.0.3", ""},
+		{">=1.0.0, <2.0.0, !=1.5.0", ">=1.5.0, <=1.5.0", ""},
+	}
+
+	for _, tc := range cases {
+		aConstr, errA := semver.NewConstraint(tc.a)
+		bConstr, errB := semver.NewConstraint(tc.b)
+		if errA != nil || errB != nil {
+			t.Fatalf("parse error: a=%v errA=%v, b=%v errB=%v", tc.a, errA, tc.b, errB)
+		}
+		got, ok := RangeIntersection(aConstr, bConstr)
+		if tc.want == "" {
+			if ok {
+				t.Errorf("RangeIntersection(%q, %q) = %v, want no intersection", tc.a, tc.b, got)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("RangeIntersection(%q, %q) found no intersection, want %q", tc.a, tc.b, tc.want)
+			continue
+		}
+		if wantConstr, err := semver.NewConstraint(tc.want); err != nil || got.String() != wantConstr.String() {
+			t.Errorf("RangeIntersection(%q, %q) = %q, want %q", tc.a, tc.b, got, tc.want)
+		}
+	}
+
+	if _, ok := RangeIntersection(nil, nil); ok {
+		t.Error("RangeIntersection(nil, nil) reported an intersection")
+	}
+}
+
+func TestRangeIntersectionMatchesOracle(t *testing.T) {
+	r := rand.New(rand.NewSource(*overlapSeed))
+	for range 500 {
+		aInput, bInput := randomConstraint(r), randomConstraint(r)
+		a, errA := semver.NewConstraint(aInput)
+		b, errB := semver.NewConstraint(bInput)
+		if errA != nil || errB != nil {
+			continue
+		}
+		got, ok := RangeIntersection(a, b)
+		if ok != overlapOracle(a, b) {
+			t.Errorf("RangeIntersection(%q, %q) reported %v, oracle disagrees", aInput, bInput, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		// The intersection allows exactly the grid versions both constraints allow
+		for major := 0; major <= overlapGridMax; major++ {
+			for minor := 0; minor <= overlapGridMax; minor++ {
+				for patch := 0; patch <= overlapGridMax; patch++ {
+					v := semver.MustParse(fmt.Sprintf("%d.%d.%d", major, minor, patch))
+					if want := a.Check(v) && b.Check(v); got.Check(v) != want {
+						t.Errorf("RangeIntersection(%q, %q) = %q: allows %s is %v, want %v", aInput, bInput, got, v, !want, want)
+					}
+				}
+			}
+		}
+	}
+	if t.Failed() {
+		t.Logf("rerun with -overlap-seed=%d", *overlapSeed)
+	}
+}
+
+// overlapGridMax bounds the oracle grid: constraints name versions up to
+// overlapGridMax-1 in each part, so any overlap also shows on the grid
+const overlapGridMax = 4
+
+var overlapSeed = flag.Int64("overlap-seed", 1, "seed for the RangesOverlap property test")
+
+// overlapOracle reports whether some version on the grid 0.0.0..4.4.4 satisfies both
+// constraints, by checking every one of them
+func overlapOracle(a, b *semver.Constraints) bool {
+	for major := 0; major <= overlapGridMax; major++ {
+		for minor := 0; minor <= overlapGridMax; minor++ {
+			for patch := 0; patch <= overlapGridMax; patch++ {
+				v := semver.MustParse(fmt.Sprintf("%d.%d.%d", major, minor, patch))
+				if a.Check(v) && b.Check(v) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// randomConstraint returns a constraint of one to three OR branches, each of one or two
+// comparisons against versions below the grid bound
+func randomConstraint(r *rand.Rand) string {
+	ops := []string{"=", "!=", ">", ">=", "<", "<=", "~", "^", "~>"}
+	var branches []string
+	for range 1 + r.Intn(3) {
+		var parts []string
+		for range 1 + r.Intn(2) {
+			parts = append(parts, fmt.Sprintf("%s%d.%d.%d", ops[r.Intn(len(ops))], r.Intn(overlapGridMax), r.Intn(overlapGridMax), r.Intn(overlapGridMax)))
+		}
+		branches = append(branches, strings.Join(parts, ", "))
+	}
+	return strings.Join(branches, " || ")
+}
+
+// checkOverlapAgainstOracle compares RangesOverlap with the oracle for one random pair
+func checkOverlapAgainstOracle(t *testing.T, r *rand.Rand) {
+	t.Helper()
+	aInput, bInput := randomConstraint(r), randomConstraint(r)
+	a, errA := semver.NewConstraint(aInput)
+	b, errB := semver.NewConstraint(bInput)
+	if errA != nil || errB != nil {
+		t.Fatalf("parse error: a=%q errA=%v, b=%q errB=%v", aInput, errA, bInput, errB)
+	}
+	if got, want := RangesOverlap(a, b), overlapOracle(a, b); got != want {
+		t.Errorf("RangesOverlap(%q, %q) = %v, oracle says %v", aInput, bInput, got, want)
+	}
+}
+
+func TestRangesOverlapMatchesOracle(t *testing.T) {
+	r := rand.New(rand.NewSource(*overlapSeed))
+	for range 2000 {
+		checkOverlapAgainstOracle(t, r)
+	}
+	if t.Failed() {
+		t.Logf("rerun with -overlap-seed=%d", *overlapSeed)
+	}
+}
+
+func FuzzRangesOverlap(f *testing.F) {
+	for seed := range int64(8) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		checkOverlapAgainstOracle(t, rand.New(rand.NewSource(seed)))
+	})
+}
+
 func TestDecideVersionOrRange(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -121,6 +366,33 @@ func TestDecideVersionOrRange(t *testing.T) {
 		{"dynamic: backward protection - range with higher minimum", ">= 3.2.2, < 4", "3.2.1", ">= 3.2.2, < 4"},
 		{"dynamic: backward protection - range with higher minimum (complex)", ">= 3.2.0, < 4.0.0", "3.0.0", ">= 3.2.0, < 4.0.0"},
 		{"dynamic: backward protection - range with same minimum", ">= 3.2.0, < 4.0.0", "3.2.0", ">= 3.2.0, < 4.0.0"},
+
+		// Open-ended ranges have no real maximum
+		{"open-ended: target raises the minimum", ">= 1.0.0", ">= 2.0.0, < 3.0.0", ">= 2.0.0, < 3.0.0"},
+		{"open-ended: open target raises the minimum", ">= 1.0.0", ">= 2.0.0", ">= 2.0.0"},
+		{"open-ended: target with the same minimum overlaps", ">= 1.0.0", ">= 1.0.0, < 2.0.0", ">= 1.0.0"},
+		{"open-ended: target below the minimum", ">= 2.0.0", ">= 1.0.0, < 3.0.0", ">= 2.0.0"},
+		{"open-ended: bounded existing above open target", ">= 3.0.0, < 4.0.0", ">= 1.0.0", ">= 3.0.0, < 4.0.0"},
+		{"open-ended: existing contains exact target", ">= 1.0.0", "2.0.0", ">= 1.0.0"},
+		{"open-ended: exact above open target", "25.0.0", ">= 2.0.0", "25.0.0"},
+
+		// OR chains are sets of intervals: bounds are compared on their highest branches, not
+		// their global min and max, and containment and overlap are checked per branch
+		{"or chain: target's highest branch is newer despite a lower minimum", ">=3.0.0,<4.0.0", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0"},
+		{"or chain: overlap in lower branches keeps existing", ">=1.0.0,<2.0.0 || >=3.0.0,<4.0.0", ">=1.5.0,<2.0.0 || >=6.0.0,<7.0.0", ">=1.0.0,<2.0.0 || >=3.0.0,<4.0.0"},
+		{"or chain: target inside lower existing branch", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0", ">=1.2.0,<1.5.0", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0"},
+		{"or chain: target branches inside different existing branches", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0", ">=1.2.0,<1.5.0 || >=5.1.0,<5.5.0", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0"},
+		{"or chain: target straddling a gap between existing branches", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0", ">=1.5.0,<5.5.0", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0"},
+		{"or chain: target above every existing branch", ">=1.0.0,<2.0.0 || >=3.0.0,<4.0.0", ">=5.0.0,<6.0.0 || >=7.0.0,<8.0.0", ">=5.0.0,<6.0.0 || >=7.0.0,<8.0.0"},
+		{"or chain: existing highest branch above target", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0", ">=3.0.0,<4.0.0", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0"},
+		{"or chain: highest branches overlap", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0", ">=5.0.0,<7.0.0", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0"},
+
+		// Exclusions in the existing or target range
+		{"exclusion: existing version excluded by target", "1.5.0", ">=1.0.0,<2.0.0,!=1.5.0", ">=1.0.0,<2.0.0,!=1.5.0"},
+		{"exclusion: existing version not excluded", "1.6.0", ">=1.0.0,<2.0.0,!=1.5.0", "1.6.0"},
+		{"exclusion: existing range excludes target", ">=1.0.0,<2.0.0,!=1.5.0", "1.5.0", ">=1.0.0,<2.0.0,!=1.5.0"},
+		{"exclusion: stepped minimum overlaps target", ">=1.5.0,<1.6.0,!=1.5.0", ">=1.5.1,<2.0.0", ">=1.5.0,<1.6.0,!=1.5.0"},
+		{"exclusion: higher minimum after stepping", ">=1.5.0,<2.0.0,!=1.5.0,!=1.5.1", ">=1.5.1,<2.0.0", ">=1.5.0,<2.0.0,!=1.5.0,!=1.5.1"},
 	}
 
 	for _, tc := range tests {
@@ -143,70 +415,889 @@ func TestDecideVersionOrRange(t *testing.T) {
 	}
 }
 
+func TestDecideVersionOrRangeOrChainReasons(t *testing.T) {
+	tests := []struct {
+		name       string
+		oldInput   string
+		newInput   string
+		want       string
+		wantReason string
+	}{
+		{"target inside lower existing branch", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0", ">=1.2.0,<1.5.0", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0", "kept existing: range contains target"},
+		{"target chain inside existing chain", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0", ">=1.2.0,<1.5.0 || >=5.1.0,<5.5.0", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0", "kept existing: range contains target"},
+		{"target partly outside lower existing branch", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0", ">=1.5.0,<2.5.0", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0", "kept existing: higher minimum bound (backward protection)"},
+		{"lower target branch overlaps existing", ">=3.0.0,<4.0.0", ">=3.5.0,<4.0.0 || >=6.0.0,<7.0.0", ">=3.0.0,<4.0.0", "kept existing: ranges overlap"},
+		{"no branch overlaps", ">=3.0.0,<4.0.0", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0", ">=1.0.0,<2.0.0 || >=5.0.0,<6.0.0", "used target: ranges do not overlap"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			oldIsVer, oldVer, oldRange, err := ParseVersionOrRange(tc.oldInput)
+			if err != nil {
+				t.Fatalf("parse old=%q error: %v", tc.oldInput, err)
+			}
+			newIsVer, newVer, newRange, err := ParseVersionOrRange(tc.newInput)
+			if err != nil {
+				t.Fatalf("parse new=%q error: %v", tc.newInput, err)
+			}
+
+			got, reason := DecideVersionOrRangeWithReason(oldIsVer, oldVer, oldRange, tc.oldInput,
+				newIsVer, newVer, newRange, tc.newInput)
+			if got != tc.want || reason != tc.wantReason {
+				t.Errorf("got %q (%s), want %q (%s)", got, reason, tc.want, tc.wantReason)
+			}
+		})
+	}
+}
+
+func TestApplyVersionStrategyWithReason(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategy   Strategy
+		target     string
+		existing   string
+		opts       StrategyOptions
+		want       string
+		wantReason string
+	}{
+		{"dynamic: backward protection", StrategyDynamic, "1.0.0", "2.0.0", StrategyOptions{}, "2.0.0", "kept existing: higher version (backward protection)"},
+		{"dynamic: upgrade", StrategyDynamic, "2.0.0", "1.0.0", StrategyOptions{}, "2.0.0", "used target: not lower than existing version"},
+		{"dynamic: higher minimum bound", StrategyDynamic, "3.2.1", ">= 3.2.2, < 4", StrategyOptions{}, ">= 3.2.2, < 4", "kept existing: higher minimum bound (backward protection)"},
+		{"dynamic: range contains target", StrategyDynamic, "1.5.0", ">=1.0.0,<2.0.0", StrategyOptions{}, ">= 1.0.0, < 2.0.0", "kept existing: range contains target"},
+		{"dynamic: range contains target range", StrategyDynamic, ">=1.5.0,<1.8.0", ">=1.0.0,<2.0.0", StrategyOptions{}, ">= 1.0.0, < 2.0.0", "kept existing: range contains target"},
+		{"exact: no existing version", StrategyExact, "1.2.3", "", StrategyOptions{}, "1.2.3", "used target: no existing version"},
+		{"exact: raised to min_version", StrategyExact, "1.0.0", "", StrategyOptions{MinVersion: "1.5.0"}, "1.5.0", "used target: no existing version; raised to min_version 1.5.0"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, reason, err := ApplyVersionStrategyWithReason(tc.strategy, tc.target, tc.existing, tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+			if reason != tc.wantReason {
+				t.Errorf("got reason %q, want %q", reason, tc.wantReason)
+			}
+		})
+	}
+}
+
+func TestIsProtectedReason(t *testing.T) {
+	tests := []struct {
+		reason string
+		want   bool
+	}{
+		{"kept existing: higher version (backward protection)", true},
+		{"kept existing: higher minimum bound (backward protection)", true},
+		{"kept existing: higher pre-1.0 minimum bound (backward protection)", true},
+		{"kept existing: range contains target", false},
+		{"kept existing: ranges overlap", false},
+		{"kept existing: minor bump blocked (minor_lock)", false},
+		{"used target: not lower than existing version", false},
+	}
+
+	for _, tc := range tests {
+		if got := IsProtectedReason(tc.reason); got != tc.want {
+			t.Errorf("IsProtectedReason(%q) = %v, want %v", tc.reason, got, tc.want)
+		}
+	}
+}
+
 func TestExpandTerraformTildeArrow(t *testing.T) {
 	tests := []struct {
 		input    string
 		expected string
 	}{
-		{"~>1.2.3", ">=1.2.3, <2.0.0"},
+		{"~>1.2.3", ">=1.2.3, <1.3.0"},
+		{"~>1.2", ">=1.2.0, <2.0.0"},
 		{"~>2.0", ">=2.0.0, <3.0.0"},
 		{"~>3", ">=3.0.0, <4.0.0"},
 		{"1.2.3", "1.2.3"},
 		{">=1.0.0", ">=1.0.0"},
-		{"~>1.2.3 || ~>2.0.0", ">=1.2.3, <2.0.0 || >=2.0.0, <3.0.0"},
+		{"~>1.2.3 || ~>2.0.0", ">=1.2.3, <1.3.0 || >=2.0.0, <2.1.0"},
+		{"", ""},
+		{"~>1.2, <1.5.0", ">=1.2.0, <2.0.0, <1.5.0"},
+		{">=1.2.5, ~> 1.2", ">=1.2.5, >=1.2.0, <2.0.0"},
+	}
+
+	for _, tc := range tests {
+		got, err := ExpandTerraformTildeArrow(tc.input)
+		if err != nil {
+			t.Errorf("ExpandTerraformTildeArrow(%q) unexpected error: %v", tc.input, err)
+			continue
+		}
+		if got != tc.expected {
+			t.Errorf("ExpandTerraformTildeArrow(%q) = %q, want %q", tc.input, got, tc.expected)
+		}
+	}
+
+	for _, input := range []string{"~>INVALID", "~>1.2.3junk", "~>", ">=1.0.0 || ~>1.2.3.4"} {
+		if got, err := ExpandTerraformTildeArrow(input); err == nil || !strings.Contains(err.Error(), "invalid tilde-arrow version") {
+			t.Errorf("ExpandTerraformTildeArrow(%q) = %q, %v, want an invalid tilde-arrow version error", input, got, err)
+		}
+	}
+}
+
+func TestBuildRangeFromTildePart(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.2.3", ">=1.2.3, <1.3.0"},
+		{"1.2", ">=1.2.0, <2.0.0"},
+		{"0.2.3", ">=0.2.3, <0.3.0"},
+		{"1.2.3-beta.1", ">=1.2.3, <1.3.0"},
+		{"2.0", ">=2.0.0, <3.0.0"},
+		{"3", ">=3.0.0, <4.0.0"},
 		{"", ""},
-		{"~>INVALID", ">=0.0.0, <1.0.0"},
+		{"1.2.3.4", ""},
+		{"1.2.3junk", ""},
+		{" 1.2.3 ", ">=1.2.3, <1.3.0"}, // test trimming
+	}
+
+	for _, tc := range tests {
+		got, err := buildRangeFromTildePart(tc.input)
+		if tc.expected == "" {
+			if err == nil {
+				t.Errorf("buildRangeFromTildePart(%q) = %q, want error", tc.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("buildRangeFromTildePart(%q) unexpected error: %v", tc.input, err)
+			continue
+		}
+		if got != tc.expected {
+			t.Errorf("buildRangeFromTildePart(%q) = %q, want %q", tc.input, got, tc.expected)
+		}
+	}
+}
+
+func TestReadToken(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedToken  string
+		expectedRemain string
+	}{
+		{"1.2.3", "1.2.3", ""},
+		{"1.2.3 || 2.0.0", "1.2.3", " || 2.0.0"},
+		{"1.2.3,2.0.0", "1.2.3", ",2.0.0"},
+		{"1.2.3 2.0.0", "1.2.3", " 2.0.0"},
+		{"", "", ""},
+		{" 1.2.3", "", " 1.2.3"},
+		{"1.2.3||2.0.0", "1.2.3", "||2.0.0"},
+	}
+
+	for _, tc := range tests {
+		gotToken, gotRemain := readToken(tc.input)
+		if gotToken != tc.expectedToken || gotRemain != tc.expectedRemain {
+			t.Errorf("readToken(%q) = (%q, %q), want (%q, %q)",
+				tc.input, gotToken, gotRemain, tc.expectedToken, tc.expectedRemain)
+		}
+	}
+}
+
+func TestSimplifyConstraint(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{">=1.0.0,<2.0.0 || >=1.5.0,<1.8.0", ">= 1.0.0, < 2.0.0"},
+		{">=1.5.0,<1.8.0 || >=1.0.0,<2.0.0", ">= 1.0.0, < 2.0.0"},
+		{">=1,<2 || >=2,<3", ">= 1.0.0, < 3.0.0"},
+		{">=1.0.0,<2.0.0 || >=1.5.0,<3.0.0", ">= 1.0.0, < 3.0.0"},
+		{">=1.0.0 <2.0.0 || >=1.5.0 <3.0.0", ">= 1.0.0, < 3.0.0"},
+		{">=1.0.0,<2.0.0 || 1.2.3", ">= 1.0.0, < 2.0.0"},
+		{">=1.0.0,<=2.0.0 || >2.0.0,<3.0.0", ">= 1.0.0, < 3.0.0"},
+		{">=1.0.0,<2.0.0 || >=1.5.0", ">= 1.0.0"},
+		{">=1.0.0,<2.0.0 || >=2.0.0,<3.0.0 || >=5.0.0,<6.0.0", ">= 1.0.0, < 3.0.0 || >= 5.0.0, < 6.0.0"},
+		// Disjoint branches are left untouched
+		{">= 1.0.0, < 2.0.0 || >= 3.0.0, < 4.0.0", ">= 1.0.0, < 2.0.0 || >= 3.0.0, < 4.0.0"},
+		// Exclusive bounds that meet at a single version do not merge
+		{">= 1.0.0, < 2.0.0 || > 2.0.0, < 3.0.0", ">= 1.0.0, < 2.0.0 || > 2.0.0, < 3.0.0"},
+		// Branches that are not simple intervals are left untouched
+		{">= 1.0.0-beta.1, < 2.0.0 || >= 1.5.0, < 1.8.0", ">= 1.0.0-beta.1, < 2.0.0 || >= 1.5.0, < 1.8.0"},
+		{"~>1.2 || >= 1.5.0, < 1.8.0", "~>1.2 || >= 1.5.0, < 1.8.0"},
+		{">= 1.0.0, < 2.0.0", ">= 1.0.0, < 2.0.0"},
+	}
+
+	for _, tc := range tests {
+		got := simplifyConstraint(tc.input)
+		if got != tc.expected {
+			t.Errorf("simplifyConstraint(%q) = %q, want %q", tc.input, got, tc.expected)
+		}
+	}
+
+	// The normalization pass applies the simplification to OR expressions
+	if got := normalizeVersionString(">=1.0.0,<2.0.0 || >=1.5.0,<1.8.0"); got != ">= 1.0.0, < 2.0.0" {
+		t.Errorf("normalizeVersionString did not collapse redundant branches, got %q", got)
+	}
+}
+
+func TestGetMinVersionFromConstraint(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// 0.0.x lower bounds are read as is rather than rounded to a minor or major boundary
+		{">=0.0.5, <0.0.9", "0.0.5"},
+		{">=0.0.2,<0.0.4", "0.0.2"},
+		{">0.0.4, <0.1.0", "0.0.5"},
+		{">= 0.0.1 <0.0.3", "0.0.1"},
+		{"~0.0.7", "0.0.7"},
+		{"^0.0.3", "0.0.3"},
+		{"0.0.6", "0.0.6"},
+		{">=0.0.8 || >=0.0.3, <0.0.5", "0.0.3"},
+		{">=0.0.2, >=0.0.6, <0.1.0", "0.0.6"},
+		// Build metadata is kept
+		{">=0.0.5+build.1, <0.1.0", "0.0.5+build.1"},
+		// Bounds above 1.0.0 and missing lower bounds
+		{">=2.3.4, <3.0.0", "2.3.4"},
+		{">1.2", "1.2.1"},
+		{"1.x", "1.0.0"},
+		{"<0.0.9", "0.0.0"},
+		{"*", "0.0.0"},
+	}
+
+	for _, tc := range tests {
+		c, err := semver.NewConstraint(tc.input)
+		if err != nil {
+			t.Fatalf("invalid test constraint %q: %v", tc.input, err)
+		}
+		got, err := getMinVersionFromConstraint(c)
+		if err != nil {
+			t.Errorf("getMinVersionFromConstraint(%q) error: %v", tc.input, err)
+			continue
+		}
+		if got.Original() != tc.expected && got.String() != tc.expected {
+			t.Errorf("getMinVersionFromConstraint(%q) = %q, want %q", tc.input, got.Original(), tc.expected)
+		}
+	}
+
+	// A pre-1.0 branch of an OR range converts to its exact minimum
+	if got, err := ConvertToRangeVersion(">=0.0.5, <0.0.9 || >=2.0.0, <3.0.0"); err != nil || got != "0.0.5" {
+		t.Errorf("ConvertToRangeVersion() = %q, %v, want \"0.0.5\"", got, err)
+	}
+}
+
+func TestFindLowestVersionInRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// Pre-release and build metadata of the lower bound are returned verbatim
+		{">=1.2.0-beta.1+build,<2.0.0", "1.2.0-beta.1+build"},
+		{">=1.2.0-beta.1, <2.0.0", "1.2.0-beta.1"},
+		{">=0.5.0-beta.1+build.7, <0.6.0", "0.5.0-beta.1+build.7"},
+		{">=2.0.0+build.3", "2.0.0+build.3"},
+		{">=1.0.0-rc.1 <1.1.0 || >=2.0.0", "1.0.0-rc.1"},
+		// Plain bounds
+		{">=1.2.3, <2.0.0", "1.2.3"},
+		{">1.2.3", "1.2.4"},
+		{"<2.0.0", "0.0.0"},
+		// Excluded bounds step up to the next included version
+		{">=1.5.0, <2.0.0, !=1.5.0", "1.5.1"},
+		{">1.4.0, <2.0.0, !=1.4.1", "1.4.2"},
+		{">=1.5.0, <=1.5.0, !=1.5.0 || >=3.0.0, <4.0.0", "3.0.0"},
+	}
+
+	for _, tc := range tests {
+		c, err := semver.NewConstraint(tc.input)
+		if err != nil {
+			t.Fatalf("invalid test constraint %q: %v", tc.input, err)
+		}
+		got := findLowestVersionInRange(c)
+		if got == nil {
+			t.Errorf("findLowestVersionInRange(%q) = nil, want %q", tc.input, tc.expected)
+			continue
+		}
+		if got.Original() != tc.expected && got.String() != tc.expected {
+			t.Errorf("findLowestVersionInRange(%q) = %q, want %q", tc.input, got.Original(), tc.expected)
+		}
+	}
+
+	// The range strategy converts a pre-1.0 range to its exact lower bound, metadata included
+	if got, err := ApplyRangeStrategy(">=0.5.0-beta.1+build, <0.6.0", "0.4.0"); err != nil || got != "0.5.0-beta.1+build" {
+		t.Errorf("ApplyRangeStrategy() = %q, %v, want \"0.5.0-beta.1+build\"", got, err)
+	}
+}
+
+func TestApplyVersionStrategyEqualityOperator(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		target   string
+		existing string
+		want     string
+	}{
+		{"exact: higher target", StrategyExact, "2.0.0", "= 1.2.3", "2.0.0"},
+		{"exact: lower target keeps existing as written", StrategyExact, "1.0.0", "= 1.2.3", "= 1.2.3"},
+		{"exact: equality target", StrategyExact, "= 2.0.0", "1.2.3", "2.0.0"},
+		{"dynamic: higher target", StrategyDynamic, "2.0.0", "= 1.2.3", "2.0.0"},
+		{"dynamic: lower target keeps existing as written", StrategyDynamic, "1.0.0", "=1.2.3", "=1.2.3"},
+		{"dynamic: existing fits target range", StrategyDynamic, ">=1.0.0,<2.0.0", "= 1.2.3", "= 1.2.3"},
+		{"range: higher target", StrategyRange, "2.0.0", "= 1.2.3", ">= 2.0.0, < 3.0.0"},
+		{"range: same as bare existing", StrategyRange, "1.5.0", "= 1.2.3", ">= 1.5.0, < 2.0.0"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ApplyVersionStrategy(tc.strategy, tc.target, tc.existing)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyVersionStrategyKeepsExclusions(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		target   string
+		existing string
+		want     string
+	}{
+		{"dynamic: canonical exclusion is kept byte for byte", StrategyDynamic, "1.2.0", ">= 1.0.0, < 2.0.0, != 1.5.0", ">= 1.0.0, < 2.0.0, != 1.5.0"},
+		{"dynamic: compact exclusion is spaced like the other operators", StrategyDynamic, "1.2.0", ">=1.0.0,<2.0.0,!=1.5.0", ">= 1.0.0, < 2.0.0, != 1.5.0"},
+		{"dynamic: overlapping target range", StrategyDynamic, ">=1.1.0,<2.0.0", ">= 1.0.0, < 2.0.0, != 1.5.0", ">= 1.0.0, < 2.0.0, != 1.5.0"},
+		{"range: exclusion in an OR branch", StrategyRange, "1.2.0", ">=1.0.0,<2.0.0,!=1.5.0 || >=3.0.0,<4.0.0", ">= 1.0.0, < 2.0.0, != 1.5.0 || >= 3.0.0, < 4.0.0"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ApplyVersionStrategy(tc.strategy, tc.target, tc.existing)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+
+			// The kept constraint allows exactly the versions the existing one does
+			before, err := semver.NewConstraint(tc.existing)
+			if err != nil {
+				t.Fatalf("invalid test constraint %q: %v", tc.existing, err)
+			}
+			after, err := semver.NewConstraint(got)
+			if err != nil {
+				t.Fatalf("result %q is not a valid constraint: %v", got, err)
+			}
+			for major := 0; major <= 4; major++ {
+				for minor := 0; minor <= 9; minor++ {
+					v := semver.MustParse(fmt.Sprintf("%d.%d.0", major, minor))
+					if before.Check(v) != after.Check(v) {
+						t.Errorf("%s: existing allows it = %v, result allows it = %v", v, before.Check(v), after.Check(v))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParseVersionOrRangeVPrefixedBounds(t *testing.T) {
+	isVer, _, c, err := ParseVersionOrRange(">= v1.0.0, < v2.0.0")
+	if err != nil || isVer {
+		t.Fatalf("ParseVersionOrRange = %v, %v", isVer, err)
+	}
+	for v, want := range map[string]bool{"0.9.0": false, "1.0.0": true, "1.5.0": true, "2.0.0": false} {
+		if got := c.Check(semver.MustParse(v)); got != want {
+			t.Errorf("%s satisfies the range: got %v, want %v", v, got, want)
+		}
+	}
+
+	// The bounds are read as intervals, so range_merge applies to them
+	if got, err := ApplyVersionStrategyWithOptions(StrategyRange, ">=1.5.0,<2.5.0", ">= v1.0.0, < v2.0.0", StrategyOptions{RangeMerge: RangeMergeIntersect}); err != nil || got != ">= 1.5.0, < 2.0.0" {
+		t.Errorf("intersection with a v-prefixed range = %q, %v", got, err)
+	}
+}
+
+func TestApplyVersionStrategyVPrefix(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		target   string
+		existing string
+		want     string
+	}{
+		{"exact: upgraded", StrategyExact, "2.0.0", "v1.2.3", "v2.0.0"},
+		{"exact: lower target keeps existing", StrategyExact, "1.0.0", "v1.2.3", "v1.2.3"},
+		{"exact: v target without v existing", StrategyExact, "v2.0.0", "1.2.3", "2.0.0"},
+		{"dynamic: upgraded", StrategyDynamic, "2.0.0", "v1.2.3", "v2.0.0"},
+		{"dynamic: existing fits target range", StrategyDynamic, ">=1.0.0,<2.0.0", "v1.2.3", "v1.2.3"},
+		{"patch_only: upgraded", StrategyPatchOnly, "1.2.5", "v1.2.3", "v1.2.5"},
+		{"major_lock: upgraded", StrategyMajorLock, "1.5.0", "v1.2.3", "v1.5.0"},
+		{"range: result is a range", StrategyRange, "2.0.0", "v1.2.3", ">= 2.0.0, < 3.0.0"},
+		{"exact: range existing", StrategyExact, "3.0.0", ">= v1.2.0", "3.0.0"},
+		{"dynamic: v range existing kept", StrategyDynamic, ">=1.5.0,<2.0.0", ">= v1.0.0, < v2.0.0", ">= v1.0.0, < v2.0.0"},
+		{"dynamic: v range existing updated", StrategyDynamic, ">=3.0.0,<4.0.0", ">= v1.0.0, < v2.0.0", ">= 3.0.0, < 4.0.0"},
+		{"dynamic: v range target", StrategyDynamic, ">= v2.0.0, < v3.0.0", "1.0.0", ">= 2.0.0, < 3.0.0"},
+		{"range: v range existing contains target", StrategyRange, "1.5.0", ">=v1.0.0,<v2.0.0", ">=v1.0.0,<v2.0.0"},
+		{"range: v range existing updated", StrategyRange, "3.0.0", ">= v1.0.0, < v2.0.0", ">= 3.0.0, < 4.0.0"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ApplyVersionStrategy(tc.strategy, tc.target, tc.existing)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyVersionStrategySpaceJoinedAnd(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		target   string
+		existing string
+		want     string
+	}{
+		{"range: same range keeps existing as written", StrategyRange, ">= 1.0.0, < 2.0.0", ">= 1.0.0 < 2.0.0", ">= 1.0.0 < 2.0.0"},
+		{"range: version inside keeps existing as written", StrategyRange, "1.5.0", ">=1.0.0 <2.0.0", ">=1.0.0 <2.0.0"},
+		{"range: higher target", StrategyRange, "2.5.0", ">= 1.0.0 < 2.0.0", ">= 2.5.0, < 3.0.0"},
+		{"dynamic: overlapping target keeps existing as written", StrategyDynamic, "~> 1.0", ">= 1.0.0 < 2.0.0", ">= 1.0.0 < 2.0.0"},
+		{"dynamic: version inside keeps existing as written", StrategyDynamic, "1.5.0", ">= 1.0.0 < 2.0.0", ">= 1.0.0 < 2.0.0"},
+		{"dynamic: space-joined target", StrategyDynamic, ">= 3.0.0 < 4.0.0", ">= 1.0.0 < 2.0.0", ">= 3.0.0, < 4.0.0"},
+		{"dynamic: or of space-joined branches", StrategyDynamic, "1.5.0", ">= 1.0.0 < 2.0.0 || >= 3.0.0 < 4.0.0", ">= 1.0.0 < 2.0.0 || >= 3.0.0 < 4.0.0"},
+		{"exact: replaces range", StrategyExact, "1.5.0", ">= 1.0.0 < 2.0.0", "1.5.0"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ApplyVersionStrategy(tc.strategy, tc.target, tc.existing)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	if got, want := NormalizeVersionString(">= 1.0.0 < 2.0.0"), NormalizeVersionString(">= 1.0.0, < 2.0.0"); got != want {
+		t.Errorf("NormalizeVersionString of space-joined range = %q, want %q", got, want)
+	}
+}
+
+func TestFindLowerBound(t *testing.T) {
+	tests := []struct {
+		input         string
+		wantVersion   string
+		wantInclusive bool
+	}{
+		{">=1.0.0", "1.0.0", true},
+		{">1.0.0", "1.0.0", false},
+		{">1.0.0, <2.0.0", "1.0.0", false},
+		{">1.0.0-rc.1", "1.0.0-rc.1", false},
+		// Of equal bounds the exclusive one applies within a group and the inclusive one
+		// across groups
+		{">=1.0.0, >1.0.0", "1.0.0", false},
+		{">1.0.0 || >=1.0.0", "1.0.0", true},
+		// A bound whose next patch is excluded reads as the lowest included version
+		{">1.0.0, !=1.0.1", "1.0.2", true},
+		{"<2.0.0", "0.0.0", true},
+	}
+
+	for _, tc := range tests {
+		c, err := semver.NewConstraint(tc.input)
+		if err != nil {
+			t.Fatalf("invalid test constraint %q: %v", tc.input, err)
+		}
+		got := findLowerBound(c)
+		if got == nil {
+			t.Errorf("findLowerBound(%q) = nil, want %s (inclusive %v)", tc.input, tc.wantVersion, tc.wantInclusive)
+			continue
+		}
+		if got.version.Original() != tc.wantVersion || got.inclusive != tc.wantInclusive {
+			t.Errorf("findLowerBound(%q) = %s (inclusive %v), want %s (inclusive %v)", tc.input, got.version.Original(), got.inclusive, tc.wantVersion, tc.wantInclusive)
+		}
+	}
+}
+
+func TestApplyVersionStrategyLowerBoundStrictness(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategy   Strategy
+		target     string
+		existing   string
+		want       string
+		wantReason string
+	}{
+		// ">1.0.0" starts above ">=1.0.0": it is kept over a target starting at 1.0.0
+		{"dynamic: exclusive existing kept over inclusive target", StrategyDynamic, ">=1.0.0,<2.0.0", ">1.0.0", "> 1.0.0", "kept existing: higher minimum bound (backward protection)"},
+		{"dynamic: inclusive existing kept as containing the target", StrategyDynamic, ">=1.0.0,<2.0.0", ">=1.0.0", ">= 1.0.0", "kept existing: range contains target"},
+		// and an exclusive target raises the minimum of ">=1.0.0" only
+		{"dynamic: exclusive target over exclusive existing", StrategyDynamic, ">1.0.0,<2.0.0", ">1.0.0", "> 1.0.0", "kept existing: range contains target"},
+		{"dynamic: exclusive target over inclusive existing", StrategyDynamic, ">1.0.0,<2.0.0", ">=1.0.0", "> 1.0.0, < 2.0.0", "used target: raises the minimum of an open-ended range"},
+		// ">1.0.0" starts below a 1.0.1 pre-release rather than at the grid point 1.0.1
+		{"dynamic: pre-release target raises an exclusive minimum", StrategyDynamic, ">=1.0.1-rc.1,<2.0.0", ">1.0.0", ">= 1.0.1-rc.1, < 2.0.0", "used target: raises the minimum of an open-ended range"},
+		{"dynamic: pre-release target raises an inclusive minimum", StrategyDynamic, ">=1.0.1-rc.1,<2.0.0", ">=1.0.0", ">= 1.0.1-rc.1, < 2.0.0", "used target: raises the minimum of an open-ended range"},
+		// An exact target at the bound is below ">1.0.0" and inside ">=1.0.0"
+		{"dynamic: exact target at an exclusive bound", StrategyDynamic, "1.0.0", ">1.0.0", "> 1.0.0", "kept existing: higher minimum bound (backward protection)"},
+		{"dynamic: exact target at an inclusive bound", StrategyDynamic, "1.0.0", ">=1.0.0", ">= 1.0.0", "kept existing: range contains target"},
+		{"range: pre-release target above an exclusive bound", StrategyRange, "1.0.1-rc.1", ">1.0.0", ">= 1.0.1-rc.1, < 2.0.0", "used target: range strategy"},
+		{"range: exact target at an exclusive bound", StrategyRange, "1.0.0", ">1.0.0", "> 1.0.0", "kept existing: range strategy"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, reason, err := ApplyVersionStrategyWithReason(tc.strategy, tc.target, tc.existing, StrategyOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want || reason != tc.wantReason {
+				t.Errorf("got %q (%s), want %q (%s)", got, reason, tc.want, tc.wantReason)
+			}
+		})
+	}
+}
+
+func TestBumpVersion(t *testing.T) {
+	tests := []struct {
+		existing string
+		level    BumpLevel
+		want     string
+		wantErr  string
+	}{
+		{existing: "1.2.3", level: BumpMajor, want: "2.0.0"},
+		{existing: "1.2.3", level: BumpMinor, want: "1.3.0"},
+		{existing: "1.2.3", level: BumpPatch, want: "1.2.4"},
+		{existing: "v1.2.3", level: BumpMinor, want: "v1.3.0"},
+		{existing: "= 1.2.3", level: BumpMinor, want: "= 1.3.0"},
+		// Ranges bump their lower bound, written with as many components as before
+		{existing: "~> 1.2", level: BumpMinor, want: "~> 1.3"},
+		{existing: "~> 1.2.3", level: BumpPatch, want: "~> 1.2.4"},
+		{existing: ">= 1.2.0, < 2.0.0", level: BumpMinor, want: ">= 1.3.0, < 2.0.0"},
+		{existing: "^1.2", level: BumpMajor, want: "^2.0"},
+		{existing: ">1.2.3", level: BumpPatch, want: ">1.2.4"},
+		{existing: "~> 1.2", level: BumpPatch, wantErr: "has no patch component"},
+		{existing: ">= 1.2.0, < 2.0.0", level: BumpMajor, wantErr: "allows no version"},
+		{existing: ">= 1.0.0, < 2.0.0 || >= 3.0.0", level: BumpMinor, wantErr: "expected one lower bound, found 2"},
+		{existing: "< 2.0.0", level: BumpMinor, wantErr: "expected one lower bound, found 0"},
+		{existing: "", level: BumpMinor, wantErr: "no version to bump"},
+		{existing: "1.2.3", level: "build", wantErr: "invalid bump level 'build'"},
+	}
+
+	for _, tc := range tests {
+		got, err := BumpVersion(tc.existing, tc.level)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("BumpVersion(%q, %s) = %q, %v, want an error containing %q", tc.existing, tc.level, got, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("BumpVersion(%q, %s) = %q, %v, want %q", tc.existing, tc.level, got, err, tc.want)
+		}
+	}
+}
+
+func TestApplyVersionStrategyRangeMerge(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategy   Strategy
+		target     string
+		existing   string
+		merge      RangeMerge
+		want       string
+		wantReason string
+	}{
+		{"range: intersect overlapping ranges", StrategyRange, ">=1.5.0,<2.5.0", ">=1.0.0,<2.0.0", RangeMergeIntersect, ">= 1.5.0, < 2.0.0", "merged: intersection of existing and target ranges"},
+		{"dynamic: intersect overlapping ranges", StrategyDynamic, ">=1.5.0,<2.5.0", ">=1.0.0,<2.0.0", RangeMergeIntersect, ">= 1.5.0, < 2.0.0", "merged: intersection of existing and target ranges"},
+		{"range: intersect keeps the inclusive upper bound", StrategyRange, ">=1.5.0,<=2.0.0", ">=1.0.0,<=2.0.0", RangeMergeIntersect, ">= 1.5.0, <= 2.0.0", "merged: intersection of existing and target ranges"},
+		{"range: intersect OR branches", StrategyRange, ">=1.5.0,<3.5.0", ">=1.0.0,<2.0.0 || >=3.0.0,<4.0.0", RangeMergeIntersect, ">= 1.5.0, < 2.0.0 || >= 3.0.0, < 3.5.0", "merged: intersection of existing and target ranges"},
+		{"range: intersect tilde arrows", StrategyRange, "~> 1.5", ">=1.0.0,<1.8.0", RangeMergeIntersect, ">= 1.5.0, < 1.8.0", "merged: intersection of existing and target ranges"},
+		{"dynamic: disjoint ranges are not intersected", StrategyDynamic, ">=3.0.0,<4.0.0", ">=1.0.0,<2.0.0", RangeMergeIntersect, ">= 3.0.0, < 4.0.0", "used target: ranges do not overlap"},
+		{"range: union overlapping ranges", StrategyRange, ">=1.5.0,<2.5.0", ">=1.0.0,<2.0.0", RangeMergeUnion, ">= 1.0.0, < 2.5.0", "merged: union of existing and target ranges"},
+		{"dynamic: union overlapping ranges", StrategyDynamic, ">=1.5.0,<2.5.0", ">=1.0.0,<2.0.0", RangeMergeUnion, ">= 1.0.0, < 2.5.0", "merged: union of existing and target ranges"},
+		{"range: union disjoint ranges", StrategyRange, ">=3.0.0,<4.0.0", ">=1.0.0,<2.0.0", RangeMergeUnion, ">= 1.0.0, < 2.0.0 || >= 3.0.0, < 4.0.0", "merged: union of existing and target ranges"},
+		{"dynamic: keep overlapping ranges", StrategyDynamic, ">=1.5.0,<2.5.0", ">=1.0.0,<2.0.0", RangeMergeKeep, ">= 1.0.0, < 2.0.0", "kept existing: ranges overlap"},
+		{"dynamic: exact existing version is not merged", StrategyDynamic, ">=1.5.0,<2.5.0", "1.6.0", RangeMergeUnion, "1.6.0", "kept existing: version satisfies target range"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, reason, err := ApplyVersionStrategyWithReason(tc.strategy, tc.target, tc.existing, StrategyOptions{RangeMerge: tc.merge})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want || reason != tc.wantReason {
+				t.Errorf("got %q (%s), want %q (%s)", got, reason, tc.want, tc.wantReason)
+			}
+		})
+	}
+}
+
+func TestApplyVersionStrategySpaceAndOrGroups(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		target   string
+		existing string
+		opts     StrategyOptions
+		want     string
+	}{
+		{"dynamic: version inside a group is kept", StrategyDynamic, ">=1.0.0 <2.0.0 || >=3.0.0 <4.0.0", "3.2.0", StrategyOptions{}, "3.2.0"},
+		{"dynamic: no existing version", StrategyDynamic, ">=1.0.0 <2.0.0 || >=3.0.0 <4.0.0", "", StrategyOptions{}, ">=1.0.0, <2.0.0 || >=3.0.0, <4.0.0"},
+		{"dynamic: pre-1.0 group pinned with its metadata", StrategyDynamic, ">=0.5.0+build.1 <0.9.0 || >=3.0.0 <4.0.0", "", StrategyOptions{}, "0.5.0+build.1"},
+		{"range: whole expression", StrategyRange, ">=1.0.0 <2.0.0 || >=3.0.0 <4.0.0", "3.2.0", StrategyOptions{}, ">= 1.0.0, < 2.0.0 || >= 3.0.0, < 4.0.0"},
+		{"range: collapse selects the group of the existing version", StrategyRange, ">=1.0.0 <2.0.0 || >=3.0.0 <4.0.0", "3.2.0", StrategyOptions{CollapseOr: true}, ">= 3.0.0, < 4.0.0"},
+		{"range: collapse selects the group of the existing range", StrategyRange, ">=1.0.0 <2.0.0 || >=3.0.0 <4.0.0", ">=3.1.0 <3.5.0", StrategyOptions{CollapseOr: true}, ">= 3.0.0, < 4.0.0"},
+		{"range: overlapping groups merge", StrategyRange, ">=1.0.0 <2.0.0 || >=1.5.0 <3.0.0", "", StrategyOptions{}, ">= 1.0.0, < 3.0.0"},
+		{"range: pre-1.0 group pinned with its metadata", StrategyRange, ">=0.5.0+build.1 <0.9.0 || >=3.0.0 <4.0.0", "", StrategyOptions{}, "0.5.0+build.1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ApplyVersionStrategyWithOptions(tc.strategy, tc.target, tc.existing, tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+
+			// Space-AND and comma-AND groups give the same result
+			commaTarget := strings.ReplaceAll(tc.target, " <", ", <")
+			commaExisting := strings.ReplaceAll(tc.existing, " <", ", <")
+			comma, err := ApplyVersionStrategyWithOptions(tc.strategy, commaTarget, commaExisting, tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error for comma-AND: %v", err)
+			}
+			if comma != got {
+				t.Errorf("comma-AND %q gives %q, space-AND gives %q", commaTarget, comma, got)
+			}
+		})
+	}
+
+	// The exported strategy functions split space-AND groups like ApplyVersionStrategy
+	target := ">=1.0.0 <2.0.0 || >=3.0.0 <4.0.0"
+	if got, err := ApplyDynamicStrategy(target, ""); err != nil || got != ">=1.0.0, <2.0.0 || >=3.0.0, <4.0.0" {
+		t.Errorf("ApplyDynamicStrategy = %q, %v", got, err)
+	}
+	if got, err := ApplyRangeStrategy(target, "3.2.0"); err != nil || got != ">= 1.0.0, < 2.0.0 || >= 3.0.0, < 4.0.0" {
+		t.Errorf("ApplyRangeStrategy = %q, %v", got, err)
+	}
+	if got, err := ConvertToRangeVersion(">=0.5.0+build.1 <0.9.0"); err != nil || got != "0.5.0+build.1" {
+		t.Errorf("ConvertToRangeVersion = %q, %v", got, err)
+	}
+}
+
+func TestApplyVersionStrategyTildeOrChain(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		target   string
+		existing string
+		want     string
+	}{
+		{"range: no existing", StrategyRange, "~>1.2.3 || ~>2.0.0", "", ">= 1.2.3, < 1.3.0 || >= 2.0.0, < 2.1.0"},
+		{"range: branch order preserved", StrategyRange, "~>2.0.0 || ~>1.2.3", "", ">= 2.0.0, < 2.1.0 || >= 1.2.3, < 1.3.0"},
+		{"range: existing version", StrategyRange, "~>1.2.3 || ~>2.0.0", "1.5.0", ">= 1.2.3, < 1.3.0 || >= 2.0.0, < 2.1.0"},
+		{"range: kept existing chain", StrategyRange, "1.5.0", "~>1.2 || ~>2.0.0", ">= 1.2.0, < 2.0.0 || >= 2.0.0, < 2.1.0"},
+		{"range: explicit adjacent branches still merge", StrategyRange, ">=1.0.0,<2.0.0 || >=2.0.0,<3.0.0", "", ">= 1.0.0, < 3.0.0"},
+		{"dynamic: kept existing chain", StrategyDynamic, "~>1.2.3 || ~>2.0.0", "~>1.2.3 || ~>2.0.0", ">= 1.2.3, < 1.3.0 || >= 2.0.0, < 2.1.0"},
+		{"dynamic: kept existing chain order", StrategyDynamic, "~> 1.2 || ~> 3.0", "~>2.0.0 || ~>1.2.3", ">= 2.0.0, < 2.1.0 || >= 1.2.3, < 1.3.0"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ApplyVersionStrategy(tc.strategy, tc.target, tc.existing)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyVersionStrategyKeepPre10Ranges(t *testing.T) {
+	keep := StrategyOptions{KeepPre10Ranges: true}
+	tests := []struct {
+		name     string
+		strategy Strategy
+		target   string
+		existing string
+		opts     StrategyOptions
+		want     string
+	}{
+		{"collapse: range pinned", StrategyRange, ">=0.2.0,<0.3.0", "0.1.5", StrategyOptions{}, "0.2.0"},
+		{"collapse: dynamic pinned", StrategyDynamic, ">=0.2.0,<0.3.0", ">=0.1.0,<0.2.0", StrategyOptions{}, "0.2.0"},
+		{"keep: range without existing", StrategyRange, ">=0.2.0,<0.3.0", "", keep, ">= 0.2.0, < 0.3.0"},
+		{"keep: range over lower version", StrategyRange, ">=0.2.0,<0.3.0", "0.1.5", keep, ">= 0.2.0, < 0.3.0"},
+		{"keep: range over lower range", StrategyRange, ">=0.2.0,<0.3.0", ">=0.1.0,<0.2.0", keep, ">= 0.2.0, < 0.3.0"},
+		{"keep: tilde arrow", StrategyRange, "~>0.2.0", "0.1.5", keep, ">= 0.2.0, < 0.3.0"},
+		{"keep: dynamic without existing", StrategyDynamic, ">=0.2.0,<0.3.0", "", keep, ">=0.2.0,<0.3.0"},
+		{"keep: dynamic over lower version", StrategyDynamic, ">=0.2.0,<0.3.0", "0.1.5", keep, ">= 0.2.0, < 0.3.0"},
+		{"keep: dynamic over lower range", StrategyDynamic, ">=0.2.0,<0.3.0", ">=0.1.0,<0.2.0", keep, ">= 0.2.0, < 0.3.0"},
+		{"keep: higher version still protected", StrategyDynamic, ">=0.2.0,<0.3.0", "0.4.0", keep, "0.4.0"},
+		{"keep: higher range still protected", StrategyDynamic, ">=0.2.0,<0.3.0", ">=0.4.0,<0.5.0", keep, ">= 0.4.0, < 0.5.0"},
+		{"keep: exact target unaffected", StrategyDynamic, "0.2.0", "0.1.5", keep, "0.2.0"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ApplyVersionStrategyWithOptions(tc.strategy, tc.target, tc.existing, tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyVersionStrategyPrereleaseRange(t *testing.T) {
+	sameMajor := StrategyOptions{PrereleaseRange: PrereleaseRangeSameMajor}
+	lockTarget := StrategyOptions{PrereleaseRange: PrereleaseRangeLockTarget}
+	tests := []struct {
+		name     string
+		strategy Strategy
+		target   string
+		existing string
+		opts     StrategyOptions
+		want     string
+	}{
+		{"default: next major", StrategyRange, "2.0.0-beta.1", "", StrategyOptions{}, ">= 2.0.0-beta.1, < 3.0.0"},
+		{"next_major: explicit", StrategyRange, "2.0.0-beta.1", "1.5.0", StrategyOptions{PrereleaseRange: PrereleaseRangeNextMajor}, ">= 2.0.0-beta.1, < 3.0.0"},
+		{"same_major: without existing", StrategyRange, "2.0.0-beta.1", "", sameMajor, ">= 2.0.0-beta.1, < 2.0.0"},
+		{"same_major: over lower version", StrategyRange, "2.0.0-beta.1", "1.5.0", sameMajor, ">= 2.0.0-beta.1, < 2.0.0"},
+		{"same_major: minor pre-release", StrategyRange, "2.1.0-rc.1", "2.0.0", sameMajor, ">= 2.1.0-rc.1, < 2.1.0"},
+		{"same_major: stable target unaffected", StrategyRange, "2.0.0", "", sameMajor, ">= 2.0.0, < 3.0.0"},
+		{"same_major: higher existing kept", StrategyRange, "2.0.0-beta.1", ">=3.0.0,<4.0.0", sameMajor, ">= 3.0.0, < 4.0.0"},
+		{"lock_target: without existing", StrategyRange, "2.0.0-beta.1", "", lockTarget, "2.0.0-beta.1"},
+		{"lock_target: over lower version", StrategyRange, "2.0.0-beta.1", "1.5.0", lockTarget, "2.0.0-beta.1"},
+		{"lock_target: stable target unaffected", StrategyRange, "2.0.0", "1.5.0", lockTarget, ">= 2.0.0, < 3.0.0"},
+		{"lock_target: exact strategy unaffected", StrategyExact, "2.0.0-beta.1", "1.5.0", lockTarget, "2.0.0-beta.1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ApplyVersionStrategyWithOptions(tc.strategy, tc.target, tc.existing, tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyVersionStrategyOutputFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		target   string
+		existing string
+		opts     StrategyOptions
+		want     string
+	}{
+		{"spaced: default", StrategyRange, ">=1.0.0,<2.0.0", "", StrategyOptions{}, ">= 1.0.0, < 2.0.0"},
+		{"compact: range", StrategyRange, ">= 1.0.0, < 2.0.0", "", StrategyOptions{CompactOutput: true}, ">=1.0.0,<2.0.0"},
+		{"compact: tilde arrow", StrategyRange, "~> 1.2", "", StrategyOptions{CompactOutput: true}, ">=1.2.0,<2.0.0"},
+		{"compact: OR separators keep spaces", StrategyRange, "~>1.2.3 || ~>2.0.0", "", StrategyOptions{CompactOutput: true}, ">=1.2.3,<1.3.0 || >=2.0.0,<2.1.0"},
+		{"compact: exact unaffected", StrategyExact, "2.0.0", "1.0.0", StrategyOptions{CompactOutput: true}, "2.0.0"},
+		{"compact: equality operator kept", StrategyExact, "1.2.3", "= 1.2.3", StrategyOptions{CompactOutput: true}, "= 1.2.3"},
+		{"compact: preserve_style follows existing", StrategyRange, ">=2.0.0,<3.0.0", ">= 1.0.0, < 2.0.0", StrategyOptions{CompactOutput: true, PreserveStyle: true}, ">= 2.0.0, < 3.0.0"},
 	}
 
 	for _, tc := range tests {
-		got := ExpandTerraformTildeArrow(tc.input)
-		if got != tc.expected {
-			t.Errorf("ExpandTerraformTildeArrow(%q) = %q, want %q", tc.input, got, tc.expected)
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ApplyVersionStrategyWithOptions(tc.strategy, tc.target, tc.existing, tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	// Switching profiles on an up-to-date value is not a change
+	for _, existing := range []string{">= 1.0.0, < 2.0.0", ">=1.0.0,<2.0.0"} {
+		for _, compact := range []bool{false, true} {
+			got, err := ApplyVersionStrategyWithOptions(StrategyRange, ">=1.0.0,<2.0.0", existing, StrategyOptions{CompactOutput: compact})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if NormalizeVersionString(got) != NormalizeVersionString(existing) {
+				t.Errorf("compact=%v over %q: got %q, which does not compare equal", compact, existing, got)
+			}
 		}
 	}
 }
 
-func TestBuildRangeFromTildePart(t *testing.T) {
+func TestApplyVersionStrategySortOrBranches(t *testing.T) {
 	tests := []struct {
-		input    string
-		expected string
+		name     string
+		strategy Strategy
+		target   string
+		existing string
+		sort     bool
+		want     string
 	}{
-		{"1.2.3", ">=1.2.3, <2.0.0"},
-		{"2.0", ">=2.0.0, <3.0.0"},
-		{"3", ">=3.0.0, <4.0.0"},
-		{"", "~>MISSING"},
-		{"1.2.3.4", "~>INVALID"},
-		{" 1.2.3 ", ">=1.2.3, <2.0.0"}, // test trimming
+		{"range: shuffled target", StrategyRange, ">=3,<4 || >=1,<2", "", true, ">= 1.0.0, < 2.0.0 || >= 3.0.0, < 4.0.0"},
+		{"range: three shuffled branches", StrategyRange, ">=5.0.0,<6.0.0 || >=1.0.0,<2.0.0 || >=3.0.0,<4.0.0", "", true, ">= 1.0.0, < 2.0.0 || >= 3.0.0, < 4.0.0 || >= 5.0.0, < 6.0.0"},
+		{"range: tilde chain", StrategyRange, "~>2.0.0 || ~>1.2.3", "", true, ">= 1.2.3, < 1.3.0 || >= 2.0.0, < 2.1.0"},
+		{"range: without option", StrategyRange, "~>2.0.0 || ~>1.2.3", "", false, ">= 2.0.0, < 2.1.0 || >= 1.2.3, < 1.3.0"},
+		{"dynamic: kept existing chain", StrategyDynamic, "1.5.0", ">= 3.0.0, < 4.0.0 || >= 1.0.0, < 2.0.0", true, ">= 1.0.0, < 2.0.0 || >= 3.0.0, < 4.0.0"},
+		{"exact: unaffected", StrategyExact, "2.0.0", "1.0.0", true, "2.0.0"},
 	}
 
 	for _, tc := range tests {
-		got := buildRangeFromTildePart(tc.input)
-		if got != tc.expected {
-			t.Errorf("buildRangeFromTildePart(%q) = %q, want %q", tc.input, got, tc.expected)
-		}
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ApplyVersionStrategyWithOptions(tc.strategy, tc.target, tc.existing, StrategyOptions{SortOrBranches: tc.sort})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
 	}
 }
 
-func TestReadToken(t *testing.T) {
+func TestFindHighestVersionInRange(t *testing.T) {
 	tests := []struct {
-		input          string
-		expectedToken  string
-		expectedRemain string
+		input    string
+		expected string
 	}{
-		{"1.2.3", "1.2.3", ""},
-		{"1.2.3 || 2.0.0", "1.2.3", " || 2.0.0"},
-		{"1.2.3,2.0.0", "1.2.3", ",2.0.0"},
-		{"1.2.3 2.0.0", "1.2.3", " 2.0.0"},
-		{"", "", ""},
-		{" 1.2.3", "", " 1.2.3"},
-		{"1.2.3||2.0.0", "1.2.3", "||2.0.0"},
+		{">=1.0.0, <=1.5.0", "1.5.0"},
+		{">=1.5.0, <1.5.2", "1.5.1"},
+		// Excluded bounds step down to the previous included version
+		{">=1.0.0, <=1.5.0, !=1.5.0", "1.4.50"},
+		{">=1.0.0, <1.5.0, !=1.4.50", "1.4.49"},
+		{">=1.5.0, <1.5.2, !=1.5.1", "1.5.0"},
+		{">=1.0.0, <=2.0.0, !=2.0.0 || >=1.0.0, <=1.2.0", "1.50.50"},
 	}
 
 	for _, tc := range tests {
-		gotToken, gotRemain := readToken(tc.input)
-		if gotToken != tc.expectedToken || gotRemain != tc.expectedRemain {
-			t.Errorf("readToken(%q) = (%q, %q), want (%q, %q)",
-				tc.input, gotToken, gotRemain, tc.expectedToken, tc.expectedRemain)
+		c, err := semver.NewConstraint(tc.input)
+		if err != nil {
+			t.Fatalf("invalid test constraint %q: %v", tc.input, err)
+		}
+		got := findHighestVersionInRange(c)
+		if got == nil {
+			t.Errorf("findHighestVersionInRange(%q) = nil, want %q", tc.input, tc.expected)
+			continue
+		}
+		if got.String() != tc.expected {
+			t.Errorf("findHighestVersionInRange(%q) = %q, want %q", tc.input, got.String(), tc.expected)
 		}
 	}
 }
@@ -558,7 +1649,7 @@ func TestVersionStrategies(t *testing.T) {
 		{
 			name:            "dynamic: multiple tilde arrow combinations",
 			strategy:        StrategyDynamic,
-			targetVersion:   "~>2.0.0 || ~>3.0",
+			targetVersion:   "~>2.0 || ~>3.0",
 			existingVersion: "~>1.0 || ~>2.1",
 			want:            ">= 1.0.0, < 2.0.0 || >= 2.1.0, < 3.0.0", // expanded format of tilde arrow
 		},
@@ -962,3 +2053,367 @@ func TestVersionStrategies(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyRangeStrategyCollapseOr(t *testing.T) {
+	target := ">=1.0.0,<2.0.0 || >=3.0.0,<4.0.0"
+
+	tests := []struct {
+		name            string
+		existingVersion string
+		opts            StrategyOptions
+		want            string
+	}{
+		{
+			name:            "second branch selected",
+			existingVersion: "3.2.0",
+			opts:            StrategyOptions{CollapseOr: true},
+			want:            ">= 3.0.0, < 4.0.0",
+		},
+		{
+			name:            "first branch selected",
+			existingVersion: "1.5.0",
+			opts:            StrategyOptions{CollapseOr: true},
+			want:            ">= 1.0.0, < 2.0.0",
+		},
+		{
+			name:            "existing range selects its branch",
+			existingVersion: ">= 3.1.0, < 3.5.0",
+			opts:            StrategyOptions{CollapseOr: true},
+			want:            ">= 3.0.0, < 4.0.0",
+		},
+		{
+			name:            "no branch contains existing",
+			existingVersion: "2.5.0",
+			opts:            StrategyOptions{CollapseOr: true},
+			want:            ">= 1.0.0, < 2.0.0 || >= 3.0.0, < 4.0.0",
+		},
+		{
+			name:            "option disabled keeps whole expression",
+			existingVersion: "3.2.0",
+			opts:            StrategyOptions{},
+			want:            ">= 1.0.0, < 2.0.0 || >= 3.0.0, < 4.0.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ApplyVersionStrategyWithOptions(StrategyRange, target, tc.existingVersion, tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyVersionStrategyMinVersion(t *testing.T) {
+	tests := []struct {
+		name            string
+		strategy        Strategy
+		targetVersion   string
+		existingVersion string
+		minVersion      string
+		want            string
+		wantErr         bool
+	}{
+		{
+			name:            "exact target and existing below floor",
+			strategy:        StrategyExact,
+			targetVersion:   "1.2.0",
+			existingVersion: "1.1.0",
+			minVersion:      "1.5.0",
+			want:            "1.5.0",
+		},
+		{
+			name:            "exact result above floor is kept",
+			strategy:        StrategyExact,
+			targetVersion:   "2.0.0",
+			existingVersion: "1.1.0",
+			minVersion:      "1.5.0",
+			want:            "2.0.0",
+		},
+		{
+			name:            "dynamic exact result below floor",
+			strategy:        StrategyDynamic,
+			targetVersion:   "1.2.0",
+			existingVersion: "1.0.0",
+			minVersion:      "1.3.0",
+			want:            "1.3.0",
+		},
+		{
+			name:            "range lower bound raised to floor",
+			strategy:        StrategyRange,
+			targetVersion:   ">=1.0.0,<2.0.0",
+			existingVersion: "1.1.0",
+			minVersion:      "1.5.0",
+			want:            ">= 1.5.0, < 2.0.0",
+		},
+		{
+			name:            "range entirely below floor",
+			strategy:        StrategyRange,
+			targetVersion:   ">=1.0.0,<2.0.0",
+			existingVersion: ">=1.0.0,<2.0.0",
+			minVersion:      "3.0.0",
+			want:            ">= 3.0.0, < 4.0.0",
+		},
+		{
+			name:            "or branches below floor dropped",
+			strategy:        StrategyRange,
+			targetVersion:   ">=1.0.0,<2.0.0 || >=3.0.0,<4.0.0",
+			existingVersion: "",
+			minVersion:      "2.5.0",
+			want:            ">= 3.0.0, < 4.0.0",
+		},
+		{
+			name:            "range above floor unchanged",
+			strategy:        StrategyRange,
+			targetVersion:   ">=2.0.0,<3.0.0",
+			existingVersion: "",
+			minVersion:      "1.0.0",
+			want:            ">= 2.0.0, < 3.0.0",
+		},
+		{
+			name:            "pre-1.0 floor wins over lower pre-1.0 versions",
+			strategy:        StrategyExact,
+			targetVersion:   "0.2.0",
+			existingVersion: "0.1.0",
+			minVersion:      "0.3.0-beta.1",
+			want:            "0.3.0-beta.1",
+		},
+		{
+			name:            "invalid floor",
+			strategy:        StrategyExact,
+			targetVersion:   "1.0.0",
+			existingVersion: "",
+			minVersion:      ">=1.0.0",
+			wantErr:         true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ApplyVersionStrategyWithOptions(tc.strategy, tc.targetVersion, tc.existingVersion, StrategyOptions{MinVersion: tc.minVersion})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyVersionStrategyMaxVersion(t *testing.T) {
+	tests := []struct {
+		name            string
+		strategy        Strategy
+		targetVersion   string
+		existingVersion string
+		opts            StrategyOptions
+		want            string
+		wantErr         bool
+	}{
+		{
+			name:          "exact target above cap is clamped",
+			strategy:      StrategyExact,
+			targetVersion: "3.0.0",
+			opts:          StrategyOptions{MaxVersion: "2.9.0"},
+			want:          "2.9.0",
+		},
+		{
+			name:          "exact target above cap is rejected",
+			strategy:      StrategyExact,
+			targetVersion: "3.0.0",
+			opts:          StrategyOptions{MaxVersion: "2.9.0", ErrorAboveMaxVersion: true},
+			wantErr:       true,
+		},
+		{
+			name:          "exact target at cap is kept",
+			strategy:      StrategyExact,
+			targetVersion: "2.9.0",
+			opts:          StrategyOptions{MaxVersion: "2.9.0", ErrorAboveMaxVersion: true},
+			want:          "2.9.0",
+		},
+		{
+			name:          "range extending past cap",
+			strategy:      StrategyRange,
+			targetVersion: ">=2.0.0,<4.0.0",
+			opts:          StrategyOptions{MaxVersion: "3.0.0"},
+			want:          ">= 2.0.0, <= 3.0.0",
+		},
+		{
+			name:          "open range is capped",
+			strategy:      StrategyRange,
+			targetVersion: ">=2.0.0",
+			opts:          StrategyOptions{MaxVersion: "2.5.0"},
+			want:          ">= 2.0.0, <= 2.5.0",
+		},
+		{
+			name:          "range within cap unchanged",
+			strategy:      StrategyRange,
+			targetVersion: ">=2.0.0,<3.0.0",
+			opts:          StrategyOptions{MaxVersion: "3.0.0"},
+			want:          ">= 2.0.0, < 3.0.0",
+		},
+		{
+			name:          "or branches above cap dropped",
+			strategy:      StrategyRange,
+			targetVersion: ">=1.0.0,<2.0.0 || >=3.0.0,<4.0.0",
+			opts:          StrategyOptions{MaxVersion: "2.5.0"},
+			want:          ">= 1.0.0, < 2.0.0",
+		},
+		{
+			name:          "floor and cap together",
+			strategy:      StrategyRange,
+			targetVersion: ">=1.0.0,<4.0.0",
+			opts:          StrategyOptions{MinVersion: "1.5.0", MaxVersion: "3.0.0"},
+			want:          ">= 1.5.0, <= 3.0.0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ApplyVersionStrategyWithOptions(tc.strategy, tc.targetVersion, tc.existingVersion, tc.opts)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyVersionStrategyPreserveStyle(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		target   string
+		existing string
+		preserve bool
+		expected string
+	}{
+		{name: "compact style preserved", strategy: StrategyRange, target: "2.0.0", existing: ">=1,<2", preserve: true, expected: ">=2.0.0,<3.0.0"},
+		{name: "compact style without option", strategy: StrategyRange, target: "2.0.0", existing: ">=1,<2", expected: ">= 2.0.0, < 3.0.0"},
+		{name: "operator spacing without comma spacing", strategy: StrategyRange, target: "2.0.0", existing: ">= 1.0.0,< 2.0.0", preserve: true, expected: ">= 2.0.0,< 3.0.0"},
+		{name: "compact or", strategy: StrategyRange, target: ">=3.0.0,<4.0.0 || >=5.0.0,<6.0.0", existing: ">=1,<2||>=2.5,<2.8", preserve: true, expected: ">=3.0.0,<4.0.0||>=5.0.0,<6.0.0"},
+		{name: "space-joined comparisons", strategy: StrategyRange, target: "2.0.0", existing: ">= 1.0.0 < 2.0.0", preserve: true, expected: ">= 2.0.0 < 3.0.0"},
+		{name: "exact existing keeps default style", strategy: StrategyRange, target: ">=2.0.0,<3.0.0", existing: "1.0.0", preserve: true, expected: ">= 2.0.0, < 3.0.0"},
+		{name: "exact result unaffected", strategy: StrategyExact, target: "2.0.0", existing: "1.0.0", preserve: true, expected: "2.0.0"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ApplyVersionStrategyWithOptions(tc.strategy, tc.target, tc.existing, StrategyOptions{PreserveStyle: tc.preserve})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("got %q, want %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestApplyVersionStrategyPatchOnly(t *testing.T) {
+	tests := []struct {
+		name            string
+		targetVersion   string
+		existingVersion string
+		opts            StrategyOptions
+		want            string
+		wantReason      string
+		wantErr         bool
+	}{
+		{name: "patch bump accepted", targetVersion: "1.2.5", existingVersion: "1.2.3", want: "1.2.5", wantReason: "used target: patch update"},
+		{name: "minor bump rejected", targetVersion: "1.3.0", existingVersion: "1.2.3", want: "1.2.3", wantReason: "kept existing: minor bump blocked (patch_only)"},
+		{name: "major bump rejected", targetVersion: "2.0.0", existingVersion: "1.2.3", want: "1.2.3", wantReason: "kept existing: major bump blocked (patch_only)"},
+		{name: "minor bump errors", targetVersion: "1.3.0", existingVersion: "1.2.3", opts: StrategyOptions{ErrorOnBlockedBump: true}, wantErr: true},
+		{name: "patch bump with error option", targetVersion: "1.2.4", existingVersion: "1.2.3", opts: StrategyOptions{ErrorOnBlockedBump: true}, want: "1.2.4", wantReason: "used target: patch update"},
+		{name: "pre-1.0 patch bump accepted", targetVersion: "0.3.2", existingVersion: "0.3.1", want: "0.3.2", wantReason: "used target: patch update"},
+		{name: "pre-1.0 minor bump is breaking", targetVersion: "0.4.0", existingVersion: "0.3.1", want: "0.3.1", wantReason: "kept existing: minor bump blocked (patch_only)"},
+		{name: "lower target keeps existing", targetVersion: "1.2.1", existingVersion: "1.2.3", want: "1.2.3", wantReason: "kept existing: higher version (backward protection)"},
+		{name: "no existing version", targetVersion: "1.3.0", want: "1.3.0", wantReason: "used target: no existing version"},
+		{name: "existing range kept", targetVersion: "1.2.5", existingVersion: ">= 1.2.0, < 1.3.0", want: ">= 1.2.0, < 1.3.0", wantReason: "kept existing: not an exact version (patch_only)"},
+		{name: "range target rejected", targetVersion: ">= 1.2.0", existingVersion: "1.2.3", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, reason, err := ApplyVersionStrategyWithReason(StrategyPatchOnly, tc.targetVersion, tc.existingVersion, tc.opts)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want || reason != tc.wantReason {
+				t.Errorf("got %q (%s), want %q (%s)", got, reason, tc.want, tc.wantReason)
+			}
+		})
+	}
+}
+
+func TestApplyVersionStrategyMajorLock(t *testing.T) {
+	tests := []struct {
+		name            string
+		targetVersion   string
+		existingVersion string
+		opts            StrategyOptions
+		want            string
+		wantReason      string
+		wantErr         bool
+	}{
+		{name: "minor bump within major", targetVersion: "1.5.0", existingVersion: "1.2.3", want: "1.5.0"},
+		{name: "major bump blocked", targetVersion: "2.0.0", existingVersion: "1.2.3", want: "1.2.3", wantReason: "kept existing: major bump blocked (major_lock)"},
+		{name: "major bump errors", targetVersion: "2.0.0", existingVersion: "1.2.3", opts: StrategyOptions{ErrorOnBlockedBump: true}, wantErr: true},
+		{name: "lower target keeps existing", targetVersion: "1.1.0", existingVersion: "1.2.3", want: "1.2.3", wantReason: "kept existing: higher version (backward protection)"},
+		{name: "lower major keeps existing", targetVersion: "0.9.0", existingVersion: "1.2.3", want: "1.2.3"},
+		{name: "range existing within major", targetVersion: "1.8.0", existingVersion: ">= 1.2.0, < 2.0.0", want: ">= 1.2.0, < 2.0.0"},
+		{name: "range existing crossing major", targetVersion: "2.1.0", existingVersion: ">= 1.2.0, < 2.0.0", want: ">= 1.2.0, < 2.0.0", wantReason: "kept existing: major bump blocked (major_lock)"},
+		{name: "range target capped below next major", targetVersion: ">= 1.5.0, < 3.0.0", existingVersion: ">= 1.0.0, < 1.5.0", want: ">= 1.5.0, < 2.0.0"},
+		{name: "open range target capped", targetVersion: ">= 1.5.0", existingVersion: "1.2.0", want: ">= 1.5.0, < 2.0.0"},
+		{name: "range target starting in next major blocked", targetVersion: ">= 2.0.0, < 3.0.0", existingVersion: "1.2.0", want: "1.2.0", wantReason: "kept existing: major bump blocked (major_lock)"},
+		{name: "pre-1.0 minor counts as major", targetVersion: "0.4.0", existingVersion: "0.3.1", want: "0.3.1", wantReason: "kept existing: major bump blocked (major_lock)"},
+		{name: "pre-1.0 patch bump", targetVersion: "0.3.5", existingVersion: "0.3.1", want: "0.3.5"},
+		{name: "no existing version", targetVersion: "3.0.0", want: "3.0.0"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, reason, err := ApplyVersionStrategyWithReason(StrategyMajorLock, tc.targetVersion, tc.existingVersion, tc.opts)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q (%s), want %q", got, reason, tc.want)
+			}
+			if tc.wantReason != "" && reason != tc.wantReason {
+				t.Errorf("got reason %q, want %q", reason, tc.wantReason)
+			}
+		})
+	}
+}